@@ -0,0 +1,76 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// how the bell from server is delivered to local terminal
+const (
+	bellAudible = iota // write BEL to local terminal
+	bellVisual         // flash the screen
+	bellNotify         // desktop notification
+	bellNone           // ignore the bell
+)
+
+var bellModes = []string{"audible", "visual", "notify", "none"}
+
+const (
+	bellInterval = 500 * time.Millisecond // at most one bell in the interval
+	bellFlash    = 100 * time.Millisecond // how long the screen is flashed
+)
+
+// bell limits the bell rate and flashes the screen for visual bell.
+type bell struct {
+	last     time.Time // the last delivered bell
+	flashEnd time.Time // the flash stops at, zero means not flashing
+	mode     int
+}
+
+// return true if the bell should be delivered. the bell storm is limited to
+// one bell per bellInterval.
+func (b *bell) allow(now time.Time) bool {
+	if b.mode == bellNone {
+		return false
+	}
+	if !b.last.IsZero() && now.Sub(b.last) < bellInterval {
+		return false
+	}
+	b.last = now
+	return true
+}
+
+// start the visual bell, reverse is the current reverse video mode of screen.
+// return the sequence to flash the screen.
+func (b *bell) flash(now time.Time, reverse bool) string {
+	b.flashEnd = now.Add(bellFlash)
+	if reverse {
+		return "\x1B[?5l"
+	}
+	return "\x1B[?5h"
+}
+
+// stop the visual bell if it's time, return the sequence to restore the
+// reverse video mode of screen.
+func (b *bell) restore(now time.Time, reverse bool) string {
+	if b.flashEnd.IsZero() || now.Before(b.flashEnd) {
+		return ""
+	}
+	b.flashEnd = time.Time{}
+	if reverse {
+		return "\x1B[?5h"
+	}
+	return "\x1B[?5l"
+}
+
+// return the milliseconds until the visual bell stops.
+func (b *bell) waitTime(now time.Time) int {
+	if b.flashEnd.IsZero() {
+		return math.MaxInt
+	}
+	return max(0, int(b.flashEnd.Sub(now).Milliseconds()))
+}
@@ -0,0 +1,70 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBellAllow(t *testing.T) {
+	start := time.Now()
+	tc := []struct {
+		label  string
+		mode   int
+		after  time.Duration
+		expect bool
+	}{
+		{"first bell", bellAudible, 0, true},
+		{"bell storm", bellAudible, 100 * time.Millisecond, false},
+		{"still in interval", bellAudible, 400 * time.Millisecond, false},
+		{"after interval", bellAudible, 600 * time.Millisecond, true},
+		{"none mode", bellNone, 2 * time.Second, false},
+	}
+
+	var b bell
+	for _, v := range tc {
+		b.mode = v.mode
+		if got := b.allow(start.Add(v.after)); got != v.expect {
+			t.Errorf("%s expect %t, got %t\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestBellFlash(t *testing.T) {
+	tc := []struct {
+		label   string
+		reverse bool
+		flash   string
+		restore string
+	}{
+		{"normal screen", false, "\x1B[?5h", "\x1B[?5l"},
+		{"reverse screen", true, "\x1B[?5l", "\x1B[?5h"},
+	}
+
+	now := time.Now()
+	for _, v := range tc {
+		b := bell{mode: bellVisual}
+		if got := b.waitTime(now); got != math.MaxInt {
+			t.Errorf("%s expect no wait time before flash, got %d\n", v.label, got)
+		}
+		if got := b.flash(now, v.reverse); got != v.flash {
+			t.Errorf("%s expect flash %q, got %q\n", v.label, v.flash, got)
+		}
+		if got := b.waitTime(now); got != int(bellFlash.Milliseconds()) {
+			t.Errorf("%s expect wait time %d, got %d\n", v.label, bellFlash.Milliseconds(), got)
+		}
+		if got := b.restore(now, v.reverse); got != "" {
+			t.Errorf("%s expect no restore during flash, got %q\n", v.label, got)
+		}
+		if got := b.restore(now.Add(bellFlash), v.reverse); got != v.restore {
+			t.Errorf("%s expect restore %q, got %q\n", v.label, v.restore, got)
+		}
+		if got := b.restore(now.Add(2*bellFlash), v.reverse); got != "" {
+			t.Errorf("%s expect restore only once, got %q\n", v.label, got)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/ericwq/aprilsh/util"
+	"golang.org/x/text/encoding"
+)
+
+// charsetOutput converts the output of client from UTF-8 to the legacy charset
+// of local terminal. os.Stdout is replaced by a pipe, the pipe reader is copied
+// to the real stdout through the charset writer.
+type charsetOutput struct {
+	stdout *os.File      // the real stdout
+	done   chan struct{} // closed when the copy is finished
+}
+
+func openCharsetOutput(enc encoding.Encoding) (*charsetOutput, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	co := &charsetOutput{stdout: os.Stdout, done: make(chan struct{})}
+	go func() {
+		io.Copy(util.NewCharsetWriter(co.stdout, enc), r)
+		r.Close()
+		close(co.done)
+	}()
+
+	os.Stdout = w
+	return co, nil
+}
+
+// flush the pending output and restore os.Stdout.
+func (co *charsetOutput) close() {
+	if co == nil {
+		return
+	}
+	os.Stdout.Close()
+	<-co.done
+	os.Stdout = co.stdout
+}
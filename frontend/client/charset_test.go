@@ -0,0 +1,45 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/ericwq/aprilsh/util"
+)
+
+func TestCharsetOutput(t *testing.T) {
+	enc, _ := util.LookupCharset("GB18030")
+
+	r, w, _ := os.Pipe()
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+
+	co, err := openCharsetOutput(enc)
+	if err != nil {
+		t.Fatalf("open charset output: %s\n", err)
+	}
+	if os.Stdout == w {
+		t.Errorf("os.Stdout expect replaced by pipe\n")
+	}
+	os.Stdout.WriteString("\x1B[1m中文\x1B[0m")
+	co.close()
+
+	if os.Stdout != w {
+		t.Errorf("os.Stdout expect restored\n")
+	}
+	w.Close()
+	got, _ := io.ReadAll(r)
+	if expect := "\x1B[1m\xd6\xd0\xce\xc4\x1B[0m"; string(got) != expect {
+		t.Errorf("output expect %q, got %q\n", expect, got)
+	}
+
+	// nil output is UTF-8, close does nothing
+	var none *charsetOutput
+	none.close()
+}
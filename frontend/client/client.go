@@ -16,9 +16,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -38,18 +39,27 @@ import (
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/unix"
 	"golang.org/x/term"
+	"golang.org/x/text/encoding"
 )
 
 const (
 	// _APRILSH_KEY          = "APRISH_KEY"
 	_PREDICTION_DISPLAY   = "APRISH_PREDICTION_DISPLAY"
 	_PREDICTION_OVERWRITE = "APRISH_PREDICTION_OVERWRITE"
+
+	// exit code of apsh if remote shell/command exit status is not available.
+	exitInitFailure    = 1   // the client failed to initialize
+	exitUsage          = 2   // invalid command line option or configuration
+	exitServerRefused  = 253 // apshd refused the request or is not installed
+	exitAuthFailure    = 254 // ssh authentication or host key check failed
+	exitNetworkTimeout = 255 // can't reach the server or lost connection
 )
 
 var (
 	usage = `Usage:
   ` + frontend.CommandClientName + ` [--version] [--help] [--colors]
-  ` + frontend.CommandClientName + ` [-v[v]] [--port PORT] [-i identity_file] destination
+  ` + frontend.CommandClientName + ` [-v[v]] [--port PORT] [-i identity_file] [-A] [--scrollback N] [--cwd DIR] [--bell MODE] [--clipboard MODE] [--theme FILE] [--width POLICY] [--terminfo] [--send-env PATTERN] [--set-env NAME=VALUE]
+       [-L [bind_address:]port:host:hostport] [-R [bind_address:]port:host:hostport] destination [-- command...]
 Options:
 ---------------------------------------------------------------------------------------------------
   -h,  --help        print this message
@@ -63,11 +73,31 @@ Options:
   -v,  --verbose     verbose log output (debug level, default info level)
   -vv                verbose log output (trace level)
   -m,  --mapping     container port mapping (default 0, new port = returned port + mapping)
+  -A                 forward ssh agent connection to server (same as --forward-agent)
+  -L                 forward local port to host:hostport on the server side (you can have multiple -L options)
+  -R                 forward remote port to host:hostport on the client side (you can have multiple -R options)
+       --scrollback  number of scrollback history rows kept by server (default server setting)
+       --cwd         start the shell in this server directory (absolute path, default HOME)
+       --bell        bell mode: audible, visual, notify or none (default audible)
+       --bell-urgent set the urgency hint of window with the audible bell (xterm)
+       --clipboard   clipboard write (OSC 52) mode: allow, deny or ask (default allow)
+       --clipboard-max   max bytes of clipboard write (default 1048576)
+       --clipboard-read  let server read the local clipboard (OSC 52 query)
+       --theme       theme file which remaps the 16 ANSI colors (lines of "color0 #rrggbb" to "color15 #rrggbb")
+       --width       grapheme width policy, such as "ambiguous=2,unicode=9,vs16=0" (default probe local terminal)
+       --terminfo    install aprilsh terminfo entry in server ~/.terminfo and use it as TERM of shell
+       --send-env    send local environment variables matching the pattern (such as LC_*)
+       --set-env     send environment variable NAME=VALUE (you can have multiple --set-env options)
+       -- command    run command instead of login shell (note the space before command)
 ---------------------------------------------------------------------------------------------------
+Exit status is the exit status of remote shell/command, or 128+N if it's killed by signal N.
+Otherwise 1 means client initialization failed, 2 means invalid option or configuration,
+253 means server refused, 254 means authentication failed, 255 means network timeout.
 `
 	predictionValues = []string{"always", "never", "adaptive", "experimental"}
 	// defaultSSHClientID = filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa")
 	signals frontend.Signals
+	osExit  = os.Exit // replaced by test
 )
 
 func printVersion() {
@@ -111,28 +141,34 @@ type tResp struct {
 type tCap struct {
 	label string
 	query string
+	reply *regexp.Regexp // the reply of query
 	resp  tResp
 }
 
 // query terminal capablility
 // for remote session, query need more time to finish
+//
+// the queries are sent in one batch, the terminal may not answer some of
+// them. the replies are matched with the queries, the primary DA is the last
+// one and it's always answered, its reply ends the reading.
 func queryTerminal(stdout *os.File, timeout int) (caps []tCap, err error) {
 	caps = []tCap{
-		{label: "Primary DA", query: "\x1B[c"},
-		{label: "Device Status Report", query: "\x1b[5n"},
-		{label: "XTGETTCAP RGB", query: "\x1bP+q524742\x1b\\"},
-		{label: "XTGETTCAP TN", query: "\x1bP+q544e\x1b\\"},
-		{label: "XTGETTCAP Co", query: "\x1bP+q436f\x1b\\"},
-		{label: "Synchronized output", query: "\x1b[?2026$p"},
+		{label: "Device Status Report", query: "\x1b[5n", reply: regexp.MustCompile(`^\x1B\[[0-9]*n$`)},
+		{label: "XTGETTCAP RGB", query: "\x1bP+q524742\x1b\\", reply: regexp.MustCompile(`^\x1BP[01]\+r(?i:524742)`)},
+		{label: "XTGETTCAP TN", query: "\x1bP+q544e\x1b\\", reply: regexp.MustCompile(`^\x1BP[01]\+r(?i:544e)`)},
+		{label: "XTGETTCAP Co", query: "\x1bP+q436f\x1b\\", reply: regexp.MustCompile(`^\x1BP[01]\+r(?i:436f)`)},
 		// DECRQM 2026: Synchronized output
-		{label: "CSI u", query: "\x1B[?u"},
-		{label: "Secondary DA", query: "\x1B[>c"},
+		{label: "Synchronized output", query: "\x1b[?2026$p", reply: regexp.MustCompile(`^\x1B\[\?2026;[0-9]*\$y$`)},
+		{label: "CSI u", query: "\x1B[?u", reply: regexp.MustCompile(`^\x1B\[\?[0-9]*u$`)},
+		{label: "Cell size", query: "\x1B[16t", reply: regexp.MustCompile(`^\x1B\[6;[0-9]+;[0-9]+t$`)},
+		{label: "Kitty graphics", query: "\x1B_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1B\\", reply: regexp.MustCompile(`^\x1B_Gi=31;`)},
+		{label: "Palette", query: paletteQuery, reply: paletteReply},
+		widthProbes[0], widthProbes[1], widthProbes[2],
+		{label: "Secondary DA", query: "\x1B[>c", reply: regexp.MustCompile(`^\x1B\[>[0-9;]*c$`)},
+		{label: "Primary DA", query: "\x1B[c", reply: regexp.MustCompile(`^\x1B\[\?[0-9;]*c$`)},
 		// the last one should always get response from terminal, it will stop the read goroutine
 	}
-
-	respChan := make(chan tResp, 1)
-	var buf [1024]byte
-	var reading int64 = 0
+	last := caps[len(caps)-1].reply
 
 	// set terminal in raw mode , don't print to output.
 	save1, err := term.MakeRaw(int(stdout.Fd()))
@@ -140,39 +176,63 @@ func queryTerminal(stdout *os.File, timeout int) (caps []tCap, err error) {
 		return caps, fmt.Errorf("set raw mode for %s error: %w", stdout.Name(), err)
 	}
 
+	var b strings.Builder
 	for i := range caps {
-		// send query
-		_, err := stdout.WriteString(caps[i].query)
-		if err != nil {
+		b.WriteString(caps[i].query)
+	}
+
+	// send query
+	begin := time.Now()
+	if _, err := stdout.WriteString(b.String()); err != nil {
+		for i := range caps {
 			caps[i].resp.error = fmt.Errorf("write to %s error: %w", stdout.Name(), err)
-			continue
 		}
-
-		begin := time.Now()
-		timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
-		if atomic.LoadInt64(&reading) == 0 {
-			go func(fr io.Reader, buf []byte, respChan chan tResp) {
-				atomic.StoreInt64(&reading, 1)
-
-				n, err := fr.Read(buf)
-				if n > 0 {
-					respChan <- tResp{response: string(buf[:n]), error: nil}
-				} else {
+	} else {
+		respChan := make(chan tResp, 64)
+		go func(fr io.Reader, respChan chan tResp) {
+			var buf [1024]byte
+			var remains string
+			for {
+				n, err := fr.Read(buf[:])
+				if err != nil {
 					respChan <- tResp{error: err}
+					return
 				}
 
-				atomic.StoreInt64(&reading, 0)
-			}(stdout, buf[:], respChan)
-		}
+				var replies []string
+				replies, remains = splitReplies(remains + string(buf[:n]))
+				for _, reply := range replies {
+					respChan <- tResp{response: reply, time: time.Since(begin)}
+					if last.MatchString(reply) {
+						return
+					}
+				}
+			}
+		}(stdout, respChan)
 
 		// wait response or timeout
-		select {
-		case resp := <-respChan:
-			caps[i].resp = resp
-		case <-timer.C:
-			caps[i].resp = tResp{error: os.ErrDeadlineExceeded}
+		timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	wait:
+		for {
+			select {
+			case resp := <-respChan:
+				if resp.error != nil {
+					break wait
+				}
+				if matchReply(caps, resp) == len(caps)-1 {
+					break wait
+				}
+			case <-timer.C:
+				break wait
+			}
+		}
+		timer.Stop()
+
+		for i := range caps {
+			if caps[i].resp.response == "" {
+				caps[i].resp = tResp{error: os.ErrDeadlineExceeded, time: time.Since(begin)}
+			}
 		}
-		caps[i].resp.time = time.Since(begin)
 	}
 
 	err = term.Restore(int(stdout.Fd()), save1)
@@ -184,6 +244,66 @@ func queryTerminal(stdout *os.File, timeout int) (caps []tCap, err error) {
 	return caps, nil
 }
 
+// split the terminal replies in s, return the complete replies and the
+// incomplete remains. the bytes out of control sequence are dropped.
+func splitReplies(s string) (replies []string, remains string) {
+	for {
+		i := strings.IndexByte(s, '\x1B')
+		if i < 0 {
+			return replies, ""
+		}
+		s = s[i:]
+		if len(s) < 2 {
+			return replies, s
+		}
+
+		end := -1
+		switch s[1] {
+		case '[': // CSI ends with the final byte
+			if j := strings.IndexFunc(s[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7E }); j >= 0 {
+				end = j + 3
+			}
+		case ']', 'P', '_': // OSC, DCS and APC end with ST, OSC may also end with BEL
+			if j := strings.Index(s, "\x1B\\"); j > 0 {
+				end = j + 2
+			}
+			if j := strings.IndexByte(s, '\x07'); s[1] == ']' && j > 0 && (end < 0 || j < end) {
+				end = j + 1
+			}
+		default:
+			s = s[1:]
+			continue
+		}
+		if end < 0 {
+			return replies, s
+		}
+		replies = append(replies, s[:end])
+		s = s[end:]
+	}
+}
+
+// assign the reply to the first matched query without reply, if all of them
+// have reply, append it to the last matched one, such as the palette query,
+// which gets several replies. return the index of matched query, or -1.
+func matchReply(caps []tCap, resp tResp) int {
+	matched := -1
+	for i := range caps {
+		if caps[i].reply == nil || !caps[i].reply.MatchString(resp.response) {
+			continue
+		}
+		if caps[i].resp.response == "" {
+			caps[i].resp = resp
+			return i
+		}
+		matched = i
+	}
+	if matched >= 0 {
+		caps[matched].resp.response += resp.response
+		caps[matched].resp.time = resp.time
+	}
+	return matched
+}
+
 // simple parser for hex decoding
 func parseHex(s string) string {
 	var b strings.Builder
@@ -238,13 +358,42 @@ func parseFlags(progname string, args []string) (config *Config, output string,
 	flagSet.BoolVar(&conf.query, "query", false, "query terminal extend capability")
 	flagSet.BoolVar(&conf.query, "q", false, "query terminal extend capability")
 
+	flagSet.BoolVar(&conf.agent, "forward-agent", false, "forward ssh agent")
+	flagSet.BoolVar(&conf.agent, "A", false, "forward ssh agent")
+
+	flagSet.IntVar(&conf.scrollback, "scrollback", 0, "number of scrollback history rows")
+	flagSet.StringVar(&conf.cwd, "cwd", "", "initial working directory on server")
+	flagSet.StringVar(&conf.bellMode, "bell", bellModes[bellAudible], "bell mode")
+	flagSet.BoolVar(&conf.bellUrgent, "bell-urgent", false, "set urgency hint when the bell rings")
+	flagSet.StringVar(&conf.clipboardMode, "clipboard", clipboardModes[clipboardAllow], "clipboard write mode")
+	flagSet.IntVar(&conf.clipboardMax, "clipboard-max", clipboardMaxSize, "max bytes of clipboard write")
+	flagSet.BoolVar(&conf.clipboardRead, "clipboard-read", false, "let server read the local clipboard")
+	flagSet.StringVar(&conf.themeFile, "theme", "", "theme file of the 16 ANSI colors")
+	flagSet.StringVar(&conf.widthPolicy, "width", "", "grapheme width policy")
+	flagSet.BoolVar(&conf.terminfo, "terminfo", false, "install and use aprilsh terminfo entry")
+
+	flagSet.Var(&conf.localForward, "L", "local tcp forwarding")
+	flagSet.Var(&conf.remoteForward, "R", "remote tcp forwarding")
+
+	flagSet.Var(&conf.sendEnv, "send-env", "send environment variables matching the pattern")
+	flagSet.Var(&conf.setEnv, "set-env", "send environment variable, NAME=VALUE pair")
+
 	err = flagSet.Parse(args)
 	if err != nil {
 		return nil, buf.String(), err
 	}
 
 	// get the non-flag command-line arguments.
+	// the arguments after "--" is the remote command.
 	conf.destination = flagSet.Args()
+	conf.options = sessionOptions(args[:len(args)-len(conf.destination)])
+	for i := range conf.destination {
+		if conf.destination[i] == "--" {
+			conf.command = conf.destination[i+1:]
+			conf.destination = conf.destination[:i]
+			break
+		}
+	}
 
 	// detremine verbose level
 	if v1 {
@@ -255,9 +404,42 @@ func parseFlags(progname string, args []string) (config *Config, output string,
 	return &conf, buf.String(), nil
 }
 
+// return the command-line options to open a new session, the initial working
+// directory is removed, the new session has its own.
+func sessionOptions(args []string) []string {
+	options := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if name == "cwd" {
+			if !hasValue {
+				i++ // skip the value
+			}
+			continue
+		}
+		options = append(options, args[i])
+	}
+	return options
+}
+
+// listFlag collects the value of repeated flag
+type listFlag []string
+
+func (lf *listFlag) String() string {
+	return fmt.Sprint(*lf)
+}
+
+func (lf *listFlag) Set(value string) error {
+	*lf = append(*lf, value)
+	return nil
+}
+
 // fieldalignment -fix frontend/client/client.go
 type Config struct {
 	caps             map[int]string
+	theme            theme // colors read from themeFile
 	predictOverwrite string
 	sshClientID      string // ssh client identity, for SSH public key authentication
 	host             string // target host/server
@@ -265,14 +447,34 @@ type Config struct {
 	sshPort          string // ssh port, default 22
 	key              string
 	predictMode      string
+	cwd              string   // initial working directory on server
+	termName         string   // terminal name reported by XTGETTCAP TN
+	bellMode         string   // how the bell is delivered, one of bellModes
+	clipboardMode    string   // how the clipboard write is handled, one of clipboardModes
+	themeFile        string   // theme file which remaps the 16 ANSI colors
+	widthPolicy      string   // grapheme width policy, see terminal.ParseWidthPolicy()
 	destination      []string // raw parameter
-	port             int      // first server port, then target port
-	mapping          int      // container(such as docker) port mapping value
+	command          []string // remote command and its arguments
+	options          []string // raw command-line options, used by "copy new session command"
+	sendEnv          listFlag // patterns of environment variables to send
+	setEnv           listFlag // NAME=VALUE environment variables to send
+	localForward     listFlag // local tcp forwarding specifications
+	remoteForward    listFlag // remote tcp forwarding specifications
+	localForwards    []frontend.Forward
+	remoteForwards   []frontend.Forward
+	port             int // first server port, then target port
+	mapping          int // container(such as docker) port mapping value
+	scrollback       int // number of scrollback history rows, 0 means server setting
+	clipboardMax     int // max bytes of clipboard write
 	verbose          int
 	version          bool
 	colors           bool
 	addSource        bool // add source file to log
 	query            bool
+	agent            bool // forward ssh agent
+	bellUrgent       bool // set urgency hint when the bell rings
+	clipboardRead    bool // let server read the local clipboard
+	terminfo         bool // install and use aprilsh terminfo entry on server
 }
 
 var errNoResponse = errors.New("no response, please make sure the server is running")
@@ -496,6 +698,13 @@ func (c *Config) fetchKey() error {
 	defer session.Close()
 
 	// https://medium.com/@briankworld/working-with-json-data-in-go-a-guide-to-marshalling-and-unmarshalling-78eccb51b115
+	if c.clipboardRead {
+		// tell server to forward the clipboard query instead of answering it
+		if c.caps == nil {
+			c.caps = make(map[int]string)
+		}
+		c.caps[terminal.OSC_52] = "read"
+	}
 	dst := frontend.EncodeTerminalCaps(c.caps)
 
 	// Once a Session is created, you can execute a single command on
@@ -507,6 +716,32 @@ func (c *Config) fetchKey() error {
 	var b []byte
 	cmd := fmt.Sprintf("/usr/bin/apshd -b -t %s -destination %s -p %d -caps %s",
 		os.Getenv("TERM"), c.destination[0], c.port, dst)
+	if len(c.command) > 0 {
+		// the encoded command is safe for remote shell, no quoting is required.
+		cmd = fmt.Sprintf("%s -command %s", cmd, frontend.EncodeCommand(c.command))
+	}
+	if env := c.buildEnv(); len(env) > 0 {
+		// apshd only accepts the variables allowed by its -accept-env patterns.
+		// the values are sent on stdin, the command line is visible to ps.
+		cmd = fmt.Sprintf("%s -env -", cmd)
+		session.Stdin = strings.NewReader(string(frontend.EncodeEnv(env)) + "\n")
+	}
+	if c.agent {
+		cmd = fmt.Sprintf("%s -agent", cmd)
+	}
+	if len(c.remoteForward) > 0 {
+		cmd = fmt.Sprintf("%s -forward %s", cmd, frontend.EncodeForward(c.remoteForward))
+	}
+	if c.scrollback > 0 {
+		cmd = fmt.Sprintf("%s -scrollback %d", cmd, c.scrollback)
+	}
+	if c.cwd != "" {
+		cmd = fmt.Sprintf("%s -cwd %s", cmd, frontend.EncodeCwd(c.cwd))
+	}
+	if c.terminfo {
+		// the entry matches the colors of local terminal.
+		cmd = fmt.Sprintf("%s -terminfo %s", cmd, terminal.AprilshTermName(terminal.TerminalColors(c.caps)))
+	}
 	util.Logger.Debug("fetchKey", "cmd", cmd, "caps length", len(dst))
 
 	// Turns out, you can't simply change environment variables in SSH sessions.
@@ -562,6 +797,65 @@ func (c *Config) fetchKey() error {
 	return nil
 }
 
+// the ssh_config settings used by buildEnv(), it's replaced by test.
+var sshSettings = ssh_config.DefaultUserSettings
+
+// build the environment variables forwarded to the remote shell. the SendEnv
+// and SetEnv entries in ssh_config are combined with the --send-env and
+// --set-env options, the option takes precedence over ssh_config.
+func (c *Config) buildEnv() map[string]string {
+	env := make(map[string]string)
+
+	patterns := []string{}
+	values, _ := sshSettings.GetAllStrict(c.host, "SendEnv")
+	for _, v := range append(values, c.sendEnv...) {
+		patterns = append(patterns, strings.Fields(v)...)
+	}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if frontend.MatchEnv(k, patterns) {
+			env[k] = v
+		}
+	}
+
+	values, _ = sshSettings.GetAllStrict(c.host, "SetEnv")
+	pairs := []string{}
+	for _, v := range values {
+		pairs = append(pairs, strings.Fields(v)...)
+	}
+	for _, kv := range append(pairs, c.setEnv...) {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			env[k] = v
+		}
+	}
+
+	return env
+}
+
+// get the forwarding specifications from ssh_config, convert them into the
+// form of -L/-R option.
+func sshForward(host string, key string) (specs []string) {
+	values, _ := ssh_config.GetAllStrict(host, key)
+	for _, v := range values {
+		if f := strings.Fields(v); len(f) == 2 {
+			specs = append(specs, f[0]+":"+f[1])
+		}
+	}
+	return specs
+}
+
+func parseForwards(specs []string) ([]frontend.Forward, error) {
+	forwards := make([]frontend.Forward, 0, len(specs))
+	for _, spec := range specs {
+		f, err := frontend.ParseForward(spec)
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, f)
+	}
+	return forwards, nil
+}
+
 func (c *Config) buildConfig() (string, bool) {
 	// just need version info
 	if c.version {
@@ -603,6 +897,56 @@ func (c *Config) buildConfig() (string, bool) {
 		return "destination should be in the form of user@host[:port]", false
 	}
 
+	if c.scrollback < 0 || c.scrollback > terminal.SaveLineUpperLimit {
+		return fmt.Sprintf("scrollback should be in the range of [0,%d].", terminal.SaveLineUpperLimit), false
+	}
+
+	if c.cwd != "" && !strings.HasPrefix(c.cwd, "/") {
+		return "cwd should be an absolute path on server.", false
+	}
+
+	if c.bellMode != "" && !slices.Contains(bellModes, c.bellMode) {
+		return fmt.Sprintf("bell mode should be one of %s.", strings.Join(bellModes, ", ")), false
+	}
+
+	if c.clipboardMode != "" && !slices.Contains(clipboardModes, c.clipboardMode) {
+		return fmt.Sprintf("clipboard mode should be one of %s.", strings.Join(clipboardModes, ", ")), false
+	}
+
+	if c.clipboardMax < 0 {
+		return "clipboard-max should not be negative.", false
+	}
+
+	if _, err := terminal.ParseWidthPolicy(c.widthPolicy, terminal.DefaultWidthPolicy); err != nil {
+		return fmt.Sprintf("width: %s.", err), false
+	}
+
+	if c.themeFile != "" {
+		var err error
+		if c.theme, err = loadTheme(c.themeFile); err != nil {
+			return fmt.Sprintf("theme: %s.", err), false
+		}
+	}
+
+	// ForwardAgent in ssh_config, nothing to forward without local agent.
+	if !c.agent {
+		c.agent = ssh_config.Get(c.host, "ForwardAgent") == "yes"
+	}
+	if c.agent && os.Getenv("SSH_AUTH_SOCK") == "" {
+		c.agent = false
+	}
+
+	// LocalForward and RemoteForward in ssh_config, in the form of "port host:hostport"
+	var err error
+	c.localForward = append(c.localForward, sshForward(c.host, "LocalForward")...)
+	if c.localForwards, err = parseForwards(c.localForward); err != nil {
+		return err.Error(), false
+	}
+	c.remoteForward = append(c.remoteForward, sshForward(c.host, "RemoteForward")...)
+	if c.remoteForwards, err = parseForwards(c.remoteForward); err != nil {
+		return err.Error(), false
+	}
+
 	// Read key from environment
 	// c.key = os.Getenv(_APRILSH_KEY)
 	// if c.key == "" {
@@ -633,12 +977,36 @@ func (c *Config) buildConfig() (string, bool) {
 
 func (c *Config) buildCaps(caps []tCap) {
 	p := terminal.NewParser()
+	width := terminal.DefaultWidthPolicy
 	for _, cap := range caps {
+		if applyWidthProbe(&width, cap) {
+			continue
+		}
 		hds := p.ProcessStream(cap.query)
 		if cap.resp.response != "" && cap.resp.error == nil {
-			c.caps[hds[0].GetId()] = cap.resp.response
+			// the XTGETTCAP replies share the same handler id, keep all of them
+			id := hds[0].GetId()
+			if id == terminal.DCS_XTGETTCAP {
+				c.caps[id] += cap.resp.response
+			} else {
+				c.caps[id] = cap.resp.response
+			}
+			if cap.label == "XTGETTCAP TN" {
+				c.termName = parseTermName(cap.resp.response)
+			}
 		}
 	}
+
+	// the theme colors override the palette of local terminal
+	if len(c.theme) > 0 {
+		c.caps[terminal.OSC_4] += c.theme.sequence()
+	}
+
+	// the policy given by command line overrides the probe result.
+	width, _ = terminal.ParseWidthPolicy(c.widthPolicy, width)
+	if width != terminal.DefaultWidthPolicy {
+		c.caps[terminal.CAPS_WIDTH_POLICY] = width.String()
+	}
 }
 
 // read password from specified input source
@@ -728,22 +1096,42 @@ type STMClient struct {
 	windowSize             *unix.Winsize
 	network                *network.Transport[*statesync.UserStream, *statesync.Complete]
 	overlays               *frontend.OverlayManager
-	savedTermios           *term.State // store the original termios, used for shutdown
-	rawTermios             *term.State // set IUTF8 flag, set raw terminal in raw mode, used for resume
+	mux                    *frontend.Mux           // forwarding channels
+	scrollback             *scrollback             // scrollback view, nil means live view
+	listeners              map[net.Listener]string // local forwarding listener and channel target
+	theme                  theme                   // remapped ANSI colors of local terminal
+	charset                encoding.Encoding       // legacy charset of local terminal, nil means UTF-8
+	output                 *charsetOutput          // convert output to the legacy charset
+	caps                   map[int]string          // local terminal capability
+	savedTermios           *term.State             // store the original termios, used for shutdown
+	rawTermios             *term.State             // set IUTF8 flag, set raw terminal in raw mode, used for resume
 	connectingNotification string
 	key                    string
 	escapeKeyHelp          string
+	destination            string   // user@host[:port], used by "copy new session command"
+	options                []string // command-line options, used by "copy new session command"
+	remoteForwards         []frontend.Forward
 	ip                     string
 	escapeKey              int
 	verbose                int
 	escapePassKey2         int
 	escapePassKey          int
 	port                   int
+	chanSeq                uint64    // last channel message got from server
+	eventSeq               uint64    // last event got from server
+	historyID              uint32    // id of the last history request
+	notifyStyle            int       // the notification sequence understood by local terminal
+	bell                   bell      // bell rate limiting and visual bell
+	clipboard              clipboard // clipboard policy
 	escapeRequireslf       bool
 	lfEntered              bool
 	quitSequenceStarted    bool
 	cleanShutdown          bool
 	repaintRequested       bool
+	agent                  bool // forward ssh agent
+	bellUrgent             bool // set urgency hint when the bell rings
+	kittyKbd               bool // local terminal supports kitty keyboard protocol
+	syncOutput             bool // local terminal supports synchronized output (mode 2026)
 }
 
 func newSTMClient(config *Config) *STMClient {
@@ -771,6 +1159,32 @@ func newSTMClient(config *Config) *STMClient {
 	sc.quitSequenceStarted = false
 	sc.cleanShutdown = false
 	sc.verbose = config.verbose
+	sc.agent = config.agent
+	if len(config.destination) > 0 {
+		sc.destination = config.destination[0]
+	}
+	sc.options = config.options
+
+	termName := config.termName
+	if termName == "" {
+		termName = os.Getenv("TERM")
+	}
+	sc.notifyStyle = notifyStyle(termName, os.Getenv("TERM_PROGRAM"), os.Getenv("VTE_VERSION") != "")
+	if i := slices.Index(bellModes, config.bellMode); i >= 0 {
+		sc.bell.mode = i
+	}
+	sc.bellUrgent = config.bellUrgent
+	sc.theme = config.theme
+	sc.caps = config.caps
+	sc.display.SetColors(terminal.TerminalColors(sc.caps))
+	sc.kittyKbd = config.caps[terminal.CSI_U_QUERY] != ""
+	sc.syncOutput = terminal.SyncOutputSupported(config.caps)
+	if i := slices.Index(clipboardModes, config.clipboardMode); i >= 0 {
+		sc.clipboard.mode = i
+	}
+	sc.clipboard.maxSize = config.clipboardMax
+	sc.clipboard.read = config.clipboardRead
+	sc.clipboard.wrap = clipboardWrap(os.Getenv("TERM"), os.Getenv("TMUX") != "")
 
 	if config.predictMode != "" {
 		switch config.predictMode {
@@ -788,6 +1202,18 @@ func newSTMClient(config *Config) *STMClient {
 	if config.predictOverwrite == "yes" {
 		sc.overlays.GetPredictionEngine().SetPredictOverwrite(true)
 	}
+
+	// local tcp forwarding, like ssh, it's not fatal if we can't listen.
+	sc.listeners = make(map[net.Listener]string)
+	for _, f := range config.localForwards {
+		ln, err := net.Listen("tcp", f.Listen)
+		if err != nil {
+			fmt.Printf("Warning: local forwarding %s failed: %s\n", f.Listen, err)
+			continue
+		}
+		sc.listeners[ln] = f.ChannelTarget()
+	}
+	sc.remoteForwards = config.remoteForwards
 	return &sc
 }
 
@@ -815,10 +1241,16 @@ func (sc *STMClient) init() error {
 		nativeType := util.GetCtype()
 		nativeCharset := util.LocaleCharset()
 
-		fmt.Printf("%s needs a UTF-8 native locale to run.\n\n", frontend.CommandClientName)
-		fmt.Printf("Unfortunately, the client's environment (%s) specifies\nthe character set %q.\n\n",
-			nativeType, nativeCharset)
-		return errors.New(frontend.CommandClientName + " requires UTF-8 environment")
+		// the legacy charset is converted at the edge, the rest of client keeps UTF-8.
+		enc, err := util.LookupCharset(nativeCharset)
+		if err != nil || util.IsAsciiCharset(enc) {
+			fmt.Printf("%s needs a UTF-8 native locale or a supported legacy charset to run.\n\n", frontend.CommandClientName)
+			fmt.Printf("Unfortunately, the client's environment (%s) specifies\nthe character set %q.\n\n",
+				nativeType, nativeCharset)
+			return errors.New(frontend.CommandClientName + " requires UTF-8 environment")
+		}
+		sc.charset = enc
+		util.Logger.Info("legacy charset", "charset", nativeCharset)
 	}
 
 	var err error
@@ -830,8 +1262,10 @@ func (sc *STMClient) init() error {
 
 	// set IUTF8 if available
 	// term package doesn't allow us to access termios, we use util package to do that.
-	if err = util.SetIUTF8(int(os.Stdin.Fd())); err != nil {
-		return err
+	if sc.charset == nil {
+		if err = util.SetIUTF8(int(os.Stdin.Fd())); err != nil {
+			return err
+		}
 	}
 
 	// Put terminal driver in raw mode
@@ -847,10 +1281,30 @@ func (sc *STMClient) init() error {
 		return err
 	}
 
+	if sc.charset != nil {
+		if sc.output, err = openCharsetOutput(sc.charset); err != nil {
+			return err
+		}
+	}
+
 	// Put terminal in application-cursor-key mode
 	os.Stdout.WriteString(sc.display.Open())
 	util.Logger.Info("open terminal", "seq", sc.display.Open())
 
+	// xterm sets the urgency hint of window when it receives BEL
+	if sc.bellUrgent {
+		os.Stdout.WriteString("\x1B[?1042h")
+	}
+
+	// remap the ANSI colors of local terminal
+	os.Stdout.WriteString(sc.theme.sequence())
+
+	// save the kitty keyboard flags of local terminal, the flags requested
+	// by application are set by NewFrame().
+	if sc.kittyKbd {
+		os.Stdout.WriteString("\x1B[>0u")
+	}
+
 	// Add our name to window title
 	prefix := os.Getenv("APRILSH_TITLE_PREFIX")
 	if prefix != "" {
@@ -918,7 +1372,7 @@ func (sc *STMClient) init() error {
 			sc.escapeRequireslf = true
 		}
 
-		sc.escapeKeyHelp = fmt.Sprintf("Commands: Ctrl-Z suspends, \".\" quits, " + escapePassName +
+		sc.escapeKeyHelp = fmt.Sprintf("Commands: Ctrl-Z suspends, \".\" quits, \"[\" scrollback, \"n\" copies new session command, " + escapePassName +
 			" gives literal " + escapeKeyName)
 		sc.overlays.GetNotificationEngine().SetEscapeKeyString(b.String())
 	}
@@ -928,6 +1382,8 @@ func (sc *STMClient) init() error {
 }
 
 func (sc *STMClient) shutdown() error {
+	defer sc.output.close()
+
 	// Restore screen state
 	sc.overlays.GetNotificationEngine().SetNotificationString("", false, true)
 	sc.overlays.GetNotificationEngine().ServerHeard(time.Now().UnixMilli())
@@ -936,6 +1392,13 @@ func (sc *STMClient) shutdown() error {
 	sc.outputNewFrame()
 
 	// Restore terminal and terminal-driver state
+	if sc.bellUrgent {
+		os.Stdout.WriteString("\x1B[?1042l")
+	}
+	if sc.kittyKbd {
+		os.Stdout.WriteString("\x1B[<u")
+	}
+	os.Stdout.WriteString(sc.theme.reset())
 	os.Stdout.WriteString(sc.display.Close())
 	util.Logger.Info("close terminal", "seq", sc.display.Close())
 
@@ -963,6 +1426,30 @@ func (sc *STMClient) shutdown() error {
 	return nil
 }
 
+// return the exit code of apsh according to the shutdown result and the
+// exit status of remote shell/command.
+func (sc *STMClient) exitCode() int {
+	if sc.network == nil || sc.stillConnecting() || !sc.cleanShutdown {
+		return exitNetworkTimeout
+	}
+
+	state := sc.network.GetLatestRemoteState()
+	code, sig, ok := state.GetState().GetExitStatus()
+	if !ok {
+		return 0
+	}
+	return exitCodeOf(code, sig)
+}
+
+// convert exit status of remote shell/command into exit code, follow the
+// shell convention: 128+N if it's killed by signal N.
+func exitCodeOf(code int, sig int) int {
+	if sig > 0 {
+		return 128 + sig
+	}
+	return code
+}
+
 func (sc *STMClient) mainInit() error {
 	// get initial window size
 	col, row, err := term.GetSize(int(os.Stdin.Fd()))
@@ -974,6 +1461,7 @@ func (sc *STMClient) mainInit() error {
 	// local state
 	savedLines := terminal.SaveLinesRowsOption
 	sc.localFramebuffer = terminal.NewEmulator3(col, row, savedLines)
+	sc.localFramebuffer.SetTerminalCaps(sc.caps)
 	sc.newState = terminal.NewEmulator3(col, row, savedLines)
 
 	// initialize screen
@@ -991,6 +1479,10 @@ func (sc *STMClient) mainInit() error {
 	if err != nil {
 		return err
 	}
+	// track the terminal state depending on the capability, like server
+	terminal.SetTerminalCaps(sc.caps)
+	// the image placeholder is accepted from server only
+	terminal.SetImagePlaceholder(true)
 	sc.network = network.NewTransportClient(blank, terminal, sc.key, sc.ip, fmt.Sprintf("%d", sc.port))
 
 	// minimal delay on outgoing keystrokes
@@ -1002,9 +1494,28 @@ func (sc *STMClient) mainInit() error {
 	// be noisy as necessary
 	sc.network.SetVerbose(uint(sc.verbose))
 
+	// forwarding channels, client only accepts channel opened by server.
+	sc.mux = frontend.NewMux(1, sc.dialChannel)
+	for ln, target := range sc.listeners {
+		sc.mux.Serve(ln, target)
+	}
+
 	return nil
 }
 
+// connect the target of channel opened by server.
+func (sc *STMClient) dialChannel(target string) (net.Conn, error) {
+	if target == "agent" && sc.agent {
+		return net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	}
+	for _, f := range sc.remoteForwards {
+		if target == f.ChannelTarget() {
+			return frontend.DialForward(target)
+		}
+	}
+	return nil, fmt.Errorf("channel target %q is not allowed", target)
+}
+
 func (sc *STMClient) outputNewFrame() {
 	// clean shutdown even when not initialized
 	if sc.network == nil {
@@ -1014,6 +1525,13 @@ func (sc *STMClient) outputNewFrame() {
 	// fetch target state
 	// NOTE: clone the state for prediction, otherwise the state will be messed up by prediction
 	state := sc.network.GetLatestRemoteState()
+
+	// the live screen is repainted after leaving scrollback view
+	if sc.scrollback != nil {
+		state.GetState().GetDiff()
+		sc.outputScrollback(state.GetState().GetHistory())
+		return
+	}
 	sc.newState = state.GetState().GetEmulator().Clone()
 
 	// util.Logger.Trace("outputNewFrame", "before", "Apply",
@@ -1038,11 +1556,21 @@ func (sc *STMClient) outputNewFrame() {
 
 	// calculate minimal difference from where we are
 	if predictDiff != "" {
-		os.Stdout.WriteString(predictDiff)
+		predictDiff = sc.display.DowngradeColors(predictDiff)
+		os.Stdout.WriteString(sc.syncFrame(predictDiff))
 		util.Logger.Debug("outputNewFrame", "action", "predict", "predictDiff", predictDiff)
 	} else if diff != "" {
 		if !sc.overlays.GetPredictionEngine().IsApplied() {
-			os.Stdout.WriteString(diff)
+			if !sc.display.SupportCwd() {
+				diff = stripWorkingDir(diff)
+			}
+			if !sc.display.SupportLink() {
+				diff = stripHyperlinks(diff)
+			}
+			diff = sc.display.DowngradeColors(diff)
+			diff = state.GetState().GetEmulator().ReplaceImages(diff)
+			os.Stdout.WriteString(sc.syncFrame(diff))
+			sc.display.TrackPalette(state.GetState().GetEmulator())
 			util.Logger.Debug("outputNewFrame", "action", "output", "diff", diff)
 		} else {
 			util.Logger.Debug("outputNewFrame", "action", "skip", "diff", diff)
@@ -1064,6 +1592,119 @@ func (sc *STMClient) outputNewFrame() {
 	sc.localFramebuffer = sc.newState
 }
 
+// request the history rows needed by scrollback view, render the view if
+// it's changed.
+func (sc *STMClient) outputScrollback(reply statesync.HistoryReply) {
+	sc.scrollback.apply(reply)
+	if clip, ok := sc.scrollback.takeClip(); ok {
+		// copy the command output to the clipboard of local terminal
+		os.Stdout.WriteString(sc.clipboard.copy(clip))
+	}
+	if req, ok := sc.scrollback.request(); ok && !sc.network.ShutdownInProgress() {
+		sc.network.GetCurrentState().PushBackHistory(req)
+		sc.historyID = req.ID
+	}
+
+	if sc.scrollback.dirty {
+		frame := sc.scrollback.frame()
+		os.Stdout.WriteString(sc.display.NewFrame(true, sc.localFramebuffer, frame))
+		sc.localFramebuffer = frame
+	}
+}
+
+// the working directory report (OSC 7) replicated by server.
+var workingDirReport = regexp.MustCompile("\x1B\\]7;[^\x07\x1B]*(\x07|\x1B\\\\)")
+
+// remove the working directory report from server diff, which confuses the
+// terminals not supporting it.
+func stripWorkingDir(diff string) string {
+	if !strings.Contains(diff, "\x1B]7;") {
+		return diff
+	}
+	return workingDirReport.ReplaceAllString(diff, "")
+}
+
+var hyperlink = regexp.MustCompile("\x1B\\]8;[^\x07\x1B]*(\x07|\x1B\\\\)")
+
+// remove the hyperlinks from server diff, the terminals not supporting it may
+// print the URL on screen.
+func stripHyperlinks(diff string) string {
+	if !strings.Contains(diff, "\x1B]8;") {
+		return diff
+	}
+	return hyperlink.ReplaceAllString(diff, "")
+}
+
+// wrap the frame in synchronized update if local terminal supports it, the
+// terminal paints the whole frame at once instead of tearing.
+func (sc *STMClient) syncFrame(frame string) string {
+	if !sc.syncOutput {
+		return frame
+	}
+	return "\x1B[?2026h" + frame + "\x1B[?2026l"
+}
+
+// build the command line which opens a new session to the same destination
+// with the same options, starting in the working directory reported by the
+// shell (OSC 7).
+func (sc *STMClient) newSessionCommand() (string, bool) {
+	if sc.localFramebuffer == nil || sc.destination == "" {
+		return "", false
+	}
+	_, dir := sc.localFramebuffer.GetWorkingDir()
+	if dir == "" {
+		return "", false
+	}
+
+	words := []string{frontend.CommandClientName}
+	for _, opt := range sc.options {
+		words = append(words, shellQuote(opt))
+	}
+	words = append(words, "--cwd", shellQuote(dir), shellQuote(sc.destination))
+	return strings.Join(words, " "), true
+}
+
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// quote the word for POSIX shell if it contains special characters.
+func shellQuote(word string) string {
+	if shellSafe.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// copy the new session command line to the clipboard of local terminal.
+func (sc *STMClient) copyNewSession() {
+	cmd, ok := sc.newSessionCommand()
+	if !ok {
+		sc.overlays.GetNotificationEngine().SetNotificationString(
+			"The shell doesn't report its working directory (OSC 7).", false, false)
+		return
+	}
+	os.Stdout.WriteString(sc.clipboard.copy(cmd))
+	sc.overlays.GetNotificationEngine().SetNotificationString("Copied: "+cmd, false, false)
+}
+
+// enter scrollback view, the live screen is frozen until leaving it.
+func (sc *STMClient) enterScrollback() {
+	sc.scrollback = newScrollback(sc.localFramebuffer.GetWidth(), sc.localFramebuffer.GetHeight(), sc.historyID)
+	sc.overlays.GetPredictionEngine().Reset()
+}
+
+// leave scrollback view, repaint the live screen.
+func (sc *STMClient) leaveScrollback() {
+	if sc.scrollback == nil {
+		return
+	}
+	sc.scrollback = nil
+
+	state := sc.network.GetLatestRemoteState()
+	live := state.GetState().GetEmulator().Clone()
+	os.Stdout.WriteString(sc.display.NewFrame(true, sc.localFramebuffer, live))
+	sc.localFramebuffer = live
+}
+
 func (sc *STMClient) processNetworkInput(s string) {
 	// sc.network.Recv()
 	if err := sc.network.ProcessPayload(s); err != nil {
@@ -1085,12 +1726,120 @@ func (sc *STMClient) processNetworkInput(s string) {
 	lateAcked := state.GetState().GetEchoAck()
 	sc.overlays.GetPredictionEngine().SetLocalFrameLateAcked(lateAcked)
 	util.Logger.Trace("processNetworkInput", "lateAcked", lateAcked)
+
+	// deliver the channel messages from server
+	var msgs []statesync.ChannelMsg
+	msgs, sc.chanSeq = state.GetState().TakeChannels(sc.chanSeq)
+	for i := range msgs {
+		sc.mux.Handle(msgs[i])
+	}
+
+	// handle the events from server
+	var events []statesync.Event
+	events, sc.eventSeq = state.GetState().TakeEvents(sc.eventSeq)
+	for i := range events {
+		sc.handleEvent(events[i])
+	}
+}
+
+func (sc *STMClient) handleEvent(e statesync.Event) {
+	switch e.Kind {
+	case statesync.EventNotify:
+		if e.Title == "" && e.Body == "" {
+			return
+		}
+		if seq := notifySequence(sc.notifyStyle, e.Title, e.Body); seq != "" {
+			os.Stdout.WriteString(seq)
+			return
+		}
+		// the local terminal can't show it, use the notification bar instead
+		msg := sanitizeNotify(e.Title)
+		if e.Body != "" {
+			msg = strings.TrimPrefix(msg+": "+sanitizeNotify(e.Body), ": ")
+		}
+		sc.overlays.GetNotificationEngine().SetNotificationString(msg, false, false)
+	case statesync.EventBell:
+		now := time.Now()
+		if !sc.bell.allow(now) {
+			return
+		}
+		switch sc.bell.mode {
+		case bellAudible:
+			os.Stdout.WriteString("\a")
+		case bellVisual:
+			os.Stdout.WriteString(sc.bell.flash(now, sc.reverseVideo()))
+		case bellNotify:
+			sc.handleEvent(statesync.Event{Kind: statesync.EventNotify, Title: frontend.CommandClientName,
+				Body: "Bell in " + sc.destination})
+		}
+	case statesync.EventClipboard:
+		seq, notice := sc.clipboard.handle(e)
+		if seq != "" {
+			os.Stdout.WriteString(seq)
+		}
+		if notice != "" {
+			sc.overlays.GetNotificationEngine().SetNotificationString(notice, sc.clipboard.pending != nil, false)
+		}
+	}
+}
+
+// return the reverse video mode of local screen.
+func (sc *STMClient) reverseVideo() bool {
+	return sc.localFramebuffer != nil && sc.localFramebuffer.IsReverseVideo()
 }
 
 func (sc *STMClient) processUserInput(buf string) bool {
 	if sc.network.ShutdownInProgress() {
 		return true
 	}
+	// scrollback view consumes all the input
+	if sc.scrollback != nil {
+		if sc.scrollback.handleInput(buf) {
+			sc.leaveScrollback()
+		}
+		return true
+	}
+
+	// the mouse reports and kitty key events are sent as events, server
+	// encodes them for the application.
+	mode, enc := sc.localFramebuffer.GetMouseTracking()
+	mouse := mode != terminal.MouseTrackingMode_Disable
+	kitty := sc.kittyKbd && sc.localFramebuffer.GetKittyKeyboardFlags() != 0
+	if mouse || kitty {
+		enc = terminal.LocalMouseEnc(enc)
+		for {
+			i, n, a, ok := nextEvent(buf, mouse, enc, kitty)
+			if !ok {
+				break
+			}
+			if i > 0 && !sc.processKeys(buf[:i]) {
+				return false
+			}
+			buf = buf[i+n:]
+
+			switch a := a.(type) {
+			case terminal.Mouse:
+				sc.network.GetCurrentState().PushBackMouse(a)
+			case terminal.Key:
+				// the escape key sequence is handled as keystrokes
+				if legacy := a.Legacy(); sc.quitSequenceStarted || legacy == string(rune(sc.escapeKey)) {
+					if legacy != "" && !sc.processKeys(legacy) {
+						return false
+					}
+					continue
+				}
+				sc.network.GetCurrentState().PushBackKey(a)
+			}
+		}
+		if buf == "" {
+			return true
+		}
+	}
+	return sc.processKeys(buf)
+}
+
+// process the keystrokes from user.
+func (sc *STMClient) processKeys(buf string) bool {
 	sc.overlays.GetPredictionEngine().SetLocalFrameSent(sc.network.GetSentStateLast())
 
 	// Don't predict for bulk data.
@@ -1138,6 +1887,14 @@ func (sc *STMClient) processUserInput(buf string) bool {
 				// TODO: check SIGSTOP
 
 				sc.resume()
+			} else if theByte == '[' { // Scrollback sequence is escape_key [
+				sc.enterScrollback()
+			} else if theByte == 'n' { // New session sequence is escape_key n
+				sc.copyNewSession()
+			} else if theByte == 'y' && sc.clipboard.pending != nil { // Accept clipboard write is escape_key y
+				seq, _ := sc.clipboard.accept()
+				os.Stdout.WriteString(seq)
+				sc.overlays.GetNotificationEngine().SetNotificationString("", false, true)
 			} else if theByte == rune(sc.escapePassKey) || theByte == rune(sc.escapePassKey2) {
 				// Emulation sequence to type escape_key is escape_key +
 				// escape_pass_key (that is escape key without Ctrl)
@@ -1148,6 +1905,7 @@ func (sc *STMClient) processUserInput(buf string) bool {
 			}
 
 			sc.quitSequenceStarted = false
+			sc.clipboard.pending = nil // other escape command discards the pending clipboard write
 
 			if sc.overlays.GetNotificationEngine().GetNotificationString() == sc.escapeKeyHelp {
 				sc.overlays.GetNotificationEngine().SetNotificationString("", false, true)
@@ -1184,6 +1942,9 @@ func (sc *STMClient) processResize() bool {
 		return false
 	}
 
+	// scrollback view is sized by the old window
+	sc.leaveScrollback()
+
 	// newSize := terminal.Resize{Width: col, Height: row}
 	// tell remote emulator
 	if !sc.network.ShutdownInProgress() {
@@ -1229,7 +1990,7 @@ func (sc *STMClient) main() error {
 
 	// read from pty master file
 	eg.Go(func() error {
-		frontend.ReadFromFile(10, fileChan, fileDownChan, os.Stdin)
+		frontend.ReadFromFile(10, fileChan, fileDownChan, util.NewCharsetReader(os.Stdin, sc.charset))
 		return nil
 	})
 
@@ -1254,9 +2015,14 @@ mainLoop:
 	for {
 		sc.outputNewFrame()
 
+		// stop the visual bell
+		if seq := sc.bell.restore(time.Now(), sc.reverseVideo()); seq != "" {
+			os.Stdout.WriteString(seq)
+		}
+
 		w0 := sc.network.WaitTime()
 		w1 := sc.overlays.WaitTime()
-		waitTime := min(w0, w1)
+		waitTime := min(w0, w1, sc.bell.waitTime(time.Now()))
 		// waitTime := terminal.Min(sc.network.WaitTime(), sc.overlays.WaitTime())
 
 		// Handle startup "Connecting..." message
@@ -1308,6 +2074,11 @@ mainLoop:
 					sc.network.StartShutdown()
 				}
 			}
+		case msg := <-sc.mux.Out():
+			// forwarding channel message for server
+			if !sc.network.ShutdownInProgress() {
+				sc.network.GetCurrentState().PushBackChannel(msg)
+			}
 		case s := <-sigChan:
 			util.Logger.Debug("got signal", "signal", s)
 			signals.Handler(s)
@@ -1400,8 +2171,9 @@ mainLoop:
 		}
 	}
 
-	// stop signal and network
+	// stop signal, forwarding channels and network
 	signal.Stop(sigChan)
+	sc.mux.Close()
 	sc.network.Close()
 
 	// shutdown the goroutines: file reader and network reader
@@ -1446,9 +2218,11 @@ func main() {
 		return
 	} else if err != nil {
 		frontend.PrintUsage(err.Error())
+		osExit(exitUsage)
 		return
 	} else if hint, ok := conf.buildConfig(); !ok {
 		frontend.PrintUsage(hint)
+		osExit(exitUsage)
 		return
 	}
 
@@ -1527,10 +2301,13 @@ func main() {
 		var exitError *ssh.ExitError
 		var hostkeyChangeError *hostkeyChangeError
 
+		code := exitServerRefused
 		if errors.As(err, &dnsError) {
 			frontend.PrintUsage(fmt.Sprintf("No such host: %q", dnsError.Name))
+			code = exitNetworkTimeout
 		} else if errors.As(err, &opError) && opError.Op == "dial" {
 			frontend.PrintUsage(fmt.Sprintf("Failed to connect to: %s", opError.Addr))
+			code = exitNetworkTimeout
 		} else if strings.Contains(err.Error(), "unable to authenticate") {
 			// the error returned by ssh.NewClientConn() doen't naming error,
 			// we have to check the error message directly.
@@ -1538,19 +2315,23 @@ func main() {
 			// enable 'PubkeyAuthentication yes' line in sshd_config
 			frontend.PrintUsage(fmt.Sprintf("Failed to authenticate user %q", conf.user))
 			fmt.Printf("%s\n", err)
+			code = exitAuthFailure
 		} else if errors.As(err, &keyError) {
 			// } else if strings.Contains(err.Error(), "key is unknown") {
 			// we already handle it
+			code = exitAuthFailure
 		} else if errors.Is(err, errNoResponse) {
 			frontend.PrintUsage(err.Error())
 		} else if errors.As(err, &exitError) && exitError.ExitStatus() == 127 {
 			frontend.PrintUsage("Plase check aprilsh is installed on server.")
 		} else if errors.As(err, &hostkeyChangeError) {
 			frontend.PrintUsage(hostkeyChangeError.Error())
+			code = exitAuthFailure
 		} else {
 			// printUsage(fmt.Sprintf("%#v", err))
 			frontend.PrintUsage(err.Error())
 		}
+		osExit(code)
 		return
 	}
 
@@ -1559,8 +2340,10 @@ func main() {
 	client := newSTMClient(conf)
 	if err := client.init(); err != nil {
 		fmt.Printf("%s init error:%s\n", frontend.CommandClientName, err)
+		osExit(exitInitFailure)
 		return
 	}
 	client.main()
 	client.shutdown()
+	osExit(client.exitCode())
 }
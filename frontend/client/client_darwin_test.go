@@ -39,6 +39,10 @@ func TestMainRun_Parameters2(t *testing.T) {
 			os.Stdout = w
 			os.Stderr = w
 
+			// intercept exit
+			osExit = func(int) {}
+			defer func() { osExit = os.Exit }()
+
 			// prepare data
 			os.Args = v.args
 			os.Setenv("TERM", v.term)
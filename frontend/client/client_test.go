@@ -7,7 +7,10 @@ package main
 import (
 	"errors"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -15,7 +18,9 @@ import (
 
 	"github.com/creack/pty"
 	"github.com/ericwq/aprilsh/frontend"
+	"github.com/ericwq/aprilsh/terminal"
 	"github.com/ericwq/aprilsh/terminfo"
+	"github.com/ericwq/ssh_config"
 	"golang.org/x/term"
 )
 
@@ -88,12 +93,14 @@ func TestMainRun_Parameters(t *testing.T) {
 		args   []string
 		term   string
 		expect []string
+		code   int
 	}{
 		{
 			"no parameters",
 			[]string{frontend.CommandClientName},
 			"xterm-256color",
 			[]string{"destination (user@host[:port]) is mandatory."},
+			exitUsage,
 		},
 		{
 			"just version",
@@ -103,6 +110,7 @@ func TestMainRun_Parameters(t *testing.T) {
 				frontend.CommandClientName, frontend.AprilshPackageName,
 				"Copyright (c) 2022~2024 wangqi <ericwq057@qq.com>", "remote shell support intermittent or mobile network.",
 			},
+			0,
 		},
 		{
 			"just help",
@@ -112,36 +120,42 @@ func TestMainRun_Parameters(t *testing.T) {
 				"Usage:", frontend.CommandClientName, "Options:", "-c", "--colors",
 				"print the number of terminal color",
 			},
+			0,
 		},
 		{
 			"just colors",
 			[]string{frontend.CommandClientName, "-c", "-v"},
 			"xterm-256color",
 			[]string{"xterm-256color", "256"},
+			0,
 		},
 		{
 			"invalid target parameter",
 			[]string{frontend.CommandClientName, "invalid", "target", "parameter"},
 			"xterm-256color",
 			[]string{"only one destination (user@host[:port]) is allowed."},
+			exitUsage,
 		},
 		{
 			"destination no second part",
 			[]string{frontend.CommandClientName, "malform@"},
 			"xterm-256color",
 			[]string{"destination should be in the form of user@host[:port]"},
+			exitUsage,
 		},
 		{
 			"destination no first part",
 			[]string{frontend.CommandClientName, "@malform"},
 			"xterm-256color",
 			[]string{"destination should be in the form of user@host[:port]"},
+			exitUsage,
 		},
 		{
 			"infvalid port number",
 			[]string{frontend.CommandClientName, "-p", "7s"},
 			"xterm-256color",
 			[]string{"invalid value \"7s\" for flag -p: parse error"},
+			exitUsage,
 		},
 	}
 
@@ -152,6 +166,11 @@ func TestMainRun_Parameters(t *testing.T) {
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
+			// intercept exit
+			code := 0
+			osExit = func(c int) { code = c }
+			defer func() { osExit = os.Exit }()
+
 			// prepare data
 			os.Args = v.args
 			os.Setenv("TERM", v.term)
@@ -176,6 +195,9 @@ func TestMainRun_Parameters(t *testing.T) {
 			if found != len(v.expect) {
 				t.Errorf("#test expect %s, got \n%s\n", v.expect, result)
 			}
+			if code != v.code {
+				t.Errorf("#test expect exit code %d, got %d\n", v.code, code)
+			}
 		})
 	}
 }
@@ -232,6 +254,125 @@ func TestBuildConfig(t *testing.T) {
 	}
 }
 
+func TestParseFlagsCommand(t *testing.T) {
+	tc := []struct {
+		label       string
+		args        []string
+		destination []string
+		command     []string
+	}{
+		{"login shell", []string{"usr@host"}, []string{"usr@host"}, nil},
+		{"command", []string{"usr@host", "--", "htop"}, []string{"usr@host"}, []string{"htop"}},
+		{
+			"command with args", []string{"-p", "8200", "usr@host", "--", "tmux", "new", "-A", "-s", "main"},
+			[]string{"usr@host"}, []string{"tmux", "new", "-A", "-s", "main"},
+		},
+		{"empty command", []string{"usr@host", "--"}, []string{"usr@host"}, []string{}},
+	}
+
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			conf, _, err := parseFlags("prog", v.args)
+			if err != nil {
+				t.Fatalf("%s expect nil error, got %s\n", v.label, err)
+			}
+			if !slices.Equal(conf.destination, v.destination) || !slices.Equal(conf.command, v.command) {
+				t.Errorf("%s expect destination %q command %q, got %q %q\n",
+					v.label, v.destination, v.command, conf.destination, conf.command)
+			}
+		})
+	}
+}
+
+func TestBuildEnv(t *testing.T) {
+	tc := []struct {
+		label  string
+		host   string
+		args   []string
+		expect map[string]string
+	}{
+		{"no env", "apsh.test.invalid", []string{"usr@host"}, map[string]string{}},
+		{
+			"send env with pattern", "apsh.test.invalid", []string{"--send-env", "APSH_TEST_*", "usr@host"},
+			map[string]string{"APSH_TEST_ONE": "1", "APSH_TEST_TWO": "two words"},
+		},
+		{
+			"set env override send env", "apsh.test.invalid",
+			[]string{"--send-env", "APSH_TEST_ONE", "--set-env", "APSH_TEST_ONE=3", "usr@host"},
+			map[string]string{"APSH_TEST_ONE": "3"},
+		},
+		{
+			"multiple set env", "apsh.test.invalid", []string{"--set-env", "A=1", "--set-env", "B=", "--set-env", "malform", "usr@host"},
+			map[string]string{"A": "1", "B": ""},
+		},
+		{
+			"ssh_config env", "env.test.invalid", []string{"usr@host"},
+			map[string]string{"APSH_TEST_ONE": "1", "APSH_SET": "conf"},
+		},
+		{
+			"set env override ssh_config", "env.test.invalid", []string{"--set-env", "APSH_SET=opt", "usr@host"},
+			map[string]string{"APSH_TEST_ONE": "1", "APSH_SET": "opt"},
+		},
+	}
+
+	// read the ssh_config fixture instead of the one of current user
+	config := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(config, []byte("Host env.test.invalid\n  SendEnv APSH_TEST_ONE\n  SetEnv APSH_SET=conf\n"), 0o600)
+	saved := sshSettings
+	sshSettings = &ssh_config.UserSettings{}
+	sshSettings.ConfigFinder(func() string { return config })
+	defer func() { sshSettings = saved }()
+
+	// the locale variables are not sent without SendEnv
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); k == "LANG" || strings.HasPrefix(k, "LC_") {
+			t.Setenv(k, "")
+			os.Unsetenv(k)
+		}
+	}
+	t.Setenv("APSH_TEST_ONE", "1")
+	t.Setenv("APSH_TEST_TWO", "two words")
+
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			conf, _, err := parseFlags("prog", v.args)
+			if err != nil {
+				t.Fatalf("%s expect nil error, got %s\n", v.label, err)
+			}
+			conf.host = v.host
+			if got := conf.buildEnv(); !maps.Equal(got, v.expect) {
+				t.Errorf("%s expect %v, got %v\n", v.label, v.expect, got)
+			}
+		})
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	tc := []struct {
+		label  string
+		code   int
+		sig    int
+		expect int
+	}{
+		{"exit normally", 0, 0, 0},
+		{"exit with code", 3, 0, 3},
+		{"killed by SIGKILL", -1, 9, 137},
+		{"killed by SIGHUP", -1, 1, 129},
+	}
+
+	for _, v := range tc {
+		if got := exitCodeOf(v.code, v.sig); got != v.expect {
+			t.Errorf("%s expect %d, got %d\n", v.label, v.expect, got)
+		}
+	}
+
+	// no network: can't reach the server
+	sc := &STMClient{}
+	if got := sc.exitCode(); got != exitNetworkTimeout {
+		t.Errorf("%s expect %d, got %d\n", "no network", exitNetworkTimeout, got)
+	}
+}
+
 func TestBuildConfig2(t *testing.T) {
 	tc := []struct {
 		label     string
@@ -245,6 +386,35 @@ func TestBuildConfig2(t *testing.T) {
 			"destination with wrong port",
 			&Config{destination: []string{"usr@host:a23"}}, "please check destination, illegal port number.", false,
 		},
+		{
+			"local forwarding", &Config{destination: []string{"usr@host"}, localForward: listFlag{"8080:localhost:80"}}, "", true,
+		},
+		{
+			"bad remote forwarding",
+			&Config{destination: []string{"usr@host"}, remoteForward: listFlag{"8080:localhost"}},
+			"bad forwarding specification \"8080:localhost\"", false,
+		},
+		{"absolute cwd", &Config{destination: []string{"usr@host"}, cwd: "/tmp"}, "", true},
+		{"visual bell", &Config{destination: []string{"usr@host"}, bellMode: "visual"}, "", true},
+		{
+			"unknown bell mode",
+			&Config{destination: []string{"usr@host"}, bellMode: "loud"},
+			"bell mode should be one of audible, visual, notify, none.", false,
+		},
+		{"ask clipboard", &Config{destination: []string{"usr@host"}, clipboardMode: "ask"}, "", true},
+		{
+			"unknown clipboard mode",
+			&Config{destination: []string{"usr@host"}, clipboardMode: "copy"},
+			"clipboard mode should be one of allow, deny, ask.", false,
+		},
+		{
+			"negative clipboard max",
+			&Config{destination: []string{"usr@host"}, clipboardMax: -1}, "clipboard-max should not be negative.", false,
+		},
+		{
+			"relative cwd",
+			&Config{destination: []string{"usr@host"}, cwd: "tmp"}, "cwd should be an absolute path on server.", false,
+		},
 	}
 	for _, v := range tc {
 		t.Run(v.label, func(t *testing.T) {
@@ -618,6 +788,95 @@ func TestQueryTerminal_Func(t *testing.T) {
 	}
 }
 
+func TestQueryTerminal_Reply(t *testing.T) {
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		t.Fatalf("open pty error: %s\n", err)
+	}
+	defer ptm.Close()
+	defer pts.Close()
+
+	// the fake terminal answers the queries in pieces, the cell size and kitty
+	// graphics queries are not answered.
+	replies := []string{
+		"\x1B[0n\x1BP1+r544e=787465726d\x1B\\\x1B[?2026;2$y\x1B]4;0;rgb:0000/0000/0000\x1B\\\x1B]4;1;rgb:cd",
+		"cd/0000/0000\x07\x1B]10;rgb:ffff/ffff/ffff\x1B\\\x1B[1;2R\x1B[1;3R",
+		"\x1B[1;3R\x1B[>1;10;0c\x1B[?62;4;22c",
+	}
+	go func() {
+		var buf [4096]byte
+		var query string
+		for !strings.HasSuffix(query, "\x1B[c") {
+			n, err := ptm.Read(buf[:])
+			if err != nil {
+				return
+			}
+			query += string(buf[:n])
+		}
+		for _, r := range replies {
+			ptm.WriteString(r)
+			time.Sleep(2 * time.Millisecond)
+		}
+	}()
+
+	caps, err := queryTerminal(pts, 1000)
+	if err != nil {
+		t.Fatalf("query expect nil err, got %s\n", err)
+	}
+
+	expect := map[string]string{
+		"Device Status Report": "\x1B[0n",
+		"XTGETTCAP RGB":        "",
+		"XTGETTCAP TN":         "\x1BP1+r544e=787465726d\x1B\\",
+		"XTGETTCAP Co":         "",
+		"Synchronized output":  "\x1B[?2026;2$y",
+		"CSI u":                "",
+		"Cell size":            "",
+		"Kitty graphics":       "",
+		"Palette": "\x1B]4;0;rgb:0000/0000/0000\x1B\\\x1B]4;1;rgb:cdcd/0000/0000\x07" +
+			"\x1B]10;rgb:ffff/ffff/ffff\x1B\\",
+		widthAmbiguous: "\x1B[1;2R",
+		widthEmoji:     "\x1B[1;3R",
+		widthVS16:      "\x1B[1;3R",
+		"Secondary DA": "\x1B[>1;10;0c",
+		"Primary DA":   "\x1B[?62;4;22c",
+	}
+	for _, cap := range caps {
+		if cap.resp.response != expect[cap.label] {
+			t.Errorf("%s expect %q, got %q\n", cap.label, expect[cap.label], cap.resp.response)
+		}
+		if (cap.resp.error == nil) != (expect[cap.label] != "") {
+			t.Errorf("%s expect error %t, got %v\n", cap.label, expect[cap.label] == "", cap.resp.error)
+		}
+	}
+}
+
+func TestSplitReplies(t *testing.T) {
+	tc := []struct {
+		label   string
+		stream  string
+		replies []string
+		remains string
+	}{
+		{"CSI", "\x1B[0n\x1B[?62c", []string{"\x1B[0n", "\x1B[?62c"}, ""},
+		{"OSC with BEL and ST", "\x1B]10;rgb:ff/ff/ff\x07\x1B]11;rgb:0/0/0\x1B\\",
+			[]string{"\x1B]10;rgb:ff/ff/ff\x07", "\x1B]11;rgb:0/0/0\x1B\\"}, ""},
+		{"DCS and APC", "\x1BP1+r436f=323536\x1B\\\x1B_Gi=31;OK\x1B\\",
+			[]string{"\x1BP1+r436f=323536\x1B\\", "\x1B_Gi=31;OK\x1B\\"}, ""},
+		{"partial CSI", "\x1B[0n\x1B[1;", []string{"\x1B[0n"}, "\x1B[1;"},
+		{"partial OSC", "\x1B]4;1;rgb:cd", nil, "\x1B]4;1;rgb:cd"},
+		{"only ESC", "\x1B[0n\x1B", []string{"\x1B[0n"}, "\x1B"},
+		{"drop other bytes", "abc\x1BO\x1B[0n", []string{"\x1B[0n"}, ""},
+	}
+
+	for _, v := range tc {
+		replies, remains := splitReplies(v.stream)
+		if !slices.Equal(replies, v.replies) || remains != v.remains {
+			t.Errorf("%s expect %q %q, got %q %q\n", v.label, v.replies, v.remains, replies, remains)
+		}
+	}
+}
+
 func TestQT(t *testing.T) {
 	f, _ := os.Open("/dev/tty")
 	if tm := term.IsTerminal(int(f.Fd())); !tm {
@@ -625,3 +884,139 @@ func TestQT(t *testing.T) {
 	}
 	f.Close()
 }
+
+func TestStripWorkingDir(t *testing.T) {
+	tc := []struct {
+		label  string
+		diff   string
+		expect string
+	}{
+		{"no report", "\x1B[1;1Hhello", "\x1B[1;1Hhello"},
+		{"report with ST", "\x1B]7;file://host/tmp\x1B\\\x1B[1;1H", "\x1B[1;1H"},
+		{"report with BEL", "a\x1B]7;file://host/tmp\x07b", "ab"},
+		{"other OSC", "\x1B]2;title\x07", "\x1B]2;title\x07"},
+	}
+
+	for _, v := range tc {
+		if got := stripWorkingDir(v.diff); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestStripHyperlinks(t *testing.T) {
+	tc := []struct {
+		label  string
+		diff   string
+		expect string
+	}{
+		{"no link", "\x1B[1;1Hhello", "\x1B[1;1Hhello"},
+		{"link with ST", "\x1B]8;;http://go.dev\x1B\\go\x1B]8;;\x1B\\", "go"},
+		{"link with id", "a\x1B]8;id=1;http://go.dev\x07b\x1B]8;;\x07c", "abc"},
+		{"other OSC", "\x1B]7;file://host/tmp\x07", "\x1B]7;file://host/tmp\x07"},
+	}
+
+	for _, v := range tc {
+		if got := stripHyperlinks(v.diff); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestBuildCapsXTGETTCAP(t *testing.T) {
+	rgb := "\x1BP1+r524742=382f382f38\x1B\\"
+	tn := "\x1BP1+r544e=787465726d2d6b69747479\x1B\\"
+	co := "\x1BP1+r436f=323536\x1B\\"
+	caps := []tCap{
+		{label: "XTGETTCAP RGB", query: "\x1bP+q524742\x1b\\", resp: tResp{response: rgb}},
+		{label: "XTGETTCAP TN", query: "\x1bP+q544e\x1b\\", resp: tResp{response: tn}},
+		{label: "XTGETTCAP Co", query: "\x1bP+q436f\x1b\\", resp: tResp{response: co}},
+	}
+
+	conf := &Config{caps: make(map[int]string)}
+	conf.buildCaps(caps)
+
+	if got := conf.caps[terminal.DCS_XTGETTCAP]; got != rgb+tn+co {
+		t.Errorf("XTGETTCAP expect all replies, got %q\n", got)
+	}
+	if got := terminal.TerminalColors(conf.caps); got != terminal.ColorsTrue {
+		t.Errorf("colors expect %d, got %d\n", terminal.ColorsTrue, got)
+	}
+}
+
+func TestSyncFrame(t *testing.T) {
+	tc := []struct {
+		label  string
+		resp   string
+		expect string
+	}{
+		{"not supported", "", "frame"},
+		{"supported", "\x1B[?2026;2$y", "\x1B[?2026hframe\x1B[?2026l"},
+	}
+
+	for _, v := range tc {
+		caps := []tCap{{label: "Synchronized output", query: "\x1b[?2026$p", resp: tResp{response: v.resp}}}
+		conf := &Config{caps: make(map[int]string)}
+		conf.buildCaps(caps)
+
+		sc := &STMClient{syncOutput: terminal.SyncOutputSupported(conf.caps)}
+		if got := sc.syncFrame("frame"); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestNewSessionCommand(t *testing.T) {
+	tc := []struct {
+		label   string
+		seq     string
+		options []string
+		expect  string
+		ok      bool
+	}{
+		{"no report", "", nil, "", false},
+		{"report", "\x1B]7;file://host/home/ide\x07", nil, "apsh --cwd /home/ide ide@host", true},
+		{"quote", "\x1B]7;file://host/tmp/it's\x07", nil, "apsh --cwd '/tmp/it'\\''s' ide@host", true},
+		{
+			"options", "\x1B]7;file://host/home/ide\x07", []string{"-p", "8200", "-i", "/home/me/my key", "-A"},
+			"apsh -p 8200 -i '/home/me/my key' -A --cwd /home/ide ide@host", true,
+		},
+	}
+
+	for _, v := range tc {
+		sc := &STMClient{destination: "ide@host", options: v.options}
+		sc.localFramebuffer = terminal.NewEmulator3(80, 40, 0)
+		sc.localFramebuffer.HandleStream(v.seq)
+
+		got, ok := sc.newSessionCommand()
+		if got != v.expect || ok != v.ok {
+			t.Errorf("%s expect (%q,%t), got (%q,%t)\n", v.label, v.expect, v.ok, got, ok)
+		}
+	}
+}
+
+func TestSessionOptions(t *testing.T) {
+	tc := []struct {
+		label  string
+		args   []string
+		expect []string
+	}{
+		{"no option", []string{"usr@host"}, []string{}},
+		{
+			"options", []string{"-p", "8200", "-i", "id_ed25519", "-A", "-L", "80:web:80", "usr@host", "--", "htop"},
+			[]string{"-p", "8200", "-i", "id_ed25519", "-A", "-L", "80:web:80"},
+		},
+		{"remove cwd", []string{"--cwd", "/tmp", "-A", "-cwd=/home", "usr@host"}, []string{"-A"}},
+		{"end of options", []string{"-A", "--", "usr@host"}, []string{"-A"}},
+	}
+
+	for _, v := range tc {
+		conf, _, err := parseFlags("prog", v.args)
+		if err != nil {
+			t.Fatalf("%s expect nil error, got %s\n", v.label, err)
+		}
+		if !slices.Equal(conf.options, v.expect) {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, conf.options)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ericwq/aprilsh/statesync"
+)
+
+// how the clipboard write (OSC 52) from server is handled
+const (
+	clipboardAllow = iota // forward it to local terminal
+	clipboardDeny         // drop it
+	clipboardAsk          // wait for user's confirmation
+)
+
+var clipboardModes = []string{"allow", "deny", "ask"}
+
+const clipboardMaxSize = 1024 * 1024 // default max bytes of clipboard write
+
+// how the OSC 52 sequence is wrapped for local terminal
+const (
+	clipboardRaw    = iota // write the sequence as is
+	clipboardScreen        // GNU screen: split into DCS chunks
+)
+
+// GNU screen limits the length of control string, the sequence is split into
+// DCS chunks. screen passes the content of each chunk to the outer terminal.
+const screenChunkSize = 512
+
+// clipboard applies the clipboard policy to the clipboard events.
+type clipboard struct {
+	pending *statesync.Event // clipboard write waiting for user's confirmation
+	mode    int
+	maxSize int  // max bytes of the decoded clipboard data
+	wrap    int  // how the sequence is wrapped
+	read    bool // the local clipboard can be read by server
+}
+
+// choose the wrapping of OSC 52 sequence for local terminal.
+func clipboardWrap(term string, tmux bool) int {
+	if strings.HasPrefix(term, "screen") && !tmux {
+		return clipboardScreen
+	}
+	return clipboardRaw
+}
+
+// build the OSC 52 sequence for local terminal, data is base64 encoded.
+func clipboardSequence(wrap int, selection, data string) string {
+	seq := fmt.Sprintf("\x1B]52;%s;%s\x07", selection, data)
+	if wrap != clipboardScreen {
+		return seq
+	}
+
+	var b strings.Builder
+	for len(seq) > 0 {
+		n := min(len(seq), screenChunkSize)
+		fmt.Fprintf(&b, "\x1BP%s\x1B\\", seq[:n])
+		seq = seq[n:]
+	}
+	return b.String()
+}
+
+// apply the policy to the clipboard event. return the sequence for local
+// terminal and the notice for user, both may be empty.
+func (c *clipboard) handle(e statesync.Event) (seq string, notice string) {
+	if c.mode == clipboardDeny {
+		return "", ""
+	}
+
+	if e.Data == "?" {
+		if !c.read {
+			return "", ""
+		}
+		return clipboardSequence(c.wrap, e.Selection, e.Data), ""
+	}
+
+	if size := base64.StdEncoding.DecodedLen(len(e.Data)); size > c.maxSize {
+		return "", fmt.Sprintf("Clipboard write of %d bytes exceeds the limit %d bytes.", size, c.maxSize)
+	}
+
+	if c.mode == clipboardAsk {
+		c.pending = &e
+		return "", fmt.Sprintf("Clipboard write of %d bytes is pending, escape key then \"y\" accepts it.",
+			base64.StdEncoding.DecodedLen(len(e.Data)))
+	}
+	return clipboardSequence(c.wrap, e.Selection, e.Data), ""
+}
+
+// accept the pending clipboard write, return the sequence for local terminal.
+func (c *clipboard) accept() (seq string, ok bool) {
+	if c.pending == nil {
+		return "", false
+	}
+	seq = clipboardSequence(c.wrap, c.pending.Selection, c.pending.Data)
+	c.pending = nil
+	return seq, true
+}
+
+// copy the text to the clipboard of local terminal.
+func (c *clipboard) copy(text string) string {
+	return clipboardSequence(c.wrap, "c", base64.StdEncoding.EncodeToString([]byte(text)))
+}
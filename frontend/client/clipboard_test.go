@@ -0,0 +1,91 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ericwq/aprilsh/statesync"
+)
+
+func TestClipboardHandle(t *testing.T) {
+	write := statesync.Event{Kind: statesync.EventClipboard, Selection: "c", Data: "YXByaWxzaAo="}
+	query := statesync.Event{Kind: statesync.EventClipboard, Selection: "c", Data: "?"}
+	tc := []struct {
+		label   string
+		c       clipboard
+		e       statesync.Event
+		seq     string
+		notice  string
+		pending bool
+	}{
+		{"allow write", clipboard{mode: clipboardAllow, maxSize: clipboardMaxSize}, write, "\x1B]52;c;YXByaWxzaAo=\a", "", false},
+		{"deny write", clipboard{mode: clipboardDeny, maxSize: clipboardMaxSize}, write, "", "", false},
+		{
+			"too large", clipboard{mode: clipboardAllow, maxSize: 4}, write,
+			"", "Clipboard write of 9 bytes exceeds the limit 4 bytes.", false,
+		},
+		{
+			"ask write", clipboard{mode: clipboardAsk, maxSize: clipboardMaxSize}, write,
+			"", "Clipboard write of 9 bytes is pending, escape key then \"y\" accepts it.", true,
+		},
+		{"query not permitted", clipboard{mode: clipboardAllow, maxSize: clipboardMaxSize}, query, "", "", false},
+		{"query permitted", clipboard{mode: clipboardAllow, maxSize: clipboardMaxSize, read: true}, query, "\x1B]52;c;?\a", "", false},
+		{"query denied", clipboard{mode: clipboardDeny, read: true}, query, "", "", false},
+	}
+
+	for _, v := range tc {
+		seq, notice := v.c.handle(v.e)
+		if seq != v.seq || notice != v.notice {
+			t.Errorf("%s expect %q %q, got %q %q\n", v.label, v.seq, v.notice, seq, notice)
+		}
+		if got := v.c.pending != nil; got != v.pending {
+			t.Errorf("%s expect pending %t, got %t\n", v.label, v.pending, got)
+		}
+	}
+}
+
+func TestClipboardAccept(t *testing.T) {
+	c := clipboard{mode: clipboardAsk, maxSize: clipboardMaxSize}
+	if _, ok := c.accept(); ok {
+		t.Errorf("#test accept expect nothing pending, got %v\n", c.pending)
+	}
+
+	c.handle(statesync.Event{Kind: statesync.EventClipboard, Selection: "p", Data: "YXByaWxzaAo="})
+	seq, ok := c.accept()
+	if expect := "\x1B]52;p;YXByaWxzaAo=\a"; !ok || seq != expect {
+		t.Errorf("#test accept expect %q, got %q\n", expect, seq)
+	}
+	if c.pending != nil {
+		t.Errorf("#test accept expect no pending write, got %v\n", c.pending)
+	}
+}
+
+func TestClipboardSequence(t *testing.T) {
+	tc := []struct {
+		label  string
+		term   string
+		tmux   bool
+		data   string
+		expect string
+	}{
+		{"xterm", "xterm-256color", false, "YQ==", "\x1B]52;c;YQ==\a"},
+		{"tmux", "screen-256color", true, "YQ==", "\x1B]52;c;YQ==\a"},
+		{"screen", "screen", false, "YQ==", "\x1BP\x1B]52;c;YQ==\a\x1B\\"},
+		{
+			"screen chunks", "screen.xterm-256color", false, strings.Repeat("A", 1000),
+			"\x1BP\x1B]52;c;" + strings.Repeat("A", screenChunkSize-7) + "\x1B\\" +
+				"\x1BP" + strings.Repeat("A", 1000-screenChunkSize+7) + "\a\x1B\\",
+		},
+	}
+
+	for _, v := range tc {
+		got := clipboardSequence(clipboardWrap(v.term, v.tmux), "c", v.data)
+		if got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
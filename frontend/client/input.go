@@ -0,0 +1,38 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+// find the first mouse report or kitty key event in user input. mouse is
+// true if the local terminal reports mouse in enc encoding, kitty is true if
+// the local terminal reports keys in kitty keyboard protocol. i is the
+// position of the event, n is the length of it.
+func nextEvent(buf string, mouse bool, enc terminal.MouseTrackingEnc, kitty bool) (i, n int, a terminal.ActOn, ok bool) {
+	for i < len(buf) {
+		j := strings.Index(buf[i:], "\x1B[")
+		if j < 0 {
+			break
+		}
+		i += j
+		if mouse {
+			if m, n, ok := terminal.ParseMouse(buf[i:], enc); ok {
+				return i, n, m, true
+			}
+		}
+		if kitty {
+			// the legacy sequences without event type are kept as keystrokes
+			if k, n, ok := terminal.ParseKey(buf[i:]); ok && (k.Final == 'u' || strings.Contains(buf[i:i+n], ":")) {
+				return i, n, k, true
+			}
+		}
+		i++
+	}
+	return 0, 0, nil, false
+}
@@ -0,0 +1,45 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+func TestNextEvent(t *testing.T) {
+	tc := []struct {
+		label  string
+		buf    string
+		mouse  bool
+		kitty  bool
+		i      int
+		n      int
+		expect terminal.ActOn
+	}{
+		{"only keys", "ls -l\r\x1B[A", true, true, 0, 0, nil},
+		{"mouse first", "\x1B[<0;3;4Mls", true, false, 0, 9, terminal.Mouse{X: 3, Y: 4}},
+		{"keys first", "\x1B[Ak\x1B[<0;3;4m", true, false, 4, 9, terminal.Mouse{X: 3, Y: 4, Release: true}},
+		{"mouse disabled", "\x1B[<0;3;4M", false, true, 0, 0, nil},
+		{
+			"kitty key", "a\x1B[97;5u", false, true, 1, 7,
+			terminal.Key{Code: 'a', Mods: terminal.KeyCtrl, Event: terminal.KeyPress, Final: 'u'},
+		},
+		{
+			"kitty release", "\x1B[1;1:3A", false, true, 0, 8,
+			terminal.Key{Code: 1, Event: terminal.KeyRelease, Final: 'A'},
+		},
+		{"legacy cursor key", "\x1B[1;5A", false, true, 0, 0, nil},
+		{"kitty disabled", "\x1B[97;5u", true, false, 0, 0, nil},
+	}
+
+	for _, v := range tc {
+		i, n, a, ok := nextEvent(v.buf, v.mouse, terminal.MouseTrackingEnc_SGR, v.kitty)
+		if i != v.i || n != v.n || a != v.expect || ok != (v.expect != nil) {
+			t.Errorf("%s expect %d %d %v, got %d %d %v\n", v.label, v.i, v.n, v.expect, i, n, a)
+		}
+	}
+}
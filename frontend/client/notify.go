@@ -0,0 +1,86 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// the notification sequence understood by local terminal
+const (
+	notifyOverlay = iota // not supported, show it in the notification bar
+	notifyOSC9           // OSC 9: iTerm2, WezTerm, Windows Terminal, ConEmu
+	notifyOSC777         // OSC 777: rxvt-unicode, foot, VTE, ghostty
+	notifyOSC99          // OSC 99: kitty
+)
+
+// choose the notification sequence by the terminal name (queried by
+// XTGETTCAP TN, or TERM) and TERM_PROGRAM. terminfo has nothing about
+// notification, so we hardcode the known terminals.
+func notifyStyle(name, program string, vte bool) int {
+	switch {
+	case strings.Contains(name, "kitty"):
+		return notifyOSC99
+	case strings.HasPrefix(name, "foot"), strings.HasPrefix(name, "rxvt"), strings.Contains(name, "ghostty"), vte:
+		return notifyOSC777
+	case program == "iTerm.app", program == "WezTerm", strings.HasPrefix(name, "wezterm"):
+		return notifyOSC9
+	}
+	return notifyOverlay
+}
+
+// get the terminal name from the response of XTGETTCAP TN, such as
+// "\x1BP1+r544e=787465726d2d6b69747479\x1B\\".
+func parseTermName(resp string) string {
+	_, value, ok := strings.Cut(resp, "=")
+	if !ok {
+		return ""
+	}
+	value, _, _ = strings.Cut(value, "\x1B")
+	name, err := hex.DecodeString(value)
+	if err != nil {
+		return ""
+	}
+	return string(name)
+}
+
+// remove the control characters, so the text can't break out of the
+// notification sequence.
+func sanitizeNotify(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || (0x7F <= r && r < 0xA0) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// build the notification sequence for local terminal, return empty string if
+// the terminal doesn't support it.
+func notifySequence(style int, title, body string) string {
+	title, body = sanitizeNotify(title), sanitizeNotify(body)
+	switch style {
+	case notifyOSC9:
+		if title != "" && body != "" {
+			return fmt.Sprintf("\x1B]9;%s: %s\x1B\\", title, body)
+		}
+		return fmt.Sprintf("\x1B]9;%s%s\x1B\\", title, body)
+	case notifyOSC777:
+		return fmt.Sprintf("\x1B]777;notify;%s;%s\x1B\\", strings.ReplaceAll(title, ";", ","), body)
+	case notifyOSC99:
+		var b strings.Builder
+		if body == "" {
+			fmt.Fprintf(&b, "\x1B]99;e=1;%s\x1B\\", base64.StdEncoding.EncodeToString([]byte(title)))
+		} else {
+			fmt.Fprintf(&b, "\x1B]99;i=aprilsh:d=0:e=1;%s\x1B\\", base64.StdEncoding.EncodeToString([]byte(title)))
+			fmt.Fprintf(&b, "\x1B]99;i=aprilsh:p=body:e=1;%s\x1B\\", base64.StdEncoding.EncodeToString([]byte(body)))
+		}
+		return b.String()
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestNotifyStyle(t *testing.T) {
+	tc := []struct {
+		label   string
+		name    string
+		program string
+		vte     bool
+		expect  int
+	}{
+		{"kitty", "xterm-kitty", "", false, notifyOSC99},
+		{"foot", "foot", "", false, notifyOSC777},
+		{"gnome terminal", "xterm-256color", "", true, notifyOSC777},
+		{"iTerm2", "xterm-256color", "iTerm.app", false, notifyOSC9},
+		{"WezTerm", "xterm-256color", "WezTerm", false, notifyOSC9},
+		{"xterm", "xterm-256color", "", false, notifyOverlay},
+	}
+
+	for _, v := range tc {
+		if got := notifyStyle(v.name, v.program, v.vte); got != v.expect {
+			t.Errorf("%s expect %d, got %d\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestParseTermName(t *testing.T) {
+	tc := []struct {
+		label  string
+		resp   string
+		expect string
+	}{
+		{"kitty", "\x1BP1+r544e=787465726d2d6b69747479\x1B\\", "xterm-kitty"},
+		{"invalid request", "\x1BP0+r\x1B\\", ""},
+		{"bad hex", "\x1BP1+r544e=7x\x1B\\", ""},
+	}
+
+	for _, v := range tc {
+		if got := parseTermName(v.resp); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestNotifySequence(t *testing.T) {
+	tc := []struct {
+		label  string
+		style  int
+		title  string
+		body   string
+		expect string
+	}{
+		{"OSC 9", notifyOSC9, "make", "done", "\x1B]9;make: done\x1B\\"},
+		{"OSC 9 body only", notifyOSC9, "", "done", "\x1B]9;done\x1B\\"},
+		{"OSC 777", notifyOSC777, "a;b", "done", "\x1B]777;notify;a,b;done\x1B\\"},
+		{"OSC 99 title only", notifyOSC99, "done", "", "\x1B]99;e=1;ZG9uZQ==\x1B\\"},
+		{
+			"OSC 99", notifyOSC99, "make", "done",
+			"\x1B]99;i=aprilsh:d=0:e=1;bWFrZQ==\x1B\\\x1B]99;i=aprilsh:p=body:e=1;ZG9uZQ==\x1B\\",
+		},
+		{"control characters", notifyOSC9, "", "a\x1B]0;x\x07b", "\x1B]9;a ]0;x b\x1B\\"},
+		{"not supported", notifyOverlay, "make", "done", ""},
+	}
+
+	for _, v := range tc {
+		if got := notifySequence(v.style, v.title, v.body); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ericwq/aprilsh/statesync"
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+// scrollback key actions
+const (
+	sbLineUp = iota + 1
+	sbLineDown
+	sbHalfUp
+	sbHalfDown
+	sbPageUp
+	sbPageDown
+	sbTop
+	sbBottom
+	sbSearch
+	sbNextMatch
+	sbPrevMatch
+	sbPrevPrompt
+	sbNextPrompt
+	sbCopyOutput
+	sbQuit
+)
+
+// key sequences in scrollback view, the longer sequence must be placed before
+// its prefix.
+var scrollbackKeys = []struct {
+	seq    string
+	action int
+}{
+	{"\x1B[A", sbLineUp}, {"\x1BOA", sbLineUp}, {"k", sbLineUp}, {"\x19", sbLineUp},
+	{"\x1B[B", sbLineDown}, {"\x1BOB", sbLineDown}, {"j", sbLineDown}, {"\x05", sbLineDown},
+	{"\x1B[5~", sbPageUp}, {"b", sbPageUp}, {"\x02", sbPageUp},
+	{"\x1B[6~", sbPageDown}, {"f", sbPageDown}, {" ", sbPageDown}, {"\x06", sbPageDown},
+	{"u", sbHalfUp}, {"\x15", sbHalfUp},
+	{"d", sbHalfDown}, {"\x04", sbHalfDown},
+	{"\x1B[H", sbTop}, {"\x1BOH", sbTop}, {"g", sbTop},
+	{"\x1B[F", sbBottom}, {"\x1BOF", sbBottom}, {"G", sbBottom},
+	{"/", sbSearch}, {"n", sbNextMatch}, {"N", sbPrevMatch},
+	{"{", sbPrevPrompt}, {"}", sbNextPrompt}, {"o", sbCopyOutput},
+	{"q", sbQuit}, {"\x1B", sbQuit},
+}
+
+// scrollback is the client side view of the scrollback history kept by
+// server. It caches the rows got from server, and requests more rows when
+// the view moves out of them. Only one request is outstanding at a time,
+// since server only keeps the last reply.
+//
+// The search is incremental: every change of the query is sent to server with
+// the next request, server searches all its rows and returns the matches. The
+// view jumps to the match nearest to where the search starts.
+//
+// The shell commands marked by OSC 133 are requested with the first request,
+// so the view can jump between the prompts. The output of last finished
+// command is requested on demand, it's copied to the clipboard by client.
+type scrollback struct {
+	rows      []string                 // rows got from server
+	matches   []terminal.TextMatch     // search result of searched
+	commands  []terminal.ShellCommand  // shell commands on server
+	cached    statesync.HistoryRequest // the request of cached rows
+	last      statesync.HistoryRequest // the last request
+	clip      string                   // command output waiting to be copied
+	notice    string                   // message shown with the position indicator
+	query     string                   // search query typed by user
+	pattern   string                   // regular expression of query
+	searched  string                   // pattern of the matches
+	start     int                      // row number of rows[0], see statesync.HistoryRequest
+	total     int                      // number of history rows on server, -1 means unknown
+	offset    int                      // number of rows the view is above the screen top
+	current   int                      // index of current match, -1 means none
+	origin    int                      // offset when the search starts
+	nCols     int
+	nRows     int
+	pending   bool // waiting for the reply of last request
+	searching bool // the last request searches pattern
+	prompt    bool // user is typing the query
+	regex     bool // query is regular expression instead of plain text
+	invalid   bool // query is not a valid regular expression
+	wantCmds  bool // commands is not requested yet
+	wantOut   bool // user asks for the output of last command
+	copied    bool // clip is ready
+	dirty     bool // view need to be rendered
+}
+
+// create the scrollback view, lastID is the id of previous request.
+func newScrollback(nCols, nRows int, lastID uint32) *scrollback {
+	sb := &scrollback{}
+	sb.nCols = nCols
+	sb.nRows = nRows
+	sb.total = -1
+	sb.current = -1
+	sb.wantCmds = true
+	sb.last.ID = lastID
+	sb.dirty = true
+	return sb
+}
+
+// handle the user input, return true if user quit the scrollback view.
+func (sb *scrollback) handleInput(buf string) (quit bool) {
+	for len(buf) > 0 {
+		if sb.prompt {
+			buf = sb.editQuery(buf)
+			continue
+		}
+
+		n, action := 1, 0 // skip the unknown byte
+		for _, k := range scrollbackKeys {
+			if strings.HasPrefix(buf, k.seq) {
+				n, action = len(k.seq), k.action
+				break
+			}
+		}
+		buf = buf[n:]
+		if sb.notice != "" {
+			sb.notice = ""
+			sb.dirty = true
+		}
+
+		switch action {
+		case sbLineUp:
+			sb.scroll(1)
+		case sbLineDown:
+			sb.scroll(-1)
+		case sbHalfUp:
+			sb.scroll(sb.nRows / 2)
+		case sbHalfDown:
+			sb.scroll(-sb.nRows / 2)
+		case sbPageUp:
+			sb.scroll(sb.nRows)
+		case sbPageDown:
+			sb.scroll(-sb.nRows)
+		case sbTop:
+			sb.scroll(sb.limit())
+		case sbBottom:
+			sb.scroll(-sb.offset)
+		case sbSearch:
+			sb.prompt = true
+			sb.query = ""
+			sb.origin = sb.offset
+			sb.setPattern()
+		case sbNextMatch: // the older one
+			sb.jump(sb.current - 1)
+		case sbPrevMatch:
+			sb.jump(sb.current + 1)
+		case sbPrevPrompt:
+			sb.jumpPrompt(true)
+		case sbNextPrompt:
+			sb.jumpPrompt(false)
+		case sbCopyOutput:
+			sb.wantOut = true
+		case sbQuit:
+			return true
+		}
+	}
+	return false
+}
+
+// edit the query with the first key of buf, return the rest of buf. Enter
+// finishes the editing, Esc cancels the search, Ctrl-R toggles the regular
+// expression mode.
+func (sb *scrollback) editQuery(buf string) string {
+	switch buf[0] {
+	case '\r', '\n':
+		sb.prompt = false
+		sb.dirty = true
+		return buf[1:]
+	case '\x1B':
+		// ignore the function keys
+		for _, k := range scrollbackKeys {
+			if len(k.seq) > 1 && strings.HasPrefix(buf, k.seq) {
+				return buf[len(k.seq):]
+			}
+		}
+		sb.prompt = false
+		sb.query = ""
+		sb.scroll(sb.origin - sb.offset)
+		sb.setPattern()
+		return buf[1:]
+	case '\x7F', '\b':
+		_, size := utf8.DecodeLastRuneInString(sb.query)
+		sb.query = sb.query[:len(sb.query)-size]
+	case '\x12':
+		sb.regex = !sb.regex
+	case '\x15':
+		sb.query = ""
+	default:
+		r, size := utf8.DecodeRuneInString(buf)
+		if r >= ' ' && r != utf8.RuneError {
+			sb.query += buf[:size]
+		}
+		sb.setPattern()
+		return buf[size:]
+	}
+	sb.setPattern()
+	return buf[1:]
+}
+
+// convert query into pattern. plain text query is case insensitive unless it
+// contains upper case letter. the pattern is kept if query is not valid.
+func (sb *scrollback) setPattern() {
+	pattern := sb.query
+	if !sb.regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if pattern != "" && strings.ToLower(sb.query) == sb.query {
+		pattern = "(?i)" + pattern
+	}
+
+	_, err := regexp.Compile(pattern)
+	sb.invalid = err != nil
+	if err == nil {
+		sb.pattern = pattern
+	}
+	sb.dirty = true
+}
+
+// select the i-th match, move the view to it if it's out of view.
+func (sb *scrollback) jump(i int) {
+	if i < 0 || i >= len(sb.matches) {
+		return
+	}
+	sb.current = i
+	sb.dirty = true
+
+	// place the match in the middle of view
+	row := sb.matches[i].Row
+	if row < -sb.offset || row >= -sb.offset+sb.nRows {
+		sb.scroll(sb.nRows/2 - row - sb.offset)
+	}
+}
+
+// select the nearest match at or above the bottom of view where the search
+// starts, or the first match if there is none.
+func (sb *scrollback) jumpNearest() {
+	sb.current = -1
+	i := 0
+	for j, m := range sb.matches {
+		if m.Row < -sb.origin+sb.nRows {
+			i = j
+		}
+	}
+	sb.jump(i)
+}
+
+// move the top of view to the previous (older) prompt or the next prompt.
+func (sb *scrollback) jumpPrompt(older bool) {
+	top := -sb.offset
+	if older {
+		for i := len(sb.commands) - 1; i >= 0; i-- {
+			if prompt := sb.commands[i].Prompt; prompt < top {
+				sb.scroll(top - prompt)
+				return
+			}
+		}
+	} else {
+		for _, cmd := range sb.commands {
+			if cmd.Prompt > top {
+				sb.scroll(top - cmd.Prompt)
+				return
+			}
+		}
+	}
+}
+
+// return the output of last command copied by user, only once.
+func (sb *scrollback) takeClip() (clip string, ok bool) {
+	clip, ok = sb.clip, sb.copied
+	sb.clip, sb.copied = "", false
+	return clip, ok
+}
+
+// move the view up (positive delta) or down (negative delta).
+func (sb *scrollback) scroll(delta int) {
+	offset := min(max(sb.offset+delta, 0), sb.limit())
+	if offset != sb.offset {
+		sb.offset = offset
+		sb.dirty = true
+	}
+}
+
+// the max offset of view. before the first reply, allow one page up.
+func (sb *scrollback) limit() int {
+	if sb.total < 0 {
+		return sb.nRows
+	}
+	return sb.total
+}
+
+// return the history request if the view is not covered by the cached rows,
+// or the pattern, commands or output is wanted. the request includes one more
+// page above and below the view.
+func (sb *scrollback) request() (req statesync.HistoryRequest, ok bool) {
+	top := -sb.offset
+	search := sb.pattern != sb.searched
+	if sb.pending || (!search && !sb.wantCmds && !sb.wantOut && sb.total >= 0 &&
+		top >= max(sb.cached.Start, -sb.total) && top+sb.nRows <= sb.cached.Start+sb.cached.Count) {
+		return req, false
+	}
+
+	sb.last = statesync.HistoryRequest{ID: sb.last.ID + 1, Start: top - sb.nRows, Count: sb.nRows * 3,
+		Commands: sb.wantCmds, Output: sb.wantOut}
+	if search {
+		sb.last.Pattern = sb.pattern
+	}
+	sb.searching = search
+	sb.pending = true
+	return sb.last, true
+}
+
+// apply the reply from server, return true if it's the reply of last request.
+func (sb *scrollback) apply(reply statesync.HistoryReply) bool {
+	if !sb.pending || reply.ID != sb.last.ID {
+		return false
+	}
+
+	sb.pending = false
+	sb.cached = sb.last
+	sb.rows = reply.Rows
+	sb.start = reply.Start
+	sb.total = reply.Total
+	sb.offset = min(sb.offset, sb.total)
+	sb.dirty = true
+
+	if sb.searching {
+		sb.searched = sb.last.Pattern
+		sb.matches = reply.Matches
+		sb.jumpNearest()
+	}
+	if sb.last.Commands {
+		sb.commands = reply.Commands
+		sb.wantCmds = false
+	}
+	if sb.last.Output {
+		sb.wantOut = false
+		sb.clip, sb.copied = reply.Output, reply.Output != ""
+		sb.notice = "no output"
+		if sb.copied {
+			sb.notice = fmt.Sprintf("copied %d bytes", len(sb.clip))
+		}
+	}
+	return true
+}
+
+// render the view into a new emulator, which is used by Display.NewFrame().
+func (sb *scrollback) frame() *terminal.Emulator {
+	emu := terminal.NewEmulator3(sb.nCols, sb.nRows, 0)
+
+	// hide cursor, disable auto wrap, the row is truncated if it's too long.
+	var b strings.Builder
+	b.WriteString("\x1B[?25l\x1B[?7l")
+	for i := 0; i < sb.nRows; i++ {
+		idx := -sb.offset + i - sb.start
+		if 0 <= idx && idx < len(sb.rows) {
+			fmt.Fprintf(&b, "\x1B[%d;1H%s\x1B[0m", i+1, sb.rows[idx])
+		}
+	}
+
+	emu.HandleStream(b.String())
+	b.Reset()
+
+	// highlight the matches in view, the current one is yellow.
+	for i, m := range sb.matches {
+		y := m.Row + sb.offset
+		if y < 0 || y >= sb.nRows {
+			continue
+		}
+		rend := terminal.NewRenditions(7)
+		if i == sb.current {
+			rend = terminal.Renditions{}
+			rend.SetForegroundColor(0)
+			rend.SetBackgroundColor(3)
+		}
+		for x := m.StartCol; x < min(m.EndCol, sb.nCols); x++ {
+			emu.GetCellPtr(y, x).SetRenditions(rend)
+		}
+	}
+
+	// position indicator at the top right corner
+	total := "?"
+	if sb.total >= 0 {
+		total = fmt.Sprintf("%d", sb.total)
+	}
+	indicator := fmt.Sprintf("[%d/%s]", sb.offset, total)
+	if sb.searched != "" {
+		if len(sb.matches) == 0 {
+			indicator += " no match"
+		} else {
+			indicator += fmt.Sprintf(" %d/%d", len(sb.matches)-sb.current, len(sb.matches))
+		}
+	}
+	for _, cmd := range sb.commands {
+		if cmd.Prompt == -sb.offset && cmd.Status >= 0 {
+			indicator += fmt.Sprintf(" exit %d", cmd.Status)
+		}
+	}
+	if sb.notice != "" {
+		indicator += " " + sb.notice
+	}
+	fmt.Fprintf(&b, "\x1B[1;%dH\x1B[7m%s\x1B[0m", max(sb.nCols-len(indicator)+1, 1), indicator)
+
+	// search prompt at the bottom row, with cursor at the end of query.
+	if sb.prompt {
+		prompt := "/"
+		if sb.regex {
+			prompt = "regex/"
+		}
+		if sb.invalid {
+			prompt = "invalid " + prompt
+		}
+		fmt.Fprintf(&b, "\x1B[%d;1H\x1B[2K\x1B[7m%s\x1B[0m%s\x1B[?25h", sb.nRows, prompt, sb.query)
+	}
+
+	emu.HandleStream(b.String())
+	sb.dirty = false
+	return emu
+}
@@ -0,0 +1,215 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ericwq/aprilsh/statesync"
+)
+
+func TestScrollback(t *testing.T) {
+	// server keeps 100 lines: 0~96 in history, 97~99 on screen (the last row is empty)
+	server, _ := statesync.NewComplete(20, 4, 200)
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "line %d\r\n", i)
+	}
+	server.Act(b.String())
+
+	sb := newScrollback(20, 4, 7)
+
+	// reply the request like server does
+	sync := func() {
+		if req, ok := sb.request(); ok {
+			server.ReplyHistory(req)
+			sb.apply(server.GetHistory())
+		}
+	}
+
+	tc := []struct {
+		label  string
+		input  string
+		offset int
+		row1   string // the second row of view
+		quit   bool
+	}{
+		{"enter scrollback", "", 0, "line 98", false},
+		{"line up", "k", 1, "line 97", false},
+		{"page up", "\x1B[5~", 5, "line 93", false},
+		{"half page down", "d", 3, "line 95", false},
+		{"unknown key", "x", 3, "line 95", false},
+		{"top", "g", 97, "line 1", false},
+		{"over the top", "k", 97, "line 1", false},
+		{"bottom", "G", 0, "line 98", false},
+		{"over the bottom", "j\x1B[B", 0, "line 98", false},
+		{"quit", "b\x1B", 4, "line 94", true},
+	}
+
+	sync()
+	for _, v := range tc {
+		quit := sb.handleInput(v.input)
+		sync()
+
+		if quit != v.quit || sb.offset != v.offset {
+			t.Errorf("%s expect quit=%t offset=%d, got quit=%t offset=%d\n", v.label, v.quit, v.offset, quit, sb.offset)
+		}
+
+		emu := sb.frame()
+		_, rows := emu.GetRowsString(1, 1)
+		if len(rows) != 1 || rows[0] != v.row1 {
+			t.Errorf("%s expect row %q, got %q\n", v.label, v.row1, rows)
+		}
+	}
+
+	// the request id continues from the previous one
+	if sb.last.ID <= 7 {
+		t.Errorf("expect request id greater than %d, got %d\n", 7, sb.last.ID)
+	}
+
+	// stale reply is ignored
+	sb.scroll(-sb.offset)
+	req, _ := sb.request()
+	if sb.apply(statesync.HistoryReply{ID: req.ID - 1}) {
+		t.Errorf("expect stale reply is ignored, got applied\n")
+	}
+}
+
+func TestScrollbackSearch(t *testing.T) {
+	// server keeps 100 lines: "line 0" ~ "line 96" in history, "line 97" ~ "line 99"
+	// on screen, "Line 50" is the upper case one.
+	server, _ := statesync.NewComplete(20, 4, 200)
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		if i == 50 {
+			fmt.Fprintf(&b, "Line %d\r\n", i)
+		} else {
+			fmt.Fprintf(&b, "line %d\r\n", i)
+		}
+	}
+	server.Act(b.String())
+
+	sb := newScrollback(20, 4, 0)
+	sync := func() {
+		for {
+			req, ok := sb.request()
+			if !ok {
+				return
+			}
+			server.ReplyHistory(req)
+			sb.apply(server.GetHistory())
+		}
+	}
+
+	tc := []struct {
+		label   string
+		input   string
+		pattern string
+		matches int
+		row     int // row of current match, -1 means none
+		prompt  bool
+	}{
+		{"start search", "/", "", 0, -1, true},
+		{"incremental", "7", "(?i)7", 20, 0, true},
+		{"more query", "7", "(?i)77", 1, -20, true},
+		{"backspace", "\x7F", "(?i)7", 20, 0, true},
+		{"finish query", "\r", "(?i)7", 20, 0, false},
+		{"next match", "n", "(?i)7", 20, -10, false},
+		{"previous match", "NN", "(?i)7", 20, 0, false},
+		{"smart case", "/L", "L", 1, -47, true},
+		{"regular expression", "\x15\x12E 9[89]$", "E 9[89]$", 0, -1, true},
+		{"nearest match", "\x15e 9[89]$", "(?i)e 9[89]$", 2, 1, true},
+		{"invalid regular expression", "\r/(", "", 0, -1, true},
+		{"function key ignored", "\x1B[A", "", 0, -1, true},
+		{"cancel", "\x1B", "", 0, -1, false},
+	}
+
+	sync()
+	for _, v := range tc {
+		sb.handleInput(v.input)
+		sync()
+
+		if sb.pattern != v.pattern || len(sb.matches) != v.matches || sb.prompt != v.prompt {
+			t.Errorf("%s expect pattern=%q matches=%d prompt=%t, got pattern=%q matches=%d prompt=%t\n",
+				v.label, v.pattern, v.matches, v.prompt, sb.pattern, len(sb.matches), sb.prompt)
+		}
+
+		row := -1
+		if sb.current >= 0 {
+			row = sb.matches[sb.current].Row
+			if y := row + sb.offset; y < 0 || y >= sb.nRows {
+				t.Errorf("%s expect match row %d in view, got offset %d\n", v.label, row, sb.offset)
+			}
+		}
+		if row != v.row {
+			t.Errorf("%s expect current match row %d, got %d\n", v.label, v.row, row)
+		}
+	}
+}
+
+func TestScrollbackCommands(t *testing.T) {
+	// three commands, each prints 5 lines. the last command is not finished.
+	server, _ := statesync.NewComplete(20, 4, 200)
+	var b strings.Builder
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(&b, "\x1B]133;A\a$ cmd%d\r\n\x1B]133;C\a", i)
+		for j := 0; j < 5; j++ {
+			fmt.Fprintf(&b, "out%d-%d\r\n", i, j)
+		}
+		if i < 2 {
+			fmt.Fprintf(&b, "\x1B]133;D;%d\a", i)
+		}
+	}
+	server.Act(b.String())
+
+	sb := newScrollback(20, 4, 0)
+	sync := func() {
+		for {
+			req, ok := sb.request()
+			if !ok {
+				return
+			}
+			server.ReplyHistory(req)
+			sb.apply(server.GetHistory())
+		}
+	}
+
+	tc := []struct {
+		label  string
+		input  string
+		row0   string // the first row of view
+		status string // exit status in position indicator
+	}{
+		{"previous prompt", "{", "$ cmd2", ""},
+		{"older prompt", "{", "$ cmd1", "exit 1"},
+		{"oldest prompt", "{{", "$ cmd0", "exit 0"},
+		{"next prompt", "}", "$ cmd1", "exit 1"},
+	}
+
+	sync()
+	for _, v := range tc {
+		sb.handleInput(v.input)
+		sync()
+
+		emu := sb.frame()
+		_, rows := emu.GetRowsString(0, 1)
+		if len(rows) != 1 || !strings.HasPrefix(rows[0], v.row0) || !strings.Contains(rows[0], v.status) {
+			t.Errorf("%s expect row %q with %q, got %q\n", v.label, v.row0, v.status, rows)
+		}
+	}
+
+	// copy the output of last finished command
+	sb.handleInput("o")
+	sync()
+	expect := "out1-0\nout1-1\nout1-2\nout1-3\nout1-4\n"
+	if clip, ok := sb.takeClip(); !ok || clip != expect {
+		t.Errorf("expect clip %q, got %q\n", expect, clip)
+	}
+	if _, ok := sb.takeClip(); ok {
+		t.Errorf("expect clip is taken only once\n")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+// the query of palette colors, default foreground, background and cursor
+// colors of local terminal. the replies are kept as OSC 4 capability, server
+// uses them to answer the color query of application.
+const paletteQuery = "\x1B]4;0;?;1;?;2;?;3;?;4;?;5;?;6;?;7;?;8;?;9;?;10;?;11;?;12;?;13;?;14;?;15;?\x1B\\" +
+	"\x1B]10;?\x1B\\\x1B]11;?\x1B\\\x1B]12;?\x1B\\"
+
+// the reply of paletteQuery, one OSC reply for each color.
+var paletteReply = regexp.MustCompile(`^\x1B\](4;[0-9]+|1[0-2]);`)
+
+// theme remaps the 16 ANSI colors of local terminal, the key is color index.
+type theme map[int]terminal.Color
+
+// read the theme file. each line is a color name and a color specification,
+// separated by space, '=' or ':', such as:
+//
+//	color0  #1d1f21
+//	color1 = rgb:cc/66/66
+//	*.color2: green
+//
+// the color name is color0 to color15, the color specification is the same as
+// OSC 4. empty lines and lines starting with '#' or '!' are ignored.
+func loadTheme(name string) (theme, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := make(theme)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		// the color name ends at the first separator
+		end := strings.IndexAny(line, " \t=:")
+		if end < 0 {
+			return nil, fmt.Errorf("%s:%d: expect color name and color", name, n)
+		}
+		key := strings.TrimLeft(line[:end], "*.")
+		value := strings.TrimSpace(line[end:])
+		if value != "" && (value[0] == '=' || value[0] == ':') {
+			value = strings.TrimSpace(value[1:])
+		}
+
+		idx, err := strconv.Atoi(strings.TrimPrefix(key, "color"))
+		if err != nil || !strings.HasPrefix(key, "color") || idx < 0 || idx > 15 {
+			return nil, fmt.Errorf("%s:%d: unknown color name %q", name, n, line[:end])
+		}
+		c := terminal.ParseColorSpec(value)
+		if !c.Valid() {
+			return nil, fmt.Errorf("%s:%d: invalid color %q", name, n, value)
+		}
+		t[idx] = c
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// return the OSC 4 sequence to set the theme colors. it's also the reply of
+// OSC 4 query of these colors.
+func (t theme) sequence() string {
+	var b strings.Builder
+	for _, idx := range t.indexes() {
+		fmt.Fprintf(&b, "\x1B]4;%d;%s\x1B\\", idx, t[idx])
+	}
+	return b.String()
+}
+
+// return the OSC 104 sequence to reset the theme colors.
+func (t theme) reset() string {
+	if len(t) == 0 {
+		return ""
+	}
+	s := make([]string, 0, len(t))
+	for _, idx := range t.indexes() {
+		s = append(s, strconv.Itoa(idx))
+	}
+	return "\x1B]104;" + strings.Join(s, ";") + "\x1B\\"
+}
+
+func (t theme) indexes() []int {
+	idx := make([]int, 0, len(t))
+	for k := range t {
+		idx = append(idx, k)
+	}
+	slices.Sort(idx)
+	return idx
+}
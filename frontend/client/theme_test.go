@@ -0,0 +1,94 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+func TestLoadTheme(t *testing.T) {
+	tc := []struct {
+		label   string
+		content string
+		expect  theme
+		err     string
+	}{
+		{
+			"kitty and xresources style",
+			"# comment\n! comment\n\ncolor0  #1d1f21\ncolor1 = rgb:cc/66/66\n*.color2: green\n*color15:#ffffff\n",
+			theme{
+				0:  terminal.NewRGBColor(0x1d, 0x1f, 0x21),
+				1:  terminal.NewRGBColor(0xcc, 0x66, 0x66),
+				2:  terminal.NewRGBColor(0, 0x80, 0),
+				15: terminal.NewRGBColor(0xff, 0xff, 0xff),
+			},
+			"",
+		},
+		{"empty file", "", theme{}, ""},
+		{"missing color", "color1\n", nil, ":1: expect color name and color"},
+		{"unknown name", "color16 #000000\n", nil, ":1: unknown color name \"color16\""},
+		{"not color name", "foreground #000000\n", nil, ":1: unknown color name \"foreground\""},
+		{"invalid color", "color1 #00\n", nil, ":1: invalid color \"#00\""},
+	}
+
+	for _, v := range tc {
+		name := filepath.Join(t.TempDir(), "theme")
+		os.WriteFile(name, []byte(v.content), 0o600)
+
+		got, err := loadTheme(name)
+		if v.err != "" {
+			if err == nil || !strings.HasSuffix(err.Error(), v.err) {
+				t.Errorf("%s expect error %q, got %v\n", v.label, v.err, err)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(v.expect) {
+			t.Errorf("%s expect %v, got %v, %v\n", v.label, v.expect, got, err)
+			continue
+		}
+		for k, c := range v.expect {
+			if got[k] != c {
+				t.Errorf("%s color%d expect %s, got %s\n", v.label, k, c, got[k])
+			}
+		}
+	}
+
+	if _, err := loadTheme(filepath.Join(t.TempDir(), "not-exist")); err == nil {
+		t.Errorf("not exist file expect error, got nil\n")
+	}
+}
+
+func TestThemeSequence(t *testing.T) {
+	th := theme{
+		9: terminal.NewRGBColor(0xff, 0, 0),
+		1: terminal.NewRGBColor(0x80, 0, 0),
+	}
+
+	expect := "\x1B]4;1;rgb:8080/0000/0000\x1B\\\x1B]4;9;rgb:ffff/0000/0000\x1B\\"
+	if got := th.sequence(); got != expect {
+		t.Errorf("sequence expect %q, got %q\n", expect, got)
+	}
+	if got := th.reset(); got != "\x1B]104;1;9\x1B\\" {
+		t.Errorf("reset expect %q, got %q\n", "\x1B]104;1;9\x1B\\", got)
+	}
+
+	var empty theme
+	if empty.sequence() != "" || empty.reset() != "" {
+		t.Errorf("empty theme expect empty sequence\n")
+	}
+
+	// the theme colors are reported to server as the palette of local terminal
+	conf := &Config{caps: make(map[int]string), theme: th}
+	conf.buildCaps([]tCap{{label: "Palette", query: paletteQuery,
+		resp: tResp{response: "\x1B]4;1;rgb:cdcd/0000/0000\x1B\\"}}})
+	if got := conf.caps[terminal.OSC_4]; got != "\x1B]4;1;rgb:cdcd/0000/0000\x1B\\"+expect {
+		t.Errorf("caps expect theme colors, got %q\n", got)
+	}
+}
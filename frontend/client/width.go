@@ -0,0 +1,68 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+// the width probes print a grapheme at the head of line, the cursor position
+// report (CPR) tells the width of it. each probe clears the line after the
+// report, the grapheme is not left on screen.
+const (
+	widthAmbiguous = "Width ambiguous" // U+2460 CIRCLED DIGIT ONE: East Asian ambiguous
+	widthEmoji     = "Width emoji"     // U+1F600 GRINNING FACE: wide since unicode 9
+	widthVS16      = "Width VS16"      // U+2764 HEAVY BLACK HEART + VS16: wide with emoji presentation
+)
+
+var widthProbes = []tCap{
+	{label: widthAmbiguous, query: "\r①\x1B[6n\r\x1B[K", reply: cursorPositionReport},
+	{label: widthEmoji, query: "\r\U0001F600\x1B[6n\r\x1B[K", reply: cursorPositionReport},
+	{label: widthVS16, query: "\r❤️\x1B[6n\r\x1B[K", reply: cursorPositionReport},
+}
+
+var cursorPositionReport = regexp.MustCompile(`\x1B\[[0-9]+;([0-9]+)R`)
+
+// return the width of probe grapheme from the cursor position report.
+func probeWidth(resp string) (int, bool) {
+	m := cursorPositionReport.FindStringSubmatch(resp)
+	if m == nil {
+		return 0, false
+	}
+	col, _ := strconv.Atoi(m[1])
+	if col < 2 || col > 3 {
+		return 0, false
+	}
+	return col - 1, true
+}
+
+// update the width policy according to the reply of width probe. return false
+// if cap is not a width probe.
+func applyWidthProbe(wp *terminal.WidthPolicy, cap tCap) bool {
+	switch cap.label {
+	case widthAmbiguous, widthEmoji, widthVS16:
+	default:
+		return false
+	}
+
+	w, ok := probeWidth(cap.resp.response)
+	if !ok || cap.resp.error != nil {
+		return true
+	}
+	switch cap.label {
+	case widthAmbiguous:
+		wp.Ambiguous = w
+	case widthEmoji:
+		if w == 1 {
+			wp.Unicode = 8 // the terminal is older than unicode 9
+		}
+	case widthVS16:
+		wp.EmojiVS16 = w == 2
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ericwq/aprilsh/terminal"
+)
+
+func TestProbeWidth(t *testing.T) {
+	tc := []struct {
+		label  string
+		resp   string
+		expect int
+		ok     bool
+	}{
+		{"narrow", "\x1B[5;2R", 1, true},
+		{"wide", "\x1B[12;3R", 2, true},
+		{"out of range", "\x1B[5;9R", 0, false},
+		{"no report", "\x1B[?62c", 0, false},
+	}
+
+	for _, v := range tc {
+		got, ok := probeWidth(v.resp)
+		if got != v.expect || ok != v.ok {
+			t.Errorf("%s expect %d %t, got %d %t\n", v.label, v.expect, v.ok, got, ok)
+		}
+	}
+}
+
+func TestBuildCapsWidth(t *testing.T) {
+	probes := func(ambiguous, emoji, vs16 string) []tCap {
+		caps := []tCap{widthProbes[0], widthProbes[1], widthProbes[2]}
+		caps[0].resp.response = ambiguous
+		caps[1].resp.response = emoji
+		caps[2].resp.response = vs16
+		return caps
+	}
+
+	tc := []struct {
+		label  string
+		policy string
+		caps   []tCap
+		expect string
+	}{
+		{"modern terminal", "", probes("\x1B[1;2R", "\x1B[1;3R", "\x1B[1;3R"), ""},
+		{"cjk terminal", "", probes("\x1B[1;3R", "\x1B[1;3R", "\x1B[1;3R"), "unicode=14,ambiguous=2,vs16=1"},
+		{"old terminal", "", probes("\x1B[1;2R", "\x1B[1;2R", "\x1B[1;2R"), "unicode=8,ambiguous=1,vs16=0"},
+		{"no response", "", probes("", "", ""), ""},
+		{"command line", "ambiguous=2", probes("\x1B[1;2R", "\x1B[1;2R", "\x1B[1;2R"), "unicode=8,ambiguous=2,vs16=0"},
+	}
+
+	for _, v := range tc {
+		conf := &Config{caps: make(map[int]string), widthPolicy: v.policy}
+		conf.buildCaps(v.caps)
+		if got := conf.caps[terminal.CAPS_WIDTH_POLICY]; got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+
+	// the probe with error is ignored
+	wp := terminal.DefaultWidthPolicy
+	cap := widthProbes[0]
+	cap.resp = tResp{response: "\x1B[1;3R", error: errors.New("timeout")}
+	if !applyWidthProbe(&wp, cap) || wp != terminal.DefaultWidthPolicy {
+		t.Errorf("probe error expect %v, got %v\n", terminal.DefaultWidthPolicy, wp)
+	}
+}
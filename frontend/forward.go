@@ -0,0 +1,102 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	forwardPrefix      = "tcp:" // channel target prefix of tcp forwarding
+	forwardDialTimeout = 10 * time.Second
+)
+
+// Forward is a tcp port forwarding, in the form of ssh -L/-R option:
+// [bind_address:]port:host:hostport. The connection accepted on Listen is
+// forwarded to Target by the other side.
+type Forward struct {
+	Listen string // listen address, such as localhost:8080
+	Target string // target address, such as db.example.com:5432
+}
+
+// parse the forwarding specification. like ssh, the default bind address is
+// localhost, empty bind address or "*" means all the interfaces. IPv6
+// address should be enclosed in square brackets.
+func ParseForward(spec string) (f Forward, err error) {
+	parts := splitForward(spec)
+	bind := "localhost"
+	switch len(parts) {
+	case 3:
+	case 4:
+		bind = parts[0]
+		if bind == "*" {
+			bind = ""
+		}
+		parts = parts[1:]
+	default:
+		return f, fmt.Errorf("bad forwarding specification %q", spec)
+	}
+
+	port, host, hostPort := parts[0], parts[1], parts[2]
+	if !validPort(port) || !validPort(hostPort) || host == "" {
+		return f, fmt.Errorf("bad forwarding specification %q", spec)
+	}
+
+	f.Listen = net.JoinHostPort(bind, port)
+	f.Target = net.JoinHostPort(host, hostPort)
+	return f, nil
+}
+
+// the listen port of forwarding.
+func (f Forward) Port() int {
+	_, port, _ := net.SplitHostPort(f.Listen)
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// the channel target of forwarding, used by Mux.Serve().
+func (f Forward) ChannelTarget() string {
+	return forwardPrefix + f.Target
+}
+
+// connect the tcp address of channel target, which is built by
+// Forward.ChannelTarget().
+func DialForward(target string) (net.Conn, error) {
+	addr, ok := strings.CutPrefix(target, forwardPrefix)
+	if !ok {
+		return nil, fmt.Errorf("channel target %q is not tcp forwarding", target)
+	}
+	return net.DialTimeout("tcp", addr, forwardDialTimeout)
+}
+
+// split the specification by colon, except the colon in square brackets.
+// the square brackets are removed.
+func splitForward(spec string) (parts []string) {
+	start := 0
+	depth := 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, strings.Trim(spec[start:i], "[]"))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.Trim(spec[start:], "[]"))
+}
+
+func validPort(port string) bool {
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p <= 65535
+}
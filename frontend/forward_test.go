@@ -0,0 +1,81 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestParseForward(t *testing.T) {
+	tc := []struct {
+		label  string
+		spec   string
+		expect Forward
+		port   int
+		ok     bool
+	}{
+		{"default bind address", "8080:localhost:80", Forward{"localhost:8080", "localhost:80"}, 8080, true},
+		{"bind address", "0.0.0.0:8080:db:5432", Forward{"0.0.0.0:8080", "db:5432"}, 8080, true},
+		{"all interfaces", "*:8080:db:5432", Forward{":8080", "db:5432"}, 8080, true},
+		{"empty bind address", ":8080:db:5432", Forward{":8080", "db:5432"}, 8080, true},
+		{"ipv6 address", "[::1]:8080:[fe80::1]:80", Forward{"[::1]:8080", "[fe80::1]:80"}, 8080, true},
+		{"missing host port", "8080:localhost", Forward{}, 0, false},
+		{"too many fields", "a:8080:b:80:c", Forward{}, 0, false},
+		{"bad port", "http:localhost:80", Forward{}, 0, false},
+		{"port out of range", "8080:localhost:65536", Forward{}, 0, false},
+		{"empty host", "8080::80", Forward{}, 0, false},
+	}
+
+	for _, v := range tc {
+		got, err := ParseForward(v.spec)
+		if (err == nil) != v.ok {
+			t.Errorf("%s expect ok=%t, got error %v\n", v.label, v.ok, err)
+			continue
+		}
+		if got != v.expect {
+			t.Errorf("%s expect %v, got %v\n", v.label, v.expect, got)
+		}
+		if got.Port() != v.port {
+			t.Errorf("%s expect port %d, got %d\n", v.label, v.port, got.Port())
+		}
+	}
+}
+
+func TestDialForward(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %s\n", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	f, err := ParseForward("9000:localhost:" + port)
+	if err != nil {
+		t.Fatalf("parse forward: %s\n", err)
+	}
+
+	conn, err := DialForward(f.ChannelTarget())
+	if err != nil {
+		t.Fatalf("dial forward: %s\n", err)
+	}
+	got, _ := io.ReadAll(conn)
+	conn.Close()
+	if string(got) != "hello" {
+		t.Errorf("expect %q, got %q\n", "hello", got)
+	}
+
+	if _, err := DialForward("agent"); err == nil {
+		t.Errorf("expect error for non-tcp target, got nil\n")
+	}
+}
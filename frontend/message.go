@@ -83,3 +83,116 @@ func DecodeTerminalCaps(str []byte) (map[int]string, error) {
 	}
 	return caps, nil
 }
+
+// encode v as base64 JSON for the bootstrap request. the encoded form
+// contains neither space nor comma, so it survives the ssh command line, the
+// "open aprilsh:" request and the worker environment without extra quoting.
+func encodeValue(v any) []byte {
+	jsonData, _ := json.Marshal(v)
+	dst := make([]byte, base64.StdEncoding.EncodedLen(len(jsonData)))
+	base64.StdEncoding.Encode(dst, jsonData)
+
+	return dst
+}
+
+// decode the value encoded by encodeValue into v.
+func decodeValue(str []byte, v any) error {
+	dst := make([]byte, base64.StdEncoding.DecodedLen(len(str)))
+	n, err := base64.StdEncoding.Decode(dst, str)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(dst[:n], v)
+}
+
+// encode the remote command (argv) for the bootstrap request.
+func EncodeCommand(argv []string) []byte {
+	return encodeValue(argv)
+}
+
+func DecodeCommand(str []byte) ([]string, error) {
+	var argv []string
+	if err := decodeValue(str, &argv); err != nil {
+		return nil, err
+	}
+	return argv, nil
+}
+
+// encode the forwarded environment variables for the bootstrap request.
+func EncodeEnv(env map[string]string) []byte {
+	return encodeValue(env)
+}
+
+func DecodeEnv(str []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	if err := decodeValue(str, &env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// report whether the environment variable name matches any of the patterns.
+// pattern is in the form of ssh_config SendEnv and sshd_config AcceptEnv: '*'
+// matches any sequence of characters, '?' matches exactly one character.
+func MatchEnv(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if matchWildcard(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWildcard(s, p string) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchWildcard(s[i:], p[1:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != p[0] {
+				return false
+			}
+		}
+		s = s[1:]
+		p = p[1:]
+	}
+	return len(s) == 0
+}
+
+// encode the remote forwarding specifications for the bootstrap request.
+func EncodeForward(specs []string) []byte {
+	return encodeValue(specs)
+}
+
+func DecodeForward(str []byte) ([]string, error) {
+	var specs []string
+	if err := decodeValue(str, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// encode the initial working directory for the bootstrap request.
+func EncodeCwd(dir string) []byte {
+	return encodeValue([]string{dir})
+}
+
+func DecodeCwd(str []byte) (string, error) {
+	var argv []string
+	if err := decodeValue(str, &argv); err != nil {
+		return "", err
+	}
+	if len(argv) != 1 || len(argv[0]) == 0 || argv[0][0] != '/' {
+		return "", fmt.Errorf("working directory should be an absolute path: %q", argv)
+	}
+	return argv[0], nil
+}
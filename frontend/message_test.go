@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"maps"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 
@@ -134,3 +135,85 @@ func TestTerminalCaps(t *testing.T) {
 		t.Errorf("expect error, got nil\n")
 	}
 }
+
+func TestCommand(t *testing.T) {
+	tc := []struct {
+		label  string
+		expect []string
+	}{
+		{"plain command", []string{"htop"}},
+		{"command with args", []string{"tmux", "new", "-A", "-s", "main"}},
+		{"args with space and comma", []string{"sh", "-c", "echo 'a, b' \"$HOME\"; exit 3"}},
+	}
+
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			middle := EncodeCommand(v.expect)
+			if strings.ContainsAny(string(middle), " ,") {
+				t.Errorf("%s encoded command contains space or comma: %q\n", v.label, middle)
+			}
+			got, err := DecodeCommand(middle)
+			if err != nil || !slices.Equal(got, v.expect) {
+				t.Errorf("%s expect %q, got %q, err=%v\n", v.label, v.expect, got, err)
+			}
+		})
+	}
+
+	_, err := DecodeCommand([]byte("bad base64"))
+	if err == nil {
+		t.Errorf("expect error, got nil\n")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	tc := []struct {
+		label  string
+		expect map[string]string
+	}{
+		{"normal", map[string]string{"LANG": "en_US.UTF-8", "EDITOR": "nvim"}},
+		{"value with space and comma", map[string]string{"GREETING": "hello, world"}},
+		{"empty map", map[string]string{}},
+	}
+
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			middle := EncodeEnv(v.expect)
+			if strings.ContainsAny(string(middle), " ,") {
+				t.Errorf("%s encoded env contains space or comma: %q\n", v.label, middle)
+			}
+			got, err := DecodeEnv(middle)
+			if err != nil || !maps.Equal(got, v.expect) {
+				t.Errorf("%s expect %v, got %v, err=%v\n", v.label, v.expect, got, err)
+			}
+		})
+	}
+
+	_, err := DecodeEnv([]byte("bad base64"))
+	if err == nil {
+		t.Errorf("expect error, got nil\n")
+	}
+}
+
+func TestMatchEnv(t *testing.T) {
+	tc := []struct {
+		label    string
+		name     string
+		patterns []string
+		expect   bool
+	}{
+		{"exact match", "LANG", []string{"LANG"}, true},
+		{"star suffix", "LC_ALL", []string{"LANG", "LC_*"}, true},
+		{"star only", "ANYTHING", []string{"*"}, true},
+		{"question mark", "XY1", []string{"XY?"}, true},
+		{"question mark no char", "XY", []string{"XY?"}, false},
+		{"star in middle", "GIT_AUTHOR_NAME", []string{"GIT_*_NAME"}, true},
+		{"prefix only", "LANGUAGE", []string{"LANG"}, false},
+		{"no pattern", "LANG", nil, false},
+	}
+
+	for _, v := range tc {
+		if got := MatchEnv(v.name, v.patterns); got != v.expect {
+			t.Errorf("%s expect %t, got %t\n", v.label, v.expect, got)
+		}
+	}
+}
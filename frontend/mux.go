@@ -0,0 +1,266 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/ericwq/aprilsh/statesync"
+	"github.com/ericwq/aprilsh/util"
+)
+
+const (
+	muxChunkSize  = 16 * 1024  // max payload size of data message
+	muxWindow     = 128 * 1024 // max bytes in flight for one channel
+	muxQueueSize  = 1024       // max pending data message for one connection
+	muxOutBufSize = 64
+)
+
+var errMuxQueueFull = errors.New("channel queue is full")
+
+// Mux bridges the forwarding channels with local connections. Channel
+// messages from the peer are applied by Handle(), channel messages for the
+// peer are read from Out() by the main loop, which forwards them through the
+// state synchronization. Because the channels ride on the state
+// synchronization, they survive roaming like the terminal does.
+//
+// Both sides can open channels, the client uses odd ids and the server uses
+// even ids to avoid conflict.
+//
+// Each channel has a window: the sender stops reading the local connection
+// after muxWindow bytes are in flight, the receiver returns the window with
+// ChannelAck after the data is written to its local connection. So a slow
+// connection won't fill up the state synchronization.
+type Mux struct {
+	dial   func(target string) (net.Conn, error)
+	out    chan statesync.ChannelMsg
+	done   chan struct{}
+	conns  map[uint32]*muxConn
+	cond   *sync.Cond // window changed or channel closed
+	lns    []net.Listener
+	mu     sync.Mutex
+	nextID uint32
+	closed bool
+}
+
+type muxConn struct {
+	queue  chan []byte // data waiting to be written to local connection
+	window int         // bytes can be sent to peer, protected by Mux.mu
+	closed bool        // protected by Mux.mu
+}
+
+func newMuxConn() *muxConn {
+	return &muxConn{queue: make(chan []byte, muxQueueSize), window: muxWindow}
+}
+
+// create Mux, firstID is the first channel id opened by this side, dial is
+// used to connect the target requested by the peer. nil dial rejects all
+// the channels opened by the peer.
+func NewMux(firstID uint32, dial func(target string) (net.Conn, error)) *Mux {
+	m := &Mux{}
+	m.dial = dial
+	m.out = make(chan statesync.ChannelMsg, muxOutBufSize)
+	m.done = make(chan struct{})
+	m.conns = make(map[uint32]*muxConn)
+	m.cond = sync.NewCond(&m.mu)
+	m.nextID = firstID
+	return m
+}
+
+// channel messages for peer.
+func (m *Mux) Out() <-chan statesync.ChannelMsg {
+	return m.out
+}
+
+// accept connections from listener, open a channel to target for each
+// accepted connection.
+func (m *Mux) Serve(ln net.Listener, target string) {
+	m.mu.Lock()
+	m.lns = append(m.lns, ln)
+	m.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				util.Logger.Debug("mux accept", "target", target, "error", err)
+				return
+			}
+
+			m.mu.Lock()
+			id := m.nextID
+			m.nextID += 2
+			mc := newMuxConn()
+			m.conns[id] = mc
+			m.mu.Unlock()
+
+			if !m.send(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelOpen, Data: []byte(target)}) {
+				conn.Close()
+				return
+			}
+			go m.pump(id, mc, conn)
+		}
+	}()
+}
+
+// apply the channel message from peer.
+func (m *Mux) Handle(msg statesync.ChannelMsg) {
+	switch msg.Kind {
+	case statesync.ChannelOpen:
+		m.mu.Lock()
+		if m.closed || m.conns[msg.ID] != nil {
+			m.mu.Unlock()
+			return
+		}
+		mc := newMuxConn()
+		m.conns[msg.ID] = mc
+		m.mu.Unlock()
+
+		// dial may take a while, don't block the caller.
+		go func() {
+			var conn net.Conn
+			err := errors.New("channel is not allowed")
+			if m.dial != nil {
+				conn, err = m.dial(string(msg.Data))
+			}
+			if err != nil {
+				util.Logger.Warn("mux open channel", "target", string(msg.Data), "error", err)
+				m.closeChannel(msg.ID, true)
+				return
+			}
+			m.pump(msg.ID, mc, conn)
+		}()
+	case statesync.ChannelData:
+		// the queue is closed with m.mu held, so send with m.mu held too.
+		full := false
+		m.mu.Lock()
+		if mc := m.conns[msg.ID]; mc != nil && !mc.closed {
+			select {
+			case mc.queue <- msg.Data:
+			default:
+				full = true
+			}
+		}
+		m.mu.Unlock()
+		if full {
+			// the local connection can't keep up, give up the channel
+			// instead of blocking the caller.
+			util.Logger.Warn("mux write channel", "id", msg.ID, "error", errMuxQueueFull)
+			m.closeChannel(msg.ID, true)
+		}
+	case statesync.ChannelAck:
+		m.mu.Lock()
+		if mc := m.conns[msg.ID]; mc != nil {
+			mc.window += int(msg.Window)
+			m.cond.Broadcast()
+		}
+		m.mu.Unlock()
+	case statesync.ChannelClose:
+		m.closeChannel(msg.ID, false)
+	}
+}
+
+// close all the listeners and channels.
+func (m *Mux) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	close(m.done)
+	for _, ln := range m.lns {
+		ln.Close()
+	}
+	for id, mc := range m.conns {
+		delete(m.conns, id)
+		mc.closed = true
+		close(mc.queue)
+	}
+	m.cond.Broadcast()
+	m.mu.Unlock()
+}
+
+// copy data between the local connection and the channel.
+func (m *Mux) pump(id uint32, mc *muxConn, conn net.Conn) {
+	go func() {
+		buf := make([]byte, muxChunkSize)
+		for {
+			// wait for the window
+			m.mu.Lock()
+			for mc.window == 0 && !mc.closed {
+				m.cond.Wait()
+			}
+			size, closed := min(mc.window, muxChunkSize), mc.closed
+			m.mu.Unlock()
+			if closed {
+				return
+			}
+
+			n, err := conn.Read(buf[:size])
+			if n > 0 {
+				m.mu.Lock()
+				mc.window -= n
+				m.mu.Unlock()
+
+				data := make([]byte, n)
+				copy(data, buf[:n])
+				if !m.send(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelData, Data: data}) {
+					return
+				}
+			}
+			if err != nil {
+				m.closeChannel(id, true)
+				return
+			}
+		}
+	}()
+
+	consumed := 0
+	for data := range mc.queue {
+		if _, err := conn.Write(data); err != nil {
+			m.closeChannel(id, true)
+			break
+		}
+
+		// return the window to peer, half window a time
+		consumed += len(data)
+		if consumed >= muxWindow/2 {
+			m.send(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelAck, Window: uint32(consumed)})
+			consumed = 0
+		}
+	}
+	conn.Close()
+}
+
+// remove the channel, notify peer if notify is true and the channel is still
+// open.
+func (m *Mux) closeChannel(id uint32, notify bool) {
+	m.mu.Lock()
+	mc := m.conns[id]
+	if mc != nil {
+		delete(m.conns, id)
+		mc.closed = true
+		close(mc.queue)
+		m.cond.Broadcast()
+	}
+	m.mu.Unlock()
+
+	if mc != nil && notify {
+		m.send(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelClose})
+	}
+}
+
+// send message to peer, return false if mux is closed.
+func (m *Mux) send(msg statesync.ChannelMsg) bool {
+	select {
+	case m.out <- msg:
+		return true
+	case <-m.done:
+		return false
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ericwq/aprilsh/statesync"
+)
+
+// relay the channel messages between two Mux, just like the state
+// synchronization does.
+func relayMux(a, b *Mux, done chan struct{}) {
+	for {
+		select {
+		case msg := <-a.Out():
+			b.Handle(msg)
+		case msg := <-b.Out():
+			a.Handle(msg)
+		case <-done:
+			return
+		}
+	}
+}
+
+func TestMuxForward(t *testing.T) {
+	// echo server as the forwarding target
+	target := filepath.Join(t.TempDir(), "echo.sock")
+	echo, err := net.Listen("unix", target)
+	if err != nil {
+		t.Fatalf("listen echo server: %s\n", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	dialed := ""
+	client := NewMux(1, func(t string) (net.Conn, error) {
+		dialed = t
+		return net.Dial("unix", target)
+	})
+	server := NewMux(2, nil)
+	done := make(chan struct{})
+	go relayMux(client, server, done)
+	defer func() {
+		close(done)
+		client.Close()
+		server.Close()
+	}()
+
+	// server side listener, such as SSH_AUTH_SOCK
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen agent socket: %s\n", err)
+	}
+	server.Serve(ln, "agent")
+
+	tc := []struct {
+		label string
+		data  string
+	}{
+		{"first connection", "ping"},
+		{"second connection", "hello, world"},
+	}
+
+	for _, v := range tc {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			t.Fatalf("%s dial: %s\n", v.label, err)
+		}
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		conn.Write([]byte(v.data))
+
+		buf := make([]byte, len(v.data))
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != v.data {
+			t.Errorf("%s expect %q, got %q, err=%v\n", v.label, v.data, buf, err)
+		}
+		conn.Close()
+	}
+
+	if dialed != "agent" {
+		t.Errorf("expect dial target %q, got %q\n", "agent", dialed)
+	}
+}
+
+func TestMuxReject(t *testing.T) {
+	m := NewMux(2, nil)
+	defer m.Close()
+
+	// nil dial reject the channel opened by peer
+	m.Handle(statesync.ChannelMsg{ID: 1, Kind: statesync.ChannelOpen, Data: []byte("agent")})
+
+	select {
+	case msg := <-m.Out():
+		if msg.ID != 1 || msg.Kind != statesync.ChannelClose {
+			t.Errorf("expect close channel 1, got %v\n", msg)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expect close channel message, got nothing\n")
+	}
+
+	// message for unknown channel is ignored
+	m.Handle(statesync.ChannelMsg{ID: 3, Kind: statesync.ChannelData, Data: []byte("data")})
+	m.Handle(statesync.ChannelMsg{ID: 3, Kind: statesync.ChannelClose})
+	select {
+	case msg := <-m.Out():
+		t.Errorf("expect nothing, got %v\n", msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestMuxWindow(t *testing.T) {
+	m := NewMux(2, nil)
+	defer m.Close()
+
+	sock := filepath.Join(t.TempDir(), "window.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen socket: %s\n", err)
+	}
+	m.Serve(ln, "target")
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("dial socket: %s\n", err)
+	}
+	defer conn.Close()
+
+	// write more than the window, the peer never read it
+	go conn.Write(make([]byte, muxWindow*2))
+
+	// count the received data until it stops
+	received := func() (n int, id uint32) {
+		for {
+			select {
+			case msg := <-m.Out():
+				if msg.Kind == statesync.ChannelData {
+					id = msg.ID
+					n += len(msg.Data)
+				}
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	tc := []struct {
+		label  string
+		ack    uint32
+		expect int
+	}{
+		{"stop at window", 0, muxWindow},
+		{"resume after ack", muxWindow / 2, muxWindow / 2},
+		{"rest of data", muxWindow, muxWindow / 2},
+	}
+
+	var id uint32
+	for _, v := range tc {
+		if v.ack > 0 {
+			m.Handle(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelAck, Window: v.ack})
+		}
+		got, got2 := received()
+		if v.ack == 0 {
+			id = got2
+		}
+		if got != v.expect {
+			t.Errorf("%s expect %d bytes, got %d\n", v.label, v.expect, got)
+		}
+	}
+}
+
+// the data arriving while the channel is closed by pump or Close must be
+// dropped without panic.
+func TestMuxCloseWhileData(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		m := NewMux(2, func(string) (net.Conn, error) {
+			local, remote := net.Pipe()
+			remote.Close() // pump gets error and closes the channel
+			return local, nil
+		})
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-m.Out():
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for id := uint32(1); id < 8; id += 2 {
+			m.Handle(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelOpen, Data: []byte("target")})
+		}
+		for j := 0; j < 200; j++ {
+			if j == 100 {
+				go m.Close()
+			}
+			for id := uint32(1); id < 8; id += 2 {
+				m.Handle(statesync.ChannelMsg{ID: id, Kind: statesync.ChannelData, Data: []byte("data")})
+			}
+		}
+		m.Close()
+		close(done)
+	}
+}
@@ -12,7 +12,6 @@ import (
 
 	"github.com/ericwq/aprilsh/terminal"
 	"github.com/ericwq/aprilsh/util"
-	"github.com/rivo/uniseg"
 )
 
 type (
@@ -1301,7 +1300,7 @@ if pe.cursor().col > 0 {
 */
 
 func (pe *PredictionEngine) handleUserGrapheme(emu *terminal.Emulator, now int64, chs ...rune) {
-	w := uniseg.StringWidth(string(chs))
+	w := emu.GraphemeWidth(chs)
 	pe.initCursor(emu)
 	util.Logger.Trace("prediction message", "from", "handleUserGrapheme.initCursor",
 		"row", pe.cursor().row, "col", pe.cursor().col)
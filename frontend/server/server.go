@@ -16,10 +16,12 @@ import (
 	"math"
 	"net"
 	"os"
+	"os/exec"
 	"os/signal"
 	"os/user"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,10 +35,12 @@ import (
 	"github.com/ericwq/aprilsh/network"
 	"github.com/ericwq/aprilsh/statesync"
 	"github.com/ericwq/aprilsh/terminal"
+	"github.com/ericwq/aprilsh/terminfo"
 	"github.com/ericwq/aprilsh/util"
 	utmps "github.com/ericwq/goutmp"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/unix"
+	"golang.org/x/text/encoding"
 )
 
 // set GOFLAGS="-tags=utmps" APRILSH_APSHD_PATH=~/.local/bin/apshd  before nvim
@@ -64,12 +68,14 @@ const (
 	stateStandby    = 0
 	stateInputReady = 1
 	stateEchoDone   = 2
+
+	exitStatusTimeout = 500 // ms, wait for shell exit status before shutdown
 )
 
 var usage = `Usage:
   ` + frontend.CommandServerName + ` [-version] [-h] [--auto N]
-  ` + frontend.CommandServerName + ` [-b] [-t TERM] [-destination user@server.domain]
-  ` + frontend.CommandServerName + ` [-s] [-v[v]] [-i LOCALADDR] [-p PORT[:PORT2]] [-l NAME=VALUE] [-- command...]
+  ` + frontend.CommandServerName + ` [-b] [-t TERM] [-destination user@server.domain] [-command CMD] [-env ENV] [-agent] [-forward FWD] [-cwd DIR] [-terminfo NAME]
+  ` + frontend.CommandServerName + ` [-s] [-v[v]] [-i LOCALADDR] [-p PORT[:PORT2]] [-l NAME=VALUE] [-accept-env PATTERN] [-allow-tcp-forwarding MODE] [-scrollback N] [-- command...]
 Options:
 ---------------------------------------------------------------------------------------------------
   -h,  --help        print this message
@@ -80,11 +86,21 @@ Options:
   -t,  --term        client TERM (such as xterm-256color, or alacritty or xterm-kitty)
   -d,  --destination in the form of user@host[:port], here the port is ssh server port (default 22)
        --caps        client terminal capability
+       --command     encoded remote command requested by client (default login shell)
+       --env         encoded environment variables requested by client ("-" reads them from stdin)
+       --agent       forward ssh agent requested by client
+       --forward     encoded remote tcp forwarding requested by client
+       --cwd         encoded initial working directory requested by client (default HOME)
+       --terminfo    aprilsh terminfo entry used as TERM of shell, requested by client (aprilsh or aprilsh-direct)
 ---------------------------------------------------------------------------------------------------
   -s,  --server      listen with SSH ip
   -i,  --ip          listen with this ip/host
   -p,  --port        listen base port (default 8100)
   -l,  --locale      key-value pairs (such as LANG=UTF-8, you can have multiple -l options)
+       --accept-env  accept client environment variables matching the pattern (such as "LANG LC_*")
+       --allow-tcp-forwarding  tcp forwarding mode: yes, no, local (-L only) or remote (-R only) (default yes)
+       --scrollback  number of scrollback history rows (default 60, max 50000)
+       --charset     character set of pty for legacy applications (such as GB18030, default UTF-8)
   -v,  --verbose     verbose log output (debug level, default no verbose)
   -vv                verbose log output (trace level)
        -- command    shell command and options (note the space before command)
@@ -131,10 +147,25 @@ func (lv *localeFlag) IsBoolFlag() bool {
 	return false
 }
 
+// patternFlag collects the space separated patterns of repeated flag
+type patternFlag []string
+
+func (pf *patternFlag) String() string {
+	return fmt.Sprint(*pf)
+}
+
+func (pf *patternFlag) Set(value string) error {
+	*pf = append(*pf, strings.Fields(value)...)
+	return nil
+}
+
 type Config struct {
-	locales localeFlag // localse environment variables
+	locales   localeFlag  // localse environment variables
+	acceptEnv patternFlag // accepted client environment variables
+	// tcp forwarding mode, like AllowTcpForwarding of sshd, one of tcpForwardingModes
+	allowTcpForwarding string
 	// the serve func
-	serve       func(*os.File, *os.File, *io.PipeWriter, *statesync.Complete, *network.Transport[*statesync.Complete, *statesync.UserStream], int64, int64, string) error
+	serve       func(*os.File, *os.File, *io.PipeWriter, *statesync.Complete, chan *os.ProcessState, *frontend.Mux, *network.Transport[*statesync.Complete, *statesync.UserStream], int64, int64, string, encoding.Encoding) error
 	user        string   // target user
 	desiredIP   string   // server ip/host
 	desiredPort string   // server port
@@ -142,17 +173,28 @@ type Config struct {
 	host        string   // target host/server
 	destination string   // [user@]hostname, destination string
 	caps        string   // terminal capability
+	command     string   // encoded remote command, requested by client
+	env         string   // encoded environment variables, requested by client
+	forward     string   // encoded remote tcp forwarding, requested by client
+	cwd         string   // encoded initial working directory, requested by client
+	terminfo    string   // aprilsh terminfo entry used as TERM of shell, requested by client
+	charset     string   // character set of pty, such as GB18030
+	agentSock   string   // ssh agent forwarding socket
 	commandPath string   // shell command path (absolute path)
 	commandArgv []string // the positional (non-flag) command-line arguments.
 	autoStop    int      // auto stop after N seconds
+	scrollback  int      // number of scrollback history rows
 	flowControl int      // control flow for testing
 	verbose     int      // verbose output
 	begin       bool     // begin a client connection
+	agent       bool     // forward ssh agent, requested by client
 	child       bool     // begin a child process
 	version     bool     // print version information
 	addSource   bool     // add source file to log
 	server      bool     // use SSH ip
 	withMotd    bool
+	// encoding of pty, nil means UTF-8
+	ptyCharset encoding.Encoding
 }
 
 // generate shell for specified user or current user if user is nil.
@@ -232,6 +274,24 @@ func (conf *Config) buildConfig() (string, bool) {
 
 	conf.prepareShell(nil)
 
+	if conf.allowTcpForwarding != "" && !slices.Contains(tcpForwardingModes, conf.allowTcpForwarding) {
+		return fmt.Sprintf("allow-tcp-forwarding should be one of %s.", strings.Join(tcpForwardingModes, ", ")), false
+	}
+
+	if !validTerminfo(conf.terminfo) {
+		return fmt.Sprintf("terminfo should be %s or %s.", terminal.TermAprilsh, terminal.TermAprilshDirect), false
+	}
+
+	if conf.charset != "" {
+		enc, err := util.LookupCharset(conf.charset)
+		if err != nil {
+			return err.Error(), false
+		}
+		if !util.IsUtf8Charset(enc) {
+			conf.ptyCharset = enc
+		}
+	}
+
 	// Adopt implementation locale
 	util.SetNativeLocale()
 	if !util.IsUtf8Locale() || conf.flowControl == _FC_NON_UTF8_LOCALE {
@@ -250,6 +310,18 @@ func (conf *Config) buildConfig() (string, bool) {
 		if !util.IsUtf8Locale() || conf.flowControl == _FC_NON_UTF8_LOCALE {
 			clientType := util.GetCtype()
 			clientCharset := util.LocaleCharset()
+
+			// the legacy charset is converted at pty, the emulator keeps UTF-8.
+			// C/POSIX locale is not a legacy charset, it just lacks of locale setting.
+			enc, err := util.LookupCharset(clientCharset)
+			if err == nil && !util.IsAsciiCharset(enc) && conf.flowControl != _FC_NON_UTF8_LOCALE {
+				if conf.charset == "" {
+					conf.charset = clientCharset
+					conf.ptyCharset = enc
+				}
+				return "", true
+			}
+
 			fmt.Printf("%s needs a UTF-8 native locale to run.\n", frontend.CommandServerName)
 			fmt.Printf("Unfortunately, the local environment %s specifies "+
 				"the character set \"%s\",\n", nativeType, nativeCharset)
@@ -317,6 +389,18 @@ func parseFlags(progname string, args []string) (config *Config, output string,
 
 	flagSet.StringVar(&conf.caps, "caps", "", "client TERM capability")
 
+	flagSet.StringVar(&conf.command, "command", "", "encoded remote command")
+	flagSet.StringVar(&conf.env, "env", "", "encoded environment variables")
+	flagSet.BoolVar(&conf.agent, "agent", false, "forward ssh agent")
+	flagSet.StringVar(&conf.forward, "forward", "", "encoded remote tcp forwarding")
+	flagSet.StringVar(&conf.cwd, "cwd", "", "encoded initial working directory")
+	flagSet.StringVar(&conf.terminfo, "terminfo", "", "aprilsh terminfo entry")
+	flagSet.IntVar(&conf.scrollback, "scrollback", terminal.SaveLinesRowsOption, "number of scrollback history rows")
+	flagSet.StringVar(&conf.charset, "charset", "", "character set of pty")
+
+	flagSet.Var(&conf.acceptEnv, "accept-env", "accepted client environment variables pattern")
+	flagSet.StringVar(&conf.allowTcpForwarding, "allow-tcp-forwarding", tcpForwardingModes[0], "tcp forwarding mode")
+
 	err = flagSet.Parse(args)
 	if err != nil {
 		return nil, buf.String(), err
@@ -373,10 +457,40 @@ func beginChild(conf *Config) { //(port string, term string) {
 	// 	return
 	// }
 
+	// beginChild runs as the target user, install the terminfo entry for the
+	// shell. the client TERM is used if it fails.
+	if conf.terminfo != "" {
+		if path, err := installTerminfo(conf.terminfo); err != nil {
+			fmt.Fprintf(os.Stderr, "install terminfo %s failed: %s\n", conf.terminfo, err)
+			conf.terminfo = ""
+		} else {
+			fmt.Fprintf(os.Stderr, "install terminfo %s at %s\n", conf.terminfo, path)
+		}
+	}
+
+	// the client sends the environment variables on stdin, they are not
+	// visible in the command line.
+	if conf.env == "-" {
+		conf.env = readEnv(os.Stdin)
+	}
+
 	// request from server
-	// open aprilsh:TERM,user@server.domain,{terminal capability}
-	request := fmt.Sprintf("%s%s,%s,%s",
-		frontend.AprilshMsgOpen, conf.term, conf.destination, conf.caps)
+	// open aprilsh:TERM,user@server.domain,{terminal capability}[,{command}[,{env}[,agent[,{forward}[,{scrollback}[,{cwd}[,{terminfo}]]]]]]]
+	// the trailing empty fields are omitted.
+	agent := ""
+	if conf.agent {
+		agent = "agent"
+	}
+	scrollback := ""
+	if conf.scrollback != terminal.SaveLinesRowsOption {
+		scrollback = strconv.Itoa(conf.scrollback)
+	}
+	fields := []string{conf.term, conf.destination, conf.caps, conf.command, conf.env, agent, conf.forward, scrollback,
+		conf.cwd, conf.terminfo}
+	for len(fields) > 3 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	request := frontend.AprilshMsgOpen + strings.Join(fields, ",")
 	conn.SetDeadline(time.Now().Add(time.Millisecond * 20))
 	conn.WriteTo([]byte(request), dest)
 	// n, err := conn.WriteTo([]byte(request), dest)
@@ -527,7 +641,7 @@ upon receive frontend.AprilshMsgOpen message, run() stat a new worker
 to serve the client, response to the client with choosen port number
 and session key.
 
-sample request  : open aprilsh:TERM,user@server.domain
+sample request  : open aprilsh:TERM,user@server.domain,{terminal capability}[,{command}]
 
 sample response : open aprilsh:60001,31kR3xgfmNxhDESXQ8VIQw==
 
@@ -723,11 +837,11 @@ func (m *mainSrv) startChild(req string, addr *net.UDPAddr, conf2 Config) {
 		return
 	}
 
-	// open aprilsh:TERM,user@server.domain,{terminal capability}
-	// parse term, destination and terminal capability from request
+	// open aprilsh:TERM,user@server.domain,{terminal capability}[,{command}[,{env}[,agent[,{forward}[,{scrollback}[,{cwd}[,{terminfo}]]]]]]]
+	// parse term, destination, terminal capability, command, env, agent, forward, scrollback, cwd and terminfo from request
 	body := strings.Split(req, ":")
 	content := strings.Split(body[1], ",")
-	if len(content) != 3 {
+	if len(content) < 3 || len(content) > 10 || (len(content) >= 6 && content[5] != "" && content[5] != "agent") {
 		resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform request")
 		util.Logger.Warn("malform request", "request", req, "response", resp)
 		return
@@ -735,6 +849,60 @@ func (m *mainSrv) startChild(req string, addr *net.UDPAddr, conf2 Config) {
 	conf2.term = content[0]
 	conf2.destination = content[1]
 	conf2.caps = content[2]
+	if len(content) >= 4 && content[3] != "" {
+		if _, err := frontend.DecodeCommand([]byte(content[3])); err != nil {
+			resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform command")
+			util.Logger.Warn("malform command", "command", content[3], "response", resp)
+			return
+		}
+		conf2.command = content[3]
+	}
+	if len(content) >= 5 && content[4] != "" {
+		env, err := frontend.DecodeEnv([]byte(content[4]))
+		if err != nil {
+			resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform env")
+			util.Logger.Warn("malform env", "env", content[4], "response", resp)
+			return
+		}
+		// only keep the variables allowed by -accept-env
+		if accepted := acceptEnv(env, conf2.acceptEnv); len(accepted) > 0 {
+			conf2.env = string(frontend.EncodeEnv(accepted))
+		}
+	}
+	conf2.agent = len(content) >= 6 && content[5] == "agent"
+	if len(content) >= 7 && content[6] != "" {
+		if _, err := decodeForward(content[6]); err != nil {
+			resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform forward")
+			util.Logger.Warn("malform forward", "forward", content[6], "error", err, "response", resp)
+			return
+		}
+		conf2.forward = content[6]
+	}
+	if len(content) >= 8 && content[7] != "" {
+		n, err := strconv.Atoi(content[7])
+		if err != nil || n < 0 || n > terminal.SaveLineUpperLimit {
+			resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform scrollback")
+			util.Logger.Warn("malform scrollback", "scrollback", content[7], "response", resp)
+			return
+		}
+		conf2.scrollback = n
+	}
+	if len(content) >= 9 && content[8] != "" {
+		if _, err := frontend.DecodeCwd([]byte(content[8])); err != nil {
+			resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform cwd")
+			util.Logger.Warn("malform cwd", "cwd", content[8], "error", err, "response", resp)
+			return
+		}
+		conf2.cwd = content[8]
+	}
+	if len(content) == 10 && content[9] != "" {
+		if !validTerminfo(content[9]) {
+			resp := m.writeRespTo(addr, frontend.AprilshMsgOpen, "malform terminfo")
+			util.Logger.Warn("malform terminfo", "terminfo", content[9], "response", resp)
+			return
+		}
+		conf2.terminfo = content[9]
+	}
 
 	// parse user and host from destination
 	dest := strings.Split(content[1], "@")
@@ -1109,6 +1277,179 @@ func getShellNameFrom(shellPath string) (shellName string) {
 	return
 }
 
+// create the ssh agent forwarding socket for user, return the listener and
+// the socket path. the socket is placed in a private directory owned by user.
+func listenAgent(userName string) (ln net.Listener, sock string, err error) {
+	u, err := user.Lookup(userName)
+	if err != nil {
+		return nil, "", err
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+
+	dir, err := os.MkdirTemp("", "aprilsh-agent-")
+	if err != nil {
+		return nil, "", err
+	}
+	sock = filepath.Join(dir, fmt.Sprintf("agent.%d", os.Getpid()))
+	ln, err = net.Listen("unix", sock)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+
+	// apshd run as root, hand over the socket to user
+	if os.Geteuid() == 0 {
+		if err = os.Chown(dir, uid, gid); err == nil {
+			err = os.Chown(sock, uid, gid)
+		}
+		if err != nil {
+			ln.Close()
+			os.RemoveAll(dir)
+			return nil, "", err
+		}
+	}
+	return ln, sock, nil
+}
+
+// decode the working directory requested by client, return home if it's not
+// requested or it's not an existing directory. the access of login user is
+// checked when the shell starts in it, see startShellProcess().
+func workingDir(cwd string, home string) string {
+	if cwd == "" {
+		return home
+	}
+	dir, err := frontend.DecodeCwd([]byte(cwd))
+	if err != nil {
+		util.Logger.Warn("working directory", "cwd", cwd, "error", err)
+		return home
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		util.Logger.Warn("working directory", "dir", dir, "error", "not a directory")
+		return home
+	}
+	return dir
+}
+
+// read the encoded environment variables from the first line of r.
+func readEnv(r io.Reader) string {
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// check the aprilsh terminfo entry requested by client, empty means the client
+// TERM is used.
+func validTerminfo(name string) bool {
+	return name == "" || name == terminal.TermAprilsh || name == terminal.TermAprilshDirect
+}
+
+// install the aprilsh terminfo entry into ~/.terminfo of current user, return
+// the path of entry.
+func installTerminfo(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := terminal.AprilshTerminfo(name)
+	if err != nil {
+		return "", err
+	}
+	return terminfo.Install(filepath.Join(home, ".terminfo"), name, data)
+}
+
+// the tcp forwarding modes, the first one is default.
+var tcpForwardingModes = []string{"yes", "no", "local", "remote"}
+
+// return whether the local (-L) and remote (-R) tcp forwarding are allowed.
+func (conf *Config) tcpForwarding() (local bool, remote bool) {
+	switch conf.allowTcpForwarding {
+	case "", "yes":
+		return true, true
+	case "local":
+		return true, false
+	case "remote":
+		return false, true
+	}
+	return false, false
+}
+
+// decode and parse the remote forwarding specifications.
+func decodeForward(forward string) ([]frontend.Forward, error) {
+	specs, err := frontend.DecodeForward([]byte(forward))
+	if err != nil {
+		return nil, err
+	}
+
+	forwards := make([]frontend.Forward, 0, len(specs))
+	for _, spec := range specs {
+		f, err := frontend.ParseForward(spec)
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, f)
+	}
+	return forwards, nil
+}
+
+// listen the remote forwarding address for user. like sshd, only root can
+// forward privileged ports.
+func listenForward(userName string, f frontend.Forward) (net.Listener, error) {
+	u, err := user.Lookup(userName)
+	if err != nil {
+		return nil, err
+	}
+	if f.Port() < 1024 && u.Uid != "0" {
+		return nil, fmt.Errorf("privileged port %d is not allowed for %s", f.Port(), userName)
+	}
+	return net.Listen("tcp", f.Listen)
+}
+
+// return the environment variables which match the accept patterns.
+func acceptEnv(env map[string]string, patterns []string) map[string]string {
+	accepted := make(map[string]string)
+	for k, v := range env {
+		if frontend.MatchEnv(k, patterns) {
+			accepted[k] = v
+		} else {
+			util.Logger.Debug("reject client env", "name", k)
+		}
+	}
+	return accepted
+}
+
+// append the extra environment variables to env in name order. the variables
+// already in env are kept, they can't be overridden by the client.
+func mergeEnv(env []string, extra map[string]string) []string {
+	exist := make(map[string]bool)
+	for _, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		exist[k] = true
+	}
+
+	names := make([]string, 0, len(extra))
+	for k := range extra {
+		if !exist[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		env = append(env, k+"="+extra[k])
+	}
+	return env
+}
+
+// get exit code and terminating signal from process state. the exit code is
+// -1 if the process is terminated by signal.
+func exitStatusOf(state *os.ProcessState) (code int, sig int) {
+	code = state.ExitCode()
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		sig = int(ws.Signal())
+	}
+	return
+}
+
 func getTimeFrom(env string, def int64) (ret int64) {
 	ret = def
 
@@ -1264,6 +1605,52 @@ func (e *messageError) Error() string {
 	return e.reason + ": " + e.err.Error()
 }
 
+// return the hidden command args of worker, they are passed by the envArgs
+// environment variable instead of command line.
+func childArgs(conf *Config) []string {
+	// hide the following command args from ps command
+	args := []string{"-child", "-destination", conf.destination, "-term", conf.term}
+	// inherit vervoce and source options form parent
+	switch conf.verbose {
+	case util.DebugVerbose:
+		args = append(args, "-v")
+	case util.TraceVerbose:
+		args = append(args, "-vv")
+	}
+	if conf.addSource {
+		args = append(args, "-source")
+	}
+	args = append(args, "-caps", conf.caps)
+	if conf.command != "" {
+		args = append(args, "-command", conf.command)
+	}
+	if conf.env != "" {
+		// the environment variables are sent on stdin, see startChildProcess()
+		args = append(args, "-env", "-")
+	}
+	if conf.agent {
+		args = append(args, "-agent")
+	}
+	if conf.forward != "" {
+		args = append(args, "-forward", conf.forward)
+	}
+	args = append(args, "-scrollback", strconv.Itoa(conf.scrollback))
+	if conf.cwd != "" {
+		args = append(args, "-cwd", conf.cwd)
+	}
+	if conf.charset != "" {
+		args = append(args, "-charset", conf.charset)
+	}
+	if conf.terminfo != "" {
+		args = append(args, "-terminfo", conf.terminfo)
+	}
+	if conf.allowTcpForwarding != "" && conf.allowTcpForwarding != tcpForwardingModes[0] {
+		args = append(args, "-allow-tcp-forwarding", conf.allowTcpForwarding)
+	}
+
+	return args
+}
+
 func startChildProcess(conf *Config) (*os.Process, error) {
 	// conf{term,user,desiredPort,destination}
 
@@ -1278,20 +1665,7 @@ func startChildProcess(conf *Config) (*os.Process, error) {
 		// util.Logger.Debug("startChildProcess got commandPath from env", "commandPath", commandPath)
 	}
 	commandArgv := []string{commandPath, "-p", conf.desiredPort}
-
-	// hide the following command args from ps command
-	args := []string{"-child", "-destination", conf.destination, "-term", conf.term}
-	// inherit vervoce and source options form parent
-	switch conf.verbose {
-	case util.DebugVerbose:
-		args = append(args, "-v")
-	case util.TraceVerbose:
-		args = append(args, "-vv")
-	}
-	if conf.addSource {
-		args = append(args, "-source")
-	}
-	args = append(args, "-caps", conf.caps)
+	args := childArgs(conf)
 
 	// var pts *os.File
 	// var pr *io.PipeReader
@@ -1381,14 +1755,34 @@ func startChildProcess(conf *Config) (*os.Process, error) {
 	// 	Gid: uint32(gid),
 	// }
 
+	// the client environment variables are sent on stdin, they are not
+	// visible in the command line or the process environment.
+	stdin := os.Stdin
+	var pw *os.File
+	if conf.env != "" {
+		pr, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		defer pr.Close()
+		stdin, pw = pr, w
+	}
+
 	procAttr := os.ProcAttr{
-		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr}, // use pts as stdin, stdout, stderr
+		Files: []*os.File{stdin, os.Stdout, os.Stderr}, // use pts as stdin, stdout, stderr
 		Dir:   u.HomeDir,
 		Sys:   sysProcAttr,
 		Env:   env,
 	}
 
-	return os.StartProcess(commandPath, commandArgv, &procAttr)
+	proc, err := os.StartProcess(commandPath, commandArgv, &procAttr)
+	if pw != nil {
+		if err == nil {
+			pw.WriteString(conf.env + "\n")
+		}
+		pw.Close()
+	}
+	return proc, err
 	// proc, err := os.StartProcess(commandPath, commandArgv, &procAttr)
 	// if err != nil {
 	// 	return nil, err
@@ -1427,8 +1821,10 @@ func startShellProcess(pts *os.File, pr *io.PipeReader, _ string, conf *Config)
 
 	var env []string
 
-	// set TERM based on client TERM
-	if conf.term != "" {
+	// set TERM based on client TERM, the aprilsh terminfo entry takes priority
+	if conf.terminfo != "" {
+		env = append(env, "TERM="+conf.terminfo)
+	} else if conf.term != "" {
 		env = append(env, "TERM="+conf.term)
 	} else {
 		env = append(env, "TERM=xterm-256color")
@@ -1453,8 +1849,34 @@ func startShellProcess(pts *os.File, pr *io.PipeReader, _ string, conf *Config)
 	// prepare shell for target user.
 	conf.commandArgv = []string{}
 	conf.commandPath = ""
+	if conf.command != "" {
+		// run the command requested by client, instead of login shell.
+		// commandArgv is in the form of: command path, argv[0], argv[1] ...
+		argv, err := frontend.DecodeCommand([]byte(conf.command))
+		if err != nil || len(argv) == 0 {
+			return nil, fmt.Errorf("malform command %q: %w", conf.command, err)
+		}
+		conf.commandArgv = append([]string{argv[0]}, argv...)
+	}
 	conf.prepareShell(u)
 
+	// search command in PATH if it's not a path.
+	if !strings.Contains(conf.commandPath, "/") {
+		path, err := exec.LookPath(conf.commandPath)
+		if err != nil {
+			return nil, err
+		}
+		conf.commandPath = path
+	}
+
+	// for command, SHELL is still the user login shell
+	shell := conf.commandPath
+	if conf.command != "" {
+		if s, err := util.GetShell4(u); err == nil && len(s) > 0 {
+			shell = s
+		}
+	}
+
 	var uid int64
 	var gid int64
 	if changeUser {
@@ -1462,12 +1884,15 @@ func startShellProcess(pts *os.File, pr *io.PipeReader, _ string, conf *Config)
 		gid, _ = strconv.ParseInt(u.Gid, 10, 32)
 	}
 
+	// start in the directory requested by client, HOME by default
+	dir := workingDir(conf.cwd, u.HomeDir)
+
 	// set base env
 	// TODO should we put LOGNAME, MAIL into env?
-	env = append(env, "PWD="+u.HomeDir)
+	env = append(env, "PWD="+dir)
 	env = append(env, "HOME="+u.HomeDir) // it's important for shell to source .profile
 	env = append(env, "USER="+conf.user)
-	env = append(env, "SHELL="+conf.commandPath)
+	env = append(env, "SHELL="+shell)
 
 	if v := os.Getenv("TZ"); len(v) > 0 {
 		env = append(env, "TZ="+v)
@@ -1487,6 +1912,20 @@ func startShellProcess(pts *os.File, pr *io.PipeReader, _ string, conf *Config)
 	// ask ncurses to send UTF-8 instead of ISO 2022 for line-drawing chars
 	env = append(env, "NCURSES_NO_UTF8_ACS=1")
 
+	// ssh agent forwarding socket
+	if conf.agentSock != "" {
+		env = append(env, "SSH_AUTH_SOCK="+conf.agentSock)
+	}
+
+	// add client environment variables, which is already filtered by -accept-env
+	if conf.env != "" {
+		clientEnv, err := frontend.DecodeEnv([]byte(conf.env))
+		if err != nil {
+			return nil, err
+		}
+		env = mergeEnv(env, clientEnv)
+	}
+
 	util.Logger.Debug("start shell check user", "user", u.Username, "gid", u.Gid, "HOME", u.HomeDir)
 	util.Logger.Debug("start shell check env", "env", env)
 	util.Logger.Debug("start shell check command",
@@ -1504,7 +1943,7 @@ func startShellProcess(pts *os.File, pr *io.PipeReader, _ string, conf *Config)
 
 	procAttr := os.ProcAttr{
 		Files: []*os.File{pts, pts, pts}, // use pts as stdin, stdout, stderr
-		Dir:   u.HomeDir,
+		Dir:   dir,
 		Sys:   sysProcAttr,
 		Env:   env,
 	}
@@ -1575,18 +2014,25 @@ func startShellProcess(pts *os.File, pr *io.PipeReader, _ string, conf *Config)
 		util.Logger.Info("start shell with pty", "pty", pts.Name())
 	}
 
-	return os.StartProcess(conf.commandPath, conf.commandArgv, &procAttr)
-	// proc, err := os.StartProcess(conf.commandPath, conf.commandArgv, &procAttr)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// // util.Logger.Info("start shell done", "shellPid", proc.Pid)
-	// return proc, nil
+	proc, err := os.StartProcess(conf.commandPath, conf.commandArgv, &procAttr)
+	if err != nil && dir != u.HomeDir {
+		// the child changes to the directory as the login user, who may not
+		// have access to it. start in HOME instead.
+		util.Logger.Warn("start shell in working directory", "dir", dir, "error", err)
+		procAttr.Dir = u.HomeDir
+		for i := range procAttr.Env {
+			if strings.HasPrefix(procAttr.Env[i], "PWD=") {
+				procAttr.Env[i] = "PWD=" + u.HomeDir
+			}
+		}
+		proc, err = os.StartProcess(conf.commandPath, conf.commandArgv, &procAttr)
+	}
+	return proc, err
 }
 
-func serve(ptmx *os.File, pts *os.File, pw *io.PipeWriter, complete *statesync.Complete, // waitChan chan bool,
-	server *network.Transport[*statesync.Complete, *statesync.UserStream],
-	networkTimeout int64, networkSignaledTimeout int64, user string,
+func serve(ptmx *os.File, pts *os.File, pw *io.PipeWriter, complete *statesync.Complete,
+	exitChan chan *os.ProcessState, mux *frontend.Mux, server *network.Transport[*statesync.Complete, *statesync.UserStream],
+	networkTimeout int64, networkSignaledTimeout int64, user string, charset encoding.Encoding,
 ) error {
 	// scale timeouts
 	networkTimeoutMs := networkTimeout * 1000
@@ -1605,6 +2051,12 @@ func serve(ptmx *os.File, pts *os.File, pw *io.PipeWriter, complete *statesync.C
 	var terminalToHost strings.Builder
 	var timeSinceRemoteState int64
 
+	// the user input is converted to the charset of pty
+	var host io.StringWriter = ptmx
+	if !util.IsUtf8Charset(charset) {
+		host = util.NewCharsetWriter(ptmx, charset)
+	}
+
 	// var networkChan chan frontend.Message
 	networkChan := make(chan frontend.Message, 1)
 	fileChan := make(chan frontend.Message, 1)
@@ -1623,7 +2075,7 @@ func serve(ptmx *os.File, pts *os.File, pw *io.PipeWriter, complete *statesync.C
 	// is reset back to blocking IO mode.
 	// syscall.SetNonblock(int(ptmx.Fd()), true)
 	eg.Go(func() error {
-		frontend.ReadFromFile(10, fileChan, fileDownChan, ptmx)
+		frontend.ReadFromFile(10, fileChan, fileDownChan, util.NewCharsetReader(ptmx, charset))
 		return nil
 	})
 
@@ -1705,6 +2157,14 @@ mainLoop:
 
 				// apply userstream to terminal
 				for i := 0; i < us.Size(); i++ {
+					if msg, ok := us.GetChannel(i); ok {
+						mux.Handle(msg)
+						continue
+					}
+					if req, ok := us.GetHistory(i); ok {
+						complete.ReplyHistory(req)
+						continue
+					}
 					action := us.GetAction(i)
 					if res, ok := action.(terminal.Resize); ok {
 						//  apply only the last consecutive Resize action
@@ -1801,6 +2261,12 @@ mainLoop:
 					childReleased = true
 				}
 			}
+		case msg := <-mux.Out():
+			// forwarding channel message for client
+			if !server.ShutdownInProgress() {
+				complete.PushChannel(msg)
+				server.SetCurrentState(complete)
+			}
 		case remains := <-largeFeed:
 			now = time.Now().UnixMilli()
 			p := server.GetLatestRemoteState()
@@ -1833,6 +2299,19 @@ mainLoop:
 					if !signals.AnySignal() { // avoid conflict with signal
 						util.Logger.Debug("shutdown", "from", "read file failed", "port", server.GetServerPort())
 						// &fs.PathError{Op:"read", Path:"/dev/ptmx", Err:0x5}
+						//
+						// the shell is finished, replicate its exit status with the
+						// shutdown state. current state can't be changed after
+						// StartShutdown().
+						select {
+						case state := <-exitChan:
+							code, sig := exitStatusOf(state)
+							complete.SetExitStatus(code, sig)
+							server.SetCurrentState(complete)
+							util.Logger.Debug("shell exit status", "code", code, "signal", sig)
+						case <-time.After(exitStatusTimeout * time.Millisecond):
+							util.Logger.Warn("shell exit status", "error", "timeout")
+						}
 						server.StartShutdown()
 					}
 				} else {
@@ -1853,7 +2332,7 @@ mainLoop:
 
 		// write user input and terminal writeback to the host
 		if terminalToHost.Len() > 0 {
-			_, err := ptmx.WriteString(terminalToHost.String())
+			_, err := host.WriteString(terminalToHost.String())
 			if err != nil && !signals.AnySignal() { // avoid conflict with signal
 				server.StartShutdown()
 			}
@@ -2087,11 +2566,16 @@ func runChild(conf *Config) (err error) {
 	// util.Log.Debug("init terminal size", "cols", windowSize.Col, "rows", windowSize.Row)
 
 	// open parser and terminal
-	savedLines := terminal.SaveLinesRowsOption
+	savedLines := conf.scrollback
 	terminal, err := statesync.NewComplete(int(windowSize.Col), int(windowSize.Row), savedLines)
 	caps, err := frontend.DecodeTerminalCaps([]byte(conf.caps))
 	util.Logger.Debug("runChild", "caps", caps)
 	terminal.SetTerminalCaps(caps)
+	if conf.terminfo != "" {
+		terminal.SetTermName(conf.terminfo)
+	} else {
+		terminal.SetTermName(conf.term)
+	}
 
 	// open network
 	blank := &statesync.UserStream{}
@@ -2142,6 +2626,46 @@ func runChild(conf *Config) (err error) {
 	// prepare host field for utmp record
 	utmpHost := fmt.Sprintf("%s:%s", frontend.CommandServerName, server.GetServerPort())
 
+	// exit status of shell is sent to serve()
+	exitChan := make(chan *os.ProcessState, 1)
+
+	// forwarding channels, server accepts tcp forwarding opened by client
+	// unless it's disabled.
+	local, remote := conf.tcpForwarding()
+	dial := frontend.DialForward
+	if !local {
+		dial = nil
+	}
+	mux := frontend.NewMux(2, dial)
+	defer mux.Close()
+
+	// ssh agent forwarding
+	if conf.agent {
+		ln, sock, err := listenAgent(conf.user)
+		if err != nil {
+			util.Logger.Warn("ssh agent forwarding failed", "error", err)
+		} else {
+			conf.agentSock = sock
+			mux.Serve(ln, "agent")
+			defer os.RemoveAll(filepath.Dir(sock))
+		}
+	}
+
+	// remote tcp forwarding
+	if conf.forward != "" && !remote {
+		util.Logger.Warn("remote forwarding is not allowed", "allow-tcp-forwarding", conf.allowTcpForwarding)
+	} else if conf.forward != "" {
+		forwards, _ := decodeForward(conf.forward)
+		for _, f := range forwards {
+			ln, err := listenForward(conf.user, f)
+			if err != nil {
+				util.Logger.Warn("remote forwarding failed", "listen", f.Listen, "error", err)
+				continue
+			}
+			mux.Serve(ln, f.ChannelTarget())
+		}
+	}
+
 	// start the udp server, serve the udp request
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -2156,7 +2680,7 @@ func runChild(conf *Config) (err error) {
 				util.Logger.Warn("runChild can't update utmp")
 			}
 		}
-		conf.serve(ptmx, pts, pw, terminal, server, networkTimeout, networkSignaledTimeout, conf.user)
+		conf.serve(ptmx, pts, pw, terminal, exitChan, mux, server, networkTimeout, networkSignaledTimeout, conf.user, conf.ptyCharset)
 		uxClient.send(fmt.Sprintf("%s:%s,%s", _ServeHeader, conf.desiredPort, "shutdown"))
 
 		// clear utmp entry
@@ -2182,6 +2706,9 @@ func runChild(conf *Config) (err error) {
 		// wait for the shell to finish.
 		var state *os.ProcessState
 		state, err = shell.Wait()
+		if state != nil {
+			exitChan <- state
+		}
 		if err != nil || state.Exited() {
 			if err != nil {
 				util.Logger.Warn("shell.Wait fail", "error", err, "state", state)
@@ -2298,6 +2825,10 @@ func main() {
 
 	// run child process
 	if conf.child {
+		// the daemon sends the environment variables on stdin, see startChildProcess()
+		if conf.env == "-" {
+			conf.env = readEnv(os.Stdin)
+		}
 		runChild(conf)
 		return
 	}
@@ -14,6 +14,8 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
@@ -27,8 +29,10 @@ import (
 	"github.com/ericwq/aprilsh/frontend"
 	"github.com/ericwq/aprilsh/network"
 	"github.com/ericwq/aprilsh/statesync"
+	"github.com/ericwq/aprilsh/terminal"
 	"github.com/ericwq/aprilsh/util"
 	"golang.org/x/sys/unix"
+	"golang.org/x/text/encoding"
 )
 
 func TestPrintMotd(t *testing.T) {
@@ -101,7 +105,7 @@ func TestPrintVersion(t *testing.T) {
 	}
 }
 
-var cmdOptions = "[-s] [-v[v]] [-i LOCALADDR] [-p PORT[:PORT2]] [-l NAME=VALUE] [-- command...]"
+var cmdOptions = "[-s] [-v[v]] [-i LOCALADDR] [-p PORT[:PORT2]] [-l NAME=VALUE] [-accept-env PATTERN] [-allow-tcp-forwarding MODE] [-scrollback N] [-- command...]"
 
 func TestPrintUsage(t *testing.T) {
 	tc := []struct {
@@ -454,6 +458,7 @@ func TestParseFlagsCorrect(t *testing.T) {
 			[]string{"-locale", "ALL=en_US.UTF-8", "-l", "LANG=UTF-8"},
 			Config{
 				version: false, server: false, verbose: 0, desiredIP: "", desiredPort: "8100",
+				scrollback: terminal.SaveLinesRowsOption, allowTcpForwarding: "yes",
 				locales:     localeFlag{"ALL": "en_US.UTF-8", "LANG": "UTF-8"},
 				commandPath: "", commandArgv: []string{}, withMotd: false,
 			},
@@ -462,6 +467,7 @@ func TestParseFlagsCorrect(t *testing.T) {
 			[]string{"--", "/bin/sh", "-sh"},
 			Config{
 				version: false, server: false, verbose: 0, desiredIP: "", desiredPort: "8100",
+				scrollback: terminal.SaveLinesRowsOption, allowTcpForwarding: "yes",
 				locales:     localeFlag{},
 				commandPath: "", commandArgv: []string{"/bin/sh", "-sh"}, withMotd: false,
 			},
@@ -470,6 +476,7 @@ func TestParseFlagsCorrect(t *testing.T) {
 			[]string{"--", ""},
 			Config{
 				version: false, server: false, verbose: 0, desiredIP: "", desiredPort: "8100",
+				scrollback: terminal.SaveLinesRowsOption, allowTcpForwarding: "yes",
 				locales:     localeFlag{},
 				commandPath: "", commandArgv: []string{""}, withMotd: false,
 			},
@@ -632,6 +639,114 @@ func TestGetShellNameFrom(t *testing.T) {
 	}
 }
 
+func TestAcceptEnv(t *testing.T) {
+	env := map[string]string{"LANG": "C.UTF-8", "LC_ALL": "C", "EDITOR": "vi", "LD_PRELOAD": "x.so"}
+	tc := []struct {
+		label    string
+		patterns []string
+		expect   map[string]string
+	}{
+		{"accept nothing by default", nil, map[string]string{}},
+		{"accept locale", []string{"LANG", "LC_*"}, map[string]string{"LANG": "C.UTF-8", "LC_ALL": "C"}},
+		{"accept all", []string{"*"}, env},
+	}
+
+	for _, v := range tc {
+		if got := acceptEnv(env, v.patterns); !reflect.DeepEqual(got, v.expect) {
+			t.Errorf("%q expect %v, got %v\n", v.label, v.expect, got)
+		}
+	}
+
+	// -accept-env could be repeated, each one contains space separated patterns
+	conf, _, err := parseFlags("prog", []string{"-accept-env", "LANG LC_*", "-accept-env", "EDITOR"})
+	if err != nil {
+		t.Fatalf("parseFlags expect nil error, got %s\n", err)
+	}
+	expect := patternFlag{"LANG", "LC_*", "EDITOR"}
+	if !reflect.DeepEqual(conf.acceptEnv, expect) {
+		t.Errorf("-accept-env expect %v, got %v\n", expect, conf.acceptEnv)
+	}
+}
+
+func TestDecodeForward(t *testing.T) {
+	tc := []struct {
+		label  string
+		specs  []string
+		expect []frontend.Forward
+		ok     bool
+	}{
+		{"one forwarding", []string{"8080:localhost:80"}, []frontend.Forward{{Listen: "localhost:8080", Target: "localhost:80"}}, true},
+		{
+			"two forwarding", []string{"*:8080:localhost:80", "9000:db:5432"},
+			[]frontend.Forward{{Listen: ":8080", Target: "localhost:80"}, {Listen: "localhost:9000", Target: "db:5432"}}, true,
+		},
+		{"bad forwarding", []string{"8080:localhost"}, nil, false},
+	}
+
+	for _, v := range tc {
+		got, err := decodeForward(string(frontend.EncodeForward(v.specs)))
+		if (err == nil) != v.ok || !reflect.DeepEqual(got, v.expect) {
+			t.Errorf("%q expect %v (ok=%t), got %v, %v\n", v.label, v.expect, v.ok, got, err)
+		}
+	}
+
+	// privileged port is only allowed for root
+	if u, err := user.Current(); err == nil && u.Uid != "0" {
+		f := frontend.Forward{Listen: "localhost:80", Target: "localhost:8080"}
+		if _, err := listenForward(u.Username, f); err == nil {
+			t.Errorf("listenForward expect error for privileged port, got nil\n")
+		}
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	tc := []struct {
+		label  string
+		env    []string
+		extra  map[string]string
+		expect []string
+	}{
+		{"no extra", []string{"TERM=xterm"}, nil, []string{"TERM=xterm"}},
+		{
+			"sorted extra", []string{"TERM=xterm"}, map[string]string{"B": "2", "A": "1"},
+			[]string{"TERM=xterm", "A=1", "B=2"},
+		},
+		{
+			"can't override", []string{"TERM=xterm", "HOME=/home/u"}, map[string]string{"HOME": "/tmp", "TERM": "vt100"},
+			[]string{"TERM=xterm", "HOME=/home/u"},
+		},
+	}
+
+	for _, v := range tc {
+		if got := mergeEnv(v.env, v.extra); !reflect.DeepEqual(got, v.expect) {
+			t.Errorf("%q expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestExitStatusOf(t *testing.T) {
+	tc := []struct {
+		label  string
+		script string
+		code   int
+		sig    int
+	}{
+		{"exit normally", "exit 0", 0, 0},
+		{"exit with code", "exit 3", 3, 0},
+		{"killed by signal", "kill -9 $$", -1, 9},
+	}
+
+	for _, v := range tc {
+		cmd := exec.Command("/bin/sh", "-c", v.script)
+		cmd.Run()
+
+		code, sig := exitStatusOf(cmd.ProcessState)
+		if code != v.code || sig != v.sig {
+			t.Errorf("%q expect (%d,%d), got (%d,%d)\n", v.label, v.code, v.sig, code, sig)
+		}
+	}
+}
+
 func TestGetTimeFrom(t *testing.T) {
 	tc := []struct {
 		lable      string
@@ -1205,12 +1320,13 @@ func TestMalformRequest(t *testing.T) {
 	tc := []struct {
 		label        string
 		resp         string // response client read
+		request      string // request client send
 		conf         Config
 		pause        int // pause between client send and read
 		shutdownTime int // pause before shutdown message
 	}{
 		{
-			"run() malform request", "malform request",
+			"run() malform request", "malform request", "extraParam",
 			Config{
 				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7700",
 				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
@@ -1218,6 +1334,69 @@ func TestMalformRequest(t *testing.T) {
 			},
 			20, 150,
 		},
+		{
+			"run() malform command", "malform command", "xterm,user@localhost,caps,bad command",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7710",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
+		{
+			"run() malform env", "malform env", "xterm,user@localhost,caps,,bad env",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7720",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
+		{
+			"run() malform agent", "malform request", "xterm,user@localhost,caps,,,bad agent",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7730",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
+		{
+			"run() malform forward", "malform forward", "xterm,user@localhost,caps,,,,bad forward",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7740",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
+		{
+			"run() malform scrollback", "malform scrollback", "xterm,user@localhost,caps,,,,,-1",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7750",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
+		{
+			"run() malform cwd", "malform cwd", "xterm,user@localhost,caps,,,,,,bad cwd",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7760",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
+		{
+			"run() malform terminfo", "malform terminfo", "xterm,user@localhost,caps,,,,,,,xterm",
+			Config{
+				version: false, server: true, verbose: 0, desiredIP: "", desiredPort: "7770",
+				locales:     localeFlag{"LC_ALL": "en_US.UTF-8", "LANG": "en_US.UTF-8"},
+				commandPath: "/bin/sh", commandArgv: []string{"/bin/sh"}, withMotd: false,
+			},
+			20, 150,
+		},
 	}
 
 	for _, v := range tc {
@@ -1242,7 +1421,7 @@ func TestMalformRequest(t *testing.T) {
 		m.start(&v.conf)
 
 		// mock client operation
-		resp := mockClient(v.conf.desiredPort, v.pause, frontend.AprilshMsgOpen, "extraParam")
+		resp := mockClient(v.conf.desiredPort, v.pause, frontend.AprilshMsgOpen, v.request)
 
 		m.wait()
 
@@ -1252,9 +1431,9 @@ func TestMalformRequest(t *testing.T) {
 	}
 }
 
-func mockServe(ptmx *os.File, pts *os.File, pw *io.PipeWriter, terminal *statesync.Complete, // x chan bool,
-	network *network.Transport[*statesync.Complete, *statesync.UserStream],
-	networkTimeout int64, networkSignaledTimeout int64, user string,
+func mockServe(ptmx *os.File, pts *os.File, pw *io.PipeWriter, terminal *statesync.Complete,
+	exitChan chan *os.ProcessState, mux *frontend.Mux, network *network.Transport[*statesync.Complete, *statesync.UserStream],
+	networkTimeout int64, networkSignaledTimeout int64, user string, charset encoding.Encoding,
 ) error {
 	time.Sleep(10 * time.Millisecond)
 	// x <- true
@@ -2388,6 +2567,88 @@ func TestBuildConfig2(t *testing.T) {
 	}
 }
 
+func TestBuildConfigCharset(t *testing.T) {
+	tc := []struct {
+		label   string
+		charset string
+		legacy  bool
+		hint    string
+	}{
+		{"default", "", false, ""},
+		{"utf-8", "UTF-8", false, ""},
+		{"legacy charset", "GB18030", true, ""},
+		{"unsupported charset", "UNKNOWN-1", false, "unsupported charset \"UNKNOWN-1\""},
+	}
+
+	for _, v := range tc {
+		// the result of locale check depends on the test environment
+		cfg := &Config{charset: v.charset}
+		hint, ok := cfg.buildConfig()
+		if (cfg.ptyCharset != nil) != v.legacy {
+			t.Errorf("%s expect legacy charset %t, got %v\n", v.label, v.legacy, cfg.ptyCharset)
+		}
+		if v.hint != "" && (ok || hint != v.hint) {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.hint, hint)
+		}
+	}
+}
+
+func TestTcpForwarding(t *testing.T) {
+	tc := []struct {
+		mode   string
+		local  bool
+		remote bool
+	}{
+		{"", true, true},
+		{"yes", true, true},
+		{"local", true, false},
+		{"remote", false, true},
+		{"no", false, false},
+	}
+
+	for _, v := range tc {
+		conf := &Config{allowTcpForwarding: v.mode}
+		if local, remote := conf.tcpForwarding(); local != v.local || remote != v.remote {
+			t.Errorf("%q expect %t %t, got %t %t\n", v.mode, v.local, v.remote, local, remote)
+		}
+	}
+
+	cfg := &Config{allowTcpForwarding: "all"}
+	hint, ok := cfg.buildConfig()
+	expect := "allow-tcp-forwarding should be one of yes, no, local, remote."
+	if ok || hint != expect {
+		t.Errorf("buildConfig expect %q, got %q\n", expect, hint)
+	}
+}
+
+func TestBuildConfigTerminfo(t *testing.T) {
+	for _, name := range []string{"", terminal.TermAprilsh, terminal.TermAprilshDirect} {
+		if !validTerminfo(name) {
+			t.Errorf("%q expect valid terminfo\n", name)
+		}
+	}
+
+	cfg := &Config{terminfo: "xterm-256color"}
+	hint, ok := cfg.buildConfig()
+	expect := "terminfo should be aprilsh or aprilsh-direct."
+	if ok || hint != expect {
+		t.Errorf("buildConfig expect %q, got %q\n", expect, hint)
+	}
+}
+
+func TestInstallTerminfo(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	path, err := installTerminfo(terminal.TermAprilshDirect)
+	if err != nil || !strings.HasPrefix(path, filepath.Join(home, ".terminfo")) {
+		t.Errorf("installTerminfo expect entry in %s, got %q %v\n", home, path, err)
+	}
+	if _, err = installTerminfo("xterm"); err == nil {
+		t.Errorf("installTerminfo expect error for xterm, got nil\n")
+	}
+}
+
 func TestMessageError(t *testing.T) {
 	tc := []struct {
 		label  string
@@ -2492,3 +2753,72 @@ func TestCloseChild(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkingDir(t *testing.T) {
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "file")
+	os.WriteFile(file, nil, 0o600)
+
+	tc := []struct {
+		label  string
+		cwd    string
+		expect string
+	}{
+		{"not requested", "", "/home"},
+		{"existing directory", string(frontend.EncodeCwd(tmp)), tmp},
+		{"not exist", string(frontend.EncodeCwd(filepath.Join(tmp, "none"))), "/home"},
+		{"not directory", string(frontend.EncodeCwd(file)), "/home"},
+		{"relative path", string(frontend.EncodeCwd("tmp")), "/home"},
+		{"malform", "bad cwd", "/home"},
+	}
+
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+	for _, v := range tc {
+		if got := workingDir(v.cwd, "/home"); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestReadEnv(t *testing.T) {
+	env := string(frontend.EncodeEnv(map[string]string{"LANG": "en_US.UTF-8"}))
+	tc := []struct {
+		label  string
+		input  string
+		expect string
+	}{
+		{"one line", env + "\n", env},
+		{"no newline", env, env},
+		{"empty", "", ""},
+		{"only first line", env + "\nmore\n", env},
+	}
+
+	for _, v := range tc {
+		if got := readEnv(strings.NewReader(v.input)); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestChildArgs(t *testing.T) {
+	env := string(frontend.EncodeEnv(map[string]string{"LANG": "en_US.UTF-8"}))
+	tc := []struct {
+		label  string
+		env    string
+		expect bool
+	}{
+		{"with env", env, true},
+		{"without env", "", false},
+	}
+
+	for _, v := range tc {
+		conf := &Config{destination: "ide@localhost", term: "xterm-256color", env: v.env}
+		args := strings.Join(childArgs(conf), " ")
+		if v.env != "" && strings.Contains(args, v.env) {
+			t.Errorf("%s expect no env payload in %q\n", v.label, args)
+		}
+		if got := strings.Contains(args, "-env -"); got != v.expect {
+			t.Errorf("%s expect -env - %t, got %q\n", v.label, v.expect, args)
+		}
+	}
+}
@@ -15,5 +15,6 @@ require (
 	golang.org/x/sync v0.1.0
 	golang.org/x/sys v0.20.0
 	golang.org/x/term v0.20.0
+	golang.org/x/text v0.15.0
 	google.golang.org/protobuf v1.28.1
 )
@@ -49,6 +49,7 @@ type TransportSender[T State[T]] struct {
 	mindelayClock      int64 // time of first pending change to current state
 	shutdownInProgress bool
 	pendingDataAck     bool
+	held               bool // current state is held back, see State.Hold()
 }
 
 func NewTransportSender[T State[T]](connection *Connection, initialState T) *TransportSender[T] {
@@ -133,7 +134,8 @@ func (ts *TransportSender[T]) rationalizeStates() {
 func (ts *TransportSender[T]) sendToReceiver(diff string) error {
 	var newNum uint64
 	back := len(ts.sentStates) - 1
-	if ts.currentState.Equal(ts.sentStates[back].state) { // previously sent
+	state := ts.sendState()
+	if state.Equal(ts.sentStates[back].state) { // previously sent
 		newNum = ts.sentStates[back].num
 	} else { // new state
 		newNum = ts.sentStates[back].num + 1
@@ -148,7 +150,7 @@ func (ts *TransportSender[T]) sendToReceiver(diff string) error {
 	if newNum == ts.sentStates[back].num {
 		ts.sentStates[back].timestamp = time.Now().UnixMilli()
 	} else {
-		ts.addSentState(time.Now().UnixMilli(), newNum, ts.currentState)
+		ts.addSentState(time.Now().UnixMilli(), newNum, state)
 	}
 
 	if err := ts.sendInFragments(diff, newNum); err != nil {
@@ -174,7 +176,7 @@ func (ts *TransportSender[T]) sendEmptyAck() error {
 		newNum = math.MaxUint64
 	}
 
-	ts.addSentState(now, newNum, ts.currentState)
+	ts.addSentState(now, newNum, ts.sendState())
 	if err := ts.sendInFragments("", newNum); err != nil {
 		return err
 	}
@@ -275,6 +277,15 @@ func (ts *TransportSender[T]) addSentState(timestamp int64, num uint64, state T)
 	}
 }
 
+// return the state to send. while the current state is held, the pending
+// screen update is left out, the other messages are sent as usual.
+func (ts *TransportSender[T]) sendState() T {
+	if ts.held {
+		return ts.currentState.HoldFrom(ts.sentStates[len(ts.sentStates)-1].state)
+	}
+	return ts.currentState
+}
+
 // Housekeeping routine to calculate next send and ack times
 // update assumed receiver state, cut out common prefix of all states
 func (ts *TransportSender[T]) calculateTimers() {
@@ -287,13 +298,15 @@ func (ts *TransportSender[T]) calculateTimers() {
 	ts.rationalizeStates()
 
 	back := len(ts.sentStates) - 1
+	ts.held = !ts.shutdownInProgress && ts.currentState.Hold(now)
+	state := ts.sendState()
 
 	if ts.pendingDataAck && ts.nextAckTime > now+ACK_DELAY {
 		ts.nextAckTime = now + ACK_DELAY // got data from remote, send ack message later
 		// util.Log.Debug("calculateTimers", "status", "pendingDataAck", "nextAckTime", ts.nextAckTime)
 	}
 
-	if !ts.currentState.Equal(ts.sentStates[back].state) {
+	if !state.Equal(ts.sentStates[back].state) {
 		// currentState is not the last sent states
 		if ts.mindelayClock == math.MaxInt64 {
 			ts.mindelayClock = now
@@ -305,7 +318,7 @@ func (ts *TransportSender[T]) calculateTimers() {
 		// we change from Max to Min to avoid duplicat message
 		// util.Log.Debug("calculateTimers", "status", "currentState!=lastSendStates",
 		// 	"nextSendTime", ts.nextSendTime)
-	} else if !ts.currentState.Equal(ts.assumedReceiverState.state) && ts.lastHeard+ACTIVE_RETRY_TIMEOUT > now {
+	} else if !state.Equal(ts.assumedReceiverState.state) && ts.lastHeard+ACTIVE_RETRY_TIMEOUT > now {
 		// currentState is last sent state but not the assumed receiver state
 		ts.nextSendTime = ts.sentStates[back].timestamp + int64(ts.sendInterval())
 		if ts.mindelayClock != math.MaxInt64 {
@@ -313,7 +326,7 @@ func (ts *TransportSender[T]) calculateTimers() {
 		}
 		// util.Log.Debug("calculateTimers", "status", "currentState==lastSendStates!=AssumedState",
 		// 	"nextSendTime", ts.nextSendTime, "lastHeard", ts.lastHeard)
-	} else if !ts.currentState.Equal(ts.sentStates[0].state) && ts.lastHeard+ACTIVE_RETRY_TIMEOUT > now {
+	} else if !state.Equal(ts.sentStates[0].state) && ts.lastHeard+ACTIVE_RETRY_TIMEOUT > now {
 		// currentState is the last and assumed receiver state but not the oldest sent state
 		ts.nextSendTime = ts.sentStates[back].timestamp + ts.connection.timeout() + ACK_DELAY
 		// util.Log.Debug("calculateTimers", "status", "currentState==lastSendStates==AssumedState",
@@ -367,7 +380,8 @@ func (ts *TransportSender[T]) tick() error {
 
 	// util.Logger.Debug("tick", "point", 100, "assumedReceiverState", ts.getAssumedReceiverStateIdx())
 	// Determine if a new diff or empty ack needs to be sent
-	diff := ts.currentState.DiffFrom(ts.assumedReceiverState.state)
+	state := ts.sendState()
+	diff := state.DiffFrom(ts.assumedReceiverState.state)
 	// util.Log.Debug("tick", "point", 200)
 	// diff = ts.attemptProspectiveResendOptimization(diff)
 	// util.Log.Debug("tick","point", 300,"DiffFrom", ts.assumedReceiverState.num)
@@ -383,14 +397,14 @@ func (ts *TransportSender[T]) tick() error {
 		// util.Logger.Trace("tick", "point", 410)
 		newState.ApplyString(diff)
 		// util.Logger.Trace("tick", "point", 420)
-		if !ts.currentState.Equal(newState) {
-			ts.currentState.EqualTrace(newState) // TODO remove this if integration test is finished
+		if !state.Equal(newState) {
+			state.EqualTrace(newState) // TODO remove this if integration test is finished
 			util.Logger.Warn("#tick Warning, round-trip Instruction verification failed!")
 		}
 
 		// Also verify that both the original frame and generated frame have the same initial diff.
 		// util.Log.Debug("tick","point", 500)
-		currentDiff := ts.currentState.InitDiff()
+		currentDiff := state.InitDiff()
 		// util.Log.Debug("tick","point", 600)
 		newDiff := newState.InitDiff()
 		if currentDiff != newDiff {
@@ -401,7 +415,10 @@ func (ts *TransportSender[T]) tick() error {
 		// util.Log.Debug("tick","point", 700)
 	}
 	// util.Log.SetLevel(slog.LevelDebug)
-	ts.currentState.Reset()
+	if !ts.held {
+		// keep the pending diff of held state
+		ts.currentState.Reset()
+	}
 	// util.Log.Debug("tick","point", 800,"lastRows", 0)
 
 	// fmt.Printf("#tick send %q to receiver %s.\n", diff, ts.connection.getRemoteAddr())
@@ -800,3 +800,62 @@ func TestSenderTickNextSendTime(t *testing.T) {
 	server.Close()
 	client.Close()
 }
+
+func TestSenderTickSyncOutput(t *testing.T) {
+	initialStateSrv, _ := statesync.NewComplete(80, 40, 40)
+	initialRemoteSrv := &statesync.UserStream{}
+	desiredIp := ""
+	desiredPort := "6012"
+	server := NewTransportServer(initialStateSrv, initialRemoteSrv, desiredIp, desiredPort)
+
+	initialState := &statesync.UserStream{}
+	initialRemote, _ := statesync.NewComplete(80, 40, 40)
+	keyStr := server.connection.getKey() // get the key from server
+	client := NewTransportClient(initialState, initialRemote, keyStr, desiredIp, desiredPort)
+
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+
+	// send user stream to server, so server knows the client address
+	pushUserBytesTo(client.GetCurrentState(), "mock input.")
+	client.sender.nextAckTime = 0
+	client.Tick()
+	time.Sleep(time.Millisecond * 20)
+	server.Recv()
+
+	// the application begins synchronized update, and rings the bell
+	server.GetCurrentState().Act("\x1B[?2026hhello\a")
+	server.sender.nextAckTime = 0
+	server.Tick()
+	if !server.sender.held {
+		t.Errorf("#test server expect held state\n")
+	}
+
+	// the screen update is held back, the event is sent
+	time.Sleep(time.Millisecond * 20)
+	client.Recv()
+	remote := client.GetLatestRemoteState()
+	if diff := remote.GetState().GetDiff(); strings.Contains(diff, "hello") {
+		t.Errorf("#test client expect no held content, got %q\n", diff)
+	}
+	if events, _ := remote.GetState().TakeEvents(0); len(events) != 1 || events[0].Kind != statesync.EventBell {
+		t.Errorf("#test client expect the bell event, got %v\n", events)
+	}
+
+	// the application finishes synchronized update
+	server.GetCurrentState().Act(" world\x1B[?2026l")
+	server.sender.nextAckTime = 0
+	server.Tick()
+	if server.sender.held {
+		t.Errorf("#test server expect no held state\n")
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	client.Recv()
+	remote = client.GetLatestRemoteState()
+	if diff := remote.GetState().GetDiff(); !strings.Contains(diff, "hello world") {
+		t.Errorf("#test client expect the whole update, got %q\n", diff)
+	}
+
+	server.Close()
+	client.Close()
+}
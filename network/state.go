@@ -25,6 +25,8 @@ type State[C any] interface {
 	Reset()
 	InitSize(y, x int)
 	Clone() C
+	Hold(now int64) bool // hold back the diff while the state is in the middle of update
+	HoldFrom(x C) C      // the state to send while it's held, see State.Hold()
 }
 
 // timestamp int64
@@ -72,9 +72,14 @@ type Instruction struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Hostbytes *HostBytes     `protobuf:"bytes,2,opt,name=hostbytes,proto3,oneof" json:"hostbytes,omitempty"`
-	Resize    *ResizeMessage `protobuf:"bytes,3,opt,name=resize,proto3,oneof" json:"resize,omitempty"`
-	Echoack   *EchoAck       `protobuf:"bytes,7,opt,name=echoack,proto3,oneof" json:"echoack,omitempty"` // extensions 2 to max;
+	Hostbytes  *HostBytes     `protobuf:"bytes,2,opt,name=hostbytes,proto3,oneof" json:"hostbytes,omitempty"`
+	Resize     *ResizeMessage `protobuf:"bytes,3,opt,name=resize,proto3,oneof" json:"resize,omitempty"`
+	Echoack    *EchoAck       `protobuf:"bytes,7,opt,name=echoack,proto3,oneof" json:"echoack,omitempty"`
+	Exitstatus *ExitStatus    `protobuf:"bytes,9,opt,name=exitstatus,proto3,oneof" json:"exitstatus,omitempty"`
+	Channel    *Channel       `protobuf:"bytes,12,opt,name=channel,proto3,oneof" json:"channel,omitempty"`
+	History    *History       `protobuf:"bytes,18,opt,name=history,proto3,oneof" json:"history,omitempty"`
+	Event      *Event         `protobuf:"bytes,33,opt,name=event,proto3,oneof" json:"event,omitempty"`
+	Image      *Image         `protobuf:"bytes,40,opt,name=image,proto3,oneof" json:"image,omitempty"` // extensions 2 to max;
 }
 
 func (x *Instruction) Reset() {
@@ -130,6 +135,41 @@ func (x *Instruction) GetEchoack() *EchoAck {
 	return nil
 }
 
+func (x *Instruction) GetExitstatus() *ExitStatus {
+	if x != nil {
+		return x.Exitstatus
+	}
+	return nil
+}
+
+func (x *Instruction) GetChannel() *Channel {
+	if x != nil {
+		return x.Channel
+	}
+	return nil
+}
+
+func (x *Instruction) GetHistory() *History {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+func (x *Instruction) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *Instruction) GetImage() *Image {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
 type HostBytes struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -279,6 +319,551 @@ func (x *EchoAck) GetEchoAckNum() uint64 {
 	return 0
 }
 
+type ExitStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ExitCode *int32 `protobuf:"varint,10,opt,name=exit_code,json=exitCode,proto3,oneof" json:"exit_code,omitempty"`
+	Signal   *int32 `protobuf:"varint,11,opt,name=signal,proto3,oneof" json:"signal,omitempty"`
+}
+
+func (x *ExitStatus) Reset() {
+	*x = ExitStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExitStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExitStatus) ProtoMessage() {}
+
+func (x *ExitStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExitStatus.ProtoReflect.Descriptor instead.
+func (*ExitStatus) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ExitStatus) GetExitCode() int32 {
+	if x != nil && x.ExitCode != nil {
+		return *x.ExitCode
+	}
+	return 0
+}
+
+func (x *ExitStatus) GetSignal() int32 {
+	if x != nil && x.Signal != nil {
+		return *x.Signal
+	}
+	return 0
+}
+
+type Channel struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq    *uint64 `protobuf:"varint,13,opt,name=seq,proto3,oneof" json:"seq,omitempty"`
+	Id     *uint32 `protobuf:"varint,14,opt,name=id,proto3,oneof" json:"id,omitempty"`
+	Kind   *uint32 `protobuf:"varint,15,opt,name=kind,proto3,oneof" json:"kind,omitempty"`
+	Data   []byte  `protobuf:"bytes,16,opt,name=data,proto3,oneof" json:"data,omitempty"`
+	Window *uint32 `protobuf:"varint,17,opt,name=window,proto3,oneof" json:"window,omitempty"`
+}
+
+func (x *Channel) Reset() {
+	*x = Channel{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Channel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Channel) ProtoMessage() {}
+
+func (x *Channel) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Channel.ProtoReflect.Descriptor instead.
+func (*Channel) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Channel) GetSeq() uint64 {
+	if x != nil && x.Seq != nil {
+		return *x.Seq
+	}
+	return 0
+}
+
+func (x *Channel) GetId() uint32 {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return 0
+}
+
+func (x *Channel) GetKind() uint32 {
+	if x != nil && x.Kind != nil {
+		return *x.Kind
+	}
+	return 0
+}
+
+func (x *Channel) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Channel) GetWindow() uint32 {
+	if x != nil && x.Window != nil {
+		return *x.Window
+	}
+	return 0
+}
+
+type History struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       *uint32           `protobuf:"varint,19,opt,name=id,proto3,oneof" json:"id,omitempty"`
+	Start    *int32            `protobuf:"varint,20,opt,name=start,proto3,oneof" json:"start,omitempty"`
+	Total    *uint32           `protobuf:"varint,21,opt,name=total,proto3,oneof" json:"total,omitempty"`
+	Rows     [][]byte          `protobuf:"bytes,22,rep,name=rows,proto3" json:"rows,omitempty"`
+	Matches  []*HistoryMatch   `protobuf:"bytes,23,rep,name=matches,proto3" json:"matches,omitempty"`
+	Commands []*HistoryCommand `protobuf:"bytes,27,rep,name=commands,proto3" json:"commands,omitempty"`
+	Output   []byte            `protobuf:"bytes,28,opt,name=output,proto3,oneof" json:"output,omitempty"`
+}
+
+func (x *History) Reset() {
+	*x = History{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *History) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*History) ProtoMessage() {}
+
+func (x *History) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use History.ProtoReflect.Descriptor instead.
+func (*History) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *History) GetId() uint32 {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return 0
+}
+
+func (x *History) GetStart() int32 {
+	if x != nil && x.Start != nil {
+		return *x.Start
+	}
+	return 0
+}
+
+func (x *History) GetTotal() uint32 {
+	if x != nil && x.Total != nil {
+		return *x.Total
+	}
+	return 0
+}
+
+func (x *History) GetRows() [][]byte {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *History) GetMatches() []*HistoryMatch {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+func (x *History) GetCommands() []*HistoryCommand {
+	if x != nil {
+		return x.Commands
+	}
+	return nil
+}
+
+func (x *History) GetOutput() []byte {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+type HistoryMatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Row   *int32  `protobuf:"varint,24,opt,name=row,proto3,oneof" json:"row,omitempty"`
+	Start *uint32 `protobuf:"varint,25,opt,name=start,proto3,oneof" json:"start,omitempty"`
+	End   *uint32 `protobuf:"varint,26,opt,name=end,proto3,oneof" json:"end,omitempty"`
+}
+
+func (x *HistoryMatch) Reset() {
+	*x = HistoryMatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HistoryMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryMatch) ProtoMessage() {}
+
+func (x *HistoryMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryMatch.ProtoReflect.Descriptor instead.
+func (*HistoryMatch) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *HistoryMatch) GetRow() int32 {
+	if x != nil && x.Row != nil {
+		return *x.Row
+	}
+	return 0
+}
+
+func (x *HistoryMatch) GetStart() uint32 {
+	if x != nil && x.Start != nil {
+		return *x.Start
+	}
+	return 0
+}
+
+func (x *HistoryMatch) GetEnd() uint32 {
+	if x != nil && x.End != nil {
+		return *x.End
+	}
+	return 0
+}
+
+type HistoryCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prompt *int32 `protobuf:"varint,29,opt,name=prompt,proto3,oneof" json:"prompt,omitempty"`
+	Output *int32 `protobuf:"varint,30,opt,name=output,proto3,oneof" json:"output,omitempty"`
+	End    *int32 `protobuf:"varint,31,opt,name=end,proto3,oneof" json:"end,omitempty"`
+	Status *int32 `protobuf:"varint,32,opt,name=status,proto3,oneof" json:"status,omitempty"`
+}
+
+func (x *HistoryCommand) Reset() {
+	*x = HistoryCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HistoryCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryCommand) ProtoMessage() {}
+
+func (x *HistoryCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryCommand.ProtoReflect.Descriptor instead.
+func (*HistoryCommand) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *HistoryCommand) GetPrompt() int32 {
+	if x != nil && x.Prompt != nil {
+		return *x.Prompt
+	}
+	return 0
+}
+
+func (x *HistoryCommand) GetOutput() int32 {
+	if x != nil && x.Output != nil {
+		return *x.Output
+	}
+	return 0
+}
+
+func (x *HistoryCommand) GetEnd() int32 {
+	if x != nil && x.End != nil {
+		return *x.End
+	}
+	return 0
+}
+
+func (x *HistoryCommand) GetStatus() int32 {
+	if x != nil && x.Status != nil {
+		return *x.Status
+	}
+	return 0
+}
+
+type Event struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq       *uint64 `protobuf:"varint,34,opt,name=seq,proto3,oneof" json:"seq,omitempty"`
+	Kind      *uint32 `protobuf:"varint,35,opt,name=kind,proto3,oneof" json:"kind,omitempty"`
+	Title     []byte  `protobuf:"bytes,36,opt,name=title,proto3,oneof" json:"title,omitempty"`
+	Body      []byte  `protobuf:"bytes,37,opt,name=body,proto3,oneof" json:"body,omitempty"`
+	Selection []byte  `protobuf:"bytes,38,opt,name=selection,proto3,oneof" json:"selection,omitempty"`
+	Data      []byte  `protobuf:"bytes,39,opt,name=data,proto3,oneof" json:"data,omitempty"`
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Event) GetSeq() uint64 {
+	if x != nil && x.Seq != nil {
+		return *x.Seq
+	}
+	return 0
+}
+
+func (x *Event) GetKind() uint32 {
+	if x != nil && x.Kind != nil {
+		return *x.Kind
+	}
+	return 0
+}
+
+func (x *Event) GetTitle() []byte {
+	if x != nil {
+		return x.Title
+	}
+	return nil
+}
+
+func (x *Event) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *Event) GetSelection() []byte {
+	if x != nil {
+		return x.Selection
+	}
+	return nil
+}
+
+func (x *Event) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type Image struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id   *uint32 `protobuf:"varint,41,opt,name=id,proto3,oneof" json:"id,omitempty"`
+	Kind *uint32 `protobuf:"varint,42,opt,name=kind,proto3,oneof" json:"kind,omitempty"`
+	Cols *uint32 `protobuf:"varint,43,opt,name=cols,proto3,oneof" json:"cols,omitempty"`
+	Rows *uint32 `protobuf:"varint,44,opt,name=rows,proto3,oneof" json:"rows,omitempty"`
+	Data []byte  `protobuf:"bytes,45,opt,name=data,proto3,oneof" json:"data,omitempty"`
+	Ctrl []byte  `protobuf:"bytes,46,opt,name=ctrl,proto3,oneof" json:"ctrl,omitempty"`
+	Drop *bool   `protobuf:"varint,47,opt,name=drop,proto3,oneof" json:"drop,omitempty"`
+}
+
+func (x *Image) Reset() {
+	*x = Image{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_hostInput_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Image) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Image) ProtoMessage() {}
+
+func (x *Image) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_hostInput_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Image.ProtoReflect.Descriptor instead.
+func (*Image) Descriptor() ([]byte, []int) {
+	return file_protobufs_hostInput_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Image) GetId() uint32 {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return 0
+}
+
+func (x *Image) GetKind() uint32 {
+	if x != nil && x.Kind != nil {
+		return *x.Kind
+	}
+	return 0
+}
+
+func (x *Image) GetCols() uint32 {
+	if x != nil && x.Cols != nil {
+		return *x.Cols
+	}
+	return 0
+}
+
+func (x *Image) GetRows() uint32 {
+	if x != nil && x.Rows != nil {
+		return *x.Rows
+	}
+	return 0
+}
+
+func (x *Image) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Image) GetCtrl() []byte {
+	if x != nil {
+		return x.Ctrl
+	}
+	return nil
+}
+
+func (x *Image) GetDrop() bool {
+	if x != nil && x.Drop != nil {
+		return *x.Drop
+	}
+	return false
+}
+
 var File_protobufs_hostInput_proto protoreflect.FileDescriptor
 
 var file_protobufs_hostInput_proto_rawDesc = []byte{
@@ -289,7 +874,7 @@ var file_protobufs_hostInput_proto_rawDesc = []byte{
 	0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x48,
 	0x6f, 0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x49, 0x6e, 0x73, 0x74, 0x72,
 	0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x69, 0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74,
-	0x69, 0x6f, 0x6e, 0x22, 0xdb, 0x01, 0x0a, 0x0b, 0x49, 0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x22, 0x9c, 0x04, 0x0a, 0x0b, 0x49, 0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74,
 	0x69, 0x6f, 0x6e, 0x12, 0x39, 0x0a, 0x09, 0x68, 0x6f, 0x73, 0x74, 0x62, 0x79, 0x74, 0x65, 0x73,
 	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x75, 0x66,
 	0x66, 0x65, 0x72, 0x73, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x48, 0x00,
@@ -300,25 +885,128 @@ var file_protobufs_hostInput_proto_rawDesc = []byte{
 	0x73, 0x69, 0x7a, 0x65, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x07, 0x65, 0x63, 0x68, 0x6f, 0x61,
 	0x63, 0x6b, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42,
 	0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x41, 0x63, 0x6b, 0x48, 0x02,
-	0x52, 0x07, 0x65, 0x63, 0x68, 0x6f, 0x61, 0x63, 0x6b, 0x88, 0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a,
-	0x5f, 0x68, 0x6f, 0x73, 0x74, 0x62, 0x79, 0x74, 0x65, 0x73, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x72,
-	0x65, 0x73, 0x69, 0x7a, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x65, 0x63, 0x68, 0x6f, 0x61, 0x63,
-	0x6b, 0x22, 0x3f, 0x0a, 0x09, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x23,
-	0x0a, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20, 0x01,
-	0x28, 0x0c, 0x48, 0x00, 0x52, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67,
-	0x88, 0x01, 0x01, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x74, 0x72, 0x69,
-	0x6e, 0x67, 0x22, 0x5c, 0x0a, 0x0d, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x12, 0x19, 0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x05, 0x20, 0x01,
-	0x28, 0x05, 0x48, 0x00, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x88, 0x01, 0x01, 0x12, 0x1b,
-	0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x48, 0x01,
-	0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x88, 0x01, 0x01, 0x42, 0x08, 0x0a, 0x06, 0x5f,
-	0x77, 0x69, 0x64, 0x74, 0x68, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
-	0x22, 0x41, 0x0a, 0x07, 0x45, 0x63, 0x68, 0x6f, 0x41, 0x63, 0x6b, 0x12, 0x25, 0x0a, 0x0c, 0x65,
-	0x63, 0x68, 0x6f, 0x5f, 0x61, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x08, 0x20, 0x01, 0x28,
-	0x04, 0x48, 0x00, 0x52, 0x0a, 0x65, 0x63, 0x68, 0x6f, 0x41, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x88,
-	0x01, 0x01, 0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x65, 0x63, 0x68, 0x6f, 0x5f, 0x61, 0x63, 0x6b, 0x5f,
-	0x6e, 0x75, 0x6d, 0x42, 0x11, 0x5a, 0x0f, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
-	0x73, 0x2f, 0x68, 0x6f, 0x73, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x07, 0x65, 0x63, 0x68, 0x6f, 0x61, 0x63, 0x6b, 0x88, 0x01, 0x01, 0x12, 0x3c, 0x0a, 0x0a,
+	0x65, 0x78, 0x69, 0x74, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x17, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x45,
+	0x78, 0x69, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x48, 0x03, 0x52, 0x0a, 0x65, 0x78, 0x69,
+	0x74, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x07, 0x63, 0x68,
+	0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x48, 0x6f,
+	0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65,
+	0x6c, 0x48, 0x04, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x88, 0x01, 0x01, 0x12,
+	0x33, 0x0a, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x48,
+	0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x48, 0x05, 0x52, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72,
+	0x79, 0x88, 0x01, 0x01, 0x12, 0x2d, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x18, 0x21, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72,
+	0x73, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x48, 0x06, 0x52, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x88, 0x01, 0x01, 0x12, 0x2d, 0x0a, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x18, 0x28, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73,
+	0x2e, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x48, 0x07, 0x52, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x88,
+	0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x68, 0x6f, 0x73, 0x74, 0x62, 0x79, 0x74, 0x65, 0x73,
+	0x42, 0x09, 0x0a, 0x07, 0x5f, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x5f,
+	0x65, 0x63, 0x68, 0x6f, 0x61, 0x63, 0x6b, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x65, 0x78, 0x69, 0x74,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x42, 0x08,
+	0x0a, 0x06, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x22, 0x3f, 0x0a, 0x09, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12,
+	0x23, 0x0a, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x74, 0x72, 0x69, 0x6e,
+	0x67, 0x88, 0x01, 0x01, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x74, 0x72,
+	0x69, 0x6e, 0x67, 0x22, 0x5c, 0x0a, 0x0d, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x12, 0x19, 0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x88, 0x01, 0x01, 0x12,
+	0x1b, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x48,
+	0x01, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x88, 0x01, 0x01, 0x42, 0x08, 0x0a, 0x06,
+	0x5f, 0x77, 0x69, 0x64, 0x74, 0x68, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68,
+	0x74, 0x22, 0x41, 0x0a, 0x07, 0x45, 0x63, 0x68, 0x6f, 0x41, 0x63, 0x6b, 0x12, 0x25, 0x0a, 0x0c,
+	0x65, 0x63, 0x68, 0x6f, 0x5f, 0x61, 0x63, 0x6b, 0x5f, 0x6e, 0x75, 0x6d, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x04, 0x48, 0x00, 0x52, 0x0a, 0x65, 0x63, 0x68, 0x6f, 0x41, 0x63, 0x6b, 0x4e, 0x75, 0x6d,
+	0x88, 0x01, 0x01, 0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x65, 0x63, 0x68, 0x6f, 0x5f, 0x61, 0x63, 0x6b,
+	0x5f, 0x6e, 0x75, 0x6d, 0x22, 0x64, 0x0a, 0x0a, 0x45, 0x78, 0x69, 0x74, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x12, 0x20, 0x0a, 0x09, 0x65, 0x78, 0x69, 0x74, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64,
+	0x65, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x06, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x18, 0x0b,
+	0x20, 0x01, 0x28, 0x05, 0x48, 0x01, 0x52, 0x06, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x88, 0x01,
+	0x01, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x65, 0x78, 0x69, 0x74, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x42,
+	0x09, 0x0a, 0x07, 0x5f, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x22, 0xb0, 0x01, 0x0a, 0x07, 0x43,
+	0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x15, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x0d, 0x20,
+	0x01, 0x28, 0x04, 0x48, 0x00, 0x52, 0x03, 0x73, 0x65, 0x71, 0x88, 0x01, 0x01, 0x12, 0x13, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x02, 0x69, 0x64, 0x88,
+	0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0d,
+	0x48, 0x02, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x03, 0x52, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x11,
+	0x20, 0x01, 0x28, 0x0d, 0x48, 0x04, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x88, 0x01,
+	0x01, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x73, 0x65, 0x71, 0x42, 0x05, 0x0a, 0x03, 0x5f, 0x69, 0x64,
+	0x42, 0x07, 0x0a, 0x05, 0x5f, 0x6b, 0x69, 0x6e, 0x64, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x64, 0x61,
+	0x74, 0x61, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x22, 0x99, 0x02,
+	0x0a, 0x07, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x13, 0x0a, 0x02, 0x69, 0x64, 0x18,
+	0x13, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x02, 0x69, 0x64, 0x88, 0x01, 0x01, 0x12, 0x19,
+	0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x14, 0x20, 0x01, 0x28, 0x05, 0x48, 0x01, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x74, 0x6f, 0x74,
+	0x61, 0x6c, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x02, 0x52, 0x05, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x88, 0x01, 0x01, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x18, 0x16, 0x20, 0x03,
+	0x28, 0x0c, 0x52, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x12, 0x33, 0x0a, 0x07, 0x6d, 0x61, 0x74, 0x63,
+	0x68, 0x65, 0x73, 0x18, 0x17, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x48, 0x6f, 0x73, 0x74,
+	0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x4d,
+	0x61, 0x74, 0x63, 0x68, 0x52, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x12, 0x37, 0x0a,
+	0x08, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x1b, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x42, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x48, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x08, 0x63, 0x6f,
+	0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x1b, 0x0a, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x18, 0x1c, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x03, 0x52, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x88, 0x01, 0x01, 0x42, 0x05, 0x0a, 0x03, 0x5f, 0x69, 0x64, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x73,
+	0x74, 0x61, 0x72, 0x74, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x09,
+	0x0a, 0x07, 0x5f, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x22, 0x71, 0x0a, 0x0c, 0x48, 0x69, 0x73,
+	0x74, 0x6f, 0x72, 0x79, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x15, 0x0a, 0x03, 0x72, 0x6f, 0x77,
+	0x18, 0x18, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x03, 0x72, 0x6f, 0x77, 0x88, 0x01, 0x01,
+	0x12, 0x19, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x19, 0x20, 0x01, 0x28, 0x0d, 0x48,
+	0x01, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x65,
+	0x6e, 0x64, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x02, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x88,
+	0x01, 0x01, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x72, 0x6f, 0x77, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x73,
+	0x74, 0x61, 0x72, 0x74, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x65, 0x6e, 0x64, 0x22, 0xa7, 0x01, 0x0a,
+	0x0e, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12,
+	0x1b, 0x0a, 0x06, 0x70, 0x72, 0x6f, 0x6d, 0x70, 0x74, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x05, 0x48,
+	0x00, 0x52, 0x06, 0x70, 0x72, 0x6f, 0x6d, 0x70, 0x74, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x06,
+	0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x18, 0x1e, 0x20, 0x01, 0x28, 0x05, 0x48, 0x01, 0x52, 0x06,
+	0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x65, 0x6e, 0x64,
+	0x18, 0x1f, 0x20, 0x01, 0x28, 0x05, 0x48, 0x02, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x88, 0x01, 0x01,
+	0x12, 0x1b, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x20, 0x20, 0x01, 0x28, 0x05,
+	0x48, 0x03, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x88, 0x01, 0x01, 0x42, 0x09, 0x0a,
+	0x07, 0x5f, 0x70, 0x72, 0x6f, 0x6d, 0x70, 0x74, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x6f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x65, 0x6e, 0x64, 0x42, 0x09, 0x0a, 0x07, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0xe2, 0x01, 0x0a, 0x05, 0x45, 0x76, 0x65, 0x6e, 0x74,
+	0x12, 0x15, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x22, 0x20, 0x01, 0x28, 0x04, 0x48, 0x00, 0x52,
+	0x03, 0x73, 0x65, 0x71, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18,
+	0x23, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x88, 0x01, 0x01,
+	0x12, 0x19, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x24, 0x20, 0x01, 0x28, 0x0c, 0x48,
+	0x02, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x62,
+	0x6f, 0x64, 0x79, 0x18, 0x25, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x03, 0x52, 0x04, 0x62, 0x6f, 0x64,
+	0x79, 0x88, 0x01, 0x01, 0x12, 0x21, 0x0a, 0x09, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x26, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x04, 0x52, 0x09, 0x73, 0x65, 0x6c, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x27, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x05, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x88, 0x01, 0x01,
+	0x42, 0x06, 0x0a, 0x04, 0x5f, 0x73, 0x65, 0x71, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x6b, 0x69, 0x6e,
+	0x64, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x5f,
+	0x62, 0x6f, 0x64, 0x79, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x22, 0xef, 0x01, 0x0a, 0x05,
+	0x49, 0x6d, 0x61, 0x67, 0x65, 0x12, 0x13, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x29, 0x20, 0x01, 0x28,
+	0x0d, 0x48, 0x00, 0x52, 0x02, 0x69, 0x64, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x6b, 0x69,
+	0x6e, 0x64, 0x18, 0x2a, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64,
+	0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x2b, 0x20, 0x01, 0x28,
+	0x0d, 0x48, 0x02, 0x52, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04,
+	0x72, 0x6f, 0x77, 0x73, 0x18, 0x2c, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x03, 0x52, 0x04, 0x72, 0x6f,
+	0x77, 0x73, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x2d, 0x20,
+	0x01, 0x28, 0x0c, 0x48, 0x04, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x88, 0x01, 0x01, 0x12, 0x17,
+	0x0a, 0x04, 0x63, 0x74, 0x72, 0x6c, 0x18, 0x2e, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x05, 0x52, 0x04,
+	0x63, 0x74, 0x72, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x64, 0x72, 0x6f, 0x70, 0x18,
+	0x2f, 0x20, 0x01, 0x28, 0x08, 0x48, 0x06, 0x52, 0x04, 0x64, 0x72, 0x6f, 0x70, 0x88, 0x01, 0x01,
+	0x42, 0x05, 0x0a, 0x03, 0x5f, 0x69, 0x64, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x6b, 0x69, 0x6e, 0x64,
+	0x42, 0x07, 0x0a, 0x05, 0x5f, 0x63, 0x6f, 0x6c, 0x73, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x72, 0x6f,
+	0x77, 0x73, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x42, 0x07, 0x0a, 0x05, 0x5f,
+	0x63, 0x74, 0x72, 0x6c, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x64, 0x72, 0x6f, 0x70, 0x42, 0x11, 0x5a,
+	0x0f, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x73, 0x2f, 0x68, 0x6f, 0x73, 0x74,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -333,24 +1021,38 @@ func file_protobufs_hostInput_proto_rawDescGZIP() []byte {
 	return file_protobufs_hostInput_proto_rawDescData
 }
 
-var file_protobufs_hostInput_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_protobufs_hostInput_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_protobufs_hostInput_proto_goTypes = []interface{}{
-	(*HostMessage)(nil),   // 0: HostBuffers.HostMessage
-	(*Instruction)(nil),   // 1: HostBuffers.Instruction
-	(*HostBytes)(nil),     // 2: HostBuffers.HostBytes
-	(*ResizeMessage)(nil), // 3: HostBuffers.ResizeMessage
-	(*EchoAck)(nil),       // 4: HostBuffers.EchoAck
+	(*HostMessage)(nil),    // 0: HostBuffers.HostMessage
+	(*Instruction)(nil),    // 1: HostBuffers.Instruction
+	(*HostBytes)(nil),      // 2: HostBuffers.HostBytes
+	(*ResizeMessage)(nil),  // 3: HostBuffers.ResizeMessage
+	(*EchoAck)(nil),        // 4: HostBuffers.EchoAck
+	(*ExitStatus)(nil),     // 5: HostBuffers.ExitStatus
+	(*Channel)(nil),        // 6: HostBuffers.Channel
+	(*History)(nil),        // 7: HostBuffers.History
+	(*HistoryMatch)(nil),   // 8: HostBuffers.HistoryMatch
+	(*HistoryCommand)(nil), // 9: HostBuffers.HistoryCommand
+	(*Event)(nil),          // 10: HostBuffers.Event
+	(*Image)(nil),          // 11: HostBuffers.Image
 }
 var file_protobufs_hostInput_proto_depIdxs = []int32{
-	1, // 0: HostBuffers.HostMessage.instruction:type_name -> HostBuffers.Instruction
-	2, // 1: HostBuffers.Instruction.hostbytes:type_name -> HostBuffers.HostBytes
-	3, // 2: HostBuffers.Instruction.resize:type_name -> HostBuffers.ResizeMessage
-	4, // 3: HostBuffers.Instruction.echoack:type_name -> HostBuffers.EchoAck
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	1,  // 0: HostBuffers.HostMessage.instruction:type_name -> HostBuffers.Instruction
+	2,  // 1: HostBuffers.Instruction.hostbytes:type_name -> HostBuffers.HostBytes
+	3,  // 2: HostBuffers.Instruction.resize:type_name -> HostBuffers.ResizeMessage
+	4,  // 3: HostBuffers.Instruction.echoack:type_name -> HostBuffers.EchoAck
+	5,  // 4: HostBuffers.Instruction.exitstatus:type_name -> HostBuffers.ExitStatus
+	6,  // 5: HostBuffers.Instruction.channel:type_name -> HostBuffers.Channel
+	7,  // 6: HostBuffers.Instruction.history:type_name -> HostBuffers.History
+	10, // 7: HostBuffers.Instruction.event:type_name -> HostBuffers.Event
+	11, // 8: HostBuffers.Instruction.image:type_name -> HostBuffers.Image
+	8,  // 9: HostBuffers.History.matches:type_name -> HostBuffers.HistoryMatch
+	9,  // 10: HostBuffers.History.commands:type_name -> HostBuffers.HistoryCommand
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_protobufs_hostInput_proto_init() }
@@ -419,18 +1121,109 @@ func file_protobufs_hostInput_proto_init() {
 				return nil
 			}
 		}
+		file_protobufs_hostInput_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExitStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_hostInput_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Channel); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_hostInput_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*History); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_hostInput_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HistoryMatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_hostInput_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HistoryCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_hostInput_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Event); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_hostInput_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Image); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_protobufs_hostInput_proto_msgTypes[1].OneofWrappers = []interface{}{}
 	file_protobufs_hostInput_proto_msgTypes[2].OneofWrappers = []interface{}{}
 	file_protobufs_hostInput_proto_msgTypes[3].OneofWrappers = []interface{}{}
 	file_protobufs_hostInput_proto_msgTypes[4].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[5].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[6].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[7].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[8].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[9].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[10].OneofWrappers = []interface{}{}
+	file_protobufs_hostInput_proto_msgTypes[11].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_protobufs_hostInput_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
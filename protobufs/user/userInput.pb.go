@@ -74,7 +74,11 @@ type Instruction struct {
 	unknownFields protoimpl.UnknownFields
 
 	Keystroke *Keystroke     `protobuf:"bytes,2,opt,name=keystroke,proto3,oneof" json:"keystroke,omitempty"`
-	Resize    *ResizeMessage `protobuf:"bytes,3,opt,name=resize,proto3,oneof" json:"resize,omitempty"` // extensions 2 to max;
+	Resize    *ResizeMessage `protobuf:"bytes,3,opt,name=resize,proto3,oneof" json:"resize,omitempty"`
+	Channel   *Channel       `protobuf:"bytes,7,opt,name=channel,proto3,oneof" json:"channel,omitempty"`
+	History   *History       `protobuf:"bytes,12,opt,name=history,proto3,oneof" json:"history,omitempty"`
+	Mouse     *Mouse         `protobuf:"bytes,19,opt,name=mouse,proto3,oneof" json:"mouse,omitempty"`
+	Key       *Key           `protobuf:"bytes,25,opt,name=key,proto3,oneof" json:"key,omitempty"` // extensions 2 to max;
 }
 
 func (x *Instruction) Reset() {
@@ -123,6 +127,34 @@ func (x *Instruction) GetResize() *ResizeMessage {
 	return nil
 }
 
+func (x *Instruction) GetChannel() *Channel {
+	if x != nil {
+		return x.Channel
+	}
+	return nil
+}
+
+func (x *Instruction) GetHistory() *History {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+func (x *Instruction) GetMouse() *Mouse {
+	if x != nil {
+		return x.Mouse
+	}
+	return nil
+}
+
+func (x *Instruction) GetKey() *Key {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
 type Keystroke struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -225,6 +257,338 @@ func (x *ResizeMessage) GetHeight() int32 {
 	return 0
 }
 
+type Channel struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     uint32 `protobuf:"varint,8,opt,name=id,proto3" json:"id,omitempty"`
+	Kind   uint32 `protobuf:"varint,9,opt,name=kind,proto3" json:"kind,omitempty"`
+	Data   []byte `protobuf:"bytes,10,opt,name=data,proto3" json:"data,omitempty"`
+	Window uint32 `protobuf:"varint,11,opt,name=window,proto3" json:"window,omitempty"`
+}
+
+func (x *Channel) Reset() {
+	*x = Channel{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_userInput_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Channel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Channel) ProtoMessage() {}
+
+func (x *Channel) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_userInput_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Channel.ProtoReflect.Descriptor instead.
+func (*Channel) Descriptor() ([]byte, []int) {
+	return file_protobufs_userInput_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Channel) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Channel) GetKind() uint32 {
+	if x != nil {
+		return x.Kind
+	}
+	return 0
+}
+
+func (x *Channel) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Channel) GetWindow() uint32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+type History struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       uint32 `protobuf:"varint,13,opt,name=id,proto3" json:"id,omitempty"`
+	Start    int32  `protobuf:"varint,14,opt,name=start,proto3" json:"start,omitempty"`
+	Count    uint32 `protobuf:"varint,15,opt,name=count,proto3" json:"count,omitempty"`
+	Pattern  string `protobuf:"bytes,16,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Commands bool   `protobuf:"varint,17,opt,name=commands,proto3" json:"commands,omitempty"`
+	Output   bool   `protobuf:"varint,18,opt,name=output,proto3" json:"output,omitempty"`
+}
+
+func (x *History) Reset() {
+	*x = History{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_userInput_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *History) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*History) ProtoMessage() {}
+
+func (x *History) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_userInput_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use History.ProtoReflect.Descriptor instead.
+func (*History) Descriptor() ([]byte, []int) {
+	return file_protobufs_userInput_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *History) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *History) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *History) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *History) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *History) GetCommands() bool {
+	if x != nil {
+		return x.Commands
+	}
+	return false
+}
+
+func (x *History) GetOutput() bool {
+	if x != nil {
+		return x.Output
+	}
+	return false
+}
+
+type Mouse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Button  int32 `protobuf:"varint,20,opt,name=button,proto3" json:"button,omitempty"`
+	X       int32 `protobuf:"varint,21,opt,name=x,proto3" json:"x,omitempty"`
+	Y       int32 `protobuf:"varint,22,opt,name=y,proto3" json:"y,omitempty"`
+	Release bool  `protobuf:"varint,23,opt,name=release,proto3" json:"release,omitempty"`
+	Pixel   bool  `protobuf:"varint,24,opt,name=pixel,proto3" json:"pixel,omitempty"`
+}
+
+func (x *Mouse) Reset() {
+	*x = Mouse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_userInput_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Mouse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Mouse) ProtoMessage() {}
+
+func (x *Mouse) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_userInput_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Mouse.ProtoReflect.Descriptor instead.
+func (*Mouse) Descriptor() ([]byte, []int) {
+	return file_protobufs_userInput_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Mouse) GetButton() int32 {
+	if x != nil {
+		return x.Button
+	}
+	return 0
+}
+
+func (x *Mouse) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Mouse) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *Mouse) GetRelease() bool {
+	if x != nil {
+		return x.Release
+	}
+	return false
+}
+
+func (x *Mouse) GetPixel() bool {
+	if x != nil {
+		return x.Pixel
+	}
+	return false
+}
+
+type Key struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Text    string `protobuf:"bytes,26,opt,name=text,proto3" json:"text,omitempty"`
+	Code    int32  `protobuf:"varint,27,opt,name=code,proto3" json:"code,omitempty"`
+	Shifted int32  `protobuf:"varint,28,opt,name=shifted,proto3" json:"shifted,omitempty"`
+	Base    int32  `protobuf:"varint,29,opt,name=base,proto3" json:"base,omitempty"`
+	Mods    int32  `protobuf:"varint,30,opt,name=mods,proto3" json:"mods,omitempty"`
+	Event   int32  `protobuf:"varint,31,opt,name=event,proto3" json:"event,omitempty"`
+	Final   uint32 `protobuf:"varint,32,opt,name=final,proto3" json:"final,omitempty"`
+}
+
+func (x *Key) Reset() {
+	*x = Key{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protobufs_userInput_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Key) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Key) ProtoMessage() {}
+
+func (x *Key) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_userInput_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Key.ProtoReflect.Descriptor instead.
+func (*Key) Descriptor() ([]byte, []int) {
+	return file_protobufs_userInput_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Key) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Key) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Key) GetShifted() int32 {
+	if x != nil {
+		return x.Shifted
+	}
+	return 0
+}
+
+func (x *Key) GetBase() int32 {
+	if x != nil {
+		return x.Base
+	}
+	return 0
+}
+
+func (x *Key) GetMods() int32 {
+	if x != nil {
+		return x.Mods
+	}
+	return 0
+}
+
+func (x *Key) GetEvent() int32 {
+	if x != nil {
+		return x.Event
+	}
+	return 0
+}
+
+func (x *Key) GetFinal() uint32 {
+	if x != nil {
+		return x.Final
+	}
+	return 0
+}
+
 var File_protobufs_userInput_proto protoreflect.FileDescriptor
 
 var file_protobufs_userInput_proto_rawDesc = []byte{
@@ -235,7 +599,7 @@ var file_protobufs_userInput_proto_rawDesc = []byte{
 	0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a,
 	0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x49,
 	0x6e, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x69, 0x6e, 0x73, 0x74,
-	0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x9e, 0x01, 0x0a, 0x0b, 0x49, 0x6e, 0x73, 0x74,
+	0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x92, 0x03, 0x0a, 0x0b, 0x49, 0x6e, 0x73, 0x74,
 	0x72, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3b, 0x0a, 0x09, 0x6b, 0x65, 0x79, 0x73, 0x74,
 	0x72, 0x6f, 0x6b, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x43, 0x6c, 0x69,
 	0x65, 0x6e, 0x74, 0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x4b, 0x65, 0x79, 0x73, 0x74,
@@ -243,15 +607,62 @@ var file_protobufs_userInput_proto_rawDesc = []byte{
 	0x65, 0x88, 0x01, 0x01, 0x12, 0x39, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x03,
 	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x62, 0x75, 0x66,
 	0x66, 0x65, 0x72, 0x73, 0x2e, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x48, 0x01, 0x52, 0x06, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x88, 0x01, 0x01, 0x42,
-	0x0c, 0x0a, 0x0a, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x74, 0x72, 0x6f, 0x6b, 0x65, 0x42, 0x09, 0x0a,
-	0x07, 0x5f, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x1f, 0x0a, 0x09, 0x4b, 0x65, 0x79, 0x73,
-	0x74, 0x72, 0x6f, 0x6b, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x22, 0x3d, 0x0a, 0x0d, 0x52, 0x65, 0x73,
-	0x69, 0x7a, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69,
-	0x64, 0x74, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68,
-	0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05,
-	0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x42, 0x11, 0x5a, 0x0f, 0x2f, 0x70, 0x72, 0x6f,
+	0x67, 0x65, 0x48, 0x01, 0x52, 0x06, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x88, 0x01, 0x01, 0x12,
+	0x35, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73,
+	0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x48, 0x02, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e,
+	0x6e, 0x65, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x35, 0x0a, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72,
+	0x79, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x48,
+	0x03, 0x52, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x88, 0x01, 0x01, 0x12, 0x2f, 0x0a,
+	0x05, 0x6d, 0x6f, 0x75, 0x73, 0x65, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x4d, 0x6f, 0x75,
+	0x73, 0x65, 0x48, 0x04, 0x52, 0x05, 0x6d, 0x6f, 0x75, 0x73, 0x65, 0x88, 0x01, 0x01, 0x12, 0x29,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x19, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x62, 0x75, 0x66, 0x66, 0x65, 0x72, 0x73, 0x2e, 0x4b, 0x65, 0x79, 0x48,
+	0x05, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x88, 0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x6b, 0x65,
+	0x79, 0x73, 0x74, 0x72, 0x6f, 0x6b, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x72, 0x65, 0x73, 0x69,
+	0x7a, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x42, 0x0a,
+	0x0a, 0x08, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x6d,
+	0x6f, 0x75, 0x73, 0x65, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6b, 0x65, 0x79, 0x22, 0x1f, 0x0a, 0x09,
+	0x4b, 0x65, 0x79, 0x73, 0x74, 0x72, 0x6f, 0x6b, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79,
+	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x22, 0x3d, 0x0a,
+	0x0d, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14,
+	0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x77,
+	0x69, 0x64, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x59, 0x0a, 0x07,
+	0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12,
+	0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x22, 0x93, 0x01, 0x0a, 0x07, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x0e, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x18, 0x10, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x11, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x63, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x18,
+	0x12, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x22, 0x6b, 0x0a,
+	0x05, 0x4d, 0x6f, 0x75, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x74, 0x74, 0x6f, 0x6e,
+	0x18, 0x14, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x62, 0x75, 0x74, 0x74, 0x6f, 0x6e, 0x12, 0x0c,
+	0x0a, 0x01, 0x78, 0x18, 0x15, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01,
+	0x79, 0x18, 0x16, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65,
+	0x6c, 0x65, 0x61, 0x73, 0x65, 0x18, 0x17, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x72, 0x65, 0x6c,
+	0x65, 0x61, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x69, 0x78, 0x65, 0x6c, 0x18, 0x18, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x05, 0x70, 0x69, 0x78, 0x65, 0x6c, 0x22, 0x9b, 0x01, 0x0a, 0x03, 0x4b,
+	0x65, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x1b,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x68,
+	0x69, 0x66, 0x74, 0x65, 0x64, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x73, 0x68, 0x69,
+	0x66, 0x74, 0x65, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x61, 0x73, 0x65, 0x18, 0x1d, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x04, 0x62, 0x61, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x73,
+	0x18, 0x1e, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x65, 0x76, 0x65, 0x6e, 0x74, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x65, 0x76, 0x65,
+	0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x69, 0x6e, 0x61, 0x6c, 0x18, 0x20, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x05, 0x66, 0x69, 0x6e, 0x61, 0x6c, 0x42, 0x11, 0x5a, 0x0f, 0x2f, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x62, 0x75, 0x66, 0x73, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x33,
 }
@@ -268,22 +679,30 @@ func file_protobufs_userInput_proto_rawDescGZIP() []byte {
 	return file_protobufs_userInput_proto_rawDescData
 }
 
-var file_protobufs_userInput_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_protobufs_userInput_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_protobufs_userInput_proto_goTypes = []interface{}{
 	(*UserMessage)(nil),   // 0: Clientbuffers.UserMessage
 	(*Instruction)(nil),   // 1: Clientbuffers.Instruction
 	(*Keystroke)(nil),     // 2: Clientbuffers.Keystroke
 	(*ResizeMessage)(nil), // 3: Clientbuffers.ResizeMessage
+	(*Channel)(nil),       // 4: Clientbuffers.Channel
+	(*History)(nil),       // 5: Clientbuffers.History
+	(*Mouse)(nil),         // 6: Clientbuffers.Mouse
+	(*Key)(nil),           // 7: Clientbuffers.Key
 }
 var file_protobufs_userInput_proto_depIdxs = []int32{
 	1, // 0: Clientbuffers.UserMessage.instruction:type_name -> Clientbuffers.Instruction
 	2, // 1: Clientbuffers.Instruction.keystroke:type_name -> Clientbuffers.Keystroke
 	3, // 2: Clientbuffers.Instruction.resize:type_name -> Clientbuffers.ResizeMessage
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	4, // 3: Clientbuffers.Instruction.channel:type_name -> Clientbuffers.Channel
+	5, // 4: Clientbuffers.Instruction.history:type_name -> Clientbuffers.History
+	6, // 5: Clientbuffers.Instruction.mouse:type_name -> Clientbuffers.Mouse
+	7, // 6: Clientbuffers.Instruction.key:type_name -> Clientbuffers.Key
+	7, // [7:7] is the sub-list for method output_type
+	7, // [7:7] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_protobufs_userInput_proto_init() }
@@ -340,6 +759,54 @@ func file_protobufs_userInput_proto_init() {
 				return nil
 			}
 		}
+		file_protobufs_userInput_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Channel); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_userInput_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*History); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_userInput_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Mouse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protobufs_userInput_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Key); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_protobufs_userInput_proto_msgTypes[1].OneofWrappers = []interface{}{}
 	type x struct{}
@@ -348,7 +815,7 @@ func file_protobufs_userInput_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_protobufs_userInput_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,33 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package statesync
+
+type ChannelKind uint8
+
+const (
+	ChannelOpen  ChannelKind = iota + 1 // open a channel, Data is the target
+	ChannelData                         // payload of the channel
+	ChannelClose                        // close the channel
+	ChannelAck                          // Window is the number of bytes consumed
+)
+
+// ChannelMsg is the message of a forwarding channel (such as ssh agent or tcp
+// forwarding). The channel messages are multiplexed over the state
+// synchronization, UserStream carries them from client to server, Complete
+// carries them from server to client. Both keep the messages in order and
+// resend them until the peer acknowledges.
+type ChannelMsg struct {
+	Data   []byte
+	ID     uint32
+	Window uint32
+	Kind   ChannelKind
+}
+
+// channel message with sequence number, the sequence number is used by
+// Complete to identify the messages the client already got.
+type seqChannelMsg struct {
+	ChannelMsg
+	seq uint64
+}
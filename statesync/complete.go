@@ -7,6 +7,7 @@ package statesync
 import (
 	"fmt"
 	"math"
+	"regexp"
 	"strings"
 	"time"
 
@@ -17,7 +18,8 @@ import (
 )
 
 const (
-	ECHO_TIMEOUT = 50 // for late ack
+	ECHO_TIMEOUT        = 50  // for late ack
+	SYNC_OUTPUT_TIMEOUT = 250 // max time to hold back a synchronized update
 )
 
 type pair struct {
@@ -33,6 +35,21 @@ type Complete struct {
 	diffBuf      strings.Builder
 	inputHistory []pair // user input history
 	echoAck      uint64 // which user input is echoed?
+	exited       bool   // remote shell/command is finished?
+	exitCode     int    // exit code of remote shell/command
+	exitSignal   int    // signal which terminated remote shell/command
+
+	channels []seqChannelMsg // channel messages not acknowledged by client
+	chanSeq  uint64          // sequence number of the last channel message
+
+	events   []seqEvent // events not acknowledged by client
+	eventSeq uint64     // sequence number of the last event
+	bells    int        // the bell count already turned into event
+
+	history HistoryReply // the last history reply
+
+	syncSince int64 // when the pending synchronized update begins, see Hold()
+	syncEnds  int   // the finished synchronized updates already sent
 }
 
 func NewComplete(nCols, nRows, saveLines int) (*Complete, error) {
@@ -56,6 +73,7 @@ func (c *Complete) ActLarge(str string, feed chan string) string {
 	diff, remains := c.terminal.HandleLargeStream(c.remainsBuf.String())
 	c.diffBuf.WriteString(diff)
 	c.remainsBuf.Reset()
+	c.trackSyncOutput(time.Now().UnixMilli())
 
 	// save remains if we got
 	if len(remains) > 0 {
@@ -71,6 +89,7 @@ func (c *Complete) ActLarge(str string, feed chan string) string {
 	}
 
 	// util.Log.Debug("ActLarge","diff", c.diffBuf.String())
+	c.takeEvents()
 	return c.terminal.ReadOctetsToHost()
 }
 
@@ -84,11 +103,42 @@ func (c *Complete) Act(str string) string {
 	// c.terminal.ResetDamage()
 	_, diff := c.terminal.HandleStream(str)
 	c.diffBuf.WriteString(diff)
+	c.trackSyncOutput(time.Now().UnixMilli())
 
 	// util.Logger.Debug("Act", "input", str, "diff", diff, "diffBuf", c.diffBuf.String())
+	c.takeEvents()
 	return c.terminal.ReadOctetsToHost()
 }
 
+// record when the synchronized update (DECSET 2026) begins.
+func (c *Complete) trackSyncOutput(now int64) {
+	if on, _ := c.terminal.GetSyncOutput(); !on {
+		c.syncSince = 0
+	} else if c.syncSince == 0 {
+		c.syncSince = now
+	}
+}
+
+// implements network.State[C any] interface
+// return true if the application is in the middle of synchronized update, the
+// diff is held back until the update is finished or SYNC_OUTPUT_TIMEOUT.
+func (c *Complete) Hold(now int64) bool {
+	on, ends := c.terminal.GetSyncOutput()
+	return on && ends == c.syncEnds && now-c.syncSince < SYNC_OUTPUT_TIMEOUT
+}
+
+// implements network.State[C any] interface
+// return the state to send while this state is held: the screen of existing
+// state with the other messages of this state. the pending screen update stays
+// in diffBuf until it's released.
+func (c *Complete) HoldFrom(existing *Complete) *Complete {
+	held := *c
+	held.terminal = existing.terminal
+	held.diffBuf.Reset()
+	held.remainsBuf.Reset()
+	return &held
+}
+
 func (c *Complete) GetDiff() string {
 	ret := c.diffBuf.String()
 	c.Reset()
@@ -113,6 +163,120 @@ func (c *Complete) GetEchoAck() uint64 {
 	return c.echoAck
 }
 
+// record the exit status of remote shell/command. it's replicated to the
+// client with the shutdown state.
+func (c *Complete) SetExitStatus(code int, signal int) {
+	c.exited = true
+	c.exitCode = code
+	c.exitSignal = signal
+}
+
+// return the exit status of remote shell/command, ok is false if the exit
+// status is not available.
+func (c *Complete) GetExitStatus() (code int, signal int, ok bool) {
+	return c.exitCode, c.exitSignal, c.exited
+}
+
+// append the channel message, it's sent to the client until the client
+// acknowledges it.
+func (c *Complete) PushChannel(msg ChannelMsg) {
+	c.chanSeq++
+	c.channels = append(c.channels, seqChannelMsg{msg, c.chanSeq})
+}
+
+// return the channel messages whose sequence number is greater than seq, and
+// the sequence number of the last channel message. the returned messages are
+// removed from this state.
+func (c *Complete) TakeChannels(seq uint64) (msgs []ChannelMsg, last uint64) {
+	for i := range c.channels {
+		if c.channels[i].seq > seq {
+			msgs = append(msgs, c.channels[i].ChannelMsg)
+		}
+	}
+	c.channels = nil
+	return msgs, max(seq, c.chanSeq)
+}
+
+// append the event, it's sent to the client until the client acknowledges it.
+func (c *Complete) PushEvent(e Event) {
+	c.eventSeq++
+	c.events = append(c.events, seqEvent{e, c.eventSeq})
+}
+
+// return the events whose sequence number is greater than seq, and the
+// sequence number of the last event. the returned events are removed from
+// this state.
+func (c *Complete) TakeEvents(seq uint64) (events []Event, last uint64) {
+	for i := range c.events {
+		if c.events[i].seq > seq {
+			events = append(events, c.events[i].Event)
+		}
+	}
+	c.events = nil
+	return events, max(seq, c.eventSeq)
+}
+
+// turn the notifications and clipboard operations requested by application
+// into events. the bells rang in one batch of output are merged into one
+// event.
+func (c *Complete) takeEvents() {
+	for _, n := range c.terminal.TakeNotifications() {
+		c.PushEvent(Event{Kind: EventNotify, Title: n.Title, Body: n.Body})
+	}
+	for _, cb := range c.terminal.TakeClipboards() {
+		c.PushEvent(Event{Kind: EventClipboard, Selection: cb.Selection, Data: cb.Data})
+	}
+	if n := c.terminal.GetBellCount(); n != c.bells {
+		// the bell count is reset by RIS
+		if n > c.bells {
+			c.PushEvent(Event{Kind: EventBell})
+		}
+		c.bells = n
+	}
+}
+
+// reply the history request with the rows of terminal.
+func (c *Complete) ReplyHistory(req HistoryRequest) {
+	c.history = HistoryReply{ID: req.ID, Total: c.terminal.GetHistoryRows()}
+	c.history.Start, c.history.Rows = c.terminal.GetRowsString(req.Start, req.Count)
+	if req.Pattern != "" {
+		if re, err := regexp.Compile(req.Pattern); err == nil {
+			c.history.Matches = c.terminal.SearchRows(re, HistoryMatchLimit)
+		}
+	}
+	if req.Commands || req.Output {
+		cmds := c.terminal.GetCommands(HistoryMatchLimit)
+		if req.Commands {
+			c.history.Commands = cmds
+		}
+		if req.Output {
+			c.history.Output = lastOutput(c.terminal, cmds)
+		}
+	}
+}
+
+// return the output of the last finished command, the output is truncated to
+// HistoryOutputLimit bytes.
+func lastOutput(emu *terminal.Emulator, cmds []terminal.ShellCommand) string {
+	for i := len(cmds) - 1; i >= 0; i-- {
+		if cmds[i].Status < 0 {
+			continue
+		}
+
+		output := emu.GetRowsText(cmds[i].Output, cmds[i].End-cmds[i].Output)
+		if len(output) > HistoryOutputLimit {
+			output = strings.ToValidUTF8(output[:HistoryOutputLimit], "")
+		}
+		return output
+	}
+	return ""
+}
+
+// return the last history reply.
+func (c *Complete) GetHistory() HistoryReply {
+	return c.history
+}
+
 // shrink input history according to timestamp. return true if newestEchoAck changed.
 // update echoAck if find the newest state.
 func (c *Complete) SetEchoAck(now int64, inputEchoDone bool) (ret bool) {
@@ -164,6 +328,14 @@ func (c *Complete) RegisterInputFrame(num uint64, now int64) {
 // ack actions.
 func (c *Complete) WaitTime(now int64) int {
 	// defer util.Log.Debug("Complete WaitTime", "inputHistory length", len(c.inputHistory))
+	if c.Hold(now) {
+		// wake up to release the synchronized update after timeout
+		return min(c.echoWaitTime(now), int(c.syncSince+SYNC_OUTPUT_TIMEOUT-now))
+	}
+	return c.echoWaitTime(now)
+}
+
+func (c *Complete) echoWaitTime(now int64) int {
 	if len(c.inputHistory) < 2 {
 		return math.MaxInt
 	}
@@ -179,7 +351,19 @@ func (c *Complete) WaitTime(now int64) int {
 
 // implements network.State[C any] interface
 func (c *Complete) Subtract(prefix *Complete) {
-	// do nothing
+	// drop the channel messages acknowledged by client
+	i := 0
+	for i < len(c.channels) && c.channels[i].seq <= prefix.chanSeq {
+		i++
+	}
+	c.channels = c.channels[i:]
+
+	// drop the events acknowledged by client
+	i = 0
+	for i < len(c.events) && c.events[i].seq <= prefix.eventSeq {
+		i++
+	}
+	c.events = c.events[i:]
 }
 
 // implements network.State[C any] interface
@@ -204,6 +388,74 @@ func (c *Complete) diffFrom(existing *Complete, diffBuf bool) string {
 		hm.Instruction = append(hm.Instruction, &instEcho)
 	}
 
+	if c.exited && (!existing.exited || existing.exitCode != c.exitCode ||
+		existing.exitSignal != c.exitSignal) {
+		code := int32(c.exitCode)
+		signal := int32(c.exitSignal)
+		instExit := pb.Instruction{Exitstatus: &pb.ExitStatus{ExitCode: &code, Signal: &signal}}
+		hm.Instruction = append(hm.Instruction, &instExit)
+	}
+
+	for i := range c.channels {
+		if c.channels[i].seq > existing.chanSeq {
+			seq := c.channels[i].seq
+			id := c.channels[i].ID
+			kind := uint32(c.channels[i].Kind)
+			window := c.channels[i].Window
+			instChannel := pb.Instruction{Channel: &pb.Channel{Seq: &seq, Id: &id, Kind: &kind,
+				Data: c.channels[i].Data, Window: &window}}
+			hm.Instruction = append(hm.Instruction, &instChannel)
+		}
+	}
+
+	for i := range c.events {
+		if c.events[i].seq > existing.eventSeq {
+			seq := c.events[i].seq
+			kind := uint32(c.events[i].Kind)
+			instEvent := pb.Instruction{Event: &pb.Event{Seq: &seq, Kind: &kind,
+				Title: []byte(c.events[i].Title), Body: []byte(c.events[i].Body),
+				Selection: []byte(c.events[i].Selection), Data: []byte(c.events[i].Data)}}
+			hm.Instruction = append(hm.Instruction, &instEvent)
+		}
+	}
+
+	if c.history.ID != existing.history.ID {
+		id := c.history.ID
+		start := int32(c.history.Start)
+		total := uint32(c.history.Total)
+		rows := make([][]byte, len(c.history.Rows))
+		for i := range c.history.Rows {
+			rows[i] = []byte(c.history.Rows[i])
+		}
+		matches := make([]*pb.HistoryMatch, len(c.history.Matches))
+		for i, m := range c.history.Matches {
+			row, start, end := int32(m.Row), uint32(m.StartCol), uint32(m.EndCol)
+			matches[i] = &pb.HistoryMatch{Row: &row, Start: &start, End: &end}
+		}
+		commands := make([]*pb.HistoryCommand, len(c.history.Commands))
+		for i, cmd := range c.history.Commands {
+			prompt, output, end, status := int32(cmd.Prompt), int32(cmd.Output), int32(cmd.End), int32(cmd.Status)
+			commands[i] = &pb.HistoryCommand{Prompt: &prompt, Output: &output, End: &end, Status: &status}
+		}
+		instHistory := pb.Instruction{History: &pb.History{Id: &id, Start: &start, Total: &total, Rows: rows,
+			Matches: matches, Commands: commands, Output: []byte(c.history.Output)}}
+		hm.Instruction = append(hm.Instruction, &instHistory)
+	}
+
+	// the image is sent only once, the image placeholders in host bytes refer to it.
+	added, dropped := c.terminal.DiffImages(existing.terminal)
+	for _, img := range added {
+		id, kind, cols, rows := img.ID, uint32(img.Kind), uint32(img.Cols), uint32(img.Rows)
+		instImage := pb.Instruction{Image: &pb.Image{Id: &id, Kind: &kind, Cols: &cols, Rows: &rows,
+			Data: []byte(img.Data), Ctrl: []byte(img.Ctrl)}}
+		hm.Instruction = append(hm.Instruction, &instImage)
+	}
+	for i := range dropped {
+		drop := true
+		instImage := pb.Instruction{Image: &pb.Image{Id: &dropped[i], Drop: &drop}}
+		hm.Instruction = append(hm.Instruction, &instImage)
+	}
+
 	// if !reflect.DeepEqual(existing.getFramebuffer(), c.getFramebuffer()) {
 	// if !c.getFramebuffer().Equal(existing.getFramebuffer()) {
 	if !c.Equal(existing) {
@@ -264,6 +516,50 @@ func (c *Complete) ApplyString(diff string) error {
 		} else if input.Instruction[i].Echoack != nil {
 			instEchoAckNum := input.Instruction[i].Echoack.GetEchoAckNum()
 			c.echoAck = instEchoAckNum
+		} else if input.Instruction[i].Exitstatus != nil {
+			c.SetExitStatus(int(input.Instruction[i].Exitstatus.GetExitCode()),
+				int(input.Instruction[i].Exitstatus.GetSignal()))
+		} else if input.Instruction[i].Channel != nil {
+			ch := input.Instruction[i].Channel
+			// skip the channel message we already have
+			if ch.GetSeq() > c.chanSeq {
+				msg := ChannelMsg{ID: ch.GetId(), Kind: ChannelKind(ch.GetKind()), Data: ch.GetData(), Window: ch.GetWindow()}
+				c.channels = append(c.channels, seqChannelMsg{msg, ch.GetSeq()})
+				c.chanSeq = ch.GetSeq()
+			}
+		} else if input.Instruction[i].Event != nil {
+			ev := input.Instruction[i].Event
+			// skip the event we already have
+			if ev.GetSeq() > c.eventSeq {
+				e := Event{Kind: EventKind(ev.GetKind()), Title: string(ev.GetTitle()), Body: string(ev.GetBody()),
+					Selection: string(ev.GetSelection()), Data: string(ev.GetData())}
+				c.events = append(c.events, seqEvent{e, ev.GetSeq()})
+				c.eventSeq = ev.GetSeq()
+			}
+		} else if input.Instruction[i].History != nil {
+			h := input.Instruction[i].History
+			c.history = HistoryReply{ID: h.GetId(), Start: int(h.GetStart()), Total: int(h.GetTotal())}
+			for _, row := range h.GetRows() {
+				c.history.Rows = append(c.history.Rows, string(row))
+			}
+			for _, m := range h.GetMatches() {
+				c.history.Matches = append(c.history.Matches, terminal.TextMatch{Row: int(m.GetRow()),
+					StartCol: int(m.GetStart()), EndCol: int(m.GetEnd())})
+			}
+			for _, cmd := range h.GetCommands() {
+				c.history.Commands = append(c.history.Commands, terminal.ShellCommand{Prompt: int(cmd.GetPrompt()),
+					Output: int(cmd.GetOutput()), End: int(cmd.GetEnd()), Status: int(cmd.GetStatus())})
+			}
+			c.history.Output = string(h.GetOutput())
+		} else if input.Instruction[i].Image != nil {
+			img := input.Instruction[i].Image
+			if img.GetDrop() {
+				c.terminal.DropImage(img.GetId())
+			} else {
+				c.terminal.AddImage(&terminal.Image{ID: img.GetId(), Kind: int(img.GetKind()),
+					Cols: int(img.GetCols()), Rows: int(img.GetRows()),
+					Data: string(img.GetData()), Ctrl: string(img.GetCtrl())})
+			}
 		}
 	}
 
@@ -276,6 +572,14 @@ func (c *Complete) Equal(x *Complete) bool {
 		return false
 	}
 
+	if c.exited != x.exited || c.exitCode != x.exitCode || c.exitSignal != x.exitSignal {
+		return false
+	}
+
+	if c.chanSeq != x.chanSeq || c.eventSeq != x.eventSeq || c.history.ID != x.history.ID {
+		return false
+	}
+
 	return c.terminal.Equal(x.terminal)
 }
 
@@ -292,6 +596,14 @@ func (c *Complete) ResetInput() {
 func (c *Complete) Reset() {
 	c.diffBuf.Reset()
 	c.terminal.SetLastRows(0)
+
+	// the next synchronized update begins after the finished one is sent
+	if on, ends := c.terminal.GetSyncOutput(); ends != c.syncEnds {
+		c.syncEnds = ends
+		if on {
+			c.syncSince = time.Now().UnixMilli()
+		}
+	}
 }
 
 // implements network.State[C any] interface
@@ -307,6 +619,12 @@ func (c *Complete) Clone() *Complete {
 	clone.inputHistory = make([]pair, len(c.inputHistory))
 	copy(clone.inputHistory, c.inputHistory)
 
+	clone.channels = make([]seqChannelMsg, len(c.channels))
+	copy(clone.channels, c.channels)
+
+	clone.events = make([]seqEvent, len(c.events))
+	copy(clone.events, c.events)
+
 	return &clone
 }
 
@@ -319,6 +637,31 @@ func (c *Complete) EqualTrace(x *Complete) bool {
 		return false
 	}
 
+	if c.exited != x.exited || c.exitCode != x.exitCode || c.exitSignal != x.exitSignal {
+		msg := fmt.Sprintf("exitStatus=(%t:%d:%d,%t:%d:%d)", c.exited, c.exitCode, c.exitSignal,
+			x.exited, x.exitCode, x.exitSignal)
+		util.Logger.Warn(msg)
+		return false
+	}
+
+	if c.chanSeq != x.chanSeq {
+		msg := fmt.Sprintf("chanSeq=(%d,%d)", c.chanSeq, x.chanSeq)
+		util.Logger.Warn(msg)
+		return false
+	}
+
+	if c.eventSeq != x.eventSeq {
+		msg := fmt.Sprintf("eventSeq=(%d,%d)", c.eventSeq, x.eventSeq)
+		util.Logger.Warn(msg)
+		return false
+	}
+
+	if c.history.ID != x.history.ID {
+		msg := fmt.Sprintf("history=(%d,%d)", c.history.ID, x.history.ID)
+		util.Logger.Warn(msg)
+		return false
+	}
+
 	ret := c.terminal.EqualTrace(x.terminal)
 	return ret
 }
@@ -326,3 +669,11 @@ func (c *Complete) EqualTrace(x *Complete) bool {
 func (c *Complete) SetTerminalCaps(caps map[int]string) {
 	c.terminal.SetTerminalCaps(caps)
 }
+
+func (c *Complete) SetImagePlaceholder(accept bool) {
+	c.terminal.SetImagePlaceholder(accept)
+}
+
+func (c *Complete) SetTermName(name string) {
+	c.terminal.SetTermName(name)
+}
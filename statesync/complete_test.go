@@ -96,6 +96,276 @@ func TestApplyString_Fail(t *testing.T) {
 	}
 }
 
+func TestCompleteExitStatus(t *testing.T) {
+	tc := []struct {
+		label  string
+		code   int
+		signal int
+	}{
+		{"exit normally", 0, 0},
+		{"exit with code", 3, 0},
+		{"killed by signal", -1, 9},
+	}
+
+	for _, v := range tc {
+		c0, _ := NewComplete(80, 40, 40)
+		c1, _ := NewComplete(80, 40, 40)
+
+		if _, _, ok := c0.GetExitStatus(); ok {
+			t.Errorf("%q expect no exit status for new state, got %t\n", v.label, ok)
+		}
+
+		c1.SetExitStatus(v.code, v.signal)
+		if c1.Equal(c0) {
+			t.Errorf("%q expect false equal(), got true\n", v.label)
+		}
+
+		// replicate the exit status
+		c0.ApplyString(c1.DiffFrom(c0))
+
+		code, signal, ok := c0.GetExitStatus()
+		if !ok || code != v.code || signal != v.signal {
+			t.Errorf("%q expect exit status (%d,%d), got (%d,%d,%t)\n",
+				v.label, v.code, v.signal, code, signal, ok)
+		}
+		if got := c0.DiffFrom(c1); got != "" {
+			t.Errorf("%q expect empty result after ApplyString(), got %q\n", v.label, got)
+		}
+	}
+}
+
+func TestCompleteChannel(t *testing.T) {
+	msgs := []ChannelMsg{
+		{ID: 2, Kind: ChannelOpen, Data: []byte("agent")},
+		{ID: 2, Kind: ChannelData, Data: []byte("request")},
+		{ID: 2, Kind: ChannelClose},
+	}
+
+	server, _ := NewComplete(80, 40, 40)
+	client, _ := NewComplete(80, 40, 40)
+
+	// the first two messages are sent, but not acknowledged
+	server.PushChannel(msgs[0])
+	server.PushChannel(msgs[1])
+	sent := server.Clone()
+	client.ApplyString(server.DiffFrom(client))
+
+	got, seq := client.TakeChannels(0)
+	if !reflect.DeepEqual(got, msgs[:2]) || seq != 2 {
+		t.Errorf("#test channel expect %v seq %d, got %v seq %d\n", msgs[:2], 2, got, seq)
+	}
+
+	// resend the unacknowledged messages with the new one, the client skips
+	// the duplicated messages.
+	server.PushChannel(msgs[2])
+	client.ApplyString(server.DiffFrom(&Complete{terminal: client.terminal}))
+	got, seq = client.TakeChannels(seq)
+	if !reflect.DeepEqual(got, msgs[2:]) || seq != 3 {
+		t.Errorf("#test channel expect %v seq %d, got %v seq %d\n", msgs[2:], 3, got, seq)
+	}
+
+	// the acknowledged messages are removed from the state
+	server.Subtract(sent)
+	if len(server.channels) != 1 || server.channels[0].seq != 3 {
+		t.Errorf("#test channel expect one message after Subtract(), got %v\n", server.channels)
+	}
+	if server.Equal(sent) {
+		t.Errorf("#test channel expect false equal(), got true\n")
+	}
+}
+
+func TestCompleteEvent(t *testing.T) {
+	server, _ := NewComplete(80, 40, 40)
+	client, _ := NewComplete(80, 40, 40)
+
+	// the notifications are events, they are not part of the screen
+	server.Act("\x1B]9;build done\x1B\\\x1B]777;notify;make;failed\a")
+	sent := server.Clone()
+	client.ApplyString(server.DiffFrom(client))
+
+	expect := []Event{{Kind: EventNotify, Body: "build done"}, {Kind: EventNotify, Title: "make", Body: "failed"}}
+	got, seq := client.TakeEvents(0)
+	if !reflect.DeepEqual(got, expect) || seq != 2 {
+		t.Errorf("#test event expect %v seq %d, got %v seq %d\n", expect, 2, got, seq)
+	}
+	if !client.terminal.Equal(server.terminal) {
+		t.Errorf("#test event expect the same screen\n")
+	}
+
+	// resend the unacknowledged events with the new one, the client skips
+	// the duplicated events.
+	server.Act("\x1B]99;;done\x1B\\")
+	client.ApplyString(server.DiffFrom(&Complete{terminal: client.terminal}))
+	got, seq = client.TakeEvents(seq)
+	expect = []Event{{Kind: EventNotify, Title: "done"}}
+	if !reflect.DeepEqual(got, expect) || seq != 3 {
+		t.Errorf("#test event expect %v seq %d, got %v seq %d\n", expect, 3, got, seq)
+	}
+
+	// the bells in one batch of output are merged into one event
+	server.Act("\a\a\x1B]0;title\a")
+	client.ApplyString(server.DiffFrom(&Complete{terminal: client.terminal, eventSeq: seq}))
+	got, seq = client.TakeEvents(seq)
+	expect = []Event{{Kind: EventBell}}
+	if !reflect.DeepEqual(got, expect) || seq != 4 {
+		t.Errorf("#test event expect %v seq %d, got %v seq %d\n", expect, 4, got, seq)
+	}
+	if !client.terminal.Equal(server.terminal) {
+		t.Errorf("#test event expect the same screen after bell\n")
+	}
+
+	// the clipboard write is delivered as event
+	server.Act("\x1B]52;c;YXByaWxzaAo=\x1B\\")
+	client.ApplyString(server.DiffFrom(&Complete{terminal: client.terminal, eventSeq: seq}))
+	got, seq = client.TakeEvents(seq)
+	expect = []Event{{Kind: EventClipboard, Selection: "c", Data: "YXByaWxzaAo="}}
+	if !reflect.DeepEqual(got, expect) || seq != 5 {
+		t.Errorf("#test event expect %v seq %d, got %v seq %d\n", expect, 5, got, seq)
+	}
+
+	// the acknowledged events are removed from the state
+	server.Subtract(sent)
+	if len(server.events) != 3 || server.events[0].seq != 3 {
+		t.Errorf("#test event expect one event after Subtract(), got %v\n", server.events)
+	}
+	if server.Equal(sent) {
+		t.Errorf("#test event expect false equal(), got true\n")
+	}
+}
+
+func TestCompleteImage(t *testing.T) {
+	caps := map[int]string{terminal.CSI_priDA: "\x1B[?62;4;22c"}
+	server, _ := NewComplete(80, 40, 40)
+	client, _ := NewComplete(80, 40, 40)
+	server.SetTerminalCaps(caps)
+	client.SetTerminalCaps(caps)
+	client.SetImagePlaceholder(true)
+
+	// the image payload is sent once, the host bytes keep the placeholder
+	sixel := "\x1BP0;1;0q\"1;1;20;40#0!20~-!20~\x1B\\"
+	server.Act(sixel + "ok")
+	sent := server.Clone()
+	client.ApplyString(server.DiffFrom(client))
+
+	if !client.terminal.Equal(server.terminal) {
+		t.Errorf("#test image expect the same screen\n")
+	}
+	stream := client.GetDiff()
+	if !strings.HasPrefix(stream, "\x1B_aprilsh;image=") || strings.Contains(stream, "!20~") {
+		t.Errorf("#test image expect placeholder, got %q\n", stream)
+	}
+	if got := client.terminal.ReplaceImages(stream); !strings.Contains(got, sixel) {
+		t.Errorf("#test image expect sixel image in %q\n", got)
+	}
+
+	// the acknowledged image is not sent again
+	server.Reset()
+	server.Act(sixel)
+	diff := server.DiffFrom(sent)
+	if strings.Contains(diff, "!20~") {
+		t.Errorf("#test image expect no image payload, got %q\n", diff)
+	}
+	client.ApplyString(diff)
+	if !client.terminal.Equal(server.terminal) {
+		t.Errorf("#test image expect the same screen after placement\n")
+	}
+}
+
+func TestCompleteLink(t *testing.T) {
+	server, _ := NewComplete(20, 4, 4)
+	client, _ := NewComplete(20, 4, 4)
+
+	server.Act("\x1B]8;;http://a\x1B\\link a\x1B]8;;\x1B\\ \x1B]8;id=b;http://b\x1B\\link b\x1B]8;;\x1B\\")
+	client.ApplyString(server.DiffFrom(client))
+	if !client.terminal.Equal(server.terminal) {
+		t.Errorf("#test link expect the same screen\n")
+	}
+
+	// the links are kept through alternate screen and resize
+	server.Reset()
+	server.Act("\x1B[?1049hvi\x1B[?1049l")
+	client.ApplyString(server.DiffFrom(client))
+	server.Reset()
+	server.ActOne(terminal.Resize{Width: 5, Height: 4})
+	client.ApplyString(server.DiffFrom(client))
+	if !client.terminal.Equal(server.terminal) {
+		t.Errorf("#test link expect the same screen after resize\n")
+	}
+
+	// the new client gets the same links from the screen
+	fresh, _ := NewComplete(5, 4, 4)
+	fresh.ApplyString(server.InitDiff())
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			if fresh.terminal.GetCell(y, x).GetRenditions() != server.terminal.GetCell(y, x).GetRenditions() {
+				t.Errorf("#test link expect the same renditions at (%d,%d)\n", y, x)
+			}
+		}
+	}
+	if got, expect := fresh.terminal.GetRowsText(0, 4), server.terminal.GetRowsText(0, 4); got != expect {
+		t.Errorf("#test link expect %q from InitDiff(), got %q\n", expect, got)
+	}
+	if diff := fresh.GetDiff(); !strings.Contains(diff, "\x1B]8;;http://a\x1B\\") ||
+		!strings.Contains(diff, "\x1B]8;id=b;http://b\x1B\\") {
+		t.Errorf("#test link expect hyperlinks in %q\n", diff)
+	}
+}
+
+func TestCompleteHistory(t *testing.T) {
+	server, _ := NewComplete(10, 3, 5)
+	client, _ := NewComplete(10, 3, 5)
+	server.Act("1\r\n2\r\n3\r\n4\r\n5")
+	acked := server.Clone()
+
+	server.ReplyHistory(HistoryRequest{ID: 1, Start: -3, Count: 4})
+	client.ApplyString(server.DiffFrom(acked))
+
+	expect := HistoryReply{ID: 1, Start: -2, Total: 2, Rows: []string{"1", "2", "3"}}
+	if got := client.GetHistory(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("#test history expect %v, got %v\n", expect, got)
+	}
+
+	// search the rows
+	acked = server.Clone()
+	server.ReplyHistory(HistoryRequest{ID: 2, Start: 0, Count: 1, Pattern: "[24]"})
+	client.ApplyString(server.DiffFrom(acked))
+
+	expect = HistoryReply{ID: 2, Start: 0, Total: 2, Rows: []string{"3"},
+		Matches: []terminal.TextMatch{{Row: -1, StartCol: 0, EndCol: 1}, {Row: 1, StartCol: 0, EndCol: 1}}}
+	if got := client.GetHistory(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("#test history search expect %v, got %v\n", expect, got)
+	}
+
+	// invalid pattern returns no match
+	server.ReplyHistory(HistoryRequest{ID: 3, Start: 0, Count: 1, Pattern: "["})
+	if got := server.GetHistory(); got.Matches != nil {
+		t.Errorf("#test history invalid pattern expect no match, got %v\n", got.Matches)
+	}
+
+	// the commands and the output of last finished command
+	server.Act("\x1B]133;A\a$ ls\r\n\x1B]133;C\afile\r\n\x1B]133;D;1\a\x1B]133;A\a$ ")
+	acked = server.Clone()
+	server.ReplyHistory(HistoryRequest{ID: 4, Start: 0, Count: 0, Commands: true, Output: true})
+	client.ApplyString(server.DiffFrom(acked))
+
+	expect = HistoryReply{ID: 4, Start: 0, Total: 4, Output: "file\n",
+		Commands: []terminal.ShellCommand{{Prompt: 0, Output: 1, End: 2, Status: 1}, {Prompt: 2, Output: 3, Status: -1}}}
+	if got := client.GetHistory(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("#test history commands expect %v, got %v\n", expect, got)
+	}
+
+	// the reply is not resent after client acknowledges it
+	if server.Equal(acked) {
+		t.Errorf("#test history expect false equal(), got true\n")
+	}
+	acked = server.Clone()
+	other, _ := NewComplete(10, 3, 5)
+	other.ApplyString(server.DiffFrom(acked))
+	if got := other.GetHistory(); got.ID != 0 {
+		t.Errorf("#test history expect no reply, got %v\n", got)
+	}
+}
+
 func TestCompleteSetEchoAck(t *testing.T) {
 	tc := []struct {
 		label         string
@@ -187,6 +457,67 @@ func TestCompleteWaitTime(t *testing.T) {
 	}
 }
 
+func TestCompleteSyncOutput(t *testing.T) {
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+	c, _ := NewComplete(80, 40, 40)
+
+	// the update in progress is held back
+	c.Act("\x1B[?2026hhello")
+	now := time.Now().UnixMilli()
+	if !c.Hold(now) {
+		t.Errorf("#test begin update expect hold\n")
+	}
+	if got := c.WaitTime(now); got > SYNC_OUTPUT_TIMEOUT {
+		t.Errorf("#test begin update expect wait time <= %d, got %d\n", SYNC_OUTPUT_TIMEOUT, got)
+	}
+	if c.Hold(c.syncSince + SYNC_OUTPUT_TIMEOUT) {
+		t.Errorf("#test update timeout expect no hold\n")
+	}
+
+	// the finished update is released with the next update in progress
+	c.Act(" world\x1B[?2026l\x1B[?2026hnext")
+	if c.Hold(time.Now().UnixMilli()) {
+		t.Errorf("#test finish update expect no hold\n")
+	}
+	if diff := c.GetDiff(); !strings.Contains(diff, "hello world") {
+		t.Errorf("#test finish update expect the whole update, got %q\n", diff)
+	}
+
+	// after the finished update is sent, the next update is held back
+	if !c.Hold(time.Now().UnixMilli()) {
+		t.Errorf("#test next update expect hold\n")
+	}
+	c.Act("\x1B[?2026l")
+	if c.Hold(time.Now().UnixMilli()) || c.WaitTime(time.Now().UnixMilli()) != math.MaxInt {
+		t.Errorf("#test next update expect no hold\n")
+	}
+}
+
+func TestCompleteHoldFrom(t *testing.T) {
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+	sent, _ := NewComplete(80, 40, 40)
+	c := sent.Clone()
+
+	// the screen update is held back, the bell event is not
+	c.Act("\x1B[?2026hhello\a")
+	held := c.HoldFrom(sent)
+	receiver := sent.Clone()
+	receiver.ApplyString(held.DiffFrom(sent))
+	if diff := receiver.GetDiff(); strings.Contains(diff, "hello") {
+		t.Errorf("#test held state expect no screen update, got %q\n", diff)
+	}
+	if events, _ := receiver.TakeEvents(0); len(events) != 1 || events[0].Kind != EventBell {
+		t.Errorf("#test held state expect the bell event, got %v\n", events)
+	}
+
+	// the pending screen update is released by the held state
+	c.Act(" world\x1B[?2026l")
+	receiver.ApplyString(c.DiffFrom(held))
+	if diff := receiver.GetDiff(); !strings.Contains(diff, "hello world") {
+		t.Errorf("#test released state expect the whole update, got %q\n", diff)
+	}
+}
+
 func TestCompleteResetInput(t *testing.T) {
 	c, _ := NewComplete(8, 4, 4)
 
@@ -0,0 +1,32 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package statesync
+
+type EventKind uint8
+
+const (
+	EventNotify    EventKind = iota + 1 // desktop notification, Title and Body
+	EventBell                           // the bell rang (BEL)
+	EventClipboard                      // OSC 52 clipboard operation, Selection and Data
+)
+
+// Event is something happened on the server side which the client should
+// handle exactly once, such as a desktop notification. Unlike the screen
+// state, the events are not diffed: like the channel messages, Complete keeps
+// them in order and resends them until the client acknowledges.
+type Event struct {
+	Title     string
+	Body      string
+	Selection string // clipboard selection, such as "c"
+	Data      string // base64 encoded clipboard data, "?" reads the clipboard
+	Kind      EventKind
+}
+
+// event with sequence number, the sequence number is used by Complete to
+// identify the events the client already got.
+type seqEvent struct {
+	Event
+	seq uint64
+}
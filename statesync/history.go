@@ -0,0 +1,42 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package statesync
+
+import "github.com/ericwq/aprilsh/terminal"
+
+// HistoryRequest asks the server for the scrollback history rows in range
+// [Start, Start+Count). row 0 is the screen top row, negative row is the
+// history row. If Pattern is not empty, server also searches the rows for
+// the regular expression Pattern. Commands asks for the shell commands marked
+// by OSC 133, Output asks for the output of the last finished command.
+// UserStream carries it from client to server.
+type HistoryRequest struct {
+	Pattern  string
+	ID       uint32
+	Start    int
+	Count    int
+	Commands bool
+	Output   bool
+}
+
+// HistoryReply is the rows requested by HistoryRequest with the same ID,
+// Start is the row number of the first row, Total is the number of history
+// rows kept by server, Matches is the search result of Pattern. Commands and
+// Output are replied if they are requested. Complete carries it from server
+// to client, only the last reply is kept.
+type HistoryReply struct {
+	Output   string
+	Rows     []string
+	Matches  []terminal.TextMatch
+	Commands []terminal.ShellCommand
+	ID       uint32
+	Start    int
+	Total    int
+}
+
+const (
+	HistoryMatchLimit  = 1000      // max number of matches and commands in HistoryReply
+	HistoryOutputLimit = 64 * 1024 // max bytes of command output in HistoryReply
+)
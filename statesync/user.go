@@ -21,12 +21,24 @@ type UserEventType uint8
 const (
 	UserByteType UserEventType = iota
 	ResizeType
+	ChannelType
+	HistoryType
+	MouseType
+	KeyType
 )
 
 // UserByte instance is created by aprish client, when client got the user input.
 // Resize instance is created by aprish client, when client change the window size.
+// ChannelMsg instance is created by aprish client, when forwarding channel has data.
+// HistoryRequest instance is created by aprish client, when user view the scrollback.
+// Mouse instance is created by aprish client, when local terminal reports mouse event.
+// Key instance is created by aprish client, when local terminal reports key event in kitty keyboard protocol.
 type UserEvent struct {
 	userByte terminal.UserByte
+	mouse    terminal.Mouse
+	key      terminal.Key
+	channel  ChannelMsg
+	history  HistoryRequest
 	resize   terminal.Resize
 	theType  UserEventType
 }
@@ -49,6 +61,42 @@ func NewUserEventResize(resize terminal.Resize) (u UserEvent) {
 	return u
 }
 
+func NewUserEventChannel(msg ChannelMsg) (u UserEvent) {
+	u = UserEvent{}
+
+	u.theType = ChannelType
+	u.channel = msg
+
+	return u
+}
+
+func NewUserEventHistory(req HistoryRequest) (u UserEvent) {
+	u = UserEvent{}
+
+	u.theType = HistoryType
+	u.history = req
+
+	return u
+}
+
+func NewUserEventMouse(mouse terminal.Mouse) (u UserEvent) {
+	u = UserEvent{}
+
+	u.theType = MouseType
+	u.mouse = mouse
+
+	return u
+}
+
+func NewUserEventKey(key terminal.Key) (u UserEvent) {
+	u = UserEvent{}
+
+	u.theType = KeyType
+	u.key = key
+
+	return u
+}
+
 // UserStream implements network.State[C any] interface
 type UserStream struct {
 	actions []UserEvent
@@ -81,6 +129,22 @@ func (u *UserStream) PushBackResize(width, height int) {
 	u.actions = append(u.actions, NewUserEventResize(resize))
 }
 
+func (u *UserStream) PushBackChannel(msg ChannelMsg) {
+	u.actions = append(u.actions, NewUserEventChannel(msg))
+}
+
+func (u *UserStream) PushBackHistory(req HistoryRequest) {
+	u.actions = append(u.actions, NewUserEventHistory(req))
+}
+
+func (u *UserStream) PushBackMouse(mouse terminal.Mouse) {
+	u.actions = append(u.actions, NewUserEventMouse(mouse))
+}
+
+func (u *UserStream) PushBackKey(key terminal.Key) {
+	u.actions = append(u.actions, NewUserEventKey(key))
+}
+
 func (u *UserStream) Empty() bool {
 	return len(u.actions) == 0
 }
@@ -96,12 +160,32 @@ func (u *UserStream) GetAction(i int) terminal.ActOn {
 			return u.actions[i].userByte
 		case ResizeType:
 			return u.actions[i].resize
+		case MouseType:
+			return u.actions[i].mouse
+		case KeyType:
+			return u.actions[i].key
 		}
 	}
 
 	return nil
 }
 
+// return the channel message if the specified action is a channel message.
+func (u *UserStream) GetChannel(i int) (msg ChannelMsg, ok bool) {
+	if 0 <= i && i < len(u.actions) && u.actions[i].theType == ChannelType {
+		return u.actions[i].channel, true
+	}
+	return
+}
+
+// return the history request if the specified action is a history request.
+func (u *UserStream) GetHistory(i int) (req HistoryRequest, ok bool) {
+	if 0 <= i && i < len(u.actions) && u.actions[i].theType == HistoryType {
+		return u.actions[i].history, true
+	}
+	return
+}
+
 // implements network.State[C any] interface
 // Subtract() the prefix UserStream from current UserStream
 func (u *UserStream) Subtract(prefix *UserStream) {
@@ -153,8 +237,6 @@ func (u *UserStream) DiffFrom(existing *UserStream) string {
 				um.Instruction[idx].Keystroke.Keys = append(um.Instruction[idx].Keystroke.Keys, keys...)
 			} else {
 				// create a new Instruction for Keystroke
-				um.Instruction = make([]*pb.Instruction, 0)
-
 				inst := pb.Instruction{
 					Keystroke: &pb.Keystroke{Keys: keys},
 				}
@@ -166,6 +248,34 @@ func (u *UserStream) DiffFrom(existing *UserStream) string {
 				Resize: &pb.ResizeMessage{Width: int32(ue.resize.Width), Height: int32(ue.resize.Height)},
 			}
 			um.Instruction = append(um.Instruction, &inst)
+		case ChannelType:
+			// create a new Instruction for Channel
+			inst := pb.Instruction{
+				Channel: &pb.Channel{Id: ue.channel.ID, Kind: uint32(ue.channel.Kind), Data: ue.channel.Data,
+					Window: ue.channel.Window},
+			}
+			um.Instruction = append(um.Instruction, &inst)
+		case HistoryType:
+			// create a new Instruction for History
+			inst := pb.Instruction{
+				History: &pb.History{Id: ue.history.ID, Start: int32(ue.history.Start), Count: uint32(ue.history.Count),
+					Pattern: ue.history.Pattern, Commands: ue.history.Commands, Output: ue.history.Output},
+			}
+			um.Instruction = append(um.Instruction, &inst)
+		case MouseType:
+			// create a new Instruction for Mouse
+			inst := pb.Instruction{
+				Mouse: &pb.Mouse{Button: int32(ue.mouse.Button), X: int32(ue.mouse.X), Y: int32(ue.mouse.Y),
+					Release: ue.mouse.Release, Pixel: ue.mouse.Pixel},
+			}
+			um.Instruction = append(um.Instruction, &inst)
+		case KeyType:
+			// create a new Instruction for Key
+			inst := pb.Instruction{
+				Key: &pb.Key{Text: ue.key.Text, Code: int32(ue.key.Code), Shifted: int32(ue.key.Shifted),
+					Base: int32(ue.key.Base), Mods: int32(ue.key.Mods), Event: int32(ue.key.Event), Final: uint32(ue.key.Final)},
+			}
+			um.Instruction = append(um.Instruction, &inst)
 		}
 	}
 
@@ -205,6 +315,22 @@ func (u *UserStream) ApplyString(diff string) error {
 		} else if input.Instruction[i].Resize != nil {
 			w := input.Instruction[i].Resize
 			u.actions = append(u.actions, NewUserEventResize(terminal.Resize{Width: int(w.Width), Height: int(w.Height)}))
+		} else if input.Instruction[i].Channel != nil {
+			c := input.Instruction[i].Channel
+			u.actions = append(u.actions, NewUserEventChannel(ChannelMsg{ID: c.Id, Kind: ChannelKind(c.Kind), Data: c.Data,
+				Window: c.Window}))
+		} else if input.Instruction[i].History != nil {
+			h := input.Instruction[i].History
+			u.actions = append(u.actions, NewUserEventHistory(HistoryRequest{ID: h.Id, Start: int(h.Start), Count: int(h.Count),
+				Pattern: h.Pattern, Commands: h.Commands, Output: h.Output}))
+		} else if input.Instruction[i].Mouse != nil {
+			m := input.Instruction[i].Mouse
+			u.actions = append(u.actions, NewUserEventMouse(terminal.Mouse{Button: int(m.Button), X: int(m.X), Y: int(m.Y),
+				Release: m.Release, Pixel: m.Pixel}))
+		} else if input.Instruction[i].Key != nil {
+			k := input.Instruction[i].Key
+			u.actions = append(u.actions, NewUserEventKey(terminal.Key{Text: k.Text, Code: int(k.Code), Shifted: int(k.Shifted),
+				Base: int(k.Base), Mods: int(k.Mods), Event: int(k.Event), Final: byte(k.Final)}))
 		}
 	}
 
@@ -222,8 +348,12 @@ func (u *UserStream) Equal(x *UserStream) bool {
 // implements network.State[C any] interface
 func (u *UserStream) ResetInput()               {}
 func (u *UserStream) Reset()                    {}
+func (u *UserStream) Hold(now int64) bool       { return false }
 func (u *UserStream) InitSize(nCols, nRows int) {}
 
+// implements network.State[C any] interface
+func (u *UserStream) HoldFrom(x *UserStream) *UserStream { return u }
+
 // implements network.State[C any] interface
 func (u *UserStream) Clone() *UserStream {
 	clone := UserStream{}
@@ -180,6 +180,123 @@ func TestUserStreamInitDiff(t *testing.T) {
 	}
 }
 
+func TestUserStreamChannel(t *testing.T) {
+	msgs := []ChannelMsg{
+		{ID: 1, Kind: ChannelOpen, Data: []byte("tcp:localhost:80")},
+		{ID: 1, Kind: ChannelData, Data: []byte("GET / HTTP/1.0\r\n\r\n")},
+		{ID: 1, Kind: ChannelClose},
+	}
+
+	u1 := &UserStream{}
+	u1.PushBackResize(80, 40)
+	u1.PushBackChannel(msgs[0])
+	u1.PushBack([]rune("a"))
+	u1.PushBackChannel(msgs[1])
+	u1.PushBackChannel(msgs[2])
+	u1.PushBack([]rune("b"))
+
+	u2 := &UserStream{}
+	u2.ApplyString(u1.DiffFrom(&UserStream{}))
+
+	if !u1.Equal(u2) {
+		t.Errorf("#test channel expect %v, got %v\n", u1.actions, u2.actions)
+	}
+
+	// GetChannel only return channel message
+	got := []ChannelMsg{}
+	for i := 0; i < u2.Size(); i++ {
+		if msg, ok := u2.GetChannel(i); ok {
+			got = append(got, msg)
+			if u2.GetAction(i) != nil {
+				t.Errorf("#test channel expect nil action for channel message %d\n", i)
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, msgs) {
+		t.Errorf("#test channel expect %v, got %v\n", msgs, got)
+	}
+	if _, ok := u2.GetChannel(u2.Size()); ok {
+		t.Errorf("#test channel expect false for out of range index\n")
+	}
+}
+
+func TestUserStreamHistory(t *testing.T) {
+	req := HistoryRequest{ID: 3, Start: -40, Count: 120, Pattern: "(?i)error", Commands: true, Output: true}
+
+	u1 := &UserStream{}
+	u1.PushBack([]rune("a"))
+	u1.PushBackHistory(req)
+
+	u2 := &UserStream{}
+	u2.ApplyString(u1.DiffFrom(&UserStream{}))
+
+	if !u1.Equal(u2) {
+		t.Errorf("#test history expect %v, got %v\n", u1.actions, u2.actions)
+	}
+	if _, ok := u2.GetHistory(0); ok {
+		t.Errorf("#test history expect false for keystroke\n")
+	}
+	if got, ok := u2.GetHistory(1); !ok || got != req || u2.GetAction(1) != nil {
+		t.Errorf("#test history expect %v, got %v\n", req, got)
+	}
+}
+
+func TestUserStreamMouse(t *testing.T) {
+	mouse := terminal.Mouse{Button: 32, X: 100, Y: 20, Release: true, Pixel: true}
+
+	u1 := &UserStream{}
+	u1.PushBack([]rune("a"))
+	u1.PushBackMouse(mouse)
+
+	u2 := &UserStream{}
+	u2.ApplyString(u1.DiffFrom(&UserStream{}))
+
+	if !u1.Equal(u2) {
+		t.Errorf("#test mouse expect %v, got %v\n", u1.actions, u2.actions)
+	}
+	if got, ok := u2.GetAction(1).(terminal.Mouse); !ok || got != mouse {
+		t.Errorf("#test mouse expect %v, got %v\n", mouse, u2.GetAction(1))
+	}
+	if clone := u2.Clone(); !clone.Equal(u2) {
+		t.Errorf("#test mouse expect clone %v, got %v\n", u2.actions, clone.actions)
+	}
+}
+
+func TestUserStreamKey(t *testing.T) {
+	key := terminal.Key{Code: 'a', Shifted: 'A', Base: 'a', Mods: terminal.KeyShift, Event: terminal.KeyRepeat,
+		Text: "A", Final: 'u'}
+
+	u1 := &UserStream{}
+	u1.PushBackKey(key)
+	u1.PushBack([]rune("b"))
+
+	u2 := &UserStream{}
+	u2.ApplyString(u1.DiffFrom(&UserStream{}))
+
+	if !u1.Equal(u2) {
+		t.Errorf("#test key expect %v, got %v\n", u1.actions, u2.actions)
+	}
+	if got, ok := u2.GetAction(0).(terminal.Key); !ok || got != key {
+		t.Errorf("#test key expect %v, got %v\n", key, u2.GetAction(0))
+	}
+}
+
+func TestUserStreamDiffKeystroke(t *testing.T) {
+	// the keystroke after other instruction used to drop the instructions
+	// before it, here the keystroke "a" and the resize are lost.
+	u1 := &UserStream{}
+	u1.PushBack([]rune("a"))
+	u1.PushBackResize(80, 40)
+	u1.PushBack([]rune("b"))
+
+	u2 := &UserStream{}
+	u2.ApplyString(u1.DiffFrom(&UserStream{}))
+
+	if !u1.Equal(u2) {
+		t.Errorf("#test keystroke expect %v, got %v\n", u1.actions, u2.actions)
+	}
+}
+
 func TestUserStreamApplyStringFail(t *testing.T) {
 	diff := "malformed diff"
 	u3 := &UserStream{}
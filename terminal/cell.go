@@ -19,11 +19,22 @@ type Cell struct {
 	renditions Renditions
 	// fallback   bool
 	dirty      bool
-	wrap       bool // indicate single/double width grapheme which is the last cell in the row.
-	earlyWrap  bool // indicate double width grapheme which start from position nColsEff-1
-	dwidth     bool // indicate this cell is the first cell of double width grapheme if true
-	dwidthCont bool // indicate this cell is the second cell of double width grapheme if true
-}
+	wrap       bool   // indicate single/double width grapheme which is the last cell in the row.
+	earlyWrap  bool   // indicate double width grapheme which start from position nColsEff-1
+	dwidth     bool   // indicate this cell is the first cell of double width grapheme if true
+	dwidthCont bool   // indicate this cell is the second cell of double width grapheme if true
+	mark       uint8  // shell integration marks of the row, only the first cell of row is used. see hdl_osc_133()
+	status     uint8  // exit status of the command finished in the row, valid if mark has markCommandEnd
+	image      uint32 // id of the image anchored at this cell, see placeImage()
+}
+
+// shell integration marks, see hdl_osc_133()
+const (
+	markPromptStart  uint8 = 1 << iota // OSC 133;A
+	markCommandStart                   // OSC 133;B
+	markOutputStart                    // OSC 133;C
+	markCommandEnd                     // OSC 133;D
+)
 
 // func (c *Cell) Equal(x *Cell) bool {
 // 	if c.contents != x.contents {
@@ -74,6 +85,9 @@ func (c *Cell) Reset2(attrs Cell) {
 	c.renditions = attrs.renditions
 	c.dwidth = false
 	c.dwidthCont = false
+	c.mark = 0
+	c.status = 0
+	c.image = 0
 }
 
 // return true is the contents is "".
@@ -0,0 +1,227 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ericwq/aprilsh/terminfo"
+)
+
+// the number of colors supported by terminal
+const (
+	Colors8    = 8
+	Colors16   = 16
+	Colors256  = 256
+	ColorsTrue = 1 << 24
+)
+
+// return the number of colors supported by the terminal. the XTGETTCAP
+// replies of RGB and Co in caps are checked first, then the COLORTERM
+// environment variable and the colors capability of terminfo. if nothing is
+// known, the terminal is treated as truecolor terminal.
+func TerminalColors(caps map[int]string) int {
+	colors := 0
+	for _, reply := range strings.Split(caps[DCS_XTGETTCAP], "\x1BP1+r")[1:] {
+		reply, _, _ = strings.Cut(reply, "\x1B")
+		hexName, hexValue, _ := strings.Cut(reply, "=")
+		name, _ := hex.DecodeString(hexName)
+		value, _ := hex.DecodeString(hexValue)
+
+		switch string(name) {
+		case "RGB":
+			return ColorsTrue
+		case "Co", "colors":
+			colors, _ = strconv.Atoi(string(value))
+		}
+	}
+
+	if ct := os.Getenv("COLORTERM"); ct == "truecolor" || ct == "24bit" {
+		return ColorsTrue
+	}
+	if colors == 0 {
+		if v, ok := terminfo.Lookup("colors"); ok {
+			colors, _ = strconv.Atoi(v)
+		}
+	}
+
+	switch {
+	case colors <= 0, colors >= ColorsTrue:
+		return ColorsTrue
+	case colors >= Colors256:
+		return Colors256
+	case colors >= Colors16:
+		return Colors16
+	default:
+		return Colors8
+	}
+}
+
+// Downgrade returns the perceptual nearest color the terminal supports. the
+// basic 16 colors are used for 8 and 16 colors terminal, the 256 colors
+// terminal uses the color cube and gray ramp, since the basic 16 colors are
+// usually changed by the terminal theme. the default color is not changed.
+func (c Color) Downgrade(colors int) Color {
+	if !c.Valid() || colors <= 0 || colors >= ColorsTrue {
+		return c
+	}
+	if idx := c.Index(); idx >= 0 && idx < colors {
+		return c
+	}
+
+	r, g, b := c.RGB()
+	if r < 0 {
+		return c
+	}
+
+	start := 0
+	if colors >= Colors256 {
+		start = 16
+	}
+	target := toLab(r, g, b)
+	nearest, distance := start, math.MaxFloat64
+	for i := start; i < min(colors, Colors256); i++ {
+		if d := target.distance(paletteLab[i]); d < distance {
+			nearest, distance = i, d
+		}
+	}
+	return PaletteColor(nearest)
+}
+
+// CIE L*a*b* color
+type labColor struct {
+	l, a, b float64
+}
+
+// the palette colors in CIE L*a*b*
+var paletteLab = func() (p [256]labColor) {
+	for i := range p {
+		r, g, b := PaletteColor(i).RGB()
+		p[i] = toLab(r, g, b)
+	}
+	return
+}()
+
+// convert sRGB color to CIE L*a*b* color, with D65 white point.
+func toLab(r, g, b int32) labColor {
+	linear := func(v int32) float64 {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	lr, lg, lb := linear(r), linear(g), linear(b)
+
+	x := (0.4124*lr + 0.3576*lg + 0.1805*lb) / 0.95047
+	y := 0.2126*lr + 0.7152*lg + 0.0722*lb
+	z := (0.0193*lr + 0.1192*lg + 0.9505*lb) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 0.008856 {
+			return math.Cbrt(t)
+		}
+		return 7.787*t + 16.0/116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return labColor{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}
+
+// CIE76 color difference, the squared value is enough for comparison.
+func (x labColor) distance(y labColor) float64 {
+	dl, da, db := x.l-y.l, x.a-y.a, x.b-y.b
+	return dl*dl + da*da + db*db
+}
+
+var sgrSequence = regexp.MustCompile("\x1B\\[[0-9:;]*m")
+
+// replace the colors of SGR sequences in s with the nearest colors the
+// terminal supports. the replaced colors use the semicolon form, which is
+// understood by the old terminals.
+func downgradeColors(s string, colors int) string {
+	if colors <= 0 || colors >= ColorsTrue || !strings.Contains(s, "\x1B[") {
+		return s
+	}
+	return sgrSequence.ReplaceAllStringFunc(s, func(seq string) string {
+		return "\x1B[" + downgradeSGR(seq[2:len(seq)-1], colors) + "m"
+	})
+}
+
+// replace the colors in SGR parameters.
+func downgradeSGR(params string, colors int) string {
+	ps := strings.Split(params, ";")
+	out := make([]string, 0, len(ps))
+	for i := 0; i < len(ps); i++ {
+		sub := strings.Split(ps[i], ":")
+		n, _ := strconv.Atoi(sub[0])
+
+		switch {
+		case n == 38 || n == 48 || n == 58:
+			args, k := sub[1:], 0
+			if len(sub) == 1 { // semicolon form: 38;5;n or 38;2;r;g;b
+				rest := ps[i+1:]
+				if len(rest) >= 2 && rest[0] == "5" {
+					k = 2
+				} else if len(rest) >= 4 && rest[0] == "2" {
+					k = 4
+				}
+				args = rest[:k]
+			}
+			if c := parseSGRColor(args); c.Valid() {
+				out = append(out, sgrColor(n, c.Downgrade(colors)))
+			} else {
+				out = append(out, ps[i:i+1+k]...)
+			}
+			i += k
+		case 30 <= n && n <= 37, 40 <= n && n <= 47:
+			out = append(out, sgrColor(n/10*10+8, PaletteColor(n%10).Downgrade(colors)))
+		case 90 <= n && n <= 97, 100 <= n && n <= 107:
+			out = append(out, sgrColor(n/100*10+38, PaletteColor(n%10+8).Downgrade(colors)))
+		default:
+			out = append(out, ps[i])
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+// parse the extended color parameters after 38, 48 or 58. the colon form
+// may have the color space id: 2::r:g:b.
+func parseSGRColor(args []string) Color {
+	v := make([]int, len(args))
+	for i := range args {
+		v[i], _ = strconv.Atoi(args[i])
+	}
+
+	switch {
+	case len(v) >= 2 && v[0] == 5 && v[1] < 256:
+		return PaletteColor(v[1])
+	case len(v) >= 4 && v[0] == 2:
+		r, g, b := v[len(v)-3], v[len(v)-2], v[len(v)-1]
+		return NewRGBColor(int32(r), int32(g), int32(b))
+	}
+	return ColorDefault
+}
+
+// return the SGR parameters of palette color c. base is 38 for foreground,
+// 48 for background and 58 for underline.
+func sgrColor(base int, c Color) string {
+	idx := c.Index()
+	switch {
+	case idx < 0:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base, (c>>16)&0xFF, (c>>8)&0xFF, c&0xFF)
+	case base == 58 || idx >= 16:
+		return fmt.Sprintf("%d;5;%d", base, idx)
+	case idx < 8:
+		return strconv.Itoa(base - 8 + idx)
+	default:
+		return strconv.Itoa(base + 44 + idx)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColorDowngrade(t *testing.T) {
+	tc := []struct {
+		label  string
+		color  Color
+		colors int
+		expect Color
+	}{
+		{"default color", ColorDefault, Colors8, ColorDefault},
+		{"truecolor keep rgb", NewRGBColor(1, 2, 3), ColorsTrue, NewRGBColor(1, 2, 3)},
+		{"no limit keep rgb", NewRGBColor(1, 2, 3), 0, NewRGBColor(1, 2, 3)},
+		{"red to 256", NewRGBColor(255, 0, 0), Colors256, PaletteColor(196)},
+		{"red to 16", NewRGBColor(255, 0, 0), Colors16, PaletteColor(9)},
+		{"red to 8", NewRGBColor(255, 0, 0), Colors8, PaletteColor(1)},
+		{"gray to 256", NewRGBColor(128, 128, 128), Colors256, PaletteColor(244)},
+		{"near gray to 256", NewRGBColor(130, 127, 129), Colors256, PaletteColor(244)},
+		{"orange to 256", NewRGBColor(255, 135, 0), Colors256, PaletteColor(208)},
+		{"palette in range", PaletteColor(200), Colors256, PaletteColor(200)},
+		{"basic in range", PaletteColor(12), Colors16, PaletteColor(12)},
+		{"palette to 16", PaletteColor(196), Colors16, PaletteColor(9)},
+		{"bright to 8", PaletteColor(15), Colors8, PaletteColor(7)},
+	}
+
+	for _, v := range tc {
+		if got := v.color.Downgrade(v.colors); got != v.expect {
+			t.Errorf("%s expect %d, got %d\n", v.label, v.expect.Index(), got.Index())
+		}
+	}
+}
+
+func TestDowngradeColors(t *testing.T) {
+	tc := []struct {
+		label  string
+		input  string
+		colors int
+		expect string
+	}{
+		{"truecolor", "\x1B[0;38:2:255:0:0m", ColorsTrue, "\x1B[0;38:2:255:0:0m"},
+		{"colon rgb to 256", "\x1B[0;1;38:2:255:0:0;48:2:128:128:128mA", Colors256, "\x1B[0;1;38;5;196;48;5;244mA"},
+		{"colon rgb with color space", "\x1B[38:2::255:0:0m", Colors256, "\x1B[38;5;196m"},
+		{"semicolon rgb to 16", "\x1B[38;2;255;0;0;4m", Colors16, "\x1B[91;4m"},
+		{"rgb background to 8", "\x1B[48;2;255;0;0m", Colors8, "\x1B[41m"},
+		{"256 to 16", "\x1B[38;5;196;48:5:21m", Colors16, "\x1B[91;104m"},
+		{"256 keep", "\x1B[38:5:196m", Colors256, "\x1B[38;5;196m"},
+		{"bright to 8", "\x1B[97;100m", Colors8, "\x1B[37;47m"},
+		{"basic keep", "\x1B[31;42m", Colors8, "\x1B[31;42m"},
+		{"underline color", "\x1B[58:2:255:0:0m", Colors16, "\x1B[58;5;9m"},
+		{"invalid extended color", "\x1B[38;9;1m", Colors16, "\x1B[38;9;1m"},
+		{"other sequence", "\x1B[2J\x1B[1;1Habc", Colors8, "\x1B[2J\x1B[1;1Habc"},
+	}
+
+	for _, v := range tc {
+		if got := downgradeColors(v.input, v.colors); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestTerminalColors(t *testing.T) {
+	tc := []struct {
+		label     string
+		colorterm string
+		caps      map[int]string
+		expect    int
+	}{
+		{"RGB reply", "", map[int]string{DCS_XTGETTCAP: "\x1BP1+r524742=382f382f38\x1B\\\x1BP1+r436f=323536\x1B\\"}, ColorsTrue},
+		{"Co reply 256", "", map[int]string{DCS_XTGETTCAP: "\x1BP0+r524742\x1B\\\x1BP1+r436f=323536\x1B\\"}, Colors256},
+		{"Co reply 88", "", map[int]string{DCS_XTGETTCAP: "\x1BP1+r436f=3838\x1B\\"}, Colors16},
+		{"Co reply 8", "", map[int]string{DCS_XTGETTCAP: "\x1BP1+r436f=38\x1B\\"}, Colors8},
+		{"COLORTERM", "truecolor", map[int]string{DCS_XTGETTCAP: "\x1BP1+r436f=323536\x1B\\"}, ColorsTrue},
+		{"COLORTERM 24bit", "24bit", nil, ColorsTrue},
+	}
+
+	for _, v := range tc {
+		t.Setenv("COLORTERM", v.colorterm)
+		if got := TerminalColors(v.caps); got != v.expect {
+			t.Errorf("%s expect %d, got %d\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestNewFrame_Colors(t *testing.T) {
+	oldE := NewEmulator3(80, 40, 40)
+	newE := NewEmulator3(80, 40, 40)
+	newE.HandleStream("\x1B[38:2:255:0:0mred\x1B[0m")
+
+	tc := []struct {
+		label  string
+		colors int
+		expect string
+	}{
+		{"truecolor", ColorsTrue, "38:2:255:0:0m"},
+		{"256 colors", Colors256, "38;5;196m"},
+		{"16 colors", Colors16, "91m"},
+	}
+
+	for _, v := range tc {
+		d, _ := NewDisplay(false)
+		d.SetColors(v.colors)
+
+		got := d.NewFrame(true, oldE, newE)
+		if !strings.Contains(got, v.expect) {
+			t.Errorf("%s expect %q in %q\n", v.label, v.expect, got)
+		}
+	}
+}
@@ -6,7 +6,9 @@ package terminal
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/ericwq/aprilsh/terminfo"
@@ -142,6 +144,14 @@ type Display struct {
 	hasECH       bool
 	hasBCE       bool // erases result in cell filled with background color
 	supportTitle bool // supports window title and icon name
+	supportCwd   bool // supports working directory report (OSC 7)
+	supportLink  bool // supports hyperlinks (OSC 8)
+	localMouse   bool // ask the local terminal to report mouse in LocalMouseEnc()
+	localImage   bool // draw images on the local terminal, otherwise use the image placeholders
+	colors       int  // the number of colors supported by terminal, see TerminalColors()
+
+	// the palette keys replicated to terminal, they are reset by Close()
+	replicated map[int]bool
 
 	// ti           *terminfo.Terminfo
 
@@ -167,6 +177,9 @@ func NewDisplay(useEnvironment bool) (d *Display, e error) {
 	d.hasECH = true
 	d.hasBCE = true
 	d.supportTitle = true
+	d.supportCwd = true
+	d.supportLink = true
+	d.colors = ColorsTrue
 
 	if useEnvironment {
 		term := os.Getenv("TERM")
@@ -188,6 +201,12 @@ func NewDisplay(useEnvironment bool) (d *Display, e error) {
 			}
 		}
 
+		d.supportCwd = cwdSupported(term)
+		d.supportLink = linkSupported(term)
+		d.localMouse = true
+		d.localImage = true
+		d.colors = TerminalColors(nil)
+
 		d.smcup, _ = terminfo.Lookup("smcup")
 		d.rmcup, _ = terminfo.Lookup("rmcup")
 	}
@@ -195,6 +214,45 @@ func NewDisplay(useEnvironment bool) (d *Display, e error) {
 	return d, nil
 }
 
+// Same as window title, terminfo has nothing about the working directory
+// report (OSC 7), so we hardcode a whitelist of terminals which understand it.
+func cwdSupported(term string) bool {
+	if os.Getenv("VTE_VERSION") != "" || os.Getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	cwdTermPrograms := []string{"iTerm.app", "Apple_Terminal", "WezTerm", "vscode", "ghostty", "tmux"}
+	if slices.Contains(cwdTermPrograms, os.Getenv("TERM_PROGRAM")) {
+		return true
+	}
+	cwdTermTypes := []string{"xterm-kitty", "xterm-ghostty", "foot", "wezterm", "tmux"}
+	for _, tt := range cwdTermTypes {
+		if strings.HasPrefix(term, tt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Neither terminfo nor the terminal reports tell whether hyperlinks (OSC 8)
+// are supported, some old terminals print the URL on screen. so we hardcode a
+// whitelist of terminals which understand it.
+func linkSupported(term string) bool {
+	if os.Getenv("VTE_VERSION") != "" || os.Getenv("KONSOLE_VERSION") != "" || os.Getenv("WT_SESSION") != "" {
+		return true
+	}
+	linkTermPrograms := []string{"iTerm.app", "WezTerm", "vscode", "ghostty", "tmux"}
+	if slices.Contains(linkTermPrograms, os.Getenv("TERM_PROGRAM")) {
+		return true
+	}
+	linkTermTypes := []string{"xterm-kitty", "xterm-ghostty", "foot", "wezterm", "alacritty", "contour", "tmux"}
+	for _, tt := range linkTermTypes {
+		if strings.HasPrefix(term, tt) {
+			return true
+		}
+	}
+	return false
+}
+
 // compare two terminals and generate mix (grapheme and control sequence) sequence
 // to rebuild the new terminal from the old one.
 //
@@ -209,13 +267,13 @@ func (d *Display) NewFrame(initialized bool, oldE, newE *Emulator) string {
 	frame.showCursorMode = oldE.showCursorMode
 	frame.lastFrame = oldE
 	frame.out = &strings.Builder{}
+	if d.supportLink {
+		frame.links = newE.links
+	}
+	frame.colors = d.colors
 	// ti := d.ti
 
-	// has bell been rung?
-	if newE.getBellCount() != oldE.getBellCount() {
-		// ti.TPuts(&b, ti.Bell)
-		frame.append("\a")
-	}
+	// the bell is not replicated, it's delivered as bell event.
 
 	// has icon name or window title changed?
 	// Enhanced: has window title stack changed?
@@ -250,13 +308,14 @@ func (d *Display) NewFrame(initialized bool, oldE, newE *Emulator) string {
 		d.titleChanged(initialized, frame, oldE, newE)
 	}
 
-	// has clipboard changed?
-	if newE.selectionData != oldE.selectionData {
-		// the selectionData is in the form of "\x1B]52;%s;%s\x1B\\"
-		// see hdl_osc_52() for detail
-		frame.append(newE.selectionData)
+	// has working directory changed?
+	if d.supportCwd && newE.workingDir != "" && (!initialized || newE.workingDir != oldE.workingDir) {
+		frame.append("\x1B]7;%s\x1B\\", newE.workingDir)
 	}
 
+	// the clipboard is not replicated, it's delivered as clipboard event. so
+	// the client can apply its clipboard policy.
+
 	// has reverse video state changed?
 	if !initialized || newE.reverseVideo != oldE.reverseVideo {
 		// set reverse video
@@ -366,6 +425,7 @@ func (d *Display) NewFrame(initialized bool, oldE, newE *Emulator) string {
 	}
 
 	d.replicateContent(initialized, oldE, newE, sizeChanged, asbChanged, frame)
+	d.replicateImages(initialized, oldE, newE, frame)
 
 	// has cursor location changed?
 	if !initialized || newE.GetCursorRow() != frame.cursorY || newE.GetCursorCol() != frame.cursorX {
@@ -407,13 +467,19 @@ func (d *Display) NewFrame(initialized bool, oldE, newE *Emulator) string {
 		frame.append("\x1B[%d q", Ps)
 	}
 
-	// has cursor color changed to default?
+	// has cursor color changed?
 	if !initialized || newE.cf.cursor.color != oldE.cf.cursor.color {
 		if newE.cf.cursor.color == ColorDefault {
 			frame.append("\x1B]112\a")
+		} else {
+			frame.append("\x1B]12;%s\a", newE.cf.cursor.color)
 		}
 	}
 
+	// has palette changed?
+	d.replicatePalette(initialized, oldE, newE, frame)
+	d.TrackPalette(newE)
+
 	// has renditions changed?
 	frame.updateRendition(newE.GetRenditions(), !initialized)
 
@@ -443,6 +509,12 @@ func (d *Display) NewFrame(initialized bool, oldE, newE *Emulator) string {
 		}
 	}
 
+	// has kitty keyboard flags changed?
+	newFlags, oldFlags := newE.cf.kittyKbd.GetPeek(), oldE.cf.kittyKbd.GetPeek()
+	if newFlags != oldFlags || (!initialized && newFlags != 0) {
+		frame.append("\x1B[=%d;1u", newFlags)
+	}
+
 	// has mouse focus mode changed?
 	if !initialized || newE.mouseTrk.focusEventMode != oldE.mouseTrk.focusEventMode {
 		if newE.mouseTrk.focusEventMode {
@@ -453,18 +525,23 @@ func (d *Display) NewFrame(initialized bool, oldE, newE *Emulator) string {
 	}
 
 	// has mouse encoding mode changed?
-	if !initialized || newE.mouseTrk.enc != oldE.mouseTrk.enc {
-		if newE.mouseTrk.enc == MouseTrackingEnc_Default {
+	newEnc, oldEnc := newE.mouseTrk.enc, oldE.mouseTrk.enc
+	if d.localMouse {
+		newEnc, oldEnc = LocalMouseEnc(newEnc), LocalMouseEnc(oldEnc)
+	}
+	if !initialized || newEnc != oldEnc {
+		if newEnc == MouseTrackingEnc_Default {
+			frame.append("\x1B[?1016l")
 			frame.append("\x1B[?1015l")
 			frame.append("\x1B[?1006l")
 			frame.append("\x1B[?1005l")
 		} else {
 			// close old mouse encoding mode
-			if oldE.mouseTrk.enc != MouseTrackingEnc_Default {
-				frame.append("\x1B[?%dl", oldE.mouseTrk.enc)
+			if oldEnc != MouseTrackingEnc_Default && oldEnc != newEnc {
+				frame.append("\x1B[?%dl", oldEnc)
 			}
 			// open new mouse encoding mode
-			frame.append("\x1B[?%dh", newE.mouseTrk.enc)
+			frame.append("\x1B[?%dh", newEnc)
 		}
 	}
 
@@ -749,6 +826,94 @@ func (d *Display) replicateContent(initialized bool, oldE, newE *Emulator, sizeC
 	// 	"countRows", countRows)
 }
 
+// generate the OSC sequences to change the palette of old terminal to the
+// palette of new terminal.
+func (d *Display) replicatePalette(initialized bool, oldE, newE *Emulator, frame *FrameState) {
+	keys := make([]int, 0, len(newE.palette)+len(oldE.palette))
+	for k, c := range newE.palette {
+		if old, ok := oldE.palette[k]; !initialized || !ok || old != c {
+			keys = append(keys, k)
+		}
+	}
+	for k := range oldE.palette {
+		if _, ok := newE.palette[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	slices.Sort(keys)
+	for _, k := range keys {
+		frame.append(paletteSequence(k, newE.palette[k]))
+	}
+}
+
+// remember the palette colors and cursor color changed by application of
+// emulator, which are replicated to terminal. they are reset by Close().
+func (d *Display) TrackPalette(emu *Emulator) {
+	if d.replicated == nil {
+		d.replicated = make(map[int]bool)
+	}
+	for k := range emu.palette {
+		d.replicated[k] = true
+	}
+	if emu.cf.cursor.color != ColorDefault {
+		d.replicated[paletteCursor] = true
+	}
+}
+
+// replicate the images placed on screen. the image is drawn if it's new or
+// moved. sixel image is also redrawn if the rows it covers are changed, it's
+// overwritten by the text.
+func (d *Display) replicateImages(initialized bool, oldE, newE *Emulator, frame *FrameState) {
+	var oldP []imagePlacement
+	if initialized {
+		oldP = oldE.imagePlacements()
+	}
+	newP := newE.imagePlacements()
+
+	// kitty keeps the placements in its own layer, delete the stale ones.
+	deleted := make(map[uint32]bool)
+	if d.localImage && newE.supportImage(ImageKitty) {
+		for _, p := range oldP {
+			if !slices.Contains(newP, p) && !deleted[p.id] {
+				frame.append("\x1B_Ga=d,d=i,i=%d,q=2\x1B\\", p.id)
+				deleted[p.id] = true
+			}
+		}
+	}
+
+	for _, p := range newP {
+		img, ok := newE.images.get(p.id)
+		if !ok {
+			continue
+		}
+		if initialized && slices.Contains(oldP, p) && !deleted[p.id] &&
+			!(d.localImage && img.Kind == ImageSixel && rowsChanged(oldE, newE, p.row, img.Rows)) {
+			continue
+		}
+
+		if d.localImage {
+			if newE.supportImage(img.Kind) {
+				frame.appendSilentMove(p.row, p.col)
+				frame.append(img.draw())
+			}
+		} else {
+			frame.appendSilentMove(p.row, p.col)
+			frame.append(imagePlaceholder, img.ID, img.Cols, img.Rows, imageMoveNone)
+		}
+	}
+}
+
+// return true if any screen row in [row, row+count) is different.
+func rowsChanged(oldE, newE *Emulator, row, count int) bool {
+	for y := row; y < min(row+count, newE.nRows, oldE.nRows); y++ {
+		if !equalRow(oldE.cf.getRow(oldE.cf.getPhysicalRow(y)), newE.cf.getRow(newE.cf.getPhysicalRow(y))) {
+			return true
+		}
+	}
+	return false
+}
+
 // replicate alternate screen buffer
 func (d *Display) replicateASB(initialized bool, oldE, newE *Emulator, _ bool,
 	asbChanged bool, frame *FrameState,
@@ -1204,6 +1369,19 @@ func (d *Display) putRow2(initialized bool, frame *FrameState,
 	// 	return false
 	// }
 
+	// has the shell integration mark changed? skip the wrapped row, the cursor
+	// is still in the previous row.
+	if !wrap && (newRow[0].mark != oldRow[0].mark || newRow[0].status != oldRow[0].status) {
+		frame.appendSilentMove(frameY, 0)
+		if oldRow[0].mark&^newRow[0].mark != 0 {
+			// OSC 133 can't remove mark, erase the row and draw it again.
+			frame.updateRendition(Renditions{}, false)
+			frame.append("\x1B[2K")
+			oldRow = make([]Cell, len(newRow))
+		}
+		frame.appendMark(newRow[0])
+	}
+
 	// this row should be wrapped. TODO: need to consider double width cell
 	wrapThis := newRow[len(newRow)-1].wrap
 	// fmt.Printf("#putRow row=%d, wrapThis=%t\n", frameY, wrapThis)
@@ -1393,6 +1571,26 @@ func (d *Display) titleChanged(initialized bool, frame *FrameState, oldE, newE *
 	}
 }
 
+// return true if the terminal understands the working directory report.
+func (d *Display) SupportCwd() bool {
+	return d.supportCwd
+}
+
+func (d *Display) SupportLink() bool {
+	return d.supportLink
+}
+
+// set the number of colors supported by terminal, the colors of output are
+// downgraded to the nearest supported colors.
+func (d *Display) SetColors(colors int) {
+	d.colors = colors
+}
+
+// replace the colors in diff with the nearest colors supported by terminal.
+func (d *Display) DowngradeColors(diff string) string {
+	return downgradeColors(diff, d.colors)
+}
+
 func (d *Display) Open() string {
 	var b strings.Builder
 	if d.smcup != "" {
@@ -1414,7 +1612,16 @@ func (d *Display) Close() string {
 	// disable mouse tracking mode
 	fmt.Fprintf(&b, "\x1B[?1003l\x1B[?1002l\x1B[?1001l\x1B[?1000l")
 	// reset to default mouse tracking encoding
-	fmt.Fprintf(&b, "\x1B[?1015l\x1B[?1006l\x1B[?1005l")
+	fmt.Fprintf(&b, "\x1B[?1016l\x1B[?1015l\x1B[?1006l\x1B[?1005l")
+	// OSC 104, 110, 111, 112: reset the colors changed by application
+	keys := make([]int, 0, len(d.replicated))
+	for k := range d.replicated {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		b.WriteString(paletteSequence(k, ColorDefault))
+	}
 	if d.rmcup != "" {
 		b.WriteString(d.rmcup)
 	}
@@ -1425,6 +1632,7 @@ func (d *Display) Clone() *Display {
 	clone := Display{}
 	// clone regular data fields
 	clone = *d
+	clone.replicated = maps.Clone(d.replicated)
 
 	// ignore logW
 	// ignore terminfo
@@ -1437,7 +1645,9 @@ type FrameState struct {
 	currentRendition Renditions
 	cursorX          int
 	cursorY          int
-	showCursorMode   bool // mosh: cursorVisible
+	showCursorMode   bool     // mosh: cursorVisible
+	links            *linkSet // the hyperlinks of new terminal, nil means hyperlinks are not replicated
+	colors           int      // the number of colors supported by terminal, 0 means no limit
 }
 
 func (fs *FrameState) append(x string, v ...any) {
@@ -1457,6 +1667,23 @@ func (fs *FrameState) appendCell(cell Cell) {
 	cell.printGrapheme(fs.out)
 }
 
+// generate OSC 133 sequence to set the shell integration marks of the row.
+// the generated sequence is wrote to the output stream.
+func (fs *FrameState) appendMark(cell Cell) {
+	if cell.mark&markCommandEnd != 0 {
+		fs.append("\x1B]133;D;%d\a", cell.status)
+	}
+	if cell.mark&markPromptStart != 0 {
+		fs.append("\x1B]133;A\a")
+	}
+	if cell.mark&markCommandStart != 0 {
+		fs.append("\x1B]133;B\a")
+	}
+	if cell.mark&markOutputStart != 0 {
+		fs.append("\x1B]133;C\a")
+	}
+}
+
 // turn off cursor if necessary, use appendMove to move cursor to position.
 // the generated sequence is wrote to the output stream.
 func (fs *FrameState) appendSilentMove(y int, x int) {
@@ -1516,11 +1743,30 @@ func (fs *FrameState) appendMove(y int, x int) {
 // SGR sequence to change the cell renditions and update the current renditions.
 // the generated sequence is wrote to the output stream.
 func (fs *FrameState) updateRendition(r Renditions, force bool) {
-	if force || fs.currentRendition != r {
+	current := fs.currentRendition
+	current.linkIndex = r.linkIndex
+	if force || current != r {
 		// fmt.Printf("#updateRendition currentRendition=%q, new renditions=%q - update renditions\n",
 		// 	d.currentRendition.SGR(), r.SGR())
-		fs.append(r.SGR())
-		fs.currentRendition = r
+		fs.append(downgradeColors(r.SGR(), fs.colors))
+	}
+	if fs.links != nil && (fs.currentRendition.linkIndex != r.linkIndex || force && r.linkIndex != 0) {
+		fs.appendLink(r.linkIndex)
+	}
+	fs.currentRendition = r
+}
+
+// generate OSC 8 sequence to open the hyperlink of index, or close the
+// hyperlink if the index is not found.
+func (fs *FrameState) appendLink(index int) {
+	l, ok := fs.links.getLink(index)
+	switch {
+	case !ok:
+		fs.append("\x1B]8;;\x1B\\")
+	case l.id != "":
+		fs.append("\x1B]8;id=%s;%s\x1B\\", l.id, l.url)
+	default:
+		fs.append("\x1B]8;;%s\x1B\\", l.url)
 	}
 }
 
@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
@@ -86,7 +87,7 @@ func TestOpenClose(t *testing.T) {
 		t.Errorf("#test open() expect %q, got %q\n", expect, got)
 	}
 
-	expect = "\x1b[?1l\x1b[0m\x1b[?25h\x1b[?1003l\x1b[?1002l\x1b[?1001l\x1b[?1000l\x1b[?1015l\x1b[?1006l\x1b[?1005l\x1b[?1049l\x1b[23;0;0t"
+	expect = "\x1b[?1l\x1b[0m\x1b[?25h\x1b[?1003l\x1b[?1002l\x1b[?1001l\x1b[?1000l\x1b[?1016l\x1b[?1015l\x1b[?1006l\x1b[?1005l\x1b[?1049l\x1b[23;0;0t"
 	got = d.Close()
 	if got != expect {
 		t.Errorf("#test close() expect %q, got %q\n", expect, got)
@@ -162,7 +163,7 @@ func TestNewFrame_PutRow(t *testing.T) {
 		{
 			"mix color, false initialized case",
 			"\x1b[10;1H\x1b[1;34mdevelop\x1b[m  \x1b[1;35mproj\x1b[m",
-			"\x1b[?5l\x1b[r\x1b[0m\x1b[H\x1b[2J\x1b[?25l\x1b[?1047l\x1b[r\x1b[?69l\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[0;1;34mdevelop\x1b[0m  \x1b[0;1;35mproj\x1b[0m\x1b[K\x1b[0C\x1b[?25h\x1b[1 q\x1b]112\a\x1b[0m\x1b[?2004l\x1b[?1003l\x1b[?1002l\x1b[?1001l\x1b[?1000l\x1b[?1004l\x1b[?1015l\x1b[?1006l\x1b[?1005l\x1b[?7h\x1b[20l\x1b[2l\x1b[4l\x1b[12h\x1b[?67l\x1b[?1036h\x1b[?1007l\x1b[?1l\x1b[?6l\x1b>\x1b[?3l\x1b[3g\x1b[64\"p\x1b[>4;1m",
+			"\x1b[?5l\x1b[r\x1b[0m\x1b[H\x1b[2J\x1b[?25l\x1b[?1047l\x1b[r\x1b[?69l\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[K\n\x1b[0;1;34mdevelop\x1b[0m  \x1b[0;1;35mproj\x1b[0m\x1b[K\x1b[0C\x1b[?25h\x1b[1 q\x1b]112\a\x1b[0m\x1b[?2004l\x1b[?1003l\x1b[?1002l\x1b[?1001l\x1b[?1000l\x1b[?1004l\x1b[?1006h\x1b[?7h\x1b[20l\x1b[2l\x1b[4l\x1b[12h\x1b[?67l\x1b[?1036h\x1b[?1007l\x1b[?1l\x1b[?6l\x1b>\x1b[?3l\x1b[3g\x1b[64\"p\x1b[>4;1m",
 			"[  9] develop..proj...................................................................",
 			9, ' ', ' ', false,
 		},
@@ -297,9 +298,10 @@ func TestNewFrame_Bell(t *testing.T) {
 		expectSeq   string
 		initialized bool
 		bell        bool
+		bellCount   int
 	}{
-		{"no bell", "", true, false},
-		{"has bell", "\a", true, true},
+		{"no bell", "", true, false, 0},
+		{"has bell", "", true, true, 1}, // delivered as bell event
 	}
 	oldE := NewEmulator3(80, 40, 40)
 	newE := NewEmulator3(80, 40, 40)
@@ -318,7 +320,7 @@ func TestNewFrame_Bell(t *testing.T) {
 		newE.resetTerminal()
 
 		if v.bell {
-			newE.ringBell()
+			newE.HandleStream("\x07")
 		}
 
 		// check the expect difference sequence
@@ -326,6 +328,11 @@ func TestNewFrame_Bell(t *testing.T) {
 		if gotSeq != v.expectSeq {
 			t.Errorf("%q expect \n%q, got \n%q\n", v.label, v.expectSeq, gotSeq)
 		}
+
+		// the bell is counted, it's delivered as bell event instead
+		if got := newE.GetBellCount(); got != v.bellCount {
+			t.Errorf("%q expect bell count %d, got %d\n", v.label, v.bellCount, got)
+		}
 	}
 }
 
@@ -869,18 +876,21 @@ func TestNewFrame_BracketedPasteMode(t *testing.T) {
 
 func TestNewFrame_MouseTrk(t *testing.T) {
 	tc := []struct {
-		label     string
-		diffCase  string // see the switch statement for the means
-		seq       string
-		expectSeq string
+		label      string
+		diffCase   string // see the switch statement for the means
+		seq        string
+		expectSeq  string
+		localMouse bool
 	}{
-		{"New is diffrent mode, old is default", "new", "\x1b[?1001h", "\x1b[?1001h"},
-		{"New is default, old is different mode", "old", "\x1b[?1003h", "\x1b[?1003l\x1b[?1002l\x1b[?1001l\x1b[?1000l"},
-		{"both have different mode", "\x1b[?1002h", "\x1b[?1003h", "\x1b[?1003l\x1b[?1002h"},
-		{"both terminal keep default value", "both", "", ""},
-		{"New is diffrent encoding, old is default", "new", "\x1b[?1005h", "\x1b[?1005h"},
-		{"New is default, old is different encoding", "old", "\x1b[?1006h", "\x1b[?1015l\x1b[?1006l\x1b[?1005l"},
-		{"both has different encoding", "\x1b[?1006h", "\x1b[?1015h", "\x1b[?1015l\x1b[?1006h"},
+		{"New is diffrent mode, old is default", "new", "\x1b[?1001h", "\x1b[?1001h", false},
+		{"New is default, old is different mode", "old", "\x1b[?1003h", "\x1b[?1003l\x1b[?1002l\x1b[?1001l\x1b[?1000l", false},
+		{"both have different mode", "\x1b[?1002h", "\x1b[?1003h", "\x1b[?1003l\x1b[?1002h", false},
+		{"both terminal keep default value", "both", "", "", false},
+		{"New is diffrent encoding, old is default", "new", "\x1b[?1005h", "\x1b[?1005h", false},
+		{"New is default, old is different encoding", "old", "\x1b[?1006h", "\x1b[?1016l\x1b[?1015l\x1b[?1006l\x1b[?1005l", false},
+		{"both has different encoding", "\x1b[?1006h", "\x1b[?1015h", "\x1b[?1015l\x1b[?1006h", false},
+		{"local terminal reports in SGR", "new", "\x1b[?1015h", "", true},
+		{"local terminal reports in SGR-Pixels", "new", "\x1b[?1016h", "\x1b[?1006l\x1b[?1016h", true},
 	}
 	oldE := NewEmulator3(8, 8, 4)
 	newE := NewEmulator3(8, 8, 4)
@@ -908,6 +918,40 @@ func TestNewFrame_MouseTrk(t *testing.T) {
 		}
 
 		// check the expect difference sequence
+		d.localMouse = v.localMouse
+		gotSeq := d.NewFrame(true, oldE, newE)
+		if gotSeq != v.expectSeq {
+			t.Errorf("%q expect \n%q, got \n%q\n", v.label, v.expectSeq, gotSeq)
+		}
+	}
+}
+
+func TestNewFrame_KittyKeyboard(t *testing.T) {
+	tc := []struct {
+		label     string
+		oldSeq    string
+		newSeq    string
+		expectSeq string
+	}{
+		{"push flags", "", "\x1b[>5u", "\x1b[=5;1u"},
+		{"pop flags", "\x1b[>5u", "\x1b[>5u\x1b[<u", "\x1b[=0;1u"},
+		{"same flags", "\x1b[=3u", "\x1b[>3u", ""},
+	}
+
+	os.Setenv("TERM", "xterm-256color")
+	d, e := NewDisplay(true)
+	if e != nil {
+		t.Errorf("#test create display error: %s\n", e)
+	}
+
+	for _, v := range tc {
+		oldE := NewEmulator3(8, 8, 4)
+		newE := NewEmulator3(8, 8, 4)
+		oldE.caps[CSI_U_QUERY] = "\x1b[?0u"
+		newE.caps[CSI_U_QUERY] = "\x1b[?0u"
+		oldE.HandleStream(v.oldSeq)
+		newE.HandleStream(v.newSeq)
+
 		gotSeq := d.NewFrame(true, oldE, newE)
 		if gotSeq != v.expectSeq {
 			t.Errorf("%q expect \n%q, got \n%q\n", v.label, v.expectSeq, gotSeq)
@@ -1072,22 +1116,22 @@ func TestNewFrame_TabStops(t *testing.T) {
 
 func TestNewFrame_SelectionData(t *testing.T) {
 	tc := []struct {
-		label     string
-		newRaw    string
-		oldRaw    string
-		expectSeq string
+		label  string
+		newRaw string
+		oldRaw string
+		expect []Clipboard // the selection data is delivered as clipboard event
 	}{
 		{
 			"use new selection data",
 			"new terminal has selection data",
 			"old terminal has selection data",
-			"\x1b]52;pc;bmV3IHRlcm1pbmFsIGhhcyBzZWxlY3Rpb24gZGF0YQ==\x1b\\",
+			[]Clipboard{{Selection: "pc", Data: "bmV3IHRlcm1pbmFsIGhhcyBzZWxlY3Rpb24gZGF0YQ=="}},
 		},
 		{
 			"clear selection data",
 			"",
 			"old terminal has seelction data",
-			"\x1b]52;pc;\x1b\\",
+			[]Clipboard{{Selection: "pc", Data: ""}},
 		},
 	}
 
@@ -1110,10 +1154,12 @@ func TestNewFrame_SelectionData(t *testing.T) {
 		newE.HandleStream(buildSelectionDataSequence(v.newRaw))
 		oldE.HandleStream(buildSelectionDataSequence(v.oldRaw))
 
-		// check the expect difference sequence
-		gotSeq := d.NewFrame(true, oldE, newE)
-		if gotSeq != v.expectSeq {
-			t.Errorf("%q expect \n%q, got \n%q\n", v.label, v.expectSeq, gotSeq)
+		// the selection data is not replicated
+		if gotSeq := d.NewFrame(true, oldE, newE); gotSeq != "" {
+			t.Errorf("%q expect empty sequence, got \n%q\n", v.label, gotSeq)
+		}
+		if got := newE.TakeClipboards(); !reflect.DeepEqual(got, v.expect) {
+			t.Errorf("%q expect %v, got %v\n", v.label, v.expect, got)
 		}
 	}
 }
@@ -1406,3 +1452,113 @@ func BenchmarkAppendx(b *testing.B) {
 		frame.append("%s", "powerpoint")
 	}
 }
+
+func TestNewFrame_ShellMarks(t *testing.T) {
+	tc := []struct {
+		label     string
+		seq       string
+		expectSeq string // part of the difference sequence
+		expect    []ShellCommand
+	}{
+		{
+			"replicate marks",
+			"\x1B]133;A\a$ \x1B]133;B\als\r\n\x1B]133;C\afile\r\n\x1B]133;D;2\a\x1B]133;A\a$ ",
+			"\x1B]133;D;2\a\x1B]133;A\a",
+			[]ShellCommand{{0, 1, 2, 2}, {2, 3, 0, -1}},
+		},
+		{
+			"remove marks",
+			"\r\x1B[2K$ x",
+			"\x1B[2K",
+			[]ShellCommand{{0, 1, 0, -1}},
+		},
+	}
+
+	server := NewEmulator3(20, 8, 0)
+	client := NewEmulator3(20, 8, 0)
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+
+	os.Setenv("TERM", "xterm-256color")
+	d, e := NewDisplay(true)
+	if e != nil {
+		t.Errorf("#test create display error: %s\n", e)
+	}
+
+	for _, v := range tc {
+		server.HandleStream(v.seq)
+		diff := d.NewFrame(true, client, server)
+		client.HandleStream(diff)
+
+		if !strings.Contains(diff, v.expectSeq) {
+			t.Errorf("%s expect %q in %q\n", v.label, v.expectSeq, diff)
+		}
+		if got := client.GetCommands(10); !slices.Equal(got, v.expect) {
+			t.Errorf("%s expect %v, got %v\n", v.label, v.expect, got)
+		}
+		if !client.Equal(server) {
+			t.Errorf("%s expect client equals server\n", v.label)
+		}
+	}
+}
+
+func TestNewFrame_WorkingDir(t *testing.T) {
+	tc := []struct {
+		label     string
+		seq       string
+		expectSeq string // the working directory report in difference sequence
+	}{
+		{"report", "\x1B]7;file://ubuntu/home/ide\a", "\x1B]7;file://ubuntu/home/ide\x1B\\"},
+		{"unchanged", "ls\r\n", ""},
+		{"change", "\x1B]7;file://ubuntu/tmp\x1B\\", "\x1B]7;file://ubuntu/tmp\x1B\\"},
+	}
+
+	server := NewEmulator3(20, 8, 0)
+	client := NewEmulator3(20, 8, 0)
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+
+	d, _ := NewDisplay(false)
+	for _, v := range tc {
+		server.HandleStream(v.seq)
+		diff := d.NewFrame(true, client, server)
+		client.HandleStream(diff)
+
+		if v.expectSeq != "" && !strings.Contains(diff, v.expectSeq) {
+			t.Errorf("%s expect %q in %q\n", v.label, v.expectSeq, diff)
+		}
+		if v.expectSeq == "" && strings.Contains(diff, "\x1B]7;") {
+			t.Errorf("%s expect no working directory report in %q\n", v.label, diff)
+		}
+		if !client.Equal(server) {
+			t.Errorf("%s expect client equals server\n", v.label)
+		}
+	}
+}
+
+func TestCwdSupported(t *testing.T) {
+	tc := []struct {
+		label   string
+		term    string
+		program string
+		vte     string
+		expect  bool
+	}{
+		{"kitty", "xterm-kitty", "", "", true},
+		{"foot", "foot-extra", "", "", true},
+		{"iTerm2", "xterm-256color", "iTerm.app", "", true},
+		{"gnome terminal", "xterm-256color", "", "7600", true},
+		{"plain xterm", "xterm-256color", "", "", false},
+		{"linux console", "linux", "", "", false},
+	}
+
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			t.Setenv("TERM_PROGRAM", v.program)
+			t.Setenv("VTE_VERSION", v.vte)
+			t.Setenv("KONSOLE_VERSION", "")
+
+			if got := cwdSupported(v.term); got != v.expect {
+				t.Errorf("%s expect %t, got %t\n", v.label, v.expect, got)
+			}
+		})
+	}
+}
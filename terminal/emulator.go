@@ -7,6 +7,8 @@ package terminal
 import (
 	"fmt"
 	"maps"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/ericwq/aprilsh/util"
@@ -20,16 +22,26 @@ import (
 type Emulator struct {
 	parser              *Parser
 	links               *linkSet
+	images              *imageSet        // sixel and kitty graphics images, replicated by state synchronization
 	cf                  *Framebuffer     // replicated by NewFrame(), current frame buffer
 	selectionStore      map[rune]string  // local storage buffer for selection data in sequence OSC 52
 	caps                map[int]string   // client terminal capability
+	termName            string           // terminfo entry name of the shell TERM
+	palette             map[int]Color    // replicated by NewFrame(), colors changed by OSC 4, 10 and 11
+	width               WidthPolicy      // grapheme width policy of client terminal
+	placeholder         bool             // accept the image placeholder of state diff, client side only
 	savedCursor_DEC     *SavedCursor_DEC // replicated by NewFrame(),
 	windowTitle         string           // replicated by NewFrame()
 	iconLabel           string           // replicated by NewFrame()
+	workingDir          string           // replicated by NewFrame(), URL reported by OSC 7
 	selectionData       string           // replicated by NewFrame(), store the selection data for OSC 52
 	terminalToHost      strings.Builder  // used for terminal write back
 	tabStops            []int            // replicated by NewFrame(), tab stop positions
 	windowTitleStack    []string         // for XTWINOPS
+	notifications       []Notification   // desktop notifications, taken by TakeNotifications()
+	kittyNotify         Notification     // OSC 99 notification not finished yet
+	clipboards          []Clipboard      // clipboard writes and reads, taken by TakeClipboards()
+	kittyNotifyID       string           // id of kittyNotify
 	charsetState        CharsetState     // for forward compatibility
 	attrs               Cell             // replicated by NewFrame() partially, prototype cell with current attributes
 	savedCursor_DEC_alt SavedCursor_DEC
@@ -41,7 +53,7 @@ type Emulator struct {
 	savedCursor_SCO SavedCursor_SCO    // replicated by NewFrame(), SCO console cursor state
 	bg              Color              // TODO: should we keep this?
 	lastRows        int                // last processed rows
-	bellCount       int                // replicated by NewFrame()
+	bellCount       int                // delivered as bell event, see GetBellCount()
 	posX            int                // replicated by NewFrame(), current cursor cols position (on-screen)
 	posY            int                // replicated by NewFrame(), current cursor rows position (on-screen)
 	nRows           int                // replicated by NewFrame(),
@@ -79,6 +91,8 @@ type Emulator struct {
 	autoWrapMode       bool               // replicated by NewFrame(), default:true
 	lastCol            bool
 	syncOutpuMode      bool
+	syncOutputEnds     int  // number of finished synchronized updates, see GetSyncOutput()
+	rectExtent         bool // DECSACE, DECCARA and DECRARA change rectangle(true) or stream(false)
 }
 
 func NewEmulator3(nCols, nRows, saveLines int) *Emulator {
@@ -86,6 +100,7 @@ func NewEmulator3(nCols, nRows, saveLines int) *Emulator {
 
 	emu := &Emulator{}
 	emu.parser = NewParser()
+	emu.images = newImages()
 	emu.frame_pri, emu.marginTop, emu.marginBottom = NewFramebuffer3(nCols, nRows, saveLines)
 	emu.cf = &emu.frame_pri
 	emu.frame_alt = NewFramebuffer2(1, 1)
@@ -123,6 +138,8 @@ func NewEmulator3(nCols, nRows, saveLines int) *Emulator {
 	emu.savedCursor_DEC = &emu.savedCursor_DEC_pri
 	emu.initSelectionStore()
 	emu.caps = make(map[int]string)
+	emu.width = DefaultWidthPolicy
+	emu.links = newLinks()
 
 	emu.resetTerminal()
 	emu.windowTitleStack = make([]string, 0)
@@ -156,10 +173,16 @@ func (emu *Emulator) resize(nCols, nRows int) {
 
 	emu.hideCursor()
 
+	lastCol := false
 	if emu.altScreenBufferMode {
-		// create a new frame buffer
+		// create a new frame buffer, the primary screen is reflowed when
+		// switching back.
 		emu.frame_alt, emu.marginTop, emu.marginBottom = NewFramebuffer3(nCols, nRows, 0)
 	} else {
+		if emu.nCols != nCols {
+			lastCol = emu.reflow(nCols)
+		}
+
 		// adjust the cursor position if the nRow shrinked
 		if nRows < emu.posY+1 {
 			nScroll := emu.nRows - nRows
@@ -191,12 +214,48 @@ func (emu *Emulator) resize(nCols, nRows int) {
 	}
 
 	emu.normalizeCursorPos()
+	emu.lastCol = lastCol && emu.posX == emu.nColsEff-1
 	emu.showCursor()
-	emu.links = newLinks()
 
 	// TODO pty resize
 }
 
+// re-wrap the soft-wrapped rows of primary screen and history for the new
+// width. the cursor and the saved cursors are moved along with the text.
+// return the last column flag of cursor.
+func (emu *Emulator) reflow(nCols int) (lastCol bool) {
+	var pos []*reflowPos
+	cursor := reflowPos{y: emu.posY, x: emu.posX, lastCol: emu.lastCol}
+	if !emu.altScreenBufferMode {
+		pos = append(pos, &cursor)
+	}
+
+	saved := []*SavedCursor_SCO{&emu.savedCursor_DEC_pri.SavedCursor_SCO}
+	if !emu.altScreenBufferMode {
+		saved = append(saved, &emu.savedCursor_SCO)
+	}
+	savedPos := make([]reflowPos, len(saved))
+	for i, sc := range saved {
+		if sc.isSet {
+			savedPos[i] = reflowPos{y: sc.posY, x: sc.posX, lastCol: sc.lastCol}
+			pos = append(pos, &savedPos[i])
+		}
+	}
+
+	emu.frame_pri.reflow(nCols, pos...)
+
+	for i, sc := range saved {
+		if sc.isSet {
+			sc.posY, sc.posX, sc.lastCol = savedPos[i].y, savedPos[i].x, savedPos[i].lastCol
+		}
+	}
+	if !emu.altScreenBufferMode {
+		emu.posY, emu.posX = cursor.y, cursor.x
+		lastCol = cursor.lastCol
+	}
+	return
+}
+
 // hide the implementation of write back
 func (emu *Emulator) writePty(resp string) {
 	emu.terminalToHost.WriteString(resp)
@@ -255,6 +314,8 @@ func (emu *Emulator) resetTerminal() {
 	emu.resetBell()
 	emu.resetTitle()
 	emu.resetWindowTitleStack()
+	emu.workingDir = ""
+	emu.palette = make(map[int]Color)
 	emu.marginTop, emu.marginBottom = emu.cf.resetMargins()
 	emu.clearScreen()
 
@@ -267,6 +328,8 @@ func (emu *Emulator) resetTerminal() {
 	emu.horizMarginMode = false
 	emu.hMargin = 0
 	emu.nColsEff = emu.nCols
+	emu.rectExtent = false
+	emu.endSyncOutput()
 	// TODO checking hasOSCHandler
 }
 
@@ -278,8 +341,6 @@ func (emu *Emulator) resetAttrs() {
 	// reset the character attributes
 	params := []int{0} // preapare parameters for SGR
 	hdl_csi_sgr(emu, params)
-
-	emu.links = newLinks()
 }
 
 func (emu *Emulator) resetScreen() {
@@ -470,6 +531,9 @@ func (emu *Emulator) switchScreenBufferMode(altScreenBufferMode bool) {
 	} else {
 		// fmt.Printf("-switchScreenBufferMode=%t marginBottom=%d, marginTop=%d, nRows=%d, nCols=%d\n",
 		// 	emu.altScreenBufferMode, emu.marginBottom, emu.marginTop, emu.nRows, emu.nCols)
+		if emu.frame_pri.nCols != emu.nCols {
+			emu.reflow(emu.nCols)
+		}
 		emu.marginTop, emu.marginBottom = emu.frame_pri.resize(emu.nCols, emu.nRows)
 		emu.cf = &emu.frame_pri
 		emu.cf.expose()
@@ -482,7 +546,6 @@ func (emu *Emulator) switchScreenBufferMode(altScreenBufferMode bool) {
 
 	// fmt.Printf(" switchScreenBufferMode=%t marginBottom=%d, marginTop=%d, nRows=%d, nCols=%d\n",
 	// 	emu.altScreenBufferMode, emu.marginBottom, emu.marginTop, emu.nRows, emu.nCols)
-	emu.links = newLinks()
 }
 
 // only set compatibility level for emulator
@@ -552,21 +615,35 @@ func (emu *Emulator) excludeHandler(hd *Handler, before int, after int) bool {
 	switch hd.id {
 	case CSI_DSR, CSI_priDA, CSI_secDA, DCS_DECRQSS, DCS_XTGETTCAP:
 		return true
-	case OSC_4, OSC_10_11_12_17_19, CSI_DECRQM:
+	case CSI_DECRQM:
+		return true
+	case OSC_4, OSC_10_11_12_17_19:
+		// the query is answered by server, the color change is replicated
+		return after > before
+	case OSC_9_99_777: // delivered as notification event, see TakeNotifications()
+		return true
+	case C0_BEL: // delivered as bell event, see GetBellCount()
 		return true
 	case CSI_U_QUERY:
 		return true
+	case APC_IMAGE: // only the client side emulator accepts the image placeholder
+		return !emu.placeholder
 	case CSI_U_PUSH, CSI_U_POP, CSI_U_SET:
 		if !emu.Support(CSI_U_QUERY) {
 			// special case: change local terminal emulator setting
 			return true
 		}
-	case OSC_52: // special case: set OSC 52 data, then query it, the response will be updated.
-		if before != after {
-			return true
-		}
+	case OSC_52: // delivered as clipboard event, see TakeClipboards()
+		return true
 	case VT52_ID:
 		return true
+	case CSI_XTWINOPS:
+		// pixel size report
+		return after > before
+	case CSI_DECRQCRA, CSI_DECSACE:
+		// the checksum is answered by server, the attribute change extent is
+		// not needed by the repaint sequence.
+		return true
 	}
 	return false
 }
@@ -753,6 +830,178 @@ func (emu *Emulator) GetSaveLines() int {
 	return emu.cf.saveLines
 }
 
+// Notification is the desktop notification requested by application through
+// OSC 9, OSC 777 or OSC 99. It's an event, not part of the terminal state.
+type Notification struct {
+	Title string
+	Body  string
+}
+
+// append the notification, the oldest one is dropped if there are too many
+// notifications not taken.
+func (emu *Emulator) notify(n Notification) {
+	if len(n.Title) > notificationMaxLen {
+		n.Title = strings.ToValidUTF8(n.Title[:notificationMaxLen], "")
+	}
+	if len(n.Body) > notificationMaxLen {
+		n.Body = strings.ToValidUTF8(n.Body[:notificationMaxLen], "")
+	}
+	emu.notifications = append(emu.notifications, n)
+	if len(emu.notifications) > notificationMax {
+		emu.notifications = emu.notifications[1:]
+	}
+}
+
+// return and remove the pending notifications.
+func (emu *Emulator) TakeNotifications() []Notification {
+	ns := emu.notifications
+	emu.notifications = nil
+	return ns
+}
+
+// Clipboard is the clipboard operation requested by application through OSC 52.
+// Selection is the Pc parameter, such as "c". Data is the base64 encoded
+// selection data, empty Data clears the selection, "?" reads the clipboard.
+// It's an event, not part of the terminal state.
+type Clipboard struct {
+	Selection string
+	Data      string
+}
+
+// append the clipboard operation, the oldest one is dropped if there are too
+// many operations not taken.
+func (emu *Emulator) copyToClipboard(c Clipboard) {
+	emu.clipboards = append(emu.clipboards, c)
+	if len(emu.clipboards) > notificationMax {
+		emu.clipboards = emu.clipboards[1:]
+	}
+}
+
+// return and remove the pending clipboard operations.
+func (emu *Emulator) TakeClipboards() []Clipboard {
+	cs := emu.clipboards
+	emu.clipboards = nil
+	return cs
+}
+
+// TextMatch is the text matched by SearchRows(), it's in row Row, from column
+// StartCol to EndCol (exclusive).
+type TextMatch struct {
+	Row      int
+	StartCol int
+	EndCol   int
+}
+
+// search the rows of normal screen buffer, including the history rows. row
+// number is the same as GetRowsString(). return at most limit matches, the
+// newest ones are kept.
+func (emu *Emulator) SearchRows(re *regexp.Regexp, limit int) (matches []TextMatch) {
+	fb := &emu.frame_pri
+	for pY := -fb.getHistroryRows(); pY < fb.nRows; pY++ {
+		text, cols := fb.getRowText(pY)
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] { // skip empty match
+				continue
+			}
+			matches = append(matches, TextMatch{Row: pY, StartCol: cols[loc[0]], EndCol: cols[loc[1]]})
+		}
+	}
+
+	if len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+	return matches
+}
+
+// return the number of history rows in normal screen buffer.
+func (emu *Emulator) GetHistoryRows() int {
+	return emu.frame_pri.getHistroryRows()
+}
+
+// return the rows of normal screen buffer in range [start, start+count), each
+// row is rendered as text with SGR sequence. row 0 is the screen top row,
+// negative row is the history row, -1 is the newest one. the range is limited
+// by the available rows, first is the row number of the first returned row.
+func (emu *Emulator) GetRowsString(start, count int) (first int, rows []string) {
+	fb := &emu.frame_pri
+	first = max(start, -fb.getHistroryRows())
+	end := min(start+count, fb.nRows)
+	for pY := first; pY < end; pY++ {
+		rows = append(rows, fb.getRowString(pY))
+	}
+	return first, rows
+}
+
+// return the plain text of rows in range [start, start+count), row number is
+// the same as GetRowsString(). the wrapped rows are joined.
+func (emu *Emulator) GetRowsText(start, count int) string {
+	fb := &emu.frame_pri
+	end := min(start+count, fb.nRows)
+
+	var b strings.Builder
+	for pY := max(start, -fb.getHistroryRows()); pY < end; pY++ {
+		text, _ := fb.getRowText(pY)
+		b.WriteString(text)
+		if row := fb.getRow(fb.getPhysicalRow(pY)); !row[len(row)-1].wrap {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// ShellCommand is the command recorded by OSC 133 marks. the row number is the
+// same as GetRowsString().
+type ShellCommand struct {
+	Prompt int // row of prompt start
+	Output int // row of output start, the row after Prompt if it's not marked
+	End    int // row of command finished, valid if Status >= 0
+	Status int // exit status, -1 means the command is not finished
+}
+
+// return the commands recorded in normal screen buffer, including the history
+// rows. return at most limit commands, the newest ones are kept.
+func (emu *Emulator) GetCommands(limit int) (cmds []ShellCommand) {
+	fb := &emu.frame_pri
+	marked := false // the output of last command is marked
+	output := func(pY int) {
+		if n := len(cmds) - 1; n >= 0 && cmds[n].Status < 0 && !marked {
+			cmds[n].Output = pY
+			marked = true
+		}
+	}
+
+	for pY := -fb.getHistroryRows(); pY < fb.nRows; pY++ {
+		cell := fb.getRow(fb.getPhysicalRow(pY))[0]
+		if cell.mark == 0 {
+			continue
+		}
+
+		// the empty output of previous command is in the same row as the next
+		// prompt, otherwise the output belongs to the prompt of this row.
+		if cell.mark&markCommandEnd != 0 {
+			if cell.mark&markOutputStart != 0 {
+				output(pY)
+			}
+			if n := len(cmds) - 1; n >= 0 && cmds[n].Status < 0 {
+				cmds[n].End = pY
+				cmds[n].Status = int(cell.status)
+			}
+		}
+		if cell.mark&markPromptStart != 0 {
+			cmds = append(cmds, ShellCommand{Prompt: pY, Output: pY + 1, Status: -1})
+			marked = false
+		}
+		if cell.mark&markOutputStart != 0 && cell.mark&markCommandEnd == 0 {
+			output(pY)
+		}
+	}
+
+	if len(cmds) > limit {
+		cmds = cmds[len(cmds)-limit:]
+	}
+	return cmds
+}
+
 func (emu *Emulator) GetCell(posY, posX int) Cell {
 	posY, posX = emu.regulatePos(posY, posX)
 
@@ -825,6 +1074,11 @@ func (emu *Emulator) Clone() *Emulator {
 	copy(clone.frame_pri.cells, emu.frame_pri.cells)
 
 	clone.copyCaps(emu.caps)
+	clone.palette = maps.Clone(emu.palette)
+
+	// notifications and clipboards are events, not the terminal state
+	clone.notifications = nil
+	clone.clipboards = nil
 
 	if emu.cf == &emu.frame_alt {
 		clone.cf = &clone.frame_alt
@@ -863,6 +1117,7 @@ func (emu *Emulator) Clone() *Emulator {
 	copy(clone.windowTitleStack, emu.windowTitleStack)
 
 	clone.links = emu.links.clone()
+	clone.images = emu.images.clone()
 
 	// ignore logI,logT,logU,logW
 	return &clone
@@ -883,6 +1138,21 @@ func (emu *Emulator) resetTitle() {
 	emu.titleInitialized = false
 }
 
+// return the working directory reported by OSC 7 as host and path. path is
+// empty if the shell didn't report it.
+func (emu *Emulator) GetWorkingDir() (host, path string) {
+	return parseWorkingDir(emu.workingDir)
+}
+
+// parse the file URL reported by OSC 7, such as file://host/home/user.
+func parseWorkingDir(s string) (host, path string) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "file" || !strings.HasPrefix(u.Path, "/") {
+		return "", ""
+	}
+	return u.Host, u.Path
+}
+
 func (emu *Emulator) prefixWindowTitle(s string) {
 	if emu.iconLabel == emu.windowTitle {
 		/* preserve equivalence */
@@ -892,9 +1162,47 @@ func (emu *Emulator) prefixWindowTitle(s string) {
 }
 
 func (emu *Emulator) ringBell()         { emu.bellCount += 1 }
-func (emu *Emulator) getBellCount() int { return emu.bellCount }
+func (emu *Emulator) GetBellCount() int { return emu.bellCount }
 func (emu *Emulator) resetBell()        { emu.bellCount = 0 }
 
+// return true if the synchronized output mode (DECSET 2026) is set, and the
+// number of synchronized updates finished so far.
+func (emu *Emulator) GetSyncOutput() (bool, int) {
+	return emu.syncOutpuMode, emu.syncOutputEnds
+}
+
+// return true if the terminal supports synchronized output, the DECRPM reply
+// of mode 2026 is kept as CSI_DECRQM capability.
+func SyncOutputSupported(caps map[int]string) bool {
+	var mode, setting int
+	if n, _ := fmt.Sscanf(caps[CSI_DECRQM], "\x1B[?%d;%d$y", &mode, &setting); n != 2 {
+		return false
+	}
+	// 0: not recognized, 4: permanently reset
+	return mode == 2026 && setting >= 1 && setting <= 3
+}
+
+// finish the synchronized update if it's in progress.
+func (emu *Emulator) endSyncOutput() {
+	if emu.syncOutpuMode {
+		emu.syncOutpuMode = false
+		emu.syncOutputEnds++
+	}
+}
+
+// return true if the screen is in reverse video mode (DECSCNM).
+func (emu *Emulator) IsReverseVideo() bool { return emu.reverseVideo }
+
+// return the kitty keyboard protocol flags requested by application.
+func (emu *Emulator) GetKittyKeyboardFlags() int {
+	return emu.cf.kittyKbd.GetPeek()
+}
+
+// return the mouse tracking mode and encoding requested by application.
+func (emu *Emulator) GetMouseTracking() (MouseTrackingMode, MouseTrackingEnc) {
+	return emu.mouseTrk.mode, emu.mouseTrk.enc
+}
+
 func (emu *Emulator) saveWindowTitleOnStack() {
 	title := emu.GetWindowTitle()
 	if title != "" {
@@ -1065,6 +1373,16 @@ func (emu *Emulator) equal(x *Emulator, trace bool) (ret bool) {
 		}
 	}
 
+	if !maps.Equal(emu.palette, x.palette) {
+		if trace {
+			msg := fmt.Sprintf("palette=(%v,%v)", emu.palette, x.palette)
+			util.Logger.Warn(msg)
+			ret = false
+		} else {
+			return false
+		}
+	}
+
 	if emu.compatLevel != x.compatLevel || emu.cursorKeyMode != x.cursorKeyMode {
 		if trace {
 			msg := fmt.Sprintf("compatLevel=(%d,%d), cursorKeyMode=(%d,%d)",
@@ -1155,12 +1473,12 @@ func (emu *Emulator) equal(x *Emulator, trace bool) (ret bool) {
 	// 	}
 	// }
 
-	if emu.iconLabel != x.iconLabel || emu.windowTitle != x.windowTitle ||
-		emu.bellCount != x.bellCount || emu.titleInitialized != x.titleInitialized {
+	if emu.iconLabel != x.iconLabel || emu.windowTitle != x.windowTitle || emu.workingDir != x.workingDir ||
+		emu.titleInitialized != x.titleInitialized {
 		if trace {
-			msg := fmt.Sprintf("iconLabel=(%s,%s), windowTitle=(%s,%s), bellCount=(%d,%d), titleInitialized=(%t,%t)",
-				emu.iconLabel, x.iconLabel, emu.windowTitle, x.windowTitle,
-				emu.bellCount, x.bellCount, emu.titleInitialized, x.titleInitialized)
+			msg := fmt.Sprintf("iconLabel=(%s,%s), windowTitle=(%s,%s), workingDir=(%s,%s), titleInitialized=(%t,%t)",
+				emu.iconLabel, x.iconLabel, emu.windowTitle, x.windowTitle, emu.workingDir, x.workingDir,
+				emu.titleInitialized, x.titleInitialized)
 			util.Logger.Warn(msg)
 			ret = false
 		} else {
@@ -1237,6 +1555,25 @@ func (emu *Emulator) getRowAt(pY int) (row []Cell) {
 
 func (emu *Emulator) SetTerminalCaps(x map[int]string) {
 	emu.copyCaps(x)
+	emu.width, _ = ParseWidthPolicy(x[CAPS_WIDTH_POLICY], DefaultWidthPolicy)
+}
+
+// accept the image placeholder of state diff. only the client side emulator
+// sets it, the placeholder written by application is ignored by server.
+func (emu *Emulator) SetImagePlaceholder(accept bool) {
+	emu.placeholder = accept
+}
+
+// set the terminfo entry name of the shell TERM, XTGETTCAP is answered from
+// this entry.
+func (emu *Emulator) SetTermName(name string) {
+	emu.termName = name
+}
+
+// return the column width of grapheme according to the width policy of
+// client terminal.
+func (emu *Emulator) GraphemeWidth(chs []rune) int {
+	return emu.width.GraphemeWidth(chs)
 }
 
 func (emu *Emulator) copyCaps(x map[int]string) {
@@ -11,6 +11,8 @@ import (
 	"maps"
 	"os"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 
@@ -62,6 +64,93 @@ func TestEmulatorResize(t *testing.T) {
 	}
 }
 
+func TestEmulatorReflow(t *testing.T) {
+	tc := []struct {
+		label   string
+		seq     string
+		nCols   int
+		history []string
+		screen  []string
+		posY    int
+		posX    int
+		lastCol bool
+	}{
+		{"narrow", "abcdefghij12345", 5, nil, []string{"abcde", "fghij", "12345", ""}, 2, 4, true},
+		{"widen", "abcdefghij12345", 20, nil, []string{"abcdefghij12345", "", "", ""}, 0, 15, false},
+		{"keep blank before cursor", "$ ", 5, nil, []string{"$", "", "", ""}, 0, 2, false},
+		{"wide grapheme", "abcdefgh中文", 9, nil, []string{"abcdefgh", "中文", "", ""}, 1, 4, false},
+		{
+			"widen with history", "1\r\n2\r\n3\r\n4\r\n5\r\nabcdefghijkl", 20,
+			[]string{"1", "2"}, []string{"3", "4", "5", "abcdefghijkl"}, 3, 12, false,
+		},
+		{
+			"narrow with history", "1\r\n2\r\n3\r\n4\r\n5\r\nabcdefghijkl", 5,
+			[]string{"1", "2", "3", "4"}, []string{"5", "abcde", "fghij", "kl"}, 3, 2, false,
+		},
+		{"not wrapped", "abc\r\ndef", 2, nil, []string{"ab", "c", "de", "f"}, 3, 1, false},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(10, 4, 4)
+		emu.HandleStream(v.seq)
+		emu.resize(v.nCols, 4)
+
+		fb := &emu.frame_pri
+		var history, screen []string
+		for pY := -fb.getHistroryRows(); pY < fb.nRows; pY++ {
+			text, _ := fb.getRowText(pY)
+			if pY < 0 {
+				history = append(history, text)
+			} else {
+				screen = append(screen, text)
+			}
+		}
+		if !reflect.DeepEqual(history, v.history) || !reflect.DeepEqual(screen, v.screen) {
+			t.Errorf("%s expect %q %q, got %q %q\n", v.label, v.history, v.screen, history, screen)
+		}
+		if emu.posY != v.posY || emu.posX != v.posX || emu.lastCol != v.lastCol {
+			t.Errorf("%s expect cursor (%d,%d,%t), got (%d,%d,%t)\n", v.label,
+				v.posY, v.posX, v.lastCol, emu.posY, emu.posX, emu.lastCol)
+		}
+	}
+}
+
+func TestEmulatorReflowState(t *testing.T) {
+	// the early wrap cell is recreated
+	emu := NewEmulator3(10, 4, 4)
+	emu.HandleStream("abcdefgh中文")
+	emu.resize(9, 4)
+	if c := emu.cf.getCell(0, 8); !c.IsEarlyWrap() || !c.wrap {
+		t.Errorf("early wrap expect the last cell is early wrap, got %#v\n", c)
+	}
+
+	// the saved cursor is moved along with the text
+	emu = NewEmulator3(10, 4, 4)
+	emu.HandleStream("abcdefghij12\x1B7\x1B[4;1H")
+	emu.resize(5, 4)
+	if sc := emu.savedCursor_DEC_pri; sc.posY != 1 || sc.posX != 2 || emu.posY != 3 || emu.posX != 0 {
+		t.Errorf("saved cursor expect (1,2) cursor (3,0), got (%d,%d) cursor (%d,%d)\n",
+			sc.posY, sc.posX, emu.posY, emu.posX)
+	}
+
+	// the primary screen is reflowed when switching back from alternate screen
+	emu = NewEmulator3(10, 4, 4)
+	emu.HandleStream("abcdefghij12345\x1B[?1049h")
+	emu.resize(5, 4)
+	emu.HandleStream("\x1B[?1049l")
+	if got := emu.GetRowsText(0, 4); got != "abcdefghij12345\n\n" || emu.posY != 2 || emu.posX != 4 {
+		t.Errorf("alternate screen expect reflowed text, got %q cursor (%d,%d)\n", got, emu.posY, emu.posX)
+	}
+
+	// the hyperlinks are kept
+	emu = NewEmulator3(10, 4, 4)
+	emu.HandleStream("\x1B]8;;http://example.com\x1B\\abcdefghijkl\x1B]8;;\x1B\\")
+	emu.resize(5, 4)
+	if c := emu.cf.getCell(2, 1); c.contents != "l" || c.renditions.linkIndex != hashLink("", "http://example.com") || len(emu.links.links) != 1 {
+		t.Errorf("hyperlink expect link on %q, got %d links\n", c.contents, len(emu.links.links))
+	}
+}
+
 func TestEmulatorReadOctetsToHost(t *testing.T) {
 	tc := []struct {
 		name   string
@@ -849,7 +938,7 @@ func TestExcludeHandler(t *testing.T) {
 		{"VT52_ID", "\x1B[?2l\x1BZ", "\x1B[?2l", "\x1b/Z", 2, false},
 		{
 			"OSC 52 query selection", "\x1B]52;c0;5Zub5aeR5aiY5bGxCg==\x1B\\\x1B]52;c0;?\x1B\\",
-			"\x1b]52;c0;5Zub5aeR5aiY5bGxCg==\x1b\\", "\x1b]52;c;5Zub5aeR5aiY5bGxCg==\x1b\\", 2, false,
+			"", "\x1b]52;c;5Zub5aeR5aiY5bGxCg==\x1b\\", 2, false, // set is delivered as clipboard event
 		},
 		{"CSI u query: not support", "\x1b[?u", "", "", 1, false},
 		{"CSI u query: support", "\x1b[?u", "", "\x1b[?0u", 1, true},
@@ -916,3 +1005,93 @@ func TestTerminalCaps(t *testing.T) {
 		t.Errorf("terminal caps test: expect %v, %p, got %v, %p\n", caps, caps, emu.caps, emu.caps)
 	}
 }
+
+func TestGetRowsString(t *testing.T) {
+	emu := NewEmulator3(10, 3, 5)
+	emu.HandleStream("1\r\n2\r\n3\r\n4\r\n\x1B[1m5\x1B[m")
+
+	if got := emu.GetHistoryRows(); got != 2 {
+		t.Errorf("GetHistoryRows expect %d, got %d\n", 2, got)
+	}
+
+	tc := []struct {
+		label  string
+		start  int
+		count  int
+		first  int
+		expect []string
+	}{
+		{"all rows", -5, 10, -2, []string{"1", "2", "3", "4", "\x1B[0;1m5\x1B[0m"}},
+		{"history rows", -2, 2, -2, []string{"1", "2"}},
+		{"newest history row", -1, 1, -1, []string{"2"}},
+		{"screen rows", 0, 2, 0, []string{"3", "4"}},
+		{"beyond screen", 3, 2, 3, nil},
+	}
+
+	for _, v := range tc {
+		first, rows := emu.GetRowsString(v.start, v.count)
+		if first != v.first || !slices.Equal(rows, v.expect) {
+			t.Errorf("%s expect %d %q, got %d %q\n", v.label, v.first, v.expect, first, rows)
+		}
+	}
+}
+
+func TestSearchRows(t *testing.T) {
+	emu := NewEmulator3(10, 3, 5)
+	emu.HandleStream("foo\r\nbar foo\r\n中文foo\r\n\x1B[1mfoo\x1B[m\r\nbaz")
+
+	tc := []struct {
+		label   string
+		pattern string
+		limit   int
+		expect  []TextMatch
+	}{
+		{"plain text", "foo", 10, []TextMatch{{-2, 0, 3}, {-1, 4, 7}, {0, 4, 7}, {1, 0, 3}}},
+		{"wide characters", "文f", 10, []TextMatch{{0, 2, 5}}},
+		{"limit keeps newest", "foo", 2, []TextMatch{{0, 4, 7}, {1, 0, 3}}},
+		{"empty match is skipped", "x*", 10, nil},
+		{"no match", "qux", 10, nil},
+		{"regular expression", "^ba[rz]", 10, []TextMatch{{-1, 0, 3}, {2, 0, 3}}},
+	}
+
+	for _, v := range tc {
+		got := emu.SearchRows(regexp.MustCompile(v.pattern), v.limit)
+		if !slices.Equal(got, v.expect) {
+			t.Errorf("%s expect %v, got %v\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestGetCommands(t *testing.T) {
+	emu := NewEmulator3(10, 3, 5)
+	emu.HandleStream("\x1B]133;A\a$ \x1B]133;B\als\r\n\x1B]133;C\afile1\r\nfile2\r\n" +
+		"\x1B]133;D;0\a\x1B]133;A\a$ \x1B]133;B\atrue\r\n\x1B]133;C\a" +
+		"\x1B]133;D;1\a\x1B]133;A\a$ \x1B]133;B\asleep")
+
+	tc := []struct {
+		label  string
+		limit  int
+		expect []ShellCommand
+	}{
+		{"all commands", 10, []ShellCommand{{-2, -1, 1, 0}, {1, 2, 2, 1}, {2, 3, 0, -1}}},
+		{"limit keeps newest", 2, []ShellCommand{{1, 2, 2, 1}, {2, 3, 0, -1}}},
+	}
+
+	for _, v := range tc {
+		if got := emu.GetCommands(v.limit); !slices.Equal(got, v.expect) {
+			t.Errorf("%s expect %v, got %v\n", v.label, v.expect, got)
+		}
+	}
+
+	// the output of the first command
+	if got := emu.GetRowsText(-1, 2); got != "file1\nfile2\n" {
+		t.Errorf("GetRowsText expect %q, got %q\n", "file1\nfile2\n", got)
+	}
+
+	// the wrapped rows are joined
+	emu = NewEmulator3(5, 3, 0)
+	emu.HandleStream("abcdefg\r\nhi")
+	if got := emu.GetRowsText(-1, 4); got != "abcdefg\nhi\n" {
+		t.Errorf("GetRowsText expect %q, got %q\n", "abcdefg\nhi\n", got)
+	}
+}
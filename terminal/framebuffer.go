@@ -15,6 +15,8 @@ import (
 const (
 	SaveLineUpperLimit  = 50000
 	windowTitleStackMax = 9
+	notificationMax     = 16   // max notifications not taken
+	notificationMaxLen  = 1024 // max bytes of notification title or body
 	SaveLinesRowsOption = 60
 )
 
@@ -129,6 +131,166 @@ func (fb *Framebuffer) resize(nCols, nRows int) (marginTop, marginBottom int) {
 	return
 }
 
+// the position moved along with the cells by reflow()
+type reflowPos struct {
+	y, x    int
+	lastCol bool
+}
+
+// re-wrap the soft-wrapped rows of screen and history for the new width, the
+// rows number is unchanged. the positions in pos are moved along with the
+// cells, the first one is the cursor which is kept on screen. the trailing
+// blank cells of each logical line are dropped, so are the blank rows below
+// the cursor.
+func (fb *Framebuffer) reflow(nCols int, pos ...*reflowPos) {
+	// the last row with contents
+	last := -1
+	for pY := fb.nRows - 1; pY >= 0 && last < 0; pY-- {
+		for _, c := range fb.getRow(fb.getPhysicalRow(pY)) {
+			if !reflowBlank(c) {
+				last = pY
+				break
+			}
+		}
+	}
+	for _, p := range pos {
+		last = max(last, p.y)
+	}
+
+	// join the wrapped rows into logical lines, find the line and offset of
+	// each position.
+	var lines [][]Cell
+	var line []Cell
+	where := make([][2]int, len(pos))
+	for pY := -fb.historyRows; pY <= last; pY++ {
+		row := fb.getRow(fb.getPhysicalRow(pY))
+		for i, p := range pos {
+			if p.y == pY {
+				x := p.x
+				if p.lastCol {
+					x++
+				}
+				where[i] = [2]int{len(lines), len(line) + x}
+			}
+		}
+
+		lastCell := row[len(row)-1]
+		wrapped := lastCell.wrap
+		cells := row
+		if wrapped && lastCell.IsEarlyWrap() && lastCell.IsBlank() {
+			cells = row[:len(row)-1] // skip the early wrap cell
+		}
+
+		start := len(line)
+		line = append(line, cells...)
+		for i := start; i < len(line); i++ {
+			line[i].wrap = false
+			line[i].earlyWrap = false
+		}
+		if start > 0 && line[start].mark != 0 {
+			// shell integration marks belong to the first row of line
+			line[0].mark |= line[start].mark
+			if line[start].mark&markCommandEnd != 0 {
+				line[0].status = line[start].status
+			}
+			line[start].mark, line[start].status = 0, 0
+		}
+
+		if !wrapped {
+			lines = append(lines, line)
+			line = nil
+		}
+	}
+	if line != nil {
+		lines = append(lines, line)
+	}
+
+	// wrap the logical lines with the new width
+	var rows [][]Cell
+	at := make([]reflowPos, len(pos))
+	for i, line := range lines {
+		n := len(line)
+		for n > 0 && reflowBlank(line[n-1]) {
+			n--
+		}
+		for j := range pos {
+			if where[j][0] == i {
+				n = max(n, min(where[j][1], len(line)))
+			}
+		}
+
+		offsets := make([][2]int, n+1) // new row and col of each cell
+		row := make([]Cell, nCols)
+		x := 0
+		for k := 0; k < n; k++ {
+			if x == nCols || line[k].dwidth && x == nCols-1 && x > 0 {
+				if x == nCols-1 {
+					row[x].earlyWrap = true // the double width grapheme moves to next row
+				}
+				row[nCols-1].wrap = true
+				rows = append(rows, row)
+				row = make([]Cell, nCols)
+				x = 0
+			}
+			offsets[k] = [2]int{len(rows), x}
+			row[x] = line[k]
+			x++
+		}
+		offsets[n] = [2]int{len(rows), x}
+		rows = append(rows, row)
+
+		for j := range pos {
+			if where[j][0] == i {
+				o := offsets[min(where[j][1], n)]
+				at[j] = reflowPos{y: o[0], x: o[1]}
+				if o[1] == nCols {
+					at[j] = reflowPos{y: o[0], x: nCols - 1, lastCol: true}
+				}
+			}
+		}
+	}
+
+	// the rows above screen become history, keep the cursor row on screen.
+	top := max(0, len(rows)-fb.nRows)
+	if len(pos) > 0 {
+		top = min(top, at[0].y)
+	}
+	history := min(top, fb.saveLines)
+
+	// the layout is the same as resize(): screen rows at the beginning, history
+	// rows at the end.
+	newCells := make([]Cell, nCols*(fb.nRows+fb.saveLines))
+	for pY := -history; pY < fb.nRows && top+pY < len(rows); pY++ {
+		dst := nCols * pY
+		if pY < 0 {
+			dst = nCols * (fb.nRows + fb.saveLines + pY)
+		}
+		copy(newCells[dst:], rows[top+pY])
+	}
+
+	for i, p := range pos {
+		*p = at[i]
+		p.y = min(max(0, p.y-top), fb.nRows-1)
+	}
+
+	fb.cells = newCells
+	fb.nCols = nCols
+	fb.historyRows = history
+	fb.marginTop = 0
+	fb.scrollHead = fb.marginTop
+	fb.marginBottom = fb.nRows + fb.saveLines
+	fb.margin = false
+	fb.viewOffset = 0
+	fb.damage.totalCells = fb.nCols * (fb.nRows + fb.saveLines)
+	fb.selection.clear()
+	fb.expose()
+}
+
+// blank cell which can be dropped by reflow
+func reflowBlank(c Cell) bool {
+	return c.IsBlank() && c.renditions == (Renditions{}) && c.image == 0 && c.mark == 0
+}
+
 // drop the scrollback history and view offset
 func (fb *Framebuffer) dropScrollbackHistory() {
 	fb.viewOffset = 0
@@ -315,6 +477,64 @@ func (fb *Framebuffer) pageToBottom() {
 	fb.expose()
 }
 
+// return the row as text with SGR sequence, the trailing blank cells with
+// default renditions are discarded. pY is the same as getPhysicalRow().
+func (fb *Framebuffer) getRowString(pY int) string {
+	row := fb.getRow(fb.getPhysicalRow(pY))
+	end := len(row)
+	for end > 0 && row[end-1].IsBlank() && row[end-1].renditions == (Renditions{}) {
+		end--
+	}
+
+	var b strings.Builder
+	rend := Renditions{}
+	for i := range row[:end] {
+		if row[i].dwidthCont {
+			continue
+		}
+		if row[i].renditions != rend {
+			rend = row[i].renditions
+			b.WriteString(rend.SGR())
+		}
+		if row[i].contents == "" {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(row[i].contents)
+		}
+	}
+	if rend != (Renditions{}) {
+		b.WriteString("\x1B[0m")
+	}
+	return b.String()
+}
+
+// return the row as plain text, the trailing blank cells are discarded. cols
+// maps the byte offset of text to the column, cols[len(text)] is the column
+// after the text. pY is the same as getPhysicalRow().
+func (fb *Framebuffer) getRowText(pY int) (text string, cols []int) {
+	row := fb.getRow(fb.getPhysicalRow(pY))
+	end := len(row)
+	for end > 0 && row[end-1].IsBlank() {
+		end--
+	}
+
+	var b strings.Builder
+	for x := range row[:end] {
+		if row[x].dwidthCont {
+			continue
+		}
+		contents := row[x].contents
+		if contents == "" {
+			contents = " "
+		}
+		b.WriteString(contents)
+		for i := 0; i < len(contents); i++ {
+			cols = append(cols, x)
+		}
+	}
+	return b.String(), append(cols, end)
+}
+
 func (fb *Framebuffer) getHistroryRows() int {
 	return fb.historyRows
 }
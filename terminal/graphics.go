@@ -0,0 +1,680 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ericwq/aprilsh/util"
+)
+
+// the image protocols
+const (
+	ImageSixel = 1
+	ImageKitty = 2
+)
+
+const (
+	imageMaxSize    = 4 << 20 // max bytes of sixel or kitty graphics payload
+	imageCacheMax   = 64      // prune the images not placed if the cache exceeds it
+	imageCellWidth  = 10      // cell width in pixels if the local terminal doesn't report it
+	imageCellHeight = 20      // cell height in pixels if the local terminal doesn't report it
+	kittyChunkSize  = 4096    // max bytes of kitty graphics chunk
+)
+
+// the cursor movement after placing image, see placeImage()
+const (
+	imageMoveNone  = 'n' // don't move cursor, kitty graphics with C=1
+	imageMoveBelow = 'b' // move to the row below the image, sixel
+	imageMoveAfter = 'a' // move to the column after the image in its last row, kitty graphics
+)
+
+// the private APC replacing the image sequence in state diff. it places the
+// cached image and moves the cursor like the original sequence does. the
+// image itself is delivered only once by state synchronization.
+const (
+	imagePrefix      = "\x1B_aprilsh;"
+	imagePlaceholder = imagePrefix + "image=%d;%d;%d;%c\x1B\\"
+	imageDeletion    = imagePrefix + "delete=%d\x1B\\"
+)
+
+// Image is the sixel or kitty graphics image stored by emulator. it's
+// immutable after creation.
+type Image struct {
+	Data string // sixel: DCS string without ESC P and ST, kitty: base64 encoded payload
+	Ctrl string // kitty: format keys of the transmission, such as "f=100"
+	ID   uint32 // hash of the image, it's also the image id for the local terminal
+	Kind int    // ImageSixel or ImageKitty
+	Cols int    // number of columns occupied by the image
+	Rows int    // number of rows occupied by the image
+}
+
+func newImage(kind int, ctrl, data string, cols, rows int) *Image {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d;%s;%d;%d;", kind, ctrl, cols, rows)
+	h.Write([]byte(data))
+
+	img := &Image{Kind: kind, Ctrl: ctrl, Data: data, Cols: cols, Rows: rows, ID: h.Sum32()}
+	if img.ID == 0 {
+		img.ID = 1
+	}
+	return img
+}
+
+// return the sequence to draw the image at the cursor position of the local
+// terminal, the cursor is not moved.
+func (img *Image) draw() string {
+	var b strings.Builder
+	switch img.Kind {
+	case ImageSixel:
+		// sixel moves the cursor, save and restore it
+		fmt.Fprintf(&b, "\x1B7\x1BP%s\x1B\\\x1B8", img.Data)
+	case ImageKitty:
+		ctrl := fmt.Sprintf("a=T,i=%d,q=2,C=1,c=%d,r=%d", img.ID, img.Cols, img.Rows)
+		if img.Ctrl != "" {
+			ctrl += "," + img.Ctrl
+		}
+		data := img.Data
+		for {
+			chunk := data[:min(len(data), kittyChunkSize)]
+			data = data[len(chunk):]
+			more := 0
+			if len(data) > 0 {
+				more = 1
+			}
+			fmt.Fprintf(&b, "\x1B_G%s,m=%d;%s\x1B\\", ctrl, more, chunk)
+			if more == 0 {
+				break
+			}
+			ctrl = "q=2"
+		}
+	}
+	return b.String()
+}
+
+// return the sequences making room for the image before drawing it and
+// moving the cursor after it, the same as placeImage() does to emulator.
+func imageMovement(cols, rows int, move byte) (before, after string) {
+	if move == imageMoveNone {
+		return
+	}
+	if rows > 1 {
+		before = strings.Repeat("\x1BD", rows-1) + fmt.Sprintf("\x1B[%dA", rows-1)
+	}
+	switch move {
+	case imageMoveBelow:
+		after = strings.Repeat("\x1BD", rows)
+	case imageMoveAfter:
+		after = strings.Repeat("\x1BD", rows-1) + fmt.Sprintf("\x1B[%dC", cols)
+	}
+	return
+}
+
+type imageSet struct {
+	images map[uint32]*Image
+	order  []uint32          // image ids in adding order, the oldest first
+	kitty  map[int]uint32    // kitty image id assigned by application -> image id
+	chunks []string          // kitty chunked transmission not finished yet
+	keys   map[string]string // control keys of the chunked transmission
+	size   int               // bytes of the chunked transmission
+}
+
+func newImages() *imageSet {
+	v := &imageSet{}
+	v.images = make(map[uint32]*Image)
+	v.kitty = make(map[int]uint32)
+
+	return v
+}
+
+// store the image, return the image id.
+func (x *imageSet) add(img *Image) uint32 {
+	if _, ok := x.images[img.ID]; !ok {
+		x.images[img.ID] = img
+		x.order = append(x.order, img.ID)
+	}
+	return img.ID
+}
+
+func (x *imageSet) remove(id uint32) {
+	if _, ok := x.images[id]; !ok {
+		return
+	}
+	delete(x.images, id)
+	x.order = slices.DeleteFunc(x.order, func(v uint32) bool { return v == id })
+	for k, v := range x.kitty {
+		if v == id {
+			delete(x.kitty, k)
+		}
+	}
+}
+
+func (x *imageSet) get(id uint32) (img *Image, ok bool) {
+	if x == nil {
+		return nil, false
+	}
+	img, ok = x.images[id]
+	return
+}
+
+func (x *imageSet) clone() *imageSet {
+	clone := imageSet{}
+
+	clone.images = make(map[uint32]*Image, len(x.images))
+	for k, v := range x.images {
+		clone.images[k] = v
+	}
+	clone.order = slices.Clone(x.order)
+	clone.kitty = make(map[int]uint32, len(x.kitty))
+	for k, v := range x.kitty {
+		clone.kitty[k] = v
+	}
+	clone.chunks = slices.Clone(x.chunks)
+	if x.keys != nil {
+		clone.keys = make(map[string]string, len(x.keys))
+		for k, v := range x.keys {
+			clone.keys[k] = v
+		}
+	}
+	clone.size = x.size
+
+	return &clone
+}
+
+// return true if the local terminal supports the image protocol.
+func (emu *Emulator) supportImage(kind int) bool {
+	switch kind {
+	case ImageSixel:
+		// the primary DA response, 4 means sixel graphics: CSI ? 6 4 ; 4 ; ... c
+		da, ok := strings.CutPrefix(emu.caps[CSI_priDA], "\x1B[?")
+		if !ok {
+			return false
+		}
+		da, _, _ = strings.Cut(da, "c")
+		return slices.Contains(strings.Split(da, ";")[1:], "4")
+	case ImageKitty:
+		return strings.Contains(emu.caps[APC_KITTY_GRAPHICS], ";OK")
+	}
+	return false
+}
+
+// return the cell size in pixels reported by the local terminal. ok is false
+// if the local terminal doesn't report it, the default size is returned.
+func (emu *Emulator) cellSize() (width, height int, ok bool) {
+	// the response of XTWINOPS 16: CSI 6 ; height ; width t
+	if s, found := strings.CutPrefix(emu.caps[CSI_XTWINOPS], "\x1B[6;"); found {
+		h, w, _ := strings.Cut(strings.TrimSuffix(s, "t"), ";")
+		height, err1 := strconv.Atoi(h)
+		width, err2 := strconv.Atoi(w)
+		if err1 == nil && err2 == nil && width > 0 && height > 0 {
+			return width, height, true
+		}
+	}
+	return imageCellWidth, imageCellHeight, false
+}
+
+// return the number of cells occupied by the image with pixel size.
+func (emu *Emulator) imageCells(width, height int) (cols, rows int) {
+	cw, ch, _ := emu.cellSize()
+	cols = max(1, min((width+cw-1)/cw, emu.nCols))
+	rows = max(1, min((height+ch-1)/ch, emu.nRows))
+	return
+}
+
+// place the image at the cursor position and move the cursor. id is zero if
+// the image is not stored, the cursor is moved anyway. the rows below the
+// cursor are scrolled up if there isn't enough room for the image.
+func (emu *Emulator) placeImage(id uint32, cols, rows int, move byte) {
+	if move != imageMoveNone && rows > 1 {
+		for i := 1; i < rows; i++ {
+			hdl_esc_ind(emu)
+		}
+		hdl_csi_cuu(emu, rows-1)
+	}
+
+	if id != 0 {
+		emu.cf.getCellPtr(emu.posY, emu.posX).image = id
+	}
+
+	switch move {
+	case imageMoveBelow:
+		for i := 0; i < rows; i++ {
+			hdl_esc_ind(emu)
+		}
+	case imageMoveAfter:
+		for i := 1; i < rows; i++ {
+			hdl_esc_ind(emu)
+		}
+		hdl_csi_cuf(emu, cols)
+	}
+}
+
+// remove the image anchors on screen. id zero means all the images.
+func (emu *Emulator) clearImages(id uint32) {
+	for y := 0; y < emu.nRows; y++ {
+		row := emu.cf.getRow(emu.cf.getPhysicalRow(y))
+		for x := range row {
+			if row[x].image != 0 && (id == 0 || row[x].image == id) {
+				row[x].image = 0
+			}
+		}
+	}
+}
+
+// drop the oldest images which is not placed on screen or in scrollback, if
+// the number of images exceeds imageCacheMax.
+func (emu *Emulator) pruneImages() {
+	if len(emu.images.images) <= imageCacheMax {
+		return
+	}
+
+	placed := make(map[uint32]bool)
+	for _, fb := range []*Framebuffer{&emu.frame_pri, &emu.frame_alt} {
+		for i := range fb.cells {
+			if id := fb.cells[i].image; id != 0 {
+				placed[id] = true
+			}
+		}
+	}
+
+	for _, id := range slices.Clone(emu.images.order) {
+		if len(emu.images.images) <= imageCacheMax {
+			break
+		}
+		if !placed[id] {
+			emu.images.remove(id)
+		}
+	}
+}
+
+type imagePlacement struct {
+	id  uint32
+	row int
+	col int
+}
+
+// return the images placed on screen.
+func (emu *Emulator) imagePlacements() (ps []imagePlacement) {
+	for y := 0; y < emu.nRows; y++ {
+		row := emu.cf.getRow(emu.cf.getPhysicalRow(y))
+		for x := range row {
+			if row[x].image != 0 {
+				ps = append(ps, imagePlacement{id: row[x].image, row: y, col: x})
+			}
+		}
+	}
+	return
+}
+
+// DiffImages returns the images stored by emu but not by x, and the ids of
+// the images stored by x but not by emu.
+func (emu *Emulator) DiffImages(x *Emulator) (added []*Image, dropped []uint32) {
+	for _, id := range emu.images.order {
+		if _, ok := x.images.get(id); !ok {
+			added = append(added, emu.images.images[id])
+		}
+	}
+	for _, id := range x.images.order {
+		if _, ok := emu.images.get(id); !ok {
+			dropped = append(dropped, id)
+		}
+	}
+	return
+}
+
+// AddImage stores the image delivered by state synchronization.
+func (emu *Emulator) AddImage(img *Image) {
+	emu.images.add(img)
+}
+
+// DropImage removes the image dropped by state synchronization.
+func (emu *Emulator) DropImage(id uint32) {
+	emu.images.remove(id)
+}
+
+// ReplaceImages replaces the image placeholders in state diff with the
+// sequences drawing the images on local terminal. if the image is not
+// supported by local terminal, only the cursor movement is kept.
+func (emu *Emulator) ReplaceImages(diff string) string {
+	if !strings.Contains(diff, imagePrefix) {
+		return diff
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(diff, imagePrefix)
+		if i < 0 {
+			break
+		}
+		j := strings.Index(diff[i:], "\x1B\\")
+		if j < 0 {
+			break
+		}
+		b.WriteString(diff[:i])
+		b.WriteString(emu.drawImage(diff[i+len(imagePrefix) : i+j]))
+		diff = diff[i+j+2:]
+	}
+	b.WriteString(diff)
+	return b.String()
+}
+
+// return the sequence to draw the image placeholder on local terminal.
+func (emu *Emulator) drawImage(arg string) string {
+	if id, cols, rows, move, ok := parseImagePlaceholder(arg); ok {
+		before, after := imageMovement(cols, rows, move)
+		if img, ok := emu.images.get(id); ok && emu.supportImage(img.Kind) {
+			return before + img.draw() + after
+		}
+		return before + after
+	}
+
+	if v, ok := strings.CutPrefix(arg, "delete="); ok && emu.supportImage(ImageKitty) {
+		id, _ := strconv.ParseUint(v, 10, 32)
+		if id == 0 {
+			return "\x1B_Ga=d,d=a,q=2\x1B\\"
+		}
+		return fmt.Sprintf("\x1B_Ga=d,d=i,i=%d,q=2\x1B\\", id)
+	}
+	return ""
+}
+
+// parse the image placeholder: image=id;cols;rows;move
+func parseImagePlaceholder(arg string) (id uint32, cols, rows int, move byte, ok bool) {
+	v, found := strings.CutPrefix(arg, "image=")
+	if !found {
+		return
+	}
+	var m rune
+	if _, err := fmt.Sscanf(v, "%d;%d;%d;%c", &id, &cols, &rows, &m); err != nil {
+		return 0, 0, 0, 0, false
+	}
+	switch m {
+	case imageMoveNone, imageMoveBelow, imageMoveAfter:
+	default:
+		return 0, 0, 0, 0, false
+	}
+	return id, max(cols, 1), max(rows, 1), byte(m), true
+}
+
+// return true if the DCS string is sixel: P1 ; P2 ; P3 q
+func isSixel(arg string) bool {
+	i := strings.IndexFunc(arg, func(r rune) bool { return r != ';' && (r < '0' || r > '9') })
+	return i >= 0 && arg[i] == 'q'
+}
+
+// return the pixel size of sixel image. use the raster attributes if it's
+// available, otherwise count the sixels.
+func sixelSize(data string) (width, height int) {
+	_, body, ok := strings.Cut(data, "q")
+	if !ok {
+		return
+	}
+
+	// raster attributes: " Pan ; Pad ; Ph ; Pv
+	if strings.HasPrefix(body, "\"") {
+		end := strings.IndexFunc(body[1:], func(r rune) bool { return r != ';' && (r < '0' || r > '9') })
+		if end < 0 {
+			end = len(body) - 1
+		}
+		ps := strings.Split(body[1:1+end], ";")
+		if len(ps) == 4 {
+			width, _ = strconv.Atoi(ps[2])
+			height, _ = strconv.Atoi(ps[3])
+			if width > 0 && height > 0 {
+				return
+			}
+		}
+		width, height = 0, 0
+		body = body[1+end:]
+	}
+
+	x, bands := 0, 1
+	for i := 0; i < len(body); i++ {
+		switch ch := body[i]; {
+		case ch == '!': // repeat introducer: ! Pn sixel
+			j := i + 1
+			for j < len(body) && '0' <= body[j] && body[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(body[i+1 : j])
+			if j < len(body) && '?' <= body[j] && body[j] <= '~' {
+				x += max(n, 1)
+			}
+			i = j
+		case ch == '#': // color introducer: # Pc ; Pu ; Px ; Py ; Pz
+			for i+1 < len(body) && (body[i+1] == ';' || '0' <= body[i+1] && body[i+1] <= '9') {
+				i++
+			}
+		case ch == '$': // graphics carriage return
+			x = 0
+		case ch == '-': // graphics new line
+			x = 0
+			bands++
+		case '?' <= ch && ch <= '~':
+			x++
+		}
+		width = max(width, x)
+	}
+	if strings.HasSuffix(strings.TrimRight(body, "\r\n"), "-") {
+		bands--
+	}
+	height = bands * 6
+	return
+}
+
+// return the pixel size of kitty graphics image. the size of PNG image is
+// read from its header.
+func kittySize(keys map[string]string, payload string) (width, height int) {
+	width, _ = strconv.Atoi(keys["s"])
+	height, _ = strconv.Atoi(keys["v"])
+	if keys["f"] == "100" && keys["o"] == "" && len(payload) >= 32 {
+		// PNG signature, IHDR length and type, followed by width and height
+		header, err := base64.StdEncoding.DecodeString(payload[:32])
+		if err == nil && len(header) == 24 {
+			width = int(binary.BigEndian.Uint32(header[16:20]))
+			height = int(binary.BigEndian.Uint32(header[20:24]))
+		}
+	}
+	return
+}
+
+// parse the control data of kitty graphics: key=value,key=value
+func parseKittyKeys(ctrl string) map[string]string {
+	keys := make(map[string]string)
+	for _, kv := range strings.Split(ctrl, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			keys[k] = v
+		}
+	}
+	return keys
+}
+
+// return the format keys of kitty graphics transmission.
+func kittyFormat(keys map[string]string) string {
+	var ctrl []string
+	for _, k := range []string{"f", "s", "v", "o"} {
+		if v, ok := keys[k]; ok {
+			ctrl = append(ctrl, k+"="+v)
+		}
+	}
+	return strings.Join(ctrl, ",")
+}
+
+// reply the result of kitty graphics command. q=1 suppresses OK response, q=2
+// suppresses all responses.
+func kittyReply(emu *Emulator, keys map[string]string, id int, msg string) {
+	if id == 0 || keys["q"] == "2" || (keys["q"] == "1" && msg == "OK") {
+		return
+	}
+	emu.writePty(fmt.Sprintf("\x1B_Gi=%d;%s\x1B\\", id, msg))
+}
+
+// DCS P1 ; P2 ; P3 q data ST
+//
+// Sixel graphics. the image is stored and placed at the cursor position if
+// the local terminal supports sixel, the cursor is moved to the row below the
+// image. return the sequence for state diff.
+func hdl_dcs_sixel(emu *Emulator, data string) string {
+	cols, rows := emu.imageCells(sixelSize(data))
+
+	var id uint32
+	if emu.supportImage(ImageSixel) {
+		id = emu.images.add(newImage(ImageSixel, "", data, cols, rows))
+	}
+	emu.placeImage(id, cols, rows, imageMoveBelow)
+	emu.pruneImages()
+
+	return fmt.Sprintf(imagePlaceholder, id, cols, rows, imageMoveBelow)
+}
+
+// APC G control-data ; payload ST
+//
+// Kitty graphics protocol, only direct transmission (t=d) is supported. the
+// supported actions are: transmit (t), transmit and display (T), put (p),
+// delete (d) and query (q). the image is stored if the local terminal
+// supports kitty graphics. return the sequence for state diff.
+//
+// https://sw.kovidgoyal.net/kitty/graphics-protocol/
+func hdl_apc_kitty(emu *Emulator, arg string) string {
+	ctrl, payload, _ := strings.Cut(arg, ";")
+	keys := parseKittyKeys(ctrl)
+
+	// chunked transmission: m=1 means more chunks follow
+	x := emu.images
+	if x.keys != nil {
+		x.chunks = append(x.chunks, payload)
+		x.size += len(payload)
+		if x.size > imageMaxSize {
+			util.Logger.Warn("kitty graphics: image is too large", "size", x.size)
+			x.keys, x.chunks, x.size = nil, nil, 0
+			return ""
+		}
+		if keys["m"] == "1" {
+			return ""
+		}
+		keys, payload = x.keys, strings.Join(x.chunks, "")
+		x.keys, x.chunks, x.size = nil, nil, 0
+	} else if keys["m"] == "1" {
+		x.keys, x.chunks, x.size = keys, []string{payload}, len(payload)
+		return ""
+	}
+
+	id, _ := strconv.Atoi(keys["i"])
+	var img *Image
+	switch keys["a"] {
+	case "q":
+		if emu.supportImage(ImageKitty) {
+			kittyReply(emu, keys, id, "OK")
+		}
+		return ""
+	case "", "t", "T":
+		if t := keys["t"]; t != "" && t != "d" {
+			kittyReply(emu, keys, id, "EINVAL:only direct transmission is supported")
+			return ""
+		}
+		cols, _ := strconv.Atoi(keys["c"])
+		rows, _ := strconv.Atoi(keys["r"])
+		c, r := emu.imageCells(kittySize(keys, payload))
+		if cols <= 0 {
+			cols = c
+		}
+		if rows <= 0 {
+			rows = r
+		}
+		img = newImage(ImageKitty, kittyFormat(keys), payload, min(cols, emu.nCols), min(rows, emu.nRows))
+
+		if emu.supportImage(ImageKitty) {
+			emu.images.add(img)
+			if id != 0 {
+				emu.images.kitty[id] = img.ID
+			}
+			kittyReply(emu, keys, id, "OK")
+		} else {
+			img.ID = 0
+		}
+		if keys["a"] != "T" {
+			emu.pruneImages()
+			return ""
+		}
+	case "p":
+		var ok bool
+		if img, ok = emu.images.get(emu.images.kitty[id]); !ok {
+			kittyReply(emu, keys, id, "ENOENT:image not found")
+			return ""
+		}
+		kittyReply(emu, keys, id, "OK")
+	case "d":
+		return kittyDelete(emu, keys, id)
+	default:
+		util.Logger.Warn("kitty graphics", "unimplement", "action", "a", keys["a"])
+		return ""
+	}
+
+	move := byte(imageMoveAfter)
+	if keys["C"] == "1" {
+		move = imageMoveNone
+	}
+	emu.placeImage(img.ID, img.Cols, img.Rows, move)
+	emu.pruneImages()
+
+	return fmt.Sprintf(imagePlaceholder, img.ID, img.Cols, img.Rows, move)
+}
+
+// delete the placements of kitty graphics images. d=a/A deletes all the
+// placements on screen, d=i/I deletes the placements of the image, the upper
+// case I also frees the image. return the sequence for state diff.
+func kittyDelete(emu *Emulator, keys map[string]string, id int) string {
+	switch d := keys["d"]; d {
+	case "", "a", "A":
+		emu.clearImages(0)
+		return fmt.Sprintf(imageDeletion, 0)
+	case "i", "I":
+		imgID, ok := emu.images.kitty[id]
+		if !ok {
+			return ""
+		}
+		emu.clearImages(imgID)
+		if d == "I" {
+			emu.images.remove(imgID)
+		}
+		return fmt.Sprintf(imageDeletion, imgID)
+	default:
+		util.Logger.Warn("kitty graphics", "unimplement", "delete", "d", d)
+	}
+	return ""
+}
+
+// APC aprilsh ; image=id;cols;rows;move ST
+// APC aprilsh ; delete=id ST
+//
+// The image placeholder in state diff, see imagePlaceholder. it's ignored
+// unless the emulator is on client side, see SetImagePlaceholder().
+func hdl_apc_image(emu *Emulator, arg string) {
+	if !emu.placeholder {
+		util.Logger.Warn("APC aprilsh", "ignore", "image", "arg", arg)
+		return
+	}
+
+	if id, cols, rows, move, ok := parseImagePlaceholder(arg); ok {
+		if _, found := emu.images.get(id); !found {
+			id = 0
+		}
+		emu.placeImage(id, min(cols, emu.nCols), min(rows, emu.nRows), move)
+		return
+	}
+
+	if v, ok := strings.CutPrefix(arg, "delete="); ok {
+		id, _ := strconv.ParseUint(v, 10, 32)
+		emu.clearImages(uint32(id))
+		return
+	}
+	util.Logger.Warn("APC aprilsh", "unimplement", "image", "arg", arg)
+}
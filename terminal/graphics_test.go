@@ -0,0 +1,335 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var imageCaps = map[int]string{
+	CSI_priDA:          "\x1B[?62;4;22c",
+	APC_KITTY_GRAPHICS: "\x1B_Gi=31;OK\x1B\\",
+}
+
+func TestSixelSize(t *testing.T) {
+	tc := []struct {
+		label  string
+		data   string
+		width  int
+		height int
+	}{
+		{"raster attributes", "0;1;0q\"1;1;20;40#0!20~", 20, 40},
+		{"count sixels", "q#0;2;0;0;0#0!5~??-~~~$~~~~-", 7, 12},
+		{"zero raster attributes", "q\"1;1;0;0~~-~", 2, 12},
+		{"repeat without count", "q!~~", 2, 6},
+		{"no introducer", "0;1;0", 0, 0},
+	}
+
+	for _, v := range tc {
+		w, h := sixelSize(v.data)
+		if w != v.width || h != v.height {
+			t.Errorf("%s expect %dx%d, got %dx%d\n", v.label, v.width, v.height, w, h)
+		}
+	}
+}
+
+func TestHandleSixel(t *testing.T) {
+	data := "0;1;0q\"1;1;20;40#0!20~-!20~"
+	img := newImage(ImageSixel, "", data, 2, 2)
+
+	tc := []struct {
+		label  string
+		caps   map[int]string
+		seq    string
+		diff   string
+		posY   int
+		posX   int
+		expect []imagePlacement
+	}{
+		{
+			"sixel", imageCaps, "\x1BP" + data + "\x1B\\",
+			fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveBelow), 2, 0,
+			[]imagePlacement{{img.ID, 0, 0}},
+		},
+		{
+			"not supported", nil, "\x1BP" + data + "\x1B\\",
+			fmt.Sprintf(imagePlaceholder, 0, 2, 2, imageMoveBelow), 2, 0, nil,
+		},
+		{
+			"scroll at bottom", imageCaps, "\x1B[40;5H\x1BP" + data + "\x1B\\",
+			"\x1B[40;5H" + fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveBelow), 39, 4,
+			[]imagePlacement{{img.ID, 37, 4}},
+		},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 40)
+		emu.SetTerminalCaps(v.caps)
+
+		_, diff := emu.HandleStream(v.seq)
+		if diff != v.diff {
+			t.Errorf("%s expect diff %q, got %q\n", v.label, v.diff, diff)
+		}
+		if emu.posY != v.posY || emu.posX != v.posX {
+			t.Errorf("%s expect cursor (%d,%d), got (%d,%d)\n", v.label, v.posY, v.posX, emu.posY, emu.posX)
+		}
+		if got := emu.imagePlacements(); fmt.Sprint(got) != fmt.Sprint(v.expect) {
+			t.Errorf("%s expect placements %v, got %v\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestHandleKittyGraphics(t *testing.T) {
+	img := newImage(ImageKitty, "f=24,s=20,v=40", "AAAA", 2, 2)
+	chunked := newImage(ImageKitty, "f=100", "AAAABBBBCCCC", 3, 1)
+	small := newImage(ImageKitty, "f=24,s=10,v=20", "AAAA", 1, 1)
+	display := "\x1B_Ga=T,f=24,s=20,v=40,i=7;AAAA\x1B\\"
+
+	tc := []struct {
+		label  string
+		caps   map[int]string
+		seq    string
+		resp   string
+		diff   string
+		posY   int
+		posX   int
+		images int
+	}{
+		{"query", imageCaps, "\x1B_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1B\\", "\x1B_Gi=31;OK\x1B\\", "", 0, 0, 0},
+		{"query not supported", nil, "\x1B_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1B\\", "", "", 0, 0, 0},
+		{
+			"transmit and display", imageCaps, display, "\x1B_Gi=7;OK\x1B\\",
+			fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveAfter), 1, 2, 1,
+		},
+		{
+			"not supported", nil, display, "",
+			fmt.Sprintf(imagePlaceholder, 0, 2, 2, imageMoveAfter), 1, 2, 0,
+		},
+		{
+			"no cursor movement", imageCaps, "\x1B_Ga=T,f=24,s=20,v=40,i=7,C=1,q=2;AAAA\x1B\\", "",
+			fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveNone), 0, 0, 1,
+		},
+		{
+			"chunked", imageCaps, "\x1B_Ga=T,f=100,c=3,r=1,m=1;AAAA\x1B\\\x1B_Gm=1;BBBB\x1B\\\x1B_Gm=0;CCCC\x1B\\", "",
+			fmt.Sprintf(imagePlaceholder, chunked.ID, 3, 1, imageMoveAfter), 0, 3, 1,
+		},
+		{
+			"transmit and put", imageCaps, "\x1B_Ga=t,f=24,s=10,v=20,i=5;AAAA\x1B\\\x1B_Ga=p,i=5\x1B\\",
+			"\x1B_Gi=5;OK\x1B\\\x1B_Gi=5;OK\x1B\\",
+			fmt.Sprintf(imagePlaceholder, small.ID, 1, 1, imageMoveAfter), 0, 1, 1,
+		},
+		{"put unknown image", imageCaps, "\x1B_Ga=p,i=9\x1B\\", "\x1B_Gi=9;ENOENT:image not found\x1B\\", "", 0, 0, 0},
+		{
+			"file transmission", imageCaps, "\x1B_Ga=T,t=f,i=3;L3RtcC9h\x1B\\",
+			"\x1B_Gi=3;EINVAL:only direct transmission is supported\x1B\\", "", 0, 0, 0,
+		},
+		{
+			"delete", imageCaps, display + "\x1B_Ga=d,d=i,i=7\x1B\\", "\x1B_Gi=7;OK\x1B\\",
+			fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveAfter) + fmt.Sprintf(imageDeletion, img.ID), 1, 2, 0,
+		},
+		{
+			"delete all", imageCaps, display + "\x1B_Ga=d\x1B\\", "\x1B_Gi=7;OK\x1B\\",
+			fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveAfter) + fmt.Sprintf(imageDeletion, 0), 1, 2, 0,
+		},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 40)
+		emu.SetTerminalCaps(v.caps)
+
+		_, diff := emu.HandleStream(v.seq)
+		if diff != v.diff {
+			t.Errorf("%s expect diff %q, got %q\n", v.label, v.diff, diff)
+		}
+		if got := emu.ReadOctetsToHost(); got != v.resp {
+			t.Errorf("%s expect response %q, got %q\n", v.label, v.resp, got)
+		}
+		if emu.posY != v.posY || emu.posX != v.posX {
+			t.Errorf("%s expect cursor (%d,%d), got (%d,%d)\n", v.label, v.posY, v.posX, emu.posY, emu.posX)
+		}
+		if got := len(emu.imagePlacements()); got != v.images {
+			t.Errorf("%s expect %d placements, got %d\n", v.label, v.images, got)
+		}
+	}
+}
+
+func TestParseImageSequence(t *testing.T) {
+	sixel := "\x1BPq\"1;1;10;20" + strings.Repeat("~", 8000) + "\x1B\\"
+	kitty := "\x1B_Ga=T,f=100;" + strings.Repeat("A", 8000) + "\x1B\\"
+
+	tc := []struct {
+		label string
+		seq   string
+		id    int
+	}{
+		{"large sixel", sixel, DCS_SIXEL},
+		{"large kitty graphics", kitty, APC_KITTY_GRAPHICS},
+		{"image placeholder", fmt.Sprintf(imagePlaceholder, 1, 1, 1, imageMoveNone), APC_IMAGE},
+	}
+
+	for _, v := range tc {
+		p := NewParser()
+		hds := p.ProcessStream(v.seq)
+		if len(hds) != 1 || hds[0].GetId() != v.id || hds[0].sequence != v.seq {
+			t.Errorf("%s expect %s, got %d handlers\n", v.label, strHandlerID[v.id], len(hds))
+		}
+	}
+}
+
+func TestHandleImagePlaceholder(t *testing.T) {
+	display := "\x1B_Ga=T,f=24,s=20,v=40,i=7;AAAA\x1B\\"
+	img := newImage(ImageKitty, "f=24,s=20,v=40", "AAAA", 2, 2)
+
+	tc := []struct {
+		label  string
+		accept bool
+		seq    string
+		diff   string
+		expect int
+	}{
+		{"application delete", false, "\x1B_aprilsh;delete=0\x1B\\", "", 1},
+		{"application placeholder", false, fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveNone), "", 1},
+		{"client delete", true, "\x1B_aprilsh;delete=0\x1B\\", "\x1B_aprilsh;delete=0\x1B\\", 0},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 40)
+		emu.SetTerminalCaps(imageCaps)
+		emu.HandleStream(display)
+		emu.SetImagePlaceholder(v.accept)
+
+		_, diff := emu.HandleStream(v.seq)
+		if diff != v.diff {
+			t.Errorf("%s expect diff %q, got %q\n", v.label, v.diff, diff)
+		}
+		if got := emu.imagePlacements(); len(got) != v.expect {
+			t.Errorf("%s expect %d placements, got %v\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestPruneImages(t *testing.T) {
+	emu := NewEmulator3(80, 40, 40)
+	emu.SetTerminalCaps(imageCaps)
+
+	// the placed image is kept
+	emu.HandleStream("\x1B_Ga=T,f=24,s=10,v=20,i=1,C=1;AAAA\x1B\\")
+	for i := 2; i <= imageCacheMax+2; i++ {
+		emu.HandleStream(fmt.Sprintf("\x1B_Ga=t,f=24,s=10,v=20,i=%d;%04d\x1B\\", i, i))
+	}
+
+	if len(emu.images.images) != imageCacheMax {
+		t.Errorf("prune expect %d images, got %d\n", imageCacheMax, len(emu.images.images))
+	}
+	if _, ok := emu.images.kitty[1]; !ok {
+		t.Errorf("prune expect the placed image is kept\n")
+	}
+	if _, ok := emu.images.kitty[2]; ok {
+		t.Errorf("prune expect the oldest image is dropped\n")
+	}
+}
+
+func TestImageDraw(t *testing.T) {
+	large := strings.Repeat("A", kittyChunkSize+4)
+
+	tc := []struct {
+		label  string
+		img    *Image
+		expect string
+	}{
+		{"sixel", &Image{ID: 1, Kind: ImageSixel, Data: "q~", Cols: 1, Rows: 1}, "\x1B7\x1BPq~\x1B\\\x1B8"},
+		{
+			"kitty", &Image{ID: 2, Kind: ImageKitty, Data: "AAAA", Ctrl: "f=100", Cols: 2, Rows: 3},
+			"\x1B_Ga=T,i=2,q=2,C=1,c=2,r=3,f=100,m=0;AAAA\x1B\\",
+		},
+		{
+			"kitty chunks", &Image{ID: 3, Kind: ImageKitty, Data: large, Cols: 1, Rows: 1},
+			"\x1B_Ga=T,i=3,q=2,C=1,c=1,r=1,m=1;" + large[:kittyChunkSize] + "\x1B\\\x1B_Gq=2,m=0;AAAA\x1B\\",
+		},
+	}
+
+	for _, v := range tc {
+		if got := v.img.draw(); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestReplaceImages(t *testing.T) {
+	kitty := &Image{ID: 2, Kind: ImageKitty, Data: "AAAA", Cols: 2, Rows: 3}
+	sixel := &Image{ID: 3, Kind: ImageSixel, Data: "q~", Cols: 1, Rows: 1}
+
+	tc := []struct {
+		label  string
+		caps   map[int]string
+		diff   string
+		expect string
+	}{
+		{"no image", imageCaps, "abc", "abc"},
+		{
+			"kitty", imageCaps, "a" + fmt.Sprintf(imagePlaceholder, 2, 2, 3, imageMoveAfter) + "b",
+			"a\x1BD\x1BD\x1B[2A" + kitty.draw() + "\x1BD\x1BD\x1B[2C" + "b",
+		},
+		{"sixel", imageCaps, fmt.Sprintf(imagePlaceholder, 3, 1, 1, imageMoveBelow), sixel.draw() + "\x1BD"},
+		{"not supported", nil, fmt.Sprintf(imagePlaceholder, 2, 2, 1, imageMoveAfter), "\x1B[2C"},
+		{"unknown image", imageCaps, fmt.Sprintf(imagePlaceholder, 9, 1, 1, imageMoveNone), ""},
+		{"delete", imageCaps, fmt.Sprintf(imageDeletion, 2), "\x1B_Ga=d,d=i,i=2,q=2\x1B\\"},
+		{"delete all", imageCaps, fmt.Sprintf(imageDeletion, 0), "\x1B_Ga=d,d=a,q=2\x1B\\"},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 40)
+		emu.SetTerminalCaps(v.caps)
+		emu.AddImage(kitty)
+		emu.AddImage(sixel)
+
+		if got := emu.ReplaceImages(v.diff); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestNewFrame_Images(t *testing.T) {
+	data := "0;1;0q\"1;1;20;40#0!20~-!20~"
+	img := newImage(ImageSixel, "", data, 2, 2)
+
+	oldE := NewEmulator3(80, 40, 40)
+	newE := NewEmulator3(80, 40, 40)
+	newE.SetTerminalCaps(imageCaps)
+	newE.HandleStream("\x1B[3;5H\x1BP" + data + "\x1B\\")
+
+	tc := []struct {
+		label  string
+		local  bool
+		oldE   *Emulator
+		expect string
+	}{
+		{"placeholder", false, oldE, fmt.Sprintf(imagePlaceholder, img.ID, 2, 2, imageMoveNone)},
+		{"local terminal", true, oldE, img.draw()},
+		{"unchanged", true, newE.Clone(), ""},
+	}
+
+	for _, v := range tc {
+		d, _ := NewDisplay(false)
+		d.localImage = v.local
+
+		got := d.NewFrame(true, v.oldE, newE)
+		if v.expect == "" && strings.Contains(got, "\x1BP") || !strings.Contains(got, v.expect) {
+			t.Errorf("%s expect %q in %q\n", v.label, v.expect, got)
+		}
+	}
+
+	// the client applies the placeholder
+	client := NewEmulator3(80, 40, 40)
+	client.SetImagePlaceholder(true)
+	client.AddImage(img)
+	d, _ := NewDisplay(false)
+	client.HandleStream(d.NewFrame(false, client, newE))
+	if got := client.imagePlacements(); len(got) != 1 || got[0] != (imagePlacement{img.ID, 2, 4}) {
+		t.Errorf("placeholder expect placement at (2,4), got %v\n", got)
+	}
+}
@@ -15,7 +15,6 @@ import (
 
 	"github.com/ericwq/aprilsh/terminfo"
 	"github.com/ericwq/aprilsh/util"
-	"github.com/rivo/uniseg"
 )
 
 const (
@@ -36,13 +35,15 @@ const (
 /*
  * 64 - VT420 family
  *  1 - 132 columns
+ *  4 - sixel graphics, only if the local terminal supports it
  *  9 - National Replacement Character-sets
  * 15 - DEC technical set
  * 21 - horizontal scrolling
  * 22 - color
  */
 const (
-	DEVICE_ID = "64;1;9;15;21;22c"
+	DEVICE_ID       = "64;1;9;15;21;22c"
+	DEVICE_ID_SIXEL = "64;1;4;9;15;21;22c"
 )
 
 const (
@@ -137,8 +138,23 @@ const (
 	OSC_10_11_12_17_19
 	OSC_112
 	OSC_8
+	OSC_133
+	OSC_7
+	OSC_9_99_777
 	VT52_EGM
 	VT52_ID
+	APC_IMAGE
+	APC_KITTY_GRAPHICS
+	DCS_SIXEL
+	OSC_104_110_111
+	CSI_DECCRA
+	CSI_DECFRA
+	CSI_DECERA
+	CSI_DECSERA
+	CSI_DECCARA
+	CSI_DECRARA
+	CSI_DECSACE
+	CSI_DECRQCRA
 )
 
 var strHandlerID = [...]string{
@@ -233,8 +249,23 @@ var strHandlerID = [...]string{
 	"osc_10_11_12_17_19",
 	"osc_112",
 	"osc_8",
+	"osc_133",
+	"osc_7",
+	"osc_9_99_777",
 	"vt52_egm",
 	"vt52_id",
+	"apc_image",
+	"apc_kitty_graphics",
+	"dcs_sixel",
+	"osc_104_110_111",
+	"csi_deccra",
+	"csi_decfra",
+	"csi_decera",
+	"csi_decsera",
+	"csi_deccara",
+	"csi_decrara",
+	"csi_decsace",
+	"csi_decrqcra",
 }
 
 // Handler is the outcome of parsering input, it can be used to perform control sequence on emulator.
@@ -302,7 +333,7 @@ func RunesWidth(runes []rune) (width int) {
 // https://pkg.go.dev/golang.org/x/text/encoding/charmap
 // https://github.com/rivo/uniseg
 func hdl_graphemes(emu *Emulator, chs ...rune) {
-	w := uniseg.StringWidth(string(chs))
+	w := emu.GraphemeWidth(chs)
 	if len(chs) == 1 && emu.charsetState.vtMode {
 		chs[0] = emu.lookupCharset(chs[0])
 	}
@@ -332,8 +363,11 @@ func hdl_graphemes(emu *Emulator, chs ...rune) {
 	}
 
 	// print grapheme in current cursor position with default renditions.
+	// keep the shell integration marks, the prompt is printed after OSC 133;A.
 	c := emu.cf.getCellPtr(emu.posY, emu.posX)
+	mark, status := c.mark, c.status
 	*c = emu.attrs
+	c.mark, c.status = mark, status
 	c.SetContents(chs)
 	// util.Logger.Trace("hdl_graphemes", "col", emu.posX, "row", emu.posY, "ch", c)
 
@@ -845,7 +879,11 @@ func hdl_csi_ech(emu *Emulator, arg int) {
 // DA response
 func hdl_csi_priDA(emu *Emulator) {
 	// mosh only reply "\x1B[?62c" plain vt220
-	resp := fmt.Sprintf("\x1B[?%s", DEVICE_ID)
+	id := DEVICE_ID
+	if emu.supportImage(ImageSixel) {
+		id = DEVICE_ID_SIXEL
+	}
+	resp := fmt.Sprintf("\x1B[?%s", id)
 	emu.writePty(resp)
 }
 
@@ -1228,26 +1266,48 @@ func hdl_osc_10x(emu *Emulator, cmd int, arg string) {
 			color := args[idx]
 			action := args[idx+1]
 
-			// we only support query for the time being.
-			if action == "?" {
-				colorIdx, err := strconv.Atoi(color)
-				if err != nil {
-					// emu.logW.Printf("OSC 10x: can't parse color index. %q\n", arg)
-					util.Logger.Warn("OSC 10x: can't parse color index", "arg", arg)
-					return
-				}
+			colorIdx, err := strconv.Atoi(color)
+			if err != nil {
+				// emu.logW.Printf("OSC 10x: can't parse color index. %q\n", arg)
+				util.Logger.Warn("OSC 10x: can't parse color index", "arg", arg)
+				return
+			}
 
+			if action == "?" {
 				color := ColorDefault
 				switch colorIdx {
-				case 11, 17: // 11: VT100 text background color  17: highlight background color
+				case 11: // 11: VT100 text background color
+					color = emu.paletteColor(paletteBg, emu.attrs.renditions.bgColor)
+				case 17: // 17: highlight background color
 					color = emu.attrs.renditions.bgColor
-				case 10, 19: // 10: VT100 text foreground color; 19: highlight foreground color
+				case 10: // 10: VT100 text foreground color
+					color = emu.paletteColor(paletteFg, emu.attrs.renditions.fgColor)
+				case 19: // 19: highlight foreground color
 					color = emu.attrs.renditions.fgColor
 				case 12: // 12: text cursor color
 					color = emu.cf.cursor.color
+					if color == ColorDefault {
+						color = emu.paletteColor(paletteCursor, color)
+					}
 				}
 				response := fmt.Sprintf("\x1B]%d;%s\x1B\\", colorIdx, color) // the String() method of Color will be called.
 				emu.writePty(response)
+				continue
+			}
+
+			// the highlight colors are not supported.
+			c := ParseColorSpec(action)
+			if !c.Valid() {
+				util.Logger.Warn("OSC 10x: invalid color spec", "arg", arg)
+				continue
+			}
+			switch colorIdx {
+			case 10:
+				emu.palette[paletteFg] = c
+			case 11:
+				emu.palette[paletteBg] = c
+			case 12:
+				emu.cf.cursor.color = c
 			}
 		}
 	} else {
@@ -1326,6 +1386,12 @@ func hdl_osc_52(emu *Emulator, cmd int, arg string) {
 			uses the first selection found by asking successively for each
 			item from the list of selection parameters.
 		*/
+		if emu.Support(OSC_52) {
+			// the client permits reading its clipboard, let the local
+			// terminal reply.
+			emu.copyToClipboard(Clipboard{Selection: Pc, Data: Pd})
+			return
+		}
 		for _, ch := range Pc {
 			if data, ok := emu.selectionStore[ch]; ok && data != "" {
 				// resp to the host
@@ -1354,8 +1420,9 @@ func hdl_osc_52(emu *Emulator, cmd int, arg string) {
 				}
 			}
 			if set {
-				// store the selection data, later it will be sent to terminal.
+				// store the selection data, it will be sent to client as clipboard event.
 				emu.selectionData = fmt.Sprintf("\x1B]%d;%s;%s\x1B\\", cmd, Pc, Pd)
+				emu.copyToClipboard(Clipboard{Selection: Pc, Data: Pd})
 			}
 		} else {
 			/*
@@ -1370,8 +1437,9 @@ func hdl_osc_52(emu *Emulator, cmd int, arg string) {
 				}
 			}
 			if set {
-				// store the selection data, later it will be sent to terminal.
+				// store the selection data, it will be sent to client as clipboard event.
 				emu.selectionData = fmt.Sprintf("\x1B]%d;%s;%s\x1B\\", cmd, Pc, Pd)
+				emu.copyToClipboard(Clipboard{Selection: Pc, Data: Pd})
 			}
 		}
 	}
@@ -1424,18 +1492,22 @@ func hdl_osc_4(emu *Emulator, cmd int, arg string) {
 			c := args[idx]
 			spec := args[idx+1]
 
-			// we only support query for the time being.
+			colorIdx, err := strconv.Atoi(c)
+			if err != nil || colorIdx < 0 || colorIdx > 255 {
+				// emu.logW.Printf("OSC 4: can't parse c parameter. %q\n", arg)
+				util.Logger.Warn("OSC 4: can't parse c parameter", "arg", arg)
+				return
+			}
+
 			if spec == "?" {
-				colorIdx, err := strconv.Atoi(c)
-				if err != nil {
-					// emu.logW.Printf("OSC 4: can't parse c parameter. %q\n", arg)
-					util.Logger.Warn("OSC 4: can't parse c parameter", "arg", arg)
-					return
-				}
-				color := PaletteColor(colorIdx)
+				color := emu.paletteColor(colorIdx, PaletteColor(colorIdx))
 				response := fmt.Sprintf("\x1B]%d;%d;%s\x1B\\", cmd, colorIdx, color)
 				emu.writePty(response)
-			} // TODO set the screen colors palette values to any RGB value.
+			} else if color := ParseColorSpec(spec); color.Valid() {
+				emu.palette[colorIdx] = color
+			} else {
+				util.Logger.Warn("OSC 4: invalid color spec", "arg", arg)
+			}
 		}
 	} else {
 		// emu.logW.Printf("OSC 4: malformed argument, missing ';'. %q\n", arg)
@@ -1444,6 +1516,44 @@ func hdl_osc_4(emu *Emulator, cmd int, arg string) {
 	}
 }
 
+// OSC 104 ; c ST
+//
+//	Reset Color Number c. The parameter c may be repeated, separated by ';'.
+//	if no parameter is given, the entire palette is reset.
+//
+// OSC 110 ST
+//
+//	Reset VT100 text foreground color.
+//
+// OSC 111 ST
+//
+//	Reset VT100 text background color.
+func hdl_osc_104_110_111(emu *Emulator, cmd int, arg string) {
+	switch cmd {
+	case 104:
+		if arg == "" {
+			for k := range emu.palette {
+				if k < paletteFg {
+					delete(emu.palette, k)
+				}
+			}
+			return
+		}
+		for _, c := range strings.Split(arg, ";") {
+			colorIdx, err := strconv.Atoi(c)
+			if err != nil {
+				util.Logger.Warn("OSC 104: can't parse c parameter", "arg", arg)
+				return
+			}
+			delete(emu.palette, colorIdx)
+		}
+	case 110:
+		delete(emu.palette, paletteFg)
+	case 111:
+		delete(emu.palette, paletteBg)
+	}
+}
+
 // OSC of the form "\x1B]X;<title>\007" where X can be:
 // * 0: set icon name and window title
 // * 1: set icon name
@@ -1476,6 +1586,107 @@ func hdl_osc_112(emu *Emulator, _ int, _ string) {
 	emu.cf.cursor.color = ColorDefault
 }
 
+// OSC 7 ; URL ST
+//
+// The shell reports its current working directory as a file URL, such as
+// file://hostname/home/user. The host part may be empty.
+//
+// printf '\e]7;file://%s%s\e\\' "$HOSTNAME" "$PWD"
+func hdl_osc_7(emu *Emulator, _ int, arg string) {
+	if arg == "" {
+		emu.workingDir = ""
+		return
+	}
+	if _, path := parseWorkingDir(arg); path == "" {
+		util.Logger.Warn("OSC 7: invalid working directory", "arg", arg)
+		return
+	}
+	emu.workingDir = arg
+}
+
+// OSC 9 ; message ST
+//
+//	Post a notification (iTerm2, ConEmu). OSC 9 ; 4 ; ... is the progress
+//	report of ConEmu, it's ignored.
+//
+// OSC 777 ; notify ; title ; body ST
+//
+//	Post a notification (rxvt-unicode, foot, VTE).
+//
+// OSC 99 ; metadata ; payload ST
+//
+//	Post a notification (kitty). The metadata is a list of key=value pairs
+//	separated by ':'. i is the id, d=0 means more chunks follow, p is the
+//	payload type: title (default) or body, e=1 means the payload is base64
+//	encoded. The other keys are ignored.
+func hdl_osc_notify(emu *Emulator, cmd int, arg string) {
+	switch cmd {
+	case 9:
+		if strings.HasPrefix(arg, "4;") {
+			return
+		}
+		emu.notify(Notification{Body: arg})
+	case 777:
+		params := strings.SplitN(arg, ";", 3)
+		if len(params) < 2 || params[0] != "notify" {
+			util.Logger.Warn("OSC 777: unsupported parameters", "arg", arg)
+			return
+		}
+		n := Notification{Title: params[1]}
+		if len(params) == 3 {
+			n.Body = params[2]
+		}
+		emu.notify(n)
+	case 99:
+		metadata, payload, ok := strings.Cut(arg, ";")
+		if !ok {
+			util.Logger.Warn("OSC 99: invalid parameters", "arg", arg)
+			return
+		}
+		id, done, body, encoded := "", true, false, false
+		for _, kv := range strings.Split(metadata, ":") {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "i":
+				id = v
+			case "d":
+				done = v != "0"
+			case "p":
+				body = v == "body"
+				if v != "title" && v != "body" {
+					return // such as icon, nothing to show
+				}
+			case "e":
+				encoded = v == "1"
+			}
+		}
+		if encoded {
+			data, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				util.Logger.Warn("OSC 99: invalid payload", "arg", arg, "error", err)
+				return
+			}
+			payload = string(data)
+		}
+
+		// a new notification starts
+		if id != emu.kittyNotifyID {
+			emu.kittyNotify = Notification{}
+			emu.kittyNotifyID = id
+		}
+		if body {
+			emu.kittyNotify.Body += payload
+		} else {
+			emu.kittyNotify.Title += payload
+		}
+		if done {
+			emu.notify(emu.kittyNotify)
+			emu.kittyNotify = Notification{}
+			emu.kittyNotifyID = ""
+		}
+	}
+}
+
 // A hyperlink is opened upon encountering an OSC 8 escape sequence with the target URI.
 // The syntax is
 //
@@ -1518,12 +1729,46 @@ func hdl_osc_8(emu *Emulator, _ int, arg string) {
 		}
 
 		rend.linkIndex = emu.links.addLink(id, params[1])
+		emu.pruneLinks()
 		// util.Logger.Trace("OSC 8", "linkIndex", rend.linkIndex, "id", id, "url", params[1])
 	} else {
 		rend.linkIndex = 0
 	}
 }
 
+// OSC 133 ; Pt ST  FinalTerm shell integration marks.
+//
+//	Pt = A  ⇒  Prompt start.
+//	Pt = B  ⇒  Prompt end, command input start.
+//	Pt = C  ⇒  Command output start.
+//	Pt = D ; Ps  ⇒  Command finished with exit status Ps.
+//
+// The mark is stored in the first cell of the cursor row, printing keeps the
+// mark while erasing the cell clears it. The options like aid=xyz are ignored.
+func hdl_osc_133(emu *Emulator, _ int, arg string) {
+	params := strings.Split(arg, ";")
+	cell := emu.cf.getCellPtr(emu.posY, 0)
+
+	switch params[0] {
+	case "A":
+		cell.mark |= markPromptStart
+	case "B":
+		cell.mark |= markCommandStart
+	case "C":
+		cell.mark |= markOutputStart
+	case "D":
+		cell.mark |= markCommandEnd
+		cell.status = 0
+		if len(params) > 1 {
+			if status, err := strconv.Atoi(params[1]); err == nil {
+				cell.status = uint8(status)
+			}
+		}
+	default:
+		util.Logger.Warn("OSC 133: unknown mark", "arg", arg)
+	}
+}
+
 // CSI Pm h  Set Mode (SM).
 // *  Ps = 2  ⇒  Keyboard Action Mode (KAM).
 // *  Ps = 4  ⇒  Insert Mode (IRM).
@@ -1651,6 +1896,8 @@ func hdl_csi_privSM(emu *Emulator, params []int) {
 		case 1015:
 			// emu.framebuffer.DS.mouseTrk.enc = MouseEncURXVT
 			emu.mouseTrk.enc = MouseTrackingEnc_URXVT
+		case 1016:
+			emu.mouseTrk.enc = MouseTrackingEnc_SGR_Pixels
 		case 1036, 1039:
 			// emu.framebuffer.DS.altSendsEscape = true
 			emu.altSendsEscape = true
@@ -1825,7 +2072,7 @@ func hdl_csi_privRM(emu *Emulator, params []int) {
 			// emu.framebuffer.DS.MouseFocusEvent = false
 			// emu.framebuffer.DS.mouseTrk.focusEventMode = false
 			emu.mouseTrk.focusEventMode = false
-		case 1005, 1006, 1015:
+		case 1005, 1006, 1015, 1016:
 			// emu.framebuffer.DS.mouseTrk.enc = MouseEncNone
 			emu.mouseTrk.enc = MouseTrackingEnc_Default
 		case 1007:
@@ -1847,7 +2094,7 @@ func hdl_csi_privRM(emu *Emulator, params []int) {
 			// emu.framebuffer.DS.BracketedPaste = false
 			emu.bracketedPasteMode = false
 		case 2026:
-			emu.syncOutpuMode = false
+			emu.endSyncOutput()
 		default:
 			// emu.logU.Printf("reset priv mode %d\n", param)
 			util.Logger.Warn("reset priv mode", "unimplement", "DECRST", "params", param)
@@ -2117,6 +2364,418 @@ func hdl_csi_decdc(emu *Emulator, num int) {
 	}
 }
 
+// return the rectangular area specified by params[n:n+4]: Pt ; Pl ; Pb ; Pr.
+// the default area is the whole screen. in origin mode the coordinates are
+// relative to the margins and the area is clipped by the margins. the bottom
+// right corner of the returned area is exclusive.
+func (emu *Emulator) rectArea(params []int, n int) (area Rect, ok bool) {
+	bound := emu.rectBound()
+	area.tl.y = bound.tl.y + rectParam(params, n, 1) - 1
+	area.tl.x = bound.tl.x + rectParam(params, n+1, 1) - 1
+	area.br.y = min(bound.tl.y+rectParam(params, n+2, bound.br.y-bound.tl.y), bound.br.y)
+	area.br.x = min(bound.tl.x+rectParam(params, n+3, bound.br.x-bound.tl.x), bound.br.x)
+	area.rectangular = true
+
+	return area, area.tl.y < area.br.y && area.tl.x < area.br.x
+}
+
+// return the area which the rectangular area operation can reach.
+func (emu *Emulator) rectBound() (bound Rect) {
+	bound.br = Point{x: emu.nCols, y: emu.nRows}
+	if emu.originMode == OriginMode_ScrollingRegion {
+		bound.tl = Point{x: emu.hMargin, y: emu.marginTop}
+		bound.br = Point{x: emu.nColsEff, y: emu.marginBottom}
+	}
+	return bound
+}
+
+// return params[n] or the default value if it's missing or zero.
+func rectParam(params []int, n int, defaultVal int) int {
+	if n < len(params) && params[n] > 0 {
+		return params[n]
+	}
+	return defaultVal
+}
+
+// call fn for each cell in area. if stream is true, the cells from the top
+// left corner to the bottom right corner of area are treated as a stream of
+// characters, the rows between them are changed in full width.
+func (emu *Emulator) eachRectCell(area Rect, stream bool, fn func(c *Cell)) {
+	bound := emu.rectBound()
+	for y := area.tl.y; y < area.br.y; y++ {
+		left, right := area.tl.x, area.br.x
+		if stream && area.br.y-area.tl.y > 1 {
+			if y > area.tl.y {
+				left = bound.tl.x
+			}
+			if y < area.br.y-1 {
+				right = bound.br.x
+			}
+		}
+		for x := left; x < right; x++ {
+			fn(emu.cf.getCellPtr(y, x))
+		}
+	}
+}
+
+// the rectangular area operation may break the wide grapheme at the left and
+// right edge of area, replace the broken half with blank.
+func (emu *Emulator) fixRectEdges(area Rect) {
+	for y := area.tl.y; y < area.br.y; y++ {
+		for _, x := range []int{area.tl.x - 1, area.tl.x, area.br.x - 1, area.br.x} {
+			if x < 0 || x >= emu.nCols {
+				continue
+			}
+			c := emu.cf.getCell(y, x)
+			if (c.dwidth && (x+1 >= emu.nCols || !emu.cf.getCell(y, x+1).dwidthCont)) ||
+				(c.dwidthCont && (x == 0 || !emu.cf.getCell(y, x-1).dwidth)) {
+				p := emu.cf.getCellPtr(y, x)
+				p.contents = " "
+				p.dwidth = false
+				p.dwidthCont = false
+			}
+		}
+	}
+}
+
+// generate the sequence to repaint area on the local terminal, which may not
+// support the rectangular area operations. the modes which affect printing are
+// turned off during repaint, then the modes, cursor and renditions are restored.
+func (emu *Emulator) repaintRect(area Rect) string {
+	fs := &FrameState{out: &strings.Builder{}, currentRendition: emu.attrs.renditions, links: emu.links}
+	origin := emu.originMode == OriginMode_ScrollingRegion
+
+	if emu.insertMode {
+		fs.append("\x1B[4l")
+	}
+	if origin {
+		fs.append("\x1B[?6l")
+	}
+	if emu.horizMarginMode {
+		fs.append("\x1B[?69l")
+	}
+
+	// the edges are repainted for the broken wide grapheme
+	left := max(area.tl.x-1, 0)
+	right := min(area.br.x+1, emu.nCols)
+	for y := area.tl.y; y < area.br.y; y++ {
+		x := left
+		if emu.cf.getCell(y, x).dwidthCont {
+			x++ // the wide grapheme is outside of area
+		}
+		fs.append("\x1B[%d;%dH", y+1, x+1)
+		for ; x < right; x++ {
+			c := emu.cf.getCell(y, x)
+			if c.dwidthCont {
+				continue
+			}
+			fs.updateRendition(c.renditions, false)
+			fs.appendCell(c)
+		}
+	}
+
+	if emu.horizMarginMode {
+		fs.append("\x1B[?69h")
+		if emu.hMargin != 0 || emu.nColsEff != emu.nCols {
+			fs.append("\x1B[%d;%ds", emu.hMargin+1, emu.nColsEff)
+		}
+	}
+	if origin {
+		fs.append("\x1B[?6h")
+	}
+
+	// restore the cursor, the pending wrap is restored by printing the last
+	// grapheme again.
+	x, row := emu.posX, emu.posY+1
+	if origin {
+		row -= emu.marginTop
+	}
+	if emu.lastCol && x > 0 && emu.cf.getCell(emu.posY, x).dwidthCont {
+		x--
+	}
+	fs.append("\x1B[%d;%dH", row, x+1)
+	if emu.lastCol {
+		c := emu.cf.getCell(emu.posY, x)
+		fs.updateRendition(c.renditions, false)
+		fs.appendCell(c)
+	}
+	fs.updateRendition(emu.attrs.renditions, false)
+
+	if emu.insertMode {
+		fs.append("\x1B[4h")
+	}
+	return fs.output()
+}
+
+// CSI Pts ; Pls ; Pbs ; Prs ; Pps ; Pdt ; Pdl ; Pdp $ v
+//
+//	Copy Rectangular Area (DECCRA), VT400 and up. the source area is copied to
+//	the destination, which is clipped by the screen. only one page is
+//	supported, the page parameters are ignored. return the repaint sequence.
+func hdl_csi_deccra(emu *Emulator, params []int) string {
+	src, ok := emu.rectArea(params, 0)
+	if !ok {
+		return ""
+	}
+
+	bound := emu.rectBound()
+	var dst Rect
+	dst.tl.y = bound.tl.y + rectParam(params, 5, 1) - 1
+	dst.tl.x = bound.tl.x + rectParam(params, 6, 1) - 1
+	dst.br.y = min(dst.tl.y+src.br.y-src.tl.y, bound.br.y)
+	dst.br.x = min(dst.tl.x+src.br.x-src.tl.x, bound.br.x)
+	dst.rectangular = true
+	if dst.tl.y >= dst.br.y || dst.tl.x >= dst.br.x {
+		return ""
+	}
+
+	// the source and destination may overlap
+	width := dst.br.x - dst.tl.x
+	cells := make([]Cell, 0, width*(dst.br.y-dst.tl.y))
+	for y := src.tl.y; y < src.tl.y+dst.br.y-dst.tl.y; y++ {
+		for x := src.tl.x; x < src.tl.x+width; x++ {
+			cells = append(cells, emu.cf.getCell(y, x))
+		}
+	}
+
+	i := 0
+	for y := dst.tl.y; y < dst.br.y; y++ {
+		for x := dst.tl.x; x < dst.br.x; x++ {
+			// the row attributes and image anchors are not copied
+			c := emu.cf.getCellPtr(y, x)
+			wrap, mark, status := c.wrap, c.mark, c.status
+			*c = cells[i]
+			c.wrap, c.mark, c.status, c.image = wrap, mark, status, 0
+			i++
+		}
+	}
+
+	emu.fixRectEdges(dst)
+	return emu.repaintRect(dst)
+}
+
+// CSI Pch ; Pt ; Pl ; Pb ; Pr $ x
+//
+//	Fill Rectangular Area (DECFRA), VT420 and up. fill the area with character
+//	Pch and the current renditions. return the repaint sequence.
+func hdl_csi_decfra(emu *Emulator, params []int) string {
+	ch := rectParam(params, 0, 0)
+	if (ch < 32 || ch > 126) && (ch < 160 || ch > 255) {
+		return ""
+	}
+	area, ok := emu.rectArea(params, 1)
+	if !ok {
+		return ""
+	}
+
+	r := rune(ch)
+	if emu.charsetState.vtMode {
+		g := emu.charsetState.gl
+		if r >= 0x80 {
+			g = emu.charsetState.gr
+		}
+		r = lookupTable(emu.charsetState.g[g], byte(r))
+	}
+
+	emu.eachRectCell(area, false, func(c *Cell) {
+		c.Reset2(emu.attrs)
+		c.contents = string(r)
+	})
+	emu.fixRectEdges(area)
+	return emu.repaintRect(area)
+}
+
+// CSI Pt ; Pl ; Pb ; Pr $ z
+//
+//	Erase Rectangular Area (DECERA), VT400 and up. return the repaint sequence.
+func hdl_csi_decera(emu *Emulator, params []int) string {
+	area, ok := emu.rectArea(params, 0)
+	if !ok {
+		return ""
+	}
+
+	emu.eachRectCell(area, false, func(c *Cell) {
+		c.Reset2(emu.attrs)
+	})
+	emu.fixRectEdges(area)
+	return emu.repaintRect(area)
+}
+
+// CSI Pt ; Pl ; Pb ; Pr $ {
+//
+//	Selective Erase Rectangular Area (DECSERA), VT400 and up. the characters
+//	are erased, the renditions are kept. the character protection attribute
+//	(DECSCA) is not supported, all characters are erasable. return the repaint
+//	sequence.
+func hdl_csi_decsera(emu *Emulator, params []int) string {
+	area, ok := emu.rectArea(params, 0)
+	if !ok {
+		return ""
+	}
+
+	emu.eachRectCell(area, false, func(c *Cell) {
+		c.contents = " "
+		c.dwidth = false
+		c.dwidthCont = false
+	})
+	emu.fixRectEdges(area)
+	return emu.repaintRect(area)
+}
+
+// CSI Pt ; Pl ; Pb ; Pr ; Ps $ r
+//
+//	Change Attributes in Rectangular Area (DECCARA), VT400 and up.
+//	  Ps = 0  ⇒  attributes off (no bold, no underline, no blink, positive image).
+//	  Ps = 1  ⇒  bold.
+//	  Ps = 4  ⇒  underlined.
+//	  Ps = 5  ⇒  blinking.
+//	  Ps = 7  ⇒  negative image.
+//	  Ps = 8  ⇒  invisible.
+//	  Ps = 2 2  ⇒  no bold.
+//	  Ps = 2 4  ⇒  no underline.
+//	  Ps = 2 5  ⇒  no blink.
+//	  Ps = 2 7  ⇒  positive image.
+//	  Ps = 2 8  ⇒  visible.
+//
+// the area is a stream of characters unless DECSACE selects rectangle. return
+// the repaint sequence.
+func hdl_csi_deccara(emu *Emulator, params []int) string {
+	area, ok := emu.rectArea(params, 0)
+	if !ok {
+		return ""
+	}
+
+	attrs := []int{0}
+	if len(params) > 4 {
+		attrs = params[4:]
+	}
+	emu.eachRectCell(area, !emu.rectExtent, func(c *Cell) {
+		for _, attr := range attrs {
+			switch attr {
+			case 0:
+				c.renditions.bold = false
+				c.renditions.underline = false
+				c.renditions.ulStyle = ULS_NONE
+				c.renditions.blink = false
+				c.renditions.rapidBlink = false
+				c.renditions.inverse = false
+				c.renditions.invisible = false
+			case 1, 4, 5, 7, 8, 22, 24, 25, 27, 28:
+				c.renditions.buildRendition(attr)
+			}
+		}
+	})
+	return emu.repaintRect(emu.rectExtentArea(area))
+}
+
+// CSI Pt ; Pl ; Pb ; Pr ; Ps $ t
+//
+//	Reverse Attributes in Rectangular Area (DECRARA), VT400 and up.
+//	  Ps = 0  ⇒  reverse all attributes.
+//	  Ps = 1  ⇒  reverse bold.
+//	  Ps = 4  ⇒  reverse underline.
+//	  Ps = 5  ⇒  reverse blink.
+//	  Ps = 7  ⇒  reverse negative image.
+//	  Ps = 8  ⇒  reverse invisible.
+//
+// the area is a stream of characters unless DECSACE selects rectangle. return
+// the repaint sequence.
+func hdl_csi_decrara(emu *Emulator, params []int) string {
+	area, ok := emu.rectArea(params, 0)
+	if !ok {
+		return ""
+	}
+
+	reversible := map[int]charAttribute{1: Bold, 4: Underlined, 5: Blink, 7: Inverse, 8: Invisible}
+	params = append(params, make([]int, max(5-len(params), 0))...)
+	var attrs []charAttribute
+	for _, ps := range params[4:] {
+		if ps == 0 {
+			attrs = append(attrs, Bold, Underlined, Blink, Inverse, Invisible)
+		} else if attr, ok := reversible[ps]; ok {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	emu.eachRectCell(area, !emu.rectExtent, func(c *Cell) {
+		for _, attr := range attrs {
+			value, _ := c.renditions.GetAttributes(attr)
+			c.renditions.SetAttributes(attr, !value)
+			if attr == Underlined {
+				c.renditions.ulStyle = ULS_NONE
+				if !value {
+					c.renditions.ulStyle = ULS_SINGLE
+				}
+			}
+		}
+	})
+	return emu.repaintRect(emu.rectExtentArea(area))
+}
+
+// return the area changed by DECCARA and DECRARA, the full width rows are
+// changed in stream extent.
+func (emu *Emulator) rectExtentArea(area Rect) Rect {
+	if !emu.rectExtent && area.br.y-area.tl.y > 1 {
+		bound := emu.rectBound()
+		area.tl.x, area.br.x = bound.tl.x, bound.br.x
+	}
+	return area
+}
+
+// CSI Ps * x
+//
+//	Select Attribute Change Extent (DECSACE), VT420 and up.
+//	  Ps = 0  ⇒  from start to end position, wrapped.
+//	  Ps = 1  ⇒  from start to end position, wrapped.
+//	  Ps = 2  ⇒  rectangle (exact).
+func hdl_csi_decsace(emu *Emulator, arg int) {
+	switch arg {
+	case 0, 1:
+		emu.rectExtent = false
+	case 2:
+		emu.rectExtent = true
+	}
+}
+
+// CSI Pi ; Pg ; Pt ; Pl ; Pb ; Pr * y
+//
+//	Request Checksum of Rectangular Area (DECRQCRA), VT420 and up. the response
+//	is DCS Pi ! ~ x x x x ST, the checksum is calculated as xterm does.
+func hdl_csi_decrqcra(emu *Emulator, params []int) {
+	id := 0
+	if len(params) > 0 {
+		id = params[0]
+	}
+
+	sum := 0
+	if area, ok := emu.rectArea(params, 2); ok {
+		emu.eachRectCell(area, false, func(c *Cell) {
+			if c.dwidthCont {
+				return
+			}
+			if c.Empty() {
+				sum += ' '
+			}
+			for _, r := range c.contents {
+				sum += int(r)
+			}
+			if c.renditions.underline {
+				sum += 0x10
+			}
+			if c.renditions.inverse {
+				sum += 0x20
+			}
+			if c.renditions.blink {
+				sum += 0x40
+			}
+			if c.renditions.bold {
+				sum += 0x80
+			}
+		})
+	}
+	emu.writePty(fmt.Sprintf("\x1BP%d!~%04X\x1B\\", id, -sum&0xffff))
+}
+
 // CSI Ps SP @
 //
 //	Shift left Ps columns(s) (default = 1) (SL), ECMA-48.
@@ -2237,6 +2896,14 @@ func hdl_csi_xtmodkeys(emu *Emulator, params []int) {
 // height and width in characters.  Omitted parameters reuse the
 // current height or width.  Zero parameters use the display's
 // height or width.
+//
+// Ps = 1 4  ⇒  Report xterm text area size in pixels.
+// Result is CSI  4 ;  height ;  width t
+//
+// Ps = 1 6  ⇒  Report xterm character cell size in pixels.
+// Result is CSI  6 ;  height ;  width t
+//
+// The pixel size is reported only if the local terminal reports it.
 func hdl_csi_xtwinops(emu *Emulator, params []int, sequence string) {
 	if len(params) == 0 {
 		util.Logger.Warn("unhandled operation", "seq", sequence,
@@ -2277,6 +2944,17 @@ func hdl_csi_xtwinops(emu *Emulator, params []int, sequence string) {
 			}
 		}
 		emu.resize(w, h)
+	case 14, 16:
+		w, h, ok := emu.cellSize()
+		if !ok {
+			util.Logger.Warn("unknown cell size", "seq", sequence, "id", strHandlerID[CSI_XTWINOPS])
+			return
+		}
+		if params[0] == 14 {
+			emu.writePty(fmt.Sprintf("\x1B[4;%d;%dt", h*emu.nRows, w*emu.nCols))
+		} else {
+			emu.writePty(fmt.Sprintf("\x1B[6;%d;%dt", h, w))
+		}
 	default:
 		util.Logger.Warn("unhandled operation", "seq", sequence,
 			"params", params, "id", strHandlerID[CSI_XTWINOPS])
@@ -2327,7 +3005,9 @@ func hdl_csi_mousetrack(emu *Emulator, press bool, params []int) {
 			hdl_csi_cup(emu, params[2], params[1])
 		}
 	default:
-		util.Logger.Warn("unhandled operation", "encoding", emu.mouseTrk.enc,
+		// the mouse report from user is handled by Mouse action, a mouse
+		// report in the host output is ignored.
+		util.Logger.Debug("ignore mouse report", "encoding", emu.mouseTrk.enc,
 			"press", press, "params", params, "id", strHandlerID[CSI_MOUSETRACK])
 	}
 }
@@ -2385,7 +3065,18 @@ func xtgettcapReply(emu *Emulator, hexCapName string) {
 		return
 	}
 
-	value, ok := terminfo.Lookup(string(name))
+	// the capabilities are answered by the terminfo entry of shell TERM, the
+	// aprilsh entries are built in, fallback to TERM of aprilsh itself.
+	var value string
+	var ok bool
+	switch emu.termName {
+	case TermAprilsh, TermAprilshDirect:
+		value, ok = lookupAprilshCap(emu.termName, string(name))
+	case "":
+		value, ok = terminfo.Lookup(string(name))
+	default:
+		value, ok = terminfo.LookupTerm(emu.termName, string(name))
+	}
 	// util.Logger.Warn("XTGETTCAP", "hexCapName", hexCapName, "valid", ok, "name", string(name), "value", value)
 
 	if !ok {
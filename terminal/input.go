@@ -48,7 +48,9 @@ func (u *UserInput) parse(x UserByte, cursorKeyMode CursorKeyMode) (ret string)
 	// the next byte will be A, B, C, or D (cursor control keys).
 
 	if len(x.Chs) > 1 {
-		return ""
+		// grapheme cluster, such as emoji with modifier
+		u.state = USER_INPUT_GROUND
+		return string(x.Chs)
 	}
 	r := x.Chs[0]
 
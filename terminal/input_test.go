@@ -18,7 +18,7 @@ func TestUserByteHandle(t *testing.T) {
 		input  string
 		expect string
 	}{
-		{"over size   ", "", ""},
+		{"grapheme    ", "👍🏽e\u0301", "👍🏽e\u0301"},
 		{"english text", "hello", "hello"},
 		{"chinese text", "斗罗大陆", "斗罗大陆"},
 		{"ESC sequence", "\x88", "�"},
@@ -38,24 +38,18 @@ func TestUserByteHandle(t *testing.T) {
 				emu.cursorKeyMode = CursorKeyMode_ANSI
 			}
 
-			if strings.HasPrefix(v.label, "over size") {
-				empty := UserByte{[]rune(v.label)}
-				// fmt.Printf("%v has length %d\n", empty, len(empty.Chs))
-				empty.Handle(emu)
-			} else {
-				// prepare UserByte slice
-				graphemes := uniseg.NewGraphemes(v.input)
-				ub := make([]UserByte, 0)
+			// prepare UserByte slice
+			graphemes := uniseg.NewGraphemes(v.input)
+			ub := make([]UserByte, 0)
 
-				for graphemes.Next() {
-					chs := graphemes.Runes()
-					ub = append(ub, UserByte{chs})
-				}
+			for graphemes.Next() {
+				chs := graphemes.Runes()
+				ub = append(ub, UserByte{chs})
+			}
 
-				// process each UserByte
-				for i := range ub {
-					ub[i].Handle(emu)
-				}
+			// process each UserByte
+			for i := range ub {
+				ub[i].Handle(emu)
 			}
 			// validate the result
 			got := emu.ReadOctetsToHost()
@@ -0,0 +1,328 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the modifier bits of kitty keyboard protocol, the encoded value is 1 + bits.
+const (
+	KeyShift    = 1
+	KeyAlt      = 2
+	KeyCtrl     = 4
+	KeySuper    = 8
+	KeyHyper    = 16
+	KeyMeta     = 32
+	KeyCapsLock = 64
+	KeyNumLock  = 128
+	keyLocks    = KeyCapsLock | KeyNumLock
+)
+
+// the event types of kitty keyboard protocol.
+const (
+	KeyPress   = 1
+	KeyRepeat  = 2
+	KeyRelease = 3
+)
+
+// the key codes with legacy encoding, see kitty "Legacy key event encoding".
+const (
+	keyTab       = 9
+	keyEnter     = 13
+	keyEscape    = 27
+	keyBackspace = 127
+	keyPUAStart  = 57344 // the functional keys without legacy encoding
+	keyPUAEnd    = 63743
+)
+
+// Key is the key event reported by the local terminal in kitty keyboard
+// protocol. it's parsed by aprilsh client and encoded by server according to
+// the progressive enhancement flags requested by the application.
+type Key struct {
+	Text    string // associated text
+	Code    int    // unicode key code, or the number of functional key ended by '~'
+	Shifted int    // shifted key code, 0 if absent
+	Base    int    // base layout key code, 0 if absent
+	Mods    int    // modifier bits
+	Event   int    // press, repeat or release
+	Final   byte   // 'u', '~' or the final byte of functional key, such as 'A'
+}
+
+func (k Key) Handle(emu *Emulator) {
+	emu.writePty(k.encode(emu.cf.kittyKbd.GetPeek(), emu.cursorKeyMode))
+}
+
+// Legacy returns the key event in legacy encoding, as if the application
+// doesn't request kitty keyboard protocol.
+func (k Key) Legacy() string {
+	return k.encode(0, CursorKeyMode_ANSI)
+}
+
+// encode the key event with the progressive enhancement flags.
+func (k Key) encode(flags int, ckm CursorKeyMode) string {
+	if k.Event == KeyRelease && flags&KITTY_KBD_REPORT_EVENT == 0 {
+		return ""
+	}
+	if flags&KITTY_KBD_REPORT_ALL == 0 {
+		if s, ok := k.legacy(flags, ckm); ok {
+			return s
+		}
+	}
+	return k.kitty(flags)
+}
+
+// return the legacy encoding of the key. ok is false if the key must be
+// encoded in kitty keyboard protocol with the flags.
+func (k Key) legacy(flags int, ckm CursorKeyMode) (s string, ok bool) {
+	if k.Event == KeyRelease {
+		return "", false
+	}
+
+	mods := k.Mods &^ keyLocks
+	disambiguate := flags&KITTY_KBD_DISAMBIGUATE != 0
+	alt := ""
+	if mods&KeyAlt != 0 {
+		alt = "\x1B"
+	}
+
+	if k.Final != 'u' {
+		return k.legacyFunctional(mods, ckm), true
+	}
+
+	switch k.Code {
+	case keyEnter, keyTab, keyBackspace:
+		if mods == 0 {
+			return string(rune(k.Code)), true
+		}
+		if disambiguate {
+			return "", false
+		}
+		switch {
+		case k.Code == keyTab && mods&KeyShift != 0:
+			return alt + "\x1B[Z", true
+		case k.Code == keyBackspace && mods&KeyCtrl != 0:
+			return alt + "\x08", true
+		}
+		return alt + string(rune(k.Code)), true
+	case keyEscape:
+		if disambiguate {
+			return "", false
+		}
+		return alt + "\x1B", true
+	}
+
+	if keyPUAStart <= k.Code && k.Code <= keyPUAEnd {
+		// keypad, media and modifier keys have no legacy encoding
+		if disambiguate {
+			return "", false
+		}
+		return "", true
+	}
+
+	// text key
+	text := k.text(mods)
+	if mods&^KeyShift == 0 {
+		return text, true
+	}
+	if disambiguate {
+		return "", false
+	}
+	if mods&KeyCtrl != 0 {
+		if c, ok := ctrlKey(k.Code); ok {
+			text = string(c)
+		}
+	}
+	return alt + text, true
+}
+
+// the legacy encoding of functional keys, such as arrow and F1-F12.
+func (k Key) legacyFunctional(mods int, ckm CursorKeyMode) string {
+	if mods != 0 {
+		if k.Final == '~' {
+			return fmt.Sprintf("\x1B[%d;%d~", k.Code, mods+1)
+		}
+		return fmt.Sprintf("\x1B[1;%d%c", mods+1, k.Final)
+	}
+
+	switch k.Final {
+	case '~':
+		return fmt.Sprintf("\x1B[%d~", k.Code)
+	case 'P', 'Q', 'R', 'S':
+		return fmt.Sprintf("\x1BO%c", k.Final)
+	case 'A', 'B', 'C', 'D', 'H', 'F':
+		if ckm == CursorKeyMode_Application {
+			return fmt.Sprintf("\x1BO%c", k.Final)
+		}
+	}
+	return fmt.Sprintf("\x1B[%c", k.Final)
+}
+
+// the text generated by the key.
+func (k Key) text(mods int) string {
+	if k.Text != "" {
+		return k.Text
+	}
+	if mods&KeyShift != 0 {
+		if k.Shifted != 0 {
+			return string(rune(k.Shifted))
+		}
+		return string(unicode.ToUpper(rune(k.Code)))
+	}
+	return string(rune(k.Code))
+}
+
+// the control character generated by ctrl+key.
+func ctrlKey(code int) (rune, bool) {
+	switch {
+	case 'a' <= code && code <= 'z':
+		return rune(code - 'a' + 1), true
+	case code == ' ', code == '@', code == '2':
+		return 0, true
+	case '[' <= code && code <= '_':
+		return rune(code - '@'), true
+	case code == '/':
+		return 0x1F, true
+	case code == '?':
+		return 0x7F, true
+	}
+	return 0, false
+}
+
+// encode the key in kitty keyboard protocol:
+// CSI unicode-key-code:alternate-key-codes ; modifiers:event-type ; text-as-codepoints u
+func (k Key) kitty(flags int) string {
+	var code strings.Builder
+	if k.Final == 'u' || k.Final == '~' {
+		code.WriteString(strconv.Itoa(k.Code))
+	}
+	if k.Final == 'u' && flags&KITTY_KBD_REPORT_ALTERNATE != 0 {
+		shifted := k.Shifted != 0 && k.Mods&KeyShift != 0
+		switch {
+		case k.Base != 0 && shifted:
+			fmt.Fprintf(&code, ":%d:%d", k.Shifted, k.Base)
+		case k.Base != 0:
+			fmt.Fprintf(&code, "::%d", k.Base)
+		case shifted:
+			fmt.Fprintf(&code, ":%d", k.Shifted)
+		}
+	}
+
+	mods := ""
+	if k.Mods != 0 {
+		mods = strconv.Itoa(k.Mods + 1)
+	}
+	if flags&KITTY_KBD_REPORT_EVENT != 0 && k.Event > KeyPress {
+		mods = fmt.Sprintf("%d:%d", k.Mods+1, k.Event)
+	}
+
+	text := ""
+	if k.Final == 'u' && flags&KITTY_KBD_REPORT_ASSOCIATED != 0 && k.Text != "" && k.Event != KeyRelease {
+		cps := make([]string, 0, len(k.Text))
+		for _, r := range k.Text {
+			cps = append(cps, strconv.Itoa(int(r)))
+		}
+		text = strings.Join(cps, ":")
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1B[")
+	switch {
+	case text != "":
+		fmt.Fprintf(&b, "%s;%s;%s", code.String(), mods, text)
+	case mods != "":
+		if code.Len() == 0 {
+			code.WriteString("1")
+		}
+		fmt.Fprintf(&b, "%s;%s", code.String(), mods)
+	default:
+		b.WriteString(code.String())
+	}
+	b.WriteByte(k.Final)
+	return b.String()
+}
+
+// ParseKey parses the key event in kitty keyboard protocol at the beginning
+// of s. n is the length of the sequence. ok is false if s doesn't start with
+// a key event.
+func ParseKey(s string) (k Key, n int, ok bool) {
+	if !strings.HasPrefix(s, "\x1B[") {
+		return
+	}
+	end := strings.IndexFunc(s[2:], func(r rune) bool { return r != ';' && r != ':' && (r < '0' || r > '9') })
+	if end < 0 {
+		return
+	}
+	n = 2 + end + 1
+	k.Final = s[2+end]
+	if !strings.ContainsRune("u~ABCDEFHPQS", rune(k.Final)) {
+		return Key{}, 0, false
+	}
+
+	var err error
+	fields := strings.Split(s[2:2+end], ";")
+	if len(fields) > 3 {
+		return Key{}, 0, false
+	}
+
+	// key code and alternate keys
+	codes := strings.Split(fields[0], ":")
+	if len(codes) > 3 {
+		return Key{}, 0, false
+	}
+	if k.Code, err = atoiDefault(codes[0], 1); err != nil {
+		return Key{}, 0, false
+	}
+	if len(codes) > 1 {
+		if k.Shifted, err = atoiDefault(codes[1], 0); err != nil {
+			return Key{}, 0, false
+		}
+	}
+	if len(codes) > 2 {
+		if k.Base, err = atoiDefault(codes[2], 0); err != nil {
+			return Key{}, 0, false
+		}
+	}
+	if k.Final != 'u' && k.Final != '~' && (k.Code != 1 || len(codes) > 1) {
+		return Key{}, 0, false
+	}
+
+	// modifiers and event type
+	k.Mods, k.Event = 1, KeyPress
+	if len(fields) > 1 {
+		mods, event, _ := strings.Cut(fields[1], ":")
+		if k.Mods, err = atoiDefault(mods, 1); err != nil || k.Mods < 1 {
+			return Key{}, 0, false
+		}
+		if k.Event, err = atoiDefault(event, KeyPress); err != nil || k.Event < KeyPress || k.Event > KeyRelease {
+			return Key{}, 0, false
+		}
+	}
+	k.Mods--
+
+	// associated text
+	if len(fields) > 2 {
+		var b strings.Builder
+		for _, cp := range strings.Split(fields[2], ":") {
+			r, err := strconv.Atoi(cp)
+			if err != nil || !unicode.IsPrint(rune(r)) {
+				return Key{}, 0, false
+			}
+			b.WriteRune(rune(r))
+		}
+		k.Text = b.String()
+	}
+	return k, n, true
+}
+
+func atoiDefault(s string, value int) (int, error) {
+	if s == "" {
+		return value, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,116 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"testing"
+)
+
+func TestKeyEncode(t *testing.T) {
+	a := Key{Code: 'a', Event: KeyPress, Final: 'u'}
+	shiftA := Key{Code: 'a', Shifted: 'A', Mods: KeyShift, Event: KeyPress, Final: 'u', Text: "A"}
+	ctrlA := Key{Code: 'a', Mods: KeyCtrl, Event: KeyPress, Final: 'u'}
+	altA := Key{Code: 'a', Mods: KeyAlt, Event: KeyPress, Final: 'u'}
+	releaseA := Key{Code: 'a', Event: KeyRelease, Final: 'u'}
+	repeatA := Key{Code: 'a', Event: KeyRepeat, Final: 'u', Text: "a"}
+	enter := Key{Code: keyEnter, Event: KeyPress, Final: 'u'}
+	ctrlEnter := Key{Code: keyEnter, Mods: KeyCtrl, Event: KeyPress, Final: 'u'}
+	esc := Key{Code: keyEscape, Event: KeyPress, Final: 'u'}
+	up := Key{Code: 1, Event: KeyPress, Final: 'A'}
+	ctrlUp := Key{Code: 1, Mods: KeyCtrl, Event: KeyPress, Final: 'A'}
+	upRelease := Key{Code: 1, Event: KeyRelease, Final: 'A'}
+	del := Key{Code: 3, Event: KeyPress, Final: '~'}
+	leftShift := Key{Code: 57441, Mods: KeyShift, Event: KeyPress, Final: 'u'}
+	cyrillic := Key{Code: 1092, Base: 'a', Mods: KeyCtrl, Event: KeyPress, Final: 'u'}
+
+	const (
+		disambiguate = KITTY_KBD_DISAMBIGUATE
+		events       = KITTY_KBD_DISAMBIGUATE | KITTY_KBD_REPORT_EVENT
+		all          = KITTY_KBD_SUPPORTED
+	)
+
+	tc := []struct {
+		label  string
+		key    Key
+		flags  int
+		ckm    CursorKeyMode
+		expect string
+	}{
+		{"legacy text", a, 0, CursorKeyMode_ANSI, "a"},
+		{"legacy shift text", shiftA, 0, CursorKeyMode_ANSI, "A"},
+		{"legacy ctrl", ctrlA, 0, CursorKeyMode_ANSI, "\x01"},
+		{"legacy alt", altA, 0, CursorKeyMode_ANSI, "\x1Ba"},
+		{"legacy release", releaseA, 0, CursorKeyMode_ANSI, ""},
+		{"legacy repeat", repeatA, 0, CursorKeyMode_ANSI, "a"},
+		{"legacy enter", ctrlEnter, 0, CursorKeyMode_ANSI, "\r"},
+		{"legacy escape", esc, 0, CursorKeyMode_ANSI, "\x1B"},
+		{"legacy cursor key", up, 0, CursorKeyMode_ANSI, "\x1B[A"},
+		{"legacy application cursor key", up, 0, CursorKeyMode_Application, "\x1BOA"},
+		{"legacy ctrl cursor key", ctrlUp, 0, CursorKeyMode_Application, "\x1B[1;5A"},
+		{"legacy delete", del, 0, CursorKeyMode_ANSI, "\x1B[3~"},
+		{"legacy modifier key", leftShift, 0, CursorKeyMode_ANSI, ""},
+		{"disambiguate text", a, disambiguate, CursorKeyMode_ANSI, "a"},
+		{"disambiguate ctrl", ctrlA, disambiguate, CursorKeyMode_ANSI, "\x1B[97;5u"},
+		{"disambiguate enter", enter, disambiguate, CursorKeyMode_ANSI, "\r"},
+		{"disambiguate ctrl enter", ctrlEnter, disambiguate, CursorKeyMode_ANSI, "\x1B[13;5u"},
+		{"disambiguate escape", esc, disambiguate, CursorKeyMode_ANSI, "\x1B[27u"},
+		{"disambiguate modifier key", leftShift, disambiguate, CursorKeyMode_ANSI, "\x1B[57441;2u"},
+		{"disambiguate release", releaseA, disambiguate, CursorKeyMode_ANSI, ""},
+		{"events release", releaseA, events, CursorKeyMode_ANSI, "\x1B[97;1:3u"},
+		{"events cursor key release", upRelease, events, CursorKeyMode_ANSI, "\x1B[1;1:3A"},
+		{"events repeat", repeatA, events, CursorKeyMode_ANSI, "a"},
+		{"all text", a, all, CursorKeyMode_ANSI, "\x1B[97u"},
+		{"all shift text", shiftA, all, CursorKeyMode_ANSI, "\x1B[97:65;2;65u"},
+		{"all repeat", repeatA, all, CursorKeyMode_ANSI, "\x1B[97;1:2;97u"},
+		{"all enter", enter, all, CursorKeyMode_ANSI, "\x1B[13u"},
+		{"all cursor key", up, all, CursorKeyMode_Application, "\x1B[A"},
+		{"all base layout key", cyrillic, all, CursorKeyMode_ANSI, "\x1B[1092::97;5u"},
+		{"alternate without shift", cyrillic, disambiguate | KITTY_KBD_REPORT_ALTERNATE, CursorKeyMode_ANSI, "\x1B[1092::97;5u"},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 0)
+		emu.cf.kittyKbd.UpdatePeek(v.flags)
+		emu.cursorKeyMode = v.ckm
+
+		v.key.Handle(emu)
+		if got := emu.ReadOctetsToHost(); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tc := []struct {
+		label  string
+		seq    string
+		expect Key
+		n      int
+		ok     bool
+	}{
+		{"ctrl a", "\x1B[97;5u", Key{Code: 'a', Mods: KeyCtrl, Event: KeyPress, Final: 'u'}, 7, true},
+		{"escape", "\x1B[27u", Key{Code: keyEscape, Event: KeyPress, Final: 'u'}, 5, true},
+		{
+			"alternate keys and text", "\x1B[97:65:97;2:2;65urest",
+			Key{Code: 'a', Shifted: 'A', Base: 'a', Mods: KeyShift, Event: KeyRepeat, Text: "A", Final: 'u'}, 18, true,
+		},
+		{"base key only", "\x1B[1092::97;5u", Key{Code: 1092, Base: 'a', Mods: KeyCtrl, Event: KeyPress, Final: 'u'}, 13, true},
+		{"cursor key release", "\x1B[1;1:3A", Key{Code: 1, Event: KeyRelease, Final: 'A'}, 8, true},
+		{"delete key", "\x1B[3;2~", Key{Code: 3, Mods: KeyShift, Event: KeyPress, Final: '~'}, 6, true},
+		{"legacy cursor key", "\x1B[B", Key{Code: 1, Event: KeyPress, Final: 'B'}, 3, true},
+		{"bad cursor key", "\x1B[2;5A", Key{}, 0, false},
+		{"bad event", "\x1B[97;1:4u", Key{}, 0, false},
+		{"mouse report", "\x1B[<0;1;1M", Key{}, 0, false},
+		{"flags report", "\x1B[?1u", Key{}, 0, false},
+		{"incomplete", "\x1B[97;5", Key{}, 0, false},
+	}
+
+	for _, v := range tc {
+		k, n, ok := ParseKey(v.seq)
+		if k != v.expect || n != v.n || ok != v.ok {
+			t.Errorf("%s expect %v %d %t, got %v %d %t\n", v.label, v.expect, v.n, v.ok, k, n, ok)
+		}
+	}
+}
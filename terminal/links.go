@@ -5,12 +5,14 @@
 package terminal
 
 import (
+	"hash/fnv"
 	"slices"
 )
 
 const (
 	maxURILength = 2083
 	maxIDLength  = 250
+	linkCacheMax = 64 // prune the unused links if there are more links
 )
 
 /*
@@ -36,20 +38,22 @@ type link struct {
 	index int
 }
 
+// the hyperlinks referred by the cells, see Renditions.linkIndex. the unused
+// links are dropped by Emulator.pruneLinks().
 type linkSet struct {
-	links     []link
-	nextIndex int
+	links []link
 }
 
 func newLinks() *linkSet {
 	v := &linkSet{}
 	v.links = make([]link, 0, 8)
-	v.nextIndex = 1
 
 	return v
 }
 
-// return exist link index or return new link index
+// return exist link index or return new link index. the index is derived from
+// the id and url, so the same link gets the same index in both server and
+// client, no matter in which order the links are added.
 func (x *linkSet) addLink(id string, url string) (index int) {
 	if len(url) > maxURILength-1 {
 		url = url[:maxURILength-1]
@@ -59,32 +63,77 @@ func (x *linkSet) addLink(id string, url string) (index int) {
 		id = id[:maxIDLength-1]
 	}
 
-	if len(x.links) > 0 {
-		idx := slices.IndexFunc(x.links, func(l link) bool {
-			return l.url == url && l.id == id
-		})
-
-		if idx != -1 {
-			return x.links[idx].index
+	index = hashLink(id, url)
+	for {
+		if index == 0 { // zero means no link
+			index = 1
+		}
+		l, ok := x.getLink(index)
+		if !ok {
+			break
+		}
+		if l.url == url && l.id == id {
+			return
 		}
+		index++ // hash collision, try the next one
 	}
-	index = x.nextIndex
 	x.links = append(x.links, link{id: id, url: url, index: index})
-	x.nextIndex++
 
 	return
 }
 
+// return the hash of id and url as the link index.
+func hashLink(id string, url string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	return int(h.Sum32() & 0x7FFFFFFF)
+}
+
+// return the link of index
+func (x *linkSet) getLink(index int) (l link, ok bool) {
+	idx := slices.IndexFunc(x.links, func(l link) bool { return l.index == index })
+	if idx == -1 {
+		return
+	}
+	return x.links[idx], true
+}
+
+// drop the links not in used
+func (x *linkSet) prune(used map[int]bool) {
+	x.links = slices.DeleteFunc(x.links, func(l link) bool { return !used[l.index] })
+}
+
 func (x *linkSet) clone() *linkSet {
 	clone := linkSet{}
 
-	clone.nextIndex = x.nextIndex
 	clone.links = make([]link, len(x.links))
 	copy(clone.links, x.links)
 
 	return &clone
 }
 
+// drop the links which are not referred by any cell, current renditions or
+// saved cursors.
+func (emu *Emulator) pruneLinks() {
+	if len(emu.links.links) <= linkCacheMax {
+		return
+	}
+
+	used := map[int]bool{
+		emu.attrs.renditions.linkIndex:                     true,
+		emu.savedCursor_DEC_pri.attrs.renditions.linkIndex: true,
+		emu.savedCursor_DEC_alt.attrs.renditions.linkIndex: true,
+	}
+	for _, fb := range []*Framebuffer{&emu.frame_pri, &emu.frame_alt} {
+		for i := range fb.cells {
+			used[fb.cells[i].renditions.linkIndex] = true
+		}
+	}
+	emu.links.prune(used)
+}
+
 // func (x *links) changeLink(nUrl string) bool {
 // 	idx := slices.IndexFunc(x.linkSet, func(c link) bool { return c.url == nUrl })
 // 	if idx >= 0 {
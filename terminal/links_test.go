@@ -21,22 +21,22 @@ func TestLinkSet(t *testing.T) {
 		{
 			"add new link", "", "http://go.dev",
 			[]string{"http://x.y.z", "http://a.b.c"},
-			3,
+			hashLink("", "http://go.dev"),
 		},
 		{
 			"got exist link", "2", "http://a.b.c",
 			[]string{"http://x.y.z", "http://a.b.c"},
-			2,
+			hashLink("2", "http://a.b.c"),
 		},
 		{
 			"max uri length", "", strings.Repeat("x", maxURILength),
 			[]string{"http://x.y.z", "http://a.b.c"},
-			3,
+			hashLink("", strings.Repeat("x", maxURILength-1)),
 		},
 		{
 			"max id length", strings.Repeat("x", maxIDLength), "http://c.h.i",
 			[]string{"http://x.y.z", "http://a.b.c"},
-			3,
+			hashLink(strings.Repeat("x", maxIDLength-1), "http://c.h.i"),
 		},
 	}
 
@@ -60,3 +60,79 @@ func TestLinkSet(t *testing.T) {
 		})
 	}
 }
+
+func TestLinkSetCollision(t *testing.T) {
+	links := newLinks()
+	index := hashLink("", "http://go.dev")
+
+	// another link takes the index
+	links.links = append(links.links, link{url: "http://x.y.z", index: index})
+	if got := links.addLink("", "http://go.dev"); got != index+1 {
+		t.Errorf("collision expect index %d, got %d\n", index+1, got)
+	}
+	if got := links.addLink("", "http://go.dev"); got != index+1 {
+		t.Errorf("collision expect exist index %d, got %d\n", index+1, got)
+	}
+}
+
+func TestPruneLinks(t *testing.T) {
+	emu := NewEmulator3(80, 40, 40)
+
+	// the link on screen and the open link are kept
+	emu.HandleStream("\x1B]8;;http://screen\x1B\\link\x1B]8;;\x1B\\")
+	for i := 0; i < linkCacheMax-1; i++ {
+		emu.HandleStream(fmt.Sprintf("\x1B]8;;http://unused/%d\x1B\\", i))
+	}
+	emu.HandleStream("\x1B]8;;http://open\x1B\\")
+
+	tc := []struct {
+		label string
+		url   string
+		ok    bool
+	}{
+		{"on screen", "http://screen", true},
+		{"open link", "http://open", true},
+		{"unused", "http://unused/0", false},
+	}
+
+	for _, v := range tc {
+		if _, ok := emu.links.getLink(hashLink("", v.url)); ok != v.ok {
+			t.Errorf("%s expect %t, got %t\n", v.label, v.ok, ok)
+		}
+	}
+	if len(emu.links.links) != 2 {
+		t.Errorf("prune expect 2 links, got %d\n", len(emu.links.links))
+	}
+}
+
+func TestNewFrame_Links(t *testing.T) {
+	oldE := NewEmulator3(80, 40, 40)
+	newE := NewEmulator3(80, 40, 40)
+	newE.HandleStream("\x1B]8;id=1;http://a\x1B\\ab\x1B]8;;\x1B\\c\x1B]8;;http://b\x1B\\d")
+
+	tc := []struct {
+		label  string
+		link   bool
+		expect string
+	}{
+		{"supported", true, "\x1B]8;id=1;http://a\x1B\\ab\x1B]8;;\x1B\\c\x1B]8;;http://b\x1B\\d"},
+		{"not supported", false, "abcd"},
+	}
+
+	for _, v := range tc {
+		d, _ := NewDisplay(false)
+		d.supportLink = v.link
+
+		got := d.NewFrame(true, oldE, newE)
+		if !strings.Contains(got, v.expect) || !v.link && strings.Contains(got, "\x1B]8;") {
+			t.Errorf("%s expect %q in %q\n", v.label, v.expect, got)
+		}
+	}
+
+	// the open link is closed after the link text
+	d, _ := NewDisplay(false)
+	got := d.NewFrame(true, newE, oldE)
+	if !strings.Contains(got, "\x1B]8;;\x1B\\") {
+		t.Errorf("close link expect OSC 8 in %q\n", got)
+	}
+}
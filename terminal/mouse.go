@@ -0,0 +1,198 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// the bits of mouse button code (Cb), see xterm ctlseqs "Mouse Tracking".
+const (
+	MouseButtonMask  = 3   // 0,1,2: button 1,2,3 pressed, 3: release or no button
+	MouseShift       = 4   // shift modifier
+	MouseMeta        = 8   // meta modifier
+	MouseControl     = 16  // control modifier
+	MouseMotion      = 32  // motion event
+	MouseWheel       = 64  // wheel button 4-7
+	MouseExtraButton = 128 // button 8-11
+	mouseModifiers   = MouseShift | MouseMeta | MouseControl
+	mouseX10Limit    = 255 - 32  // the max coordinate of default encoding
+	mouseUTF8Limit   = 2047 - 32 // the max coordinate of UTF-8 encoding
+	mouseNoButton    = 3         // button code of release or motion without button
+	mouseReportMin   = len("\x1B[M") + 3
+)
+
+// Mouse is the mouse event reported by the local terminal. it's parsed by
+// aprilsh client and encoded by server according to the mouse tracking mode
+// and encoding requested by the application.
+type Mouse struct {
+	Button  int  // button code (Cb) with modifiers and motion bits, without the offset 32
+	X       int  // column or pixel, 1-based
+	Y       int  // row or pixel, 1-based
+	Release bool // button release, the button is known only in SGR encoding
+	Pixel   bool // the coordinates are pixels (SGR-Pixels encoding)
+}
+
+func (m Mouse) Handle(emu *Emulator) {
+	emu.writePty(m.encode(emu.mouseTrk, emu.nCols, emu.nRows))
+}
+
+// encode the mouse event for the application. return empty string if the
+// tracking mode doesn't report it or the encoding can't represent it.
+func (m Mouse) encode(trk MouseTrackingState, nCols, nRows int) string {
+	button := m.Button & MouseButtonMask
+	wheel := m.Button&MouseWheel != 0 && m.Button&MouseExtraButton == 0
+	motion := m.Button&MouseMotion != 0
+
+	switch trk.mode {
+	case MouseTrackingMode_Disable:
+		return ""
+	case MouseTrackingMode_X10_Compat:
+		// only button press, without modifiers
+		if m.Release || motion || wheel || m.Button&MouseExtraButton != 0 || button == mouseNoButton {
+			return ""
+		}
+		m.Button &^= mouseModifiers
+	case MouseTrackingMode_VT200, MouseTrackingMode_VT200_HighLight:
+		if motion {
+			return ""
+		}
+	case MouseTrackingMode_VT200_ButtonEvent:
+		if motion && button == mouseNoButton && !wheel && m.Button&MouseExtraButton == 0 {
+			return ""
+		}
+	}
+
+	// wheel doesn't report release
+	if m.Release && wheel {
+		return ""
+	}
+
+	// the pixel coordinates can't be converted to cells, or vice versa
+	if m.Pixel != (trk.enc == MouseTrackingEnc_SGR_Pixels) {
+		return ""
+	}
+	if !m.Pixel {
+		// the local screen may be larger than server during resize
+		m.X = max(1, min(m.X, nCols))
+		m.Y = max(1, min(m.Y, nRows))
+	}
+
+	switch trk.enc {
+	case MouseTrackingEnc_SGR, MouseTrackingEnc_SGR_Pixels:
+		final := 'M'
+		if m.Release {
+			final = 'm'
+		}
+		return fmt.Sprintf("\x1B[<%d;%d;%d%c", m.Button, m.X, m.Y, final)
+	}
+
+	// the other encodings report release as button 3
+	if m.Release {
+		m.Button = m.Button&^(MouseButtonMask|MouseExtraButton) | mouseNoButton
+	}
+	switch trk.enc {
+	case MouseTrackingEnc_URXVT:
+		return fmt.Sprintf("\x1B[%d;%d;%dM", m.Button+32, m.X, m.Y)
+	case MouseTrackingEnc_UTF8:
+		if m.X > mouseUTF8Limit || m.Y > mouseUTF8Limit || m.Button > mouseUTF8Limit {
+			return ""
+		}
+		return fmt.Sprintf("\x1B[M%c%c%c", rune(m.Button+32), rune(m.X+32), rune(m.Y+32))
+	default:
+		if m.X > mouseX10Limit || m.Y > mouseX10Limit || m.Button > mouseX10Limit {
+			return ""
+		}
+		return "\x1B[M" + string([]byte{byte(m.Button + 32), byte(m.X + 32), byte(m.Y + 32)})
+	}
+}
+
+// LocalMouseEnc returns the mouse encoding requested from the local terminal.
+// SGR reports any button and coordinate without ambiguity, the server encodes
+// it again for the application. SGR-Pixels is kept since pixels can't be
+// converted to cells.
+func LocalMouseEnc(enc MouseTrackingEnc) MouseTrackingEnc {
+	if enc == MouseTrackingEnc_SGR_Pixels {
+		return enc
+	}
+	return MouseTrackingEnc_SGR
+}
+
+// ParseMouse parses the mouse report at the beginning of s, enc is the mouse
+// encoding requested from the local terminal. n is the length of the report.
+// ok is false if s doesn't start with a complete mouse report.
+func ParseMouse(s string, enc MouseTrackingEnc) (m Mouse, n int, ok bool) {
+	if !strings.HasPrefix(s, "\x1B[") {
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(s, "\x1B[M"):
+		if enc == MouseTrackingEnc_UTF8 {
+			var v [3]int
+			n = len("\x1B[M")
+			for i := range v {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if size == 0 || r == utf8.RuneError || r < 32 {
+					return Mouse{}, 0, false
+				}
+				v[i] = int(r) - 32
+				n += size
+			}
+			m = Mouse{Button: v[0], X: v[1], Y: v[2]}
+		} else {
+			if len(s) < mouseReportMin || s[3] < 32 || s[4] < 33 || s[5] < 33 {
+				return
+			}
+			m = Mouse{Button: int(s[3]) - 32, X: int(s[4]) - 32, Y: int(s[5]) - 32}
+			n = mouseReportMin
+		}
+		m.Release = m.Button&(MouseButtonMask|MouseMotion|MouseWheel) == mouseNoButton
+		return m, n, true
+	case strings.HasPrefix(s, "\x1B[<"):
+		// SGR: CSI < Cb ; Cx ; Cy M/m
+		v, end, ok := parseMouseParams(s[len("\x1B[<"):])
+		if !ok {
+			return Mouse{}, 0, false
+		}
+		n = len("\x1B[<") + end
+		m = Mouse{Button: v[0], X: v[1], Y: v[2], Release: s[n-1] == 'm'}
+		m.Pixel = enc == MouseTrackingEnc_SGR_Pixels
+		return m, n, true
+	default:
+		// URXVT: CSI Cb ; Cx ; Cy M
+		v, end, ok := parseMouseParams(s[len("\x1B["):])
+		if !ok || s[len("\x1B[")+end-1] != 'M' || v[0] < 32 {
+			return Mouse{}, 0, false
+		}
+		m = Mouse{Button: v[0] - 32, X: v[1], Y: v[2]}
+		m.Release = m.Button&(MouseButtonMask|MouseMotion|MouseWheel) == mouseNoButton
+		return m, len("\x1B[") + end, true
+	}
+}
+
+// parse the "Cb;Cx;Cy" parameters ended by 'M' or 'm', end is the length
+// including the final character.
+func parseMouseParams(s string) (v [3]int, end int, ok bool) {
+	end = strings.IndexFunc(s, func(r rune) bool { return r != ';' && (r < '0' || r > '9') })
+	if end < 0 || (s[end] != 'M' && s[end] != 'm') {
+		return
+	}
+	fields := strings.Split(s[:end], ";")
+	if len(fields) != 3 {
+		return
+	}
+	for i := range fields {
+		x, err := strconv.Atoi(fields[i])
+		if err != nil || x < 0 || (i > 0 && x < 1) {
+			return
+		}
+		v[i] = x
+	}
+	return v, end + 1, true
+}
@@ -0,0 +1,90 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"testing"
+)
+
+func TestMouseEncode(t *testing.T) {
+	press := Mouse{Button: 0, X: 10, Y: 5}
+	release := Mouse{Button: 0, X: 10, Y: 5, Release: true}
+	drag := Mouse{Button: MouseMotion, X: 11, Y: 5}
+	move := Mouse{Button: MouseMotion | mouseNoButton, X: 12, Y: 5}
+	wheel := Mouse{Button: MouseWheel | 1, X: 10, Y: 5}
+	ctrl := Mouse{Button: 2 | MouseControl, X: 10, Y: 5}
+
+	tc := []struct {
+		label  string
+		mode   MouseTrackingMode
+		enc    MouseTrackingEnc
+		mouse  Mouse
+		expect string
+	}{
+		{"disabled", MouseTrackingMode_Disable, MouseTrackingEnc_SGR, press, ""},
+		{"X10 press", MouseTrackingMode_X10_Compat, MouseTrackingEnc_Default, press, "\x1B[M *%"},
+		{"X10 no release", MouseTrackingMode_X10_Compat, MouseTrackingEnc_Default, release, ""},
+		{"X10 no modifier", MouseTrackingMode_X10_Compat, MouseTrackingEnc_SGR, ctrl, "\x1B[<2;10;5M"},
+		{"X10 no wheel", MouseTrackingMode_X10_Compat, MouseTrackingEnc_SGR, wheel, ""},
+		{"normal press", MouseTrackingMode_VT200, MouseTrackingEnc_SGR, press, "\x1B[<0;10;5M"},
+		{"normal release", MouseTrackingMode_VT200, MouseTrackingEnc_SGR, release, "\x1B[<0;10;5m"},
+		{"normal release default", MouseTrackingMode_VT200, MouseTrackingEnc_Default, release, "\x1B[M#*%"},
+		{"normal modifier", MouseTrackingMode_VT200, MouseTrackingEnc_SGR, ctrl, "\x1B[<18;10;5M"},
+		{"normal wheel", MouseTrackingMode_VT200, MouseTrackingEnc_URXVT, wheel, "\x1B[97;10;5M"},
+		{"normal no drag", MouseTrackingMode_VT200, MouseTrackingEnc_SGR, drag, ""},
+		{"button drag", MouseTrackingMode_VT200_ButtonEvent, MouseTrackingEnc_SGR, drag, "\x1B[<32;11;5M"},
+		{"button no move", MouseTrackingMode_VT200_ButtonEvent, MouseTrackingEnc_SGR, move, ""},
+		{"any move", MouseTrackingMode_VT200_AnyEvent, MouseTrackingEnc_SGR, move, "\x1B[<35;12;5M"},
+		{"URXVT release", MouseTrackingMode_VT200, MouseTrackingEnc_URXVT, release, "\x1B[35;10;5M"},
+		{"UTF-8 large column", MouseTrackingMode_VT200, MouseTrackingEnc_UTF8, Mouse{X: 300, Y: 5}, "\x1B[M Ō%"},
+		{"default large column", MouseTrackingMode_VT200, MouseTrackingEnc_Default, Mouse{X: 300, Y: 5}, ""},
+		{"clamp to screen", MouseTrackingMode_VT200, MouseTrackingEnc_SGR, Mouse{X: 500, Y: 90}, "\x1B[<0;400;50M"},
+		{"pixel", MouseTrackingMode_VT200, MouseTrackingEnc_SGR_Pixels, Mouse{X: 900, Y: 600, Pixel: true}, "\x1B[<0;900;600M"},
+		{"pixel to cell", MouseTrackingMode_VT200, MouseTrackingEnc_SGR, Mouse{X: 900, Y: 600, Pixel: true}, ""},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(400, 50, 0)
+		emu.mouseTrk.mode = v.mode
+		emu.mouseTrk.enc = v.enc
+
+		v.mouse.Handle(emu)
+		if got := emu.ReadOctetsToHost(); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestParseMouse(t *testing.T) {
+	tc := []struct {
+		label  string
+		seq    string
+		enc    MouseTrackingEnc
+		expect Mouse
+		n      int
+		ok     bool
+	}{
+		{"SGR press", "\x1B[<0;10;5Mabc", MouseTrackingEnc_SGR, Mouse{X: 10, Y: 5}, 10, true},
+		{"SGR release", "\x1B[<2;10;5m", MouseTrackingEnc_SGR, Mouse{Button: 2, X: 10, Y: 5, Release: true}, 10, true},
+		{"SGR pixels", "\x1B[<0;900;600M", MouseTrackingEnc_SGR_Pixels, Mouse{X: 900, Y: 600, Pixel: true}, 13, true},
+		{"X10 press", "\x1B[M *%", MouseTrackingEnc_Default, Mouse{X: 10, Y: 5}, 6, true},
+		{"X10 release", "\x1B[M#*%", MouseTrackingEnc_Default, Mouse{Button: 3, X: 10, Y: 5, Release: true}, 6, true},
+		{"X10 wheel", "\x1B[Ma*%", MouseTrackingEnc_Default, Mouse{Button: 65, X: 10, Y: 5}, 6, true},
+		{"X10 incomplete", "\x1B[M *", MouseTrackingEnc_Default, Mouse{}, 0, false},
+		{"UTF-8 press", "\x1B[M ō%", MouseTrackingEnc_UTF8, Mouse{X: 301, Y: 5}, 7, true},
+		{"URXVT press", "\x1B[32;10;5M", MouseTrackingEnc_URXVT, Mouse{X: 10, Y: 5}, 10, true},
+		{"URXVT release", "\x1B[35;10;5M", MouseTrackingEnc_URXVT, Mouse{Button: 3, X: 10, Y: 5, Release: true}, 10, true},
+		{"cursor key", "\x1B[A", MouseTrackingEnc_SGR, Mouse{}, 0, false},
+		{"function key", "\x1B[1;5;3~", MouseTrackingEnc_SGR, Mouse{}, 0, false},
+		{"zero column", "\x1B[<0;0;5M", MouseTrackingEnc_SGR, Mouse{}, 0, false},
+	}
+
+	for _, v := range tc {
+		m, n, ok := ParseMouse(v.seq, v.enc)
+		if m != v.expect || n != v.n || ok != v.ok {
+			t.Errorf("%s expect %v %d %t, got %v %d %t\n", v.label, v.expect, v.n, v.ok, m, n, ok)
+		}
+	}
+}
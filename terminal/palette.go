@@ -0,0 +1,130 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the keys of dynamic colors in Emulator.palette, the keys of palette colors
+// are the color index: 0-255.
+const (
+	paletteFg     = 256 // OSC 10: VT100 text foreground color
+	paletteBg     = 257 // OSC 11: VT100 text background color
+	paletteCursor = 258 // OSC 12: text cursor color, only used for client terminal colors
+)
+
+// ParseColorSpec parses the color specification of XParseColor, which can be:
+// rgb:<red>/<green>/<blue> with 1 to 4 hex digits for each component, #RGB,
+// #RRGGBB, #RRRGGGBBB, #RRRRGGGGBBBB or a color name. It returns the RGB color,
+// or ColorDefault if the specification is invalid.
+func ParseColorSpec(spec string) Color {
+	var rgb [3]int32
+	if v, ok := strings.CutPrefix(spec, "rgb:"); ok {
+		parts := strings.Split(v, "/")
+		if len(parts) != 3 {
+			return ColorDefault
+		}
+		for i, p := range parts {
+			n, err := strconv.ParseUint(p, 16, 16)
+			if err != nil || len(p) > 4 {
+				return ColorDefault
+			}
+			// scale the component to 8 bits: f -> ff, fff -> ff
+			full := uint64(1)<<(4*len(p)) - 1
+			rgb[i] = int32((n*255 + full/2) / full)
+		}
+		return NewRGBColor(rgb[0], rgb[1], rgb[2])
+	}
+
+	if v, ok := strings.CutPrefix(spec, "#"); ok {
+		n := len(v) / 3
+		if n < 1 || n > 4 || len(v)%3 != 0 {
+			return ColorDefault
+		}
+		for i := range rgb {
+			x, err := strconv.ParseUint(v[i*n:(i+1)*n], 16, 16)
+			if err != nil {
+				return ColorDefault
+			}
+			// the hex digits are the high bits of component: #3a7 is #30a070
+			rgb[i] = int32(x << (16 - 4*n) >> 8)
+		}
+		return NewRGBColor(rgb[0], rgb[1], rgb[2])
+	}
+
+	return GetColor(strings.ToLower(spec)).TrueColor()
+}
+
+var paletteReply = regexp.MustCompile("\x1B\\]([0-9]+);(?:([0-9]+);)?([^\x07\x1B]*)(?:\x07|\x1B\\\\)")
+
+// parse the replies of OSC 4, 10, 11 and 12 query, return the colors of
+// client terminal. the later reply overrides the former one.
+func parsePaletteReply(reply string) map[int]Color {
+	colors := make(map[int]Color)
+	for _, m := range paletteReply.FindAllStringSubmatch(reply, -1) {
+		c := ParseColorSpec(m[3])
+		if !c.Valid() {
+			continue
+		}
+
+		key := -1
+		switch m[1] {
+		case "4":
+			if idx, err := strconv.Atoi(m[2]); err == nil && idx < 256 {
+				key = idx
+			}
+		case "10":
+			key = paletteFg
+		case "11":
+			key = paletteBg
+		case "12":
+			key = paletteCursor
+		}
+		if key >= 0 && (m[1] == "4") == (m[2] != "") {
+			colors[key] = c
+		}
+	}
+	return colors
+}
+
+// return the color of palette key. the color changed by application comes
+// first, then the color of client terminal. if both are unknown, fallback
+// is returned.
+func (emu *Emulator) paletteColor(key int, fallback Color) Color {
+	if c, ok := emu.palette[key]; ok {
+		return c
+	}
+	if c, ok := parsePaletteReply(emu.caps[OSC_4])[key]; ok {
+		return c
+	}
+	return fallback
+}
+
+// return the OSC sequence to set the color of palette key, or to reset the
+// color if it's not valid.
+func paletteSequence(key int, c Color) string {
+	switch {
+	case key == paletteFg && c.Valid():
+		return fmt.Sprintf("\x1B]10;%s\x1B\\", c)
+	case key == paletteFg:
+		return "\x1B]110\x1B\\"
+	case key == paletteBg && c.Valid():
+		return fmt.Sprintf("\x1B]11;%s\x1B\\", c)
+	case key == paletteBg:
+		return "\x1B]111\x1B\\"
+	case key == paletteCursor && c.Valid():
+		return fmt.Sprintf("\x1B]12;%s\x1B\\", c)
+	case key == paletteCursor:
+		return "\x1B]112\x1B\\"
+	case c.Valid():
+		return fmt.Sprintf("\x1B]4;%d;%s\x1B\\", key, c)
+	default:
+		return fmt.Sprintf("\x1B]104;%d\x1B\\", key)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseColorSpec(t *testing.T) {
+	tc := []struct {
+		label  string
+		spec   string
+		expect Color
+	}{
+		{"rgb 4 digits", "rgb:ffff/8080/0000", NewRGBColor(255, 128, 0)},
+		{"rgb 2 digits", "rgb:ff/80/00", NewRGBColor(255, 128, 0)},
+		{"rgb 1 digit", "rgb:f/8/0", NewRGBColor(255, 136, 0)},
+		{"rgb mixed digits", "rgb:f/80/000", NewRGBColor(255, 128, 0)},
+		{"sharp 1 digit", "#3a7", NewRGBColor(0x30, 0xa0, 0x70)},
+		{"sharp 2 digits", "#30a070", NewRGBColor(0x30, 0xa0, 0x70)},
+		{"sharp 4 digits", "#3000a0007000", NewRGBColor(0x30, 0xa0, 0x70)},
+		{"color name", "Red", NewRGBColor(255, 0, 0)},
+		{"rgb missing component", "rgb:ff/80", ColorDefault},
+		{"rgb too many digits", "rgb:fffff/0/0", ColorDefault},
+		{"rgb not hex", "rgb:gg/0/0", ColorDefault},
+		{"sharp wrong length", "#1234", ColorDefault},
+		{"unknown name", "nocolor", ColorDefault},
+	}
+
+	for _, v := range tc {
+		if got := ParseColorSpec(v.spec); got != v.expect {
+			t.Errorf("%s expect %s, got %s\n", v.label, v.expect, got)
+		}
+	}
+}
+
+func TestParsePaletteReply(t *testing.T) {
+	reply := "\x1B]4;0;rgb:0000/0000/0000\x1B\\\x1B]4;1;rgb:cdcd/0000/0000\x07" +
+		"\x1B]10;rgb:c0c0/c0c0/c0c0\x1B\\\x1B]11;rgb:1010/1010/1010\x1B\\\x1B]12;rgb:ffff/0000/0000\x1B\\" +
+		"\x1B]4;300;rgb:0000/0000/0000\x1B\\\x1B]10;1;rgb:0000/0000/0000\x1B\\\x1B]4;1;bad\x1B\\"
+
+	expect := map[int]Color{
+		0:             NewRGBColor(0, 0, 0),
+		1:             NewRGBColor(0xcd, 0, 0),
+		paletteFg:     NewRGBColor(0xc0, 0xc0, 0xc0),
+		paletteBg:     NewRGBColor(0x10, 0x10, 0x10),
+		paletteCursor: NewRGBColor(0xff, 0, 0),
+	}
+
+	got := parsePaletteReply(reply)
+	if len(got) != len(expect) {
+		t.Errorf("expect %d colors, got %d\n", len(expect), len(got))
+	}
+	for k, c := range expect {
+		if got[k] != c {
+			t.Errorf("key %d expect %s, got %s\n", k, c, got[k])
+		}
+	}
+}
+
+func TestHandle_Palette(t *testing.T) {
+	caps := map[int]string{
+		OSC_4: "\x1B]4;1;rgb:cdcd/0000/0000\x1B\\\x1B]10;rgb:c0c0/c0c0/c0c0\x1B\\" +
+			"\x1B]11;rgb:1010/1010/1010\x1B\\\x1B]12;rgb:ffff/0000/0000\x1B\\",
+	}
+
+	tc := []struct {
+		label  string
+		seq    string
+		resp   string
+		diff   string
+		colors map[int]Color
+	}{
+		{
+			"query client colors",
+			"\x1B]4;1;?;2;?\x1B\\\x1B]10;?;11;?;12;?\x1B\\",
+			"\x1B]4;1;rgb:cdcd/0000/0000\x1B\\\x1B]4;2;rgb:0000/8080/0000\x1B\\" +
+				"\x1B]10;rgb:c0c0/c0c0/c0c0\x1B\\\x1B]11;rgb:1010/1010/1010\x1B\\\x1B]12;rgb:ffff/0000/0000\x1B\\",
+			"", map[int]Color{},
+		},
+		{
+			"set and query colors",
+			"\x1B]4;1;#00ff00\x1B\\\x1B]11;rgb:ff/ff/ff\x1B\\\x1B]4;1;?\x1B\\\x1B]11;?\x1B\\",
+			"\x1B]4;1;rgb:0000/ffff/0000\x1B\\\x1B]11;rgb:ffff/ffff/ffff\x1B\\",
+			"\x1B]4;1;#00ff00\x1B\\\x1B]11;rgb:ff/ff/ff\x1B\\",
+			map[int]Color{1: NewRGBColor(0, 255, 0), paletteBg: NewRGBColor(255, 255, 255)},
+		},
+		{
+			"reset one color",
+			"\x1B]4;1;red;2;blue\x1B\\\x1B]104;1\x1B\\",
+			"",
+			"\x1B]4;1;red;2;blue\x1B\\\x1B]104;1\x1B\\",
+			map[int]Color{2: NewRGBColor(0, 0, 255)},
+		},
+		{
+			"reset all colors",
+			"\x1B]4;1;red;2;blue\x1B\\\x1B]10;red\x1B\\\x1B]104\x1B\\",
+			"",
+			"\x1B]4;1;red;2;blue\x1B\\\x1B]10;red\x1B\\\x1B]104\x1B\\",
+			map[int]Color{paletteFg: NewRGBColor(255, 0, 0)},
+		},
+		{
+			"reset dynamic colors",
+			"\x1B]10;red\x1B\\\x1B]11;blue\x1B\\\x1B]110\x1B\\\x1B]111\x1B\\",
+			"",
+			"\x1B]10;red\x1B\\\x1B]11;blue\x1B\\\x1B]110\x1B\\\x1B]111\x1B\\",
+			map[int]Color{},
+		},
+		{
+			"invalid color spec",
+			"\x1B]4;1;nocolor\x1B\\\x1B]10;nocolor\x1B\\",
+			"",
+			"\x1B]4;1;nocolor\x1B\\\x1B]10;nocolor\x1B\\",
+			map[int]Color{},
+		},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 40)
+		emu.SetTerminalCaps(caps)
+
+		_, diff := emu.HandleStream(v.seq)
+		if got := emu.ReadOctetsToHost(); got != v.resp {
+			t.Errorf("%s expect response %q, got %q\n", v.label, v.resp, got)
+		}
+		if diff != v.diff {
+			t.Errorf("%s expect diff %q, got %q\n", v.label, v.diff, diff)
+		}
+		if len(emu.palette) != len(v.colors) {
+			t.Errorf("%s expect %d colors, got %v\n", v.label, len(v.colors), emu.palette)
+		}
+		for k, c := range v.colors {
+			if emu.palette[k] != c {
+				t.Errorf("%s key %d expect %s, got %s\n", v.label, k, c, emu.palette[k])
+			}
+		}
+	}
+}
+
+func TestNewFrame_Palette(t *testing.T) {
+	oldE := NewEmulator3(80, 40, 40)
+	newE := NewEmulator3(80, 40, 40)
+	oldE.HandleStream("\x1B]4;1;red;2;blue\x1B\\\x1B]11;blue\x1B\\")
+	newE.HandleStream("\x1B]4;2;lime;3;blue\x1B\\\x1B]10;red\x1B\\\x1B]12;red\x1B\\")
+
+	tc := []struct {
+		label       string
+		initialized bool
+		expect      string
+	}{
+		{
+			"changed colors", true,
+			"\x1B]12;rgb:ffff/0000/0000\a\x1B]104;1\x1B\\\x1B]4;2;rgb:0000/ffff/0000\x1B\\" +
+				"\x1B]4;3;rgb:0000/0000/ffff\x1B\\\x1B]10;rgb:ffff/0000/0000\x1B\\\x1B]111\x1B\\",
+		},
+		{
+			"redraw", false,
+			"\x1B]12;rgb:ffff/0000/0000\a\x1B]104;1\x1B\\\x1B]4;2;rgb:0000/ffff/0000\x1B\\" +
+				"\x1B]4;3;rgb:0000/0000/ffff\x1B\\\x1B]10;rgb:ffff/0000/0000\x1B\\\x1B]111\x1B\\",
+		},
+	}
+
+	for _, v := range tc {
+		d, _ := NewDisplay(false)
+		got := d.NewFrame(v.initialized, oldE, newE)
+		if !strings.Contains(got, v.expect) {
+			t.Errorf("%s expect %q in %q\n", v.label, v.expect, got)
+		}
+	}
+
+	// the same palette generates nothing
+	d, _ := NewDisplay(false)
+	got := d.NewFrame(true, newE, newE.Clone())
+	if strings.Contains(got, "\x1B]") {
+		t.Errorf("same palette expect no OSC in %q\n", got)
+	}
+}
+
+func TestClose_Palette(t *testing.T) {
+	oldE := NewEmulator3(80, 40, 40)
+	newE := NewEmulator3(80, 40, 40)
+	oldE.HandleStream("\x1B]4;1;red\x1B\\\x1B]11;blue\x1B\\")
+	newE.HandleStream("\x1B]4;2;lime;3;blue\x1B\\\x1B]10;red\x1B\\\x1B]12;red\x1B\\")
+
+	// nothing is replicated, nothing to reset
+	d, _ := NewDisplay(false)
+	if got := d.Close(); strings.Contains(got, "\x1B]") {
+		t.Errorf("no palette expect no OSC in %q\n", got)
+	}
+
+	d.NewFrame(false, oldE, oldE)
+	d.NewFrame(true, oldE, newE)
+	expect := "\x1B]104;1\x1B\\\x1B]104;2\x1B\\\x1B]104;3\x1B\\\x1B]110\x1B\\\x1B]111\x1B\\\x1B]112\x1B\\"
+	if got := d.Close(); !strings.Contains(got, expect) {
+		t.Errorf("replicated palette expect %q in %q\n", expect, got)
+	}
+}
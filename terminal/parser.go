@@ -30,10 +30,14 @@ const (
 	InputState_CSI_GT
 	InputState_CSI_LT
 	InputState_CSI_Equal
+	InputState_CSI_Dollar
+	InputState_CSI_Star
 	InputState_DCS
 	InputState_DCS_Esc
 	InputState_OSC
 	InputState_OSC_Esc
+	InputState_APC
+	InputState_APC_Esc
 	InputState_VT52_CUP_Arg1
 	InputState_VT52_CUP_Arg2
 )
@@ -55,10 +59,14 @@ var strInputState = [...]string{
 	"CSI_GT",
 	"CSI_LT",
 	"CSI_Equal",
+	"CSI_Dollar",
+	"CSI_Star",
 	"DCS",
 	"DCS_Esc",
 	"OSC",
 	"OSC_Esc",
+	"APC",
+	"APC_Esc",
 	"VT52_CUP_Arg1",
 	"VT52_CUP_Arg2",
 }
@@ -105,15 +113,25 @@ func (p *Parser) getState() int {
 // add rune to the history cache, store max 5 recent runes.
 func (p *Parser) appendToHistory(r rune) {
 	// max history = DCS/OSC buffer limitation 4095 + 2
+	// the image payload of DCS/APC is kept in argBuf only
 	if p.history.Len() < 4097 {
 		p.history.PushBack(r)
-	} else {
+	} else if !p.inImagePayload() {
 		util.Logger.Error("Parser histroy string overflow (>4097)",
 			"historyString", p.historyString(),
 			"rune", r)
 	}
 }
 
+// image payload of DCS/APC may exceed the history limitation
+func (p *Parser) inImagePayload() bool {
+	switch p.inputState {
+	case InputState_DCS, InputState_DCS_Esc, InputState_APC, InputState_APC_Esc:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) replaceHistory(chs ...rune) {
 	p.resetHistory()
 	// for i := range p.inputSep {
@@ -448,7 +466,7 @@ func (p *Parser) handle_OSC() (hd *Handler) {
 	} else {
 		arg = arg[pos+1:]
 	}
-	if cmd < 0 || cmd > 120 {
+	if cmd < 0 || (cmd > 120 && cmd != 133 && cmd != 777) {
 		util.Logger.Warn("OSC: malformed command string", "cmd", cmd, "arg", arg)
 	} else {
 		switch cmd {
@@ -473,16 +491,36 @@ func (p *Parser) handle_OSC() (hd *Handler) {
 			hd.handle = func(emu *Emulator) {
 				hdl_osc_10x(emu, cmd, arg)
 			}
+		case 104, 110, 111:
+			hd = &Handler{id: OSC_104_110_111, ch: p.ch, sequence: p.historyString()}
+			hd.handle = func(emu *Emulator) {
+				hdl_osc_104_110_111(emu, cmd, arg)
+			}
 		case 112:
 			hd = &Handler{id: OSC_112, ch: p.ch, sequence: p.historyString()}
 			hd.handle = func(emu *Emulator) {
 				hdl_osc_112(emu, cmd, arg)
 			}
+		case 7:
+			hd = &Handler{id: OSC_7, ch: p.ch, sequence: p.historyString()}
+			hd.handle = func(emu *Emulator) {
+				hdl_osc_7(emu, cmd, arg)
+			}
 		case 8:
 			hd = &Handler{id: OSC_8, ch: p.ch, sequence: p.historyString()}
 			hd.handle = func(emu *Emulator) {
 				hdl_osc_8(emu, cmd, arg)
 			}
+		case 9, 99, 777:
+			hd = &Handler{id: OSC_9_99_777, ch: p.ch, sequence: p.historyString()}
+			hd.handle = func(emu *Emulator) {
+				hdl_osc_notify(emu, cmd, arg)
+			}
+		case 133:
+			hd = &Handler{id: OSC_133, ch: p.ch, sequence: p.historyString()}
+			hd.handle = func(emu *Emulator) {
+				hdl_osc_133(emu, cmd, arg)
+			}
 		default:
 			util.Logger.Warn("unhandled OSC", "cmd", cmd, "arg", arg, "seq", p.historyString())
 		}
@@ -1312,6 +1350,13 @@ func (p *Parser) handle_DCS() (hd *Handler) {
 		hd.handle = func(emu *Emulator) {
 			hdl_dcs_xtgettcap(emu, arg[2:])
 		}
+	} else if isSixel(arg) {
+		// the history is too short for sixel, the sequence is replaced by
+		// image placeholder after handling.
+		hd = &Handler{id: DCS_SIXEL, ch: p.ch, sequence: "\x1BP" + arg + "\x1B\\"}
+		hd.handle = func(emu *Emulator) {
+			hd.sequence = hdl_dcs_sixel(emu, arg)
+		}
 	} else {
 		util.Logger.Warn("DCS", "unimplement", "DCS", "arg", arg, "seq", p.historyString())
 	}
@@ -1319,6 +1364,32 @@ func (p *Parser) handle_DCS() (hd *Handler) {
 	return hd
 }
 
+// Application Program Command
+func (p *Parser) handle_APC() (hd *Handler) {
+	// reset the state
+	defer p.setState(InputState_Normal)
+
+	arg := p.getArg()
+
+	if strings.HasPrefix(arg, "G") {
+		// the history is too short for kitty graphics, the sequence is
+		// replaced by image placeholder after handling.
+		hd = &Handler{id: APC_KITTY_GRAPHICS, ch: p.ch, sequence: "\x1B_" + arg + "\x1B\\"}
+		hd.handle = func(emu *Emulator) {
+			hd.sequence = hdl_apc_kitty(emu, arg[1:])
+		}
+	} else if v, ok := strings.CutPrefix(arg, imagePrefix[2:]); ok {
+		hd = &Handler{id: APC_IMAGE, ch: p.ch, sequence: "\x1B_" + arg + "\x1B\\"}
+		hd.handle = func(emu *Emulator) {
+			hdl_apc_image(emu, v)
+		}
+	} else {
+		util.Logger.Warn("APC", "unimplement", "APC", "arg", arg)
+	}
+
+	return hd
+}
+
 // disambiguation SLRM and SCOSC
 // SLRM: Set Left and Right Margins
 // SCOSC: Save Cursor Position for SCO console
@@ -1399,6 +1470,112 @@ func (p *Parser) handle_DECDC() (hd *Handler) {
 	return hd
 }
 
+// Copy Rectangular Area. the local terminal may not support the rectangular
+// area operations, the sequence is replaced by the repaint of the area after
+// handling. so do the following rectangular area operations.
+func (p *Parser) handle_DECCRA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECCRA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hd.sequence = hdl_csi_deccra(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Fill Rectangular Area
+func (p *Parser) handle_DECFRA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECFRA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hd.sequence = hdl_csi_decfra(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Erase Rectangular Area
+func (p *Parser) handle_DECERA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECERA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hd.sequence = hdl_csi_decera(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Selective Erase Rectangular Area
+func (p *Parser) handle_DECSERA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECSERA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hd.sequence = hdl_csi_decsera(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Change Attributes in Rectangular Area
+func (p *Parser) handle_DECCARA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECCARA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hd.sequence = hdl_csi_deccara(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Reverse Attributes in Rectangular Area
+func (p *Parser) handle_DECRARA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECRARA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hd.sequence = hdl_csi_decrara(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Select Attribute Change Extent
+func (p *Parser) handle_DECSACE() (hd *Handler) {
+	arg := p.getPs(0, 0)
+
+	hd = &Handler{id: CSI_DECSACE, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hdl_csi_decsace(emu, arg)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
+// Request Checksum of Rectangular Area
+func (p *Parser) handle_DECRQCRA() (hd *Handler) {
+	params := p.copyArgs()
+
+	hd = &Handler{id: CSI_DECRQCRA, ch: p.ch, sequence: p.historyString()}
+	hd.handle = func(emu *Emulator) {
+		hdl_csi_decrqcra(emu, params)
+	}
+
+	p.setState(InputState_Normal)
+	return hd
+}
+
 // Shift Left
 func (p *Parser) handle_ecma48_SL() (hd *Handler) {
 	arg := p.getPs(0, 1)
@@ -1822,6 +1999,9 @@ func (p *Parser) ProcessInput(chs ...rune) (hd *Handler) {
 		case 'P':
 			p.argBuf.Reset()
 			p.setState(InputState_DCS)
+		case '_':
+			p.argBuf.Reset()
+			p.setState(InputState_APC)
 		case 'c':
 			hd = p.handle_RIS()
 		case '6':
@@ -2004,6 +2184,10 @@ func (p *Parser) ProcessInput(chs ...rune) (hd *Handler) {
 			p.setState(InputState_CSI_LT)
 		case '=':
 			p.setState(InputState_CSI_Equal)
+		case '$':
+			p.setState(InputState_CSI_Dollar)
+		case '*':
+			p.setState(InputState_CSI_Star)
 		case '\x07': // BEL is ignored \a in c++
 		case '\x08': // BS is \b
 			// undo last character in CSI sequence:
@@ -2034,6 +2218,32 @@ func (p *Parser) ProcessInput(chs ...rune) (hd *Handler) {
 		default:
 			p.unhandledInput()
 		}
+	case InputState_CSI_Dollar:
+		switch ch {
+		case 'v':
+			hd = p.handle_DECCRA()
+		case 'x':
+			hd = p.handle_DECFRA()
+		case 'z':
+			hd = p.handle_DECERA()
+		case '{':
+			hd = p.handle_DECSERA()
+		case 'r':
+			hd = p.handle_DECCARA()
+		case 't':
+			hd = p.handle_DECRARA()
+		default:
+			p.unhandledInput()
+		}
+	case InputState_CSI_Star:
+		switch ch {
+		case 'x':
+			hd = p.handle_DECSACE()
+		case 'y':
+			hd = p.handle_DECRQCRA()
+		default:
+			p.unhandledInput()
+		}
 	case InputState_CSI_Bang:
 		switch ch {
 		case 'p':
@@ -2127,7 +2337,7 @@ func (p *Parser) ProcessInput(chs ...rune) (hd *Handler) {
 		case '\x1B':
 			p.setState(InputState_DCS_Esc)
 		default:
-			if p.argBuf.Len() < 4095 {
+			if p.argBuf.Len() < 4095 || (p.argBuf.Len() < imageMaxSize && isSixel(p.argBuf.String())) {
 				p.argBuf.WriteRune(ch)
 			} else {
 				util.Logger.Error("OSC argument string overflow (>4095)", "argBuf", p.argBuf.String()[:64])
@@ -2144,6 +2354,28 @@ func (p *Parser) ProcessInput(chs ...rune) (hd *Handler) {
 			p.argBuf.WriteRune(ch)
 			p.setState(InputState_DCS)
 		}
+	case InputState_APC:
+		switch ch {
+		case '\x1B':
+			p.setState(InputState_APC_Esc)
+		default:
+			if p.argBuf.Len() < 4095 || (p.argBuf.Len() < imageMaxSize && strings.HasPrefix(p.argBuf.String(), "G")) {
+				p.argBuf.WriteRune(ch)
+			} else {
+				util.Logger.Error("APC argument string overflow (>4095)", "argBuf", p.argBuf.String()[:64])
+				p.setState(InputState_Normal)
+			}
+		}
+	case InputState_APC_Esc:
+		switch ch {
+		case '\\':
+			hd = p.handle_APC()
+			p.argBuf.Reset()
+		default:
+			p.argBuf.WriteRune('\x1B')
+			p.argBuf.WriteRune(ch)
+			p.setState(InputState_APC)
+		}
 	case InputState_OSC:
 		switch ch {
 		case '\x07': // final byte = BEL
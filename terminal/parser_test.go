@@ -1299,7 +1299,7 @@ func TestHandle_BEL(t *testing.T) {
 	if len(hds) == 0 {
 		t.Errorf("BEL got nil for seq=%q\n", seq)
 	}
-	bellCount := emu.getBellCount()
+	bellCount := emu.GetBellCount()
 	if bellCount == 0 || hds[0].id != C0_BEL {
 		t.Errorf("BEL expect %d, got %d\n", 1, bellCount)
 		t.Errorf("BEL expect %s, got %s\n", strHandlerID[C0_BEL], strHandlerID[hds[0].id])
@@ -2966,6 +2966,226 @@ func TestHandle_DECIC_DECDC(t *testing.T) {
 	}
 }
 
+// return the contents of each row, the second cell of wide grapheme is skipped.
+func rectRows(emu *Emulator) []string {
+	rows := make([]string, emu.nRows)
+	for y := 0; y < emu.nRows; y++ {
+		var b strings.Builder
+		for x := 0; x < emu.nCols; x++ {
+			c := emu.cf.getCell(y, x)
+			if c.dwidthCont {
+				continue
+			}
+			if c.Empty() {
+				b.WriteString(" ")
+			} else {
+				b.WriteString(c.contents)
+			}
+		}
+		rows[y] = b.String()
+	}
+	return rows
+}
+
+func TestHandle_DECCRA_DECFRA_DECERA_DECSERA(t *testing.T) {
+	tc := []struct {
+		name string
+		seq  string
+		hdID int
+		rows []string
+	}{
+		{"copy area", "\x1B[1;1;2;2;1;3;3$v", CSI_DECCRA,
+			[]string{"ABCDEFGH", "IJKLMNOP", "QRABUVWX", "YZIJCDEF"}},
+		{"copy overlap area", "\x1B[1;1;1;4;1;1;3$v", CSI_DECCRA,
+			[]string{"ABABCDGH", "IJKLMNOP", "QRSTUVWX", "YZABCDEF"}},
+		{"copy clipped area", "\x1B[1;1;2;4;1;4;7$v", CSI_DECCRA,
+			[]string{"ABCDEFGH", "IJKLMNOP", "QRSTUVWX", "YZABCDAB"}},
+		{"fill area", "\x1B[42;2;2;3;3$x", CSI_DECFRA,
+			[]string{"ABCDEFGH", "I**LMNOP", "Q**TUVWX", "YZABCDEF"}},
+		{"fill invalid char", "\x1B[10;2;2;3;3$x", CSI_DECFRA,
+			[]string{"ABCDEFGH", "IJKLMNOP", "QRSTUVWX", "YZABCDEF"}},
+		{"erase area", "\x1B[1;7;4$z", CSI_DECERA,
+			[]string{"ABCDEF  ", "IJKLMN  ", "QRSTUV  ", "YZABCD  "}},
+		{"erase empty area", "\x1B[3;3;2;2$z", CSI_DECERA,
+			[]string{"ABCDEFGH", "IJKLMNOP", "QRSTUVWX", "YZABCDEF"}},
+		{"selective erase", "\x1B[$\x7B", CSI_DECSERA,
+			[]string{"        ", "        ", "        ", "        "}},
+	}
+
+	p := NewParser()
+	for _, v := range tc {
+		emu := NewEmulator3(8, 4, 4)
+		fillCells(emu.cf)
+
+		hds := make([]*Handler, 0, 16)
+		hds = p.processStream(v.seq, hds)
+		if len(hds) != 1 || hds[0].id != v.hdID {
+			t.Errorf("%s: seq=%q expect %s, got %d handlers\n", v.name, v.seq, strHandlerID[v.hdID], len(hds))
+			continue
+		}
+		hds[0].handle(emu)
+
+		got := rectRows(emu)
+		if !reflect.DeepEqual(got, v.rows) {
+			t.Errorf("%s: seq=%q expect\n%q, got\n%q\n", v.name, v.seq, v.rows, got)
+		}
+	}
+}
+
+func TestHandle_RectArea_OriginMode(t *testing.T) {
+	emu := NewEmulator3(8, 4, 4)
+	fillCells(emu.cf)
+
+	// the area is relative to and clipped by the margins
+	emu.HandleStream("\x1B[2;3r\x1B[?69h\x1B[2;7s\x1B[?6h\x1B[1;1;9;9$z")
+	want := []string{"ABCDEFGH", "I      P", "Q      X", "YZABCDEF"}
+	if got := rectRows(emu); !reflect.DeepEqual(got, want) {
+		t.Errorf("origin mode expect\n%q, got\n%q\n", want, got)
+	}
+
+	// the wide grapheme broken by area is replaced with blank
+	emu.resetTerminal()
+	emu.HandleStream("中文字\x1B[1;2;1;2$z")
+	want = []string{"  文字  ", "        ", "        ", "        "}
+	if got := rectRows(emu); !reflect.DeepEqual(got, want) {
+		t.Errorf("wide grapheme expect\n%q, got\n%q\n", want, got)
+	}
+}
+
+func TestHandle_DECCARA_DECRARA(t *testing.T) {
+	tc := []struct {
+		name string
+		seq  string
+		on   []Point // cells with bold on
+		off  []Point // cells with bold off
+	}{
+		{"stream extent", "\x1B[2;3;3;4;1$r",
+			[]Point{{x: 2, y: 1}, {x: 7, y: 1}, {x: 0, y: 2}, {x: 3, y: 2}},
+			[]Point{{x: 1, y: 1}, {x: 4, y: 2}, {x: 2, y: 0}, {x: 2, y: 3}}},
+		{"rectangle extent", "\x1B[2*x\x1B[2;3;3;4;1$r",
+			[]Point{{x: 2, y: 1}, {x: 3, y: 1}, {x: 2, y: 2}, {x: 3, y: 2}},
+			[]Point{{x: 4, y: 1}, {x: 0, y: 2}, {x: 1, y: 1}}},
+		{"attributes off", "\x1B[1;1;4;8;1$r\x1B[1;1;1;1;0$r",
+			[]Point{{x: 1, y: 0}, {x: 7, y: 3}},
+			[]Point{{x: 0, y: 0}}},
+		{"reverse", "\x1B[2*x\x1B[1;1;1;2;1$r\x1B[1;1;1;3;1$t",
+			[]Point{{x: 2, y: 0}},
+			[]Point{{x: 0, y: 0}, {x: 1, y: 0}}},
+		{"reverse all", "\x1B[1;1;1;1$t",
+			[]Point{{x: 0, y: 0}},
+			[]Point{{x: 1, y: 0}}},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(8, 4, 4)
+		fillCells(emu.cf)
+		emu.HandleStream(v.seq)
+
+		for _, pt := range v.on {
+			if !emu.cf.getCell(pt.y, pt.x).renditions.bold {
+				t.Errorf("%s: expect bold on at %v\n", v.name, pt)
+			}
+		}
+		for _, pt := range v.off {
+			if emu.cf.getCell(pt.y, pt.x).renditions.bold {
+				t.Errorf("%s: expect bold off at %v\n", v.name, pt)
+			}
+		}
+	}
+
+	// reverse all attributes
+	emu := NewEmulator3(8, 4, 4)
+	emu.HandleStream("\x1B[7mA\x1B[m\x1B[1;1;1;1;0$t")
+	r := emu.cf.getCell(0, 0).renditions
+	if !r.bold || !r.underline || !r.blink || r.inverse || !r.invisible {
+		t.Errorf("reverse all expect bold, underline, blink, invisible, got %+v\n", r)
+	}
+
+	// DECSACE is reset by RIS
+	emu.HandleStream("\x1B[2*x")
+	emu.resetTerminal()
+	if emu.rectExtent {
+		t.Errorf("RIS expect stream extent, got rectangle\n")
+	}
+}
+
+func TestHandle_DECRQCRA(t *testing.T) {
+	tc := []struct {
+		name string
+		seq  string
+		resp string
+	}{
+		{"blank cell", "\x1B[5;1;1;1;1;1*y", "\x1BP5!~FFE0\x1B\\"},
+		{"bold cell", "\x1B[1mA\x1B[m\x1B[1;1;1;1;1;1*y", "\x1BP1!~FF3F\x1B\\"},
+		{"two cells", "AB\x1B[2;1;1;1;1;2*y", "\x1BP2!~FF7D\x1B\\"},
+		{"empty area", "\x1B[3;1;2;2;1;1*y", "\x1BP3!~0000\x1B\\"},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(8, 4, 4)
+		_, diff := emu.HandleStream(v.seq)
+
+		if got := emu.ReadOctetsToHost(); got != v.resp {
+			t.Errorf("%s: expect %q, got %q\n", v.name, v.resp, got)
+		}
+		if strings.Contains(diff, "*y") {
+			t.Errorf("%s: expect the request excluded from diff, got %q\n", v.name, diff)
+		}
+	}
+}
+
+func TestHandle_RectArea_Replicate(t *testing.T) {
+	tc := []struct {
+		name string
+		init string
+		seq  string
+	}{
+		{"copy", "ABCD\r\nEFGH", "\x1B[1;1;2;2;3;3$v"},
+		{"fill with renditions", "ABCD", "\x1B[1;31m\x1B[35;1;2;2;3$x"},
+		{"erase wide grapheme", "中文字", "\x1B[1;2;1;2$z"},
+		{"selective erase", "\x1B[4mABCD", "\x1B[1;2;1;3${"},
+		{"change attributes", "ABCD\r\nEFGH", "\x1B[1;3;2;2;1;7$r"},
+		{"reverse attributes", "ABCD", "\x1B[2*x\x1B[1;1;1;4;4$t"},
+		{"origin and margins", "ABCDEFGH\r\nIJKL", "\x1B[2;3r\x1B[?69h\x1B[2;7s\x1B[?6h\x1B[2;2H\x1B[1;1;1;2$zX"},
+		{"insert mode", "ABCDEF\x1B[1;3H\x1B[4h", "\x1B[1;1;1;1$zX"},
+		{"pending wrap", "ABCDEFGH", "\x1B[1;1;1;2$zX"},
+	}
+
+	for _, v := range tc {
+		newE := NewEmulator3(8, 4, 4)
+		oldE := NewEmulator3(8, 4, 4)
+		newE.HandleStream(v.init)
+		oldE.HandleStream(v.init)
+
+		// the client doesn't need to support the rectangular area operations
+		_, diff := newE.HandleStream(v.seq)
+		if strings.Contains(diff, "$") {
+			t.Errorf("%s: expect the operation replaced by repaint, got %q\n", v.name, diff)
+		}
+		oldE.HandleStream(diff)
+
+		for y := 0; y < newE.nRows; y++ {
+			for x := 0; x < newE.nCols; x++ {
+				c1, c2 := newE.cf.getCell(y, x), oldE.cf.getCell(y, x)
+				if c1.contents != c2.contents || c1.renditions != c2.renditions ||
+					c1.dwidth != c2.dwidth || c1.dwidthCont != c2.dwidthCont {
+					t.Errorf("%s: (%d,%d) expect %q %v, got %q %v\n", v.name, y, x,
+						c1.contents, c1.renditions, c2.contents, c2.renditions)
+				}
+			}
+		}
+		if newE.posX != oldE.posX || newE.posY != oldE.posY || newE.lastCol != oldE.lastCol {
+			t.Errorf("%s: cursor expect (%d,%d,%t), got (%d,%d,%t)\n", v.name,
+				newE.posY, newE.posX, newE.lastCol, oldE.posY, oldE.posX, oldE.lastCol)
+		}
+		if newE.attrs.renditions != oldE.attrs.renditions || newE.insertMode != oldE.insertMode ||
+			newE.originMode != oldE.originMode || newE.horizMarginMode != oldE.horizMarginMode ||
+			newE.hMargin != oldE.hMargin || newE.nColsEff != oldE.nColsEff {
+			t.Errorf("%s: expect the modes and renditions restored\n", v.name)
+		}
+	}
+}
+
 func TestHandle_DECALN_RIS(t *testing.T) {
 	tc := []struct {
 		name  string
@@ -4297,7 +4517,7 @@ func TestHandle_OSC_Abort(t *testing.T) {
 		// {"OSC malform 1         ", "\x1B]ada\x1B\\", "OSC: no ';' exist"},
 		{"OSC malform 2         ", "\x1B]7fy;ada\x1B\\", "OSC: illegal Ps parameter"},
 		{"OSC Ps overflow: >120 ", "\x1B]121;home\x1B\\", "OSC: malformed command string"},
-		{"OSC malform 3         ", "\x1B]7;ada\x1B\\", "unhandled OSC"},
+		{"OSC malform 3         ", "\x1B]6;ada\x1B\\", "unhandled OSC"},
 	}
 	p := NewParser()
 	var place strings.Builder
@@ -4405,6 +4625,44 @@ func TestHandle_OSC_52(t *testing.T) {
 	}
 }
 
+func TestHandle_OSC_52_Forward(t *testing.T) {
+	tc := []struct {
+		label  string
+		seq    string
+		read   bool
+		reply  string
+		expect []Clipboard
+	}{
+		{"set selection", "\x1B]52;c;YXByaWxzaAo=\x1B\\", false, "", []Clipboard{{"c", "YXByaWxzaAo="}}},
+		{"clear selection", "\x1B]52;p;x\x1B\\", false, "", []Clipboard{{"p", "x"}}},
+		{
+			"query from cache", "\x1B]52;c;YQ==\x1B\\\x1B]52;c;?\x1B\\", false,
+			"\x1B]52;c;YQ==\x1B\\", []Clipboard{{"c", "YQ=="}},
+		},
+		{"query forwarded", "\x1B]52;c;?\x1B\\", true, "", []Clipboard{{"c", "?"}}},
+	}
+
+	p := NewParser()
+	for _, v := range tc {
+		emu := NewEmulator3(8, 4, 0)
+		if v.read {
+			emu.caps[OSC_52] = "read"
+		}
+		hds := make([]*Handler, 0, 16)
+		hds = p.processStream(v.seq, hds)
+		for _, hd := range hds {
+			hd.handle(emu)
+		}
+
+		if got := emu.terminalToHost.String(); got != v.reply {
+			t.Errorf("%s expect reply %q, got %q\n", v.label, v.reply, got)
+		}
+		if got := emu.TakeClipboards(); !reflect.DeepEqual(got, v.expect) {
+			t.Errorf("%s expect clipboard %v, got %v\n", v.label, v.expect, got)
+		}
+	}
+}
+
 func TestHandle_OSC_52_abort(t *testing.T) {
 	tc := []struct {
 		name    string
@@ -4700,11 +4958,11 @@ func TestHandle_OSC_8(t *testing.T) {
 		hdIDs []int
 		index int
 	}{
-		{"open link", "\x1B]8;;http://go.dev/\x1B\\", []int{OSC_8}, 1},
+		{"open link", "\x1B]8;;http://go.dev/\x1B\\", []int{OSC_8}, hashLink("", "http://go.dev/")},
 		{"close link", "\x1B]8;;\x1B\\", []int{OSC_8}, 0},
-		{"link with id", "\x1B]8;id=9;http://example.com\x1B\\", []int{OSC_8}, 1},
+		{"link with id", "\x1B]8;id=9;http://example.com\x1B\\", []int{OSC_8}, hashLink("9", "http://example.com")},
 		{"malform id", "\x1B]8;id9;http://example.com\x1B\\", []int{OSC_8}, -1},
-		{"empty id", "\x1B]8;id=;http://example.com\x1B\\", []int{OSC_8}, 1},
+		{"empty id", "\x1B]8;id=;http://example.com\x1B\\", []int{OSC_8}, hashLink("", "http://example.com")},
 		{"unsupported parameters", "\x1B]8;id=xyz123:foo=bar:baz=quux;http://example.com\x1B\\", []int{OSC_8}, -1},
 		{"invalid parameters", "\x1B]8;;;http://example.com\x1B\\", []int{OSC_8}, -1},
 	}
@@ -5259,6 +5517,52 @@ func TestHandle_DECRQM(t *testing.T) {
 	}
 }
 
+func TestHandle_SyncOutput(t *testing.T) {
+	tc := []struct {
+		label string
+		seq   string
+		on    bool
+		ends  int
+	}{
+		{"begin update", "\x1B[?2026hhello", true, 0},
+		{"finish update", "\x1B[?2026hhello\x1B[?2026l", false, 1},
+		{"finish and begin update", "\x1B[?2026ha\x1B[?2026l\x1B[?2026hb", true, 1},
+		{"reset without update", "\x1B[?2026l\x1B[?2026l", false, 0},
+		{"RIS finish update", "\x1B[?2026hhello\x1Bc", false, 1},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 0)
+		emu.HandleStream(v.seq)
+		if on, ends := emu.GetSyncOutput(); on != v.on || ends != v.ends {
+			t.Errorf("%s expect %t %d, got %t %d\n", v.label, v.on, v.ends, on, ends)
+		}
+	}
+}
+
+func TestSyncOutputSupported(t *testing.T) {
+	tc := []struct {
+		label  string
+		reply  string
+		expect bool
+	}{
+		{"no reply", "", false},
+		{"set", "\x1B[?2026;1$y", true},
+		{"reset", "\x1B[?2026;2$y", true},
+		{"permanently set", "\x1B[?2026;3$y", true},
+		{"permanently reset", "\x1B[?2026;4$y", false},
+		{"not recognized", "\x1B[?2026;0$y", false},
+		{"other mode", "\x1B[?2004;1$y", false},
+	}
+
+	for _, v := range tc {
+		caps := map[int]string{CSI_DECRQM: v.reply}
+		if got := SyncOutputSupported(caps); got != v.expect {
+			t.Errorf("%s expect %t, got %t\n", v.label, v.expect, got)
+		}
+	}
+}
+
 func TestHandle_MouseTrack(t *testing.T) {
 	tc := []struct {
 		label string
@@ -5615,3 +5919,130 @@ func TestProcessStream_Empty(t *testing.T) {
 		t.Errorf("ProcessStream expect empyt handlers, got %v\n", hds)
 	}
 }
+
+func TestHandle_OSC_133(t *testing.T) {
+	tc := []struct {
+		label  string
+		seq    string
+		row    int
+		mark   uint8
+		status uint8
+	}{
+		{"prompt start", "\x1B]133;A\x1B\\", 0, markPromptStart, 0},
+		{"command start", "\x1B]133;A\a$ \x1B]133;B\a", 0, markPromptStart | markCommandStart, 0},
+		{"output start", "$ ls\r\n\x1B]133;C\a", 1, markOutputStart, 0},
+		{"command end with status", "\x1B]133;D;2;aid=7\a", 0, markCommandEnd, 2},
+		{"command end without status", "\x1B]133;D\a", 0, markCommandEnd, 0},
+		{"printing keeps mark", "\x1B]133;A\aprompt", 0, markPromptStart, 0},
+		{"erasing clears mark", "\x1B]133;D;1\aprompt\r\x1B[K", 0, 0, 0},
+		{"unknown mark", "\x1B]133;Z\a", 0, 0, 0},
+	}
+
+	p := NewParser()
+	emu := NewEmulator3(80, 40, 5)
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+
+	for _, v := range tc {
+		emu.resetTerminal()
+
+		t.Run(v.label, func(t *testing.T) {
+			hds := make([]*Handler, 0, 16)
+			hds = p.processStream(v.seq, hds)
+			for _, hd := range hds {
+				hd.handle(emu)
+			}
+
+			cell := emu.cf.getCell(v.row, 0)
+			if cell.mark != v.mark || cell.status != v.status {
+				t.Errorf("%s expect mark=%04b status=%d, got mark=%04b status=%d\n",
+					v.label, v.mark, v.status, cell.mark, cell.status)
+			}
+		})
+	}
+}
+
+func TestHandle_OSC_7(t *testing.T) {
+	tc := []struct {
+		label string
+		seq   string
+		host  string
+		path  string
+	}{
+		{"with host", "\x1B]7;file://ubuntu/home/ide\x1B\\", "ubuntu", "/home/ide"},
+		{"without host", "\x1B]7;file:///tmp\a", "", "/tmp"},
+		{"escaped path", "\x1B]7;file://ubuntu/tmp/a%20b\a", "ubuntu", "/tmp/a b"},
+		{"not file url", "\x1B]7;http://ubuntu/home\a", "ubuntu", "/tmp/a b"},
+		{"relative path", "\x1B]7;tmp\a", "ubuntu", "/tmp/a b"},
+		{"clear", "\x1B]7;\a", "", ""},
+	}
+
+	p := NewParser()
+	emu := NewEmulator3(80, 40, 5)
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+
+	// the invalid report keeps the previous working directory
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			hds := make([]*Handler, 0, 16)
+			hds = p.processStream(v.seq, hds)
+			if len(hds) == 0 || hds[0].id != OSC_7 {
+				t.Fatalf("%s expect OSC_7 handler, got %v\n", v.label, hds)
+			}
+			for _, hd := range hds {
+				hd.handle(emu)
+			}
+
+			host, path := emu.GetWorkingDir()
+			if host != v.host || path != v.path {
+				t.Errorf("%s expect host=%q path=%q, got host=%q path=%q\n", v.label, v.host, v.path, host, path)
+			}
+		})
+	}
+}
+
+func TestHandle_OSC_Notify(t *testing.T) {
+	tc := []struct {
+		label  string
+		seq    string
+		expect []Notification
+	}{
+		{"OSC 9", "\x1B]9;build done\x1B\\", []Notification{{Body: "build done"}}},
+		{"OSC 9 progress", "\x1B]9;4;1;50\a", nil},
+		{"OSC 777", "\x1B]777;notify;make;exit 2; see log\a", []Notification{{Title: "make", Body: "exit 2; see log"}}},
+		{"OSC 777 unsupported", "\x1B]777;preexec\a", nil},
+		{"OSC 99 title", "\x1B]99;;done\x1B\\", []Notification{{Title: "done"}}},
+		{
+			"OSC 99 chunks",
+			"\x1B]99;i=1:d=0;make\x1B\\\x1B]99;i=1:d=0:p=body;exit \x1B\\\x1B]99;i=1:p=body:e=1;Mg==\x1B\\",
+			[]Notification{{Title: "make", Body: "exit 2"}},
+		},
+		{"OSC 99 icon", "\x1B]99;i=2:p=icon;abc\x1B\\", nil},
+		{"OSC 99 bad base64", "\x1B]99;e=1;%%%\x1B\\", nil},
+	}
+
+	emu := NewEmulator3(80, 40, 5)
+	util.Logger.CreateLogger(io.Discard, true, slog.LevelDebug)
+
+	for _, v := range tc {
+		t.Run(v.label, func(t *testing.T) {
+			_, diff := emu.HandleStream(v.seq)
+			if diff != "" {
+				t.Errorf("%s expect empty diff, got %q\n", v.label, diff)
+			}
+
+			got := emu.TakeNotifications()
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("%s expect %v, got %v\n", v.label, v.expect, got)
+			}
+		})
+	}
+
+	// the oldest notification is dropped
+	for i := 0; i < notificationMax+2; i++ {
+		emu.HandleStream(fmt.Sprintf("\x1B]9;%d\a", i))
+	}
+	got := emu.TakeNotifications()
+	if len(got) != notificationMax || got[0].Body != "2" {
+		t.Errorf("expect %d notifications from %q, got %v\n", notificationMax, "2", got)
+	}
+}
@@ -83,10 +83,11 @@ const (
 )
 
 const (
-	MouseTrackingEnc_Default MouseTrackingEnc = 0
-	MouseTrackingEnc_UTF8    MouseTrackingEnc = 1005
-	MouseTrackingEnc_SGR     MouseTrackingEnc = 1006
-	MouseTrackingEnc_URXVT   MouseTrackingEnc = 1015
+	MouseTrackingEnc_Default    MouseTrackingEnc = 0
+	MouseTrackingEnc_UTF8       MouseTrackingEnc = 1005
+	MouseTrackingEnc_SGR        MouseTrackingEnc = 1006
+	MouseTrackingEnc_URXVT      MouseTrackingEnc = 1015
+	MouseTrackingEnc_SGR_Pixels MouseTrackingEnc = 1016
 )
 
 const (
@@ -0,0 +1,296 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ericwq/aprilsh/terminfo"
+)
+
+// the terminfo entries describe the aprilsh emulator, see AprilshTerminfo().
+const (
+	TermAprilsh       = "aprilsh"
+	TermAprilshDirect = "aprilsh-direct"
+)
+
+// capability of the aprilsh terminfo entry. the output capability lists the
+// handlers which implement it, the input capability (such as key) has none.
+type capability struct {
+	name  string
+	value any // bool, int or string
+	hdIDs []int
+}
+
+// the capabilities shared by all aprilsh entries.
+var aprilshCaps = []capability{
+	// booleans
+	{"am", true, nil},
+	{"bce", true, nil},
+	{"hs", true, nil},
+	{"km", true, nil},
+	{"mir", true, nil},
+	{"msgr", true, nil},
+	{"npc", true, nil},
+	{"xenl", true, nil},
+	{"AX", true, nil},
+	{"XT", true, nil},
+
+	// numbers
+	{"cols", 80, nil},
+	{"it", 8, nil},
+	{"lines", 24, nil},
+
+	// cursor movement
+	{"cbt", "\x1B[Z", []int{CSI_CBT}},
+	{"cr", "\r", []int{C0_CR}},
+	{"cub", "\x1B[%p1%dD", []int{CSI_CUB}},
+	{"cub1", "\b", nil},
+	{"cud", "\x1B[%p1%dB", []int{CSI_CUD}},
+	{"cud1", "\n", nil},
+	{"cuf", "\x1B[%p1%dC", []int{CSI_CUF}},
+	{"cuf1", "\x1B[C", []int{CSI_CUF}},
+	{"cup", "\x1B[%i%p1%d;%p2%dH", []int{CSI_CUP}},
+	{"cuu", "\x1B[%p1%dA", []int{CSI_CUU}},
+	{"cuu1", "\x1B[A", []int{CSI_CUU}},
+	{"home", "\x1B[H", []int{CSI_CUP}},
+	{"hpa", "\x1B[%i%p1%dG", []int{CSI_CHA}},
+	{"ht", "\t", []int{C0_HT}},
+	{"hts", "\x1BH", []int{ESC_HTS}},
+	{"ind", "\n", nil},
+	{"indn", "\x1B[%p1%dS", []int{CSI_SU}},
+	{"rc", "\x1B8", []int{ESC_DECRC}},
+	{"ri", "\x1BM", []int{ESC_RI}},
+	{"rin", "\x1B[%p1%dT", []int{CSI_SD}},
+	{"sc", "\x1B7", []int{ESC_DECSC}},
+	{"tbc", "\x1B[3g", []int{CSI_TBC}},
+	{"vpa", "\x1B[%i%p1%dd", []int{CSI_VPA}},
+	{"csr", "\x1B[%i%p1%d;%p2%dr", []int{CSI_DECSTBM}},
+	{"u6", "\x1B[%i%d;%dR", nil},
+	{"u7", "\x1B[6n", []int{CSI_DSR}},
+	{"u8", "\x1B[?%[;0123456789]c", nil},
+	{"u9", "\x1B[c", []int{CSI_priDA}},
+
+	// editing
+	{"clear", "\x1B[H\x1B[2J", []int{CSI_CUP, CSI_ED}},
+	{"dch", "\x1B[%p1%dP", []int{CSI_DCH}},
+	{"dch1", "\x1B[P", []int{CSI_DCH}},
+	{"dl", "\x1B[%p1%dM", []int{CSI_DL}},
+	{"dl1", "\x1B[M", []int{CSI_DL}},
+	{"ech", "\x1B[%p1%dX", []int{CSI_ECH}},
+	{"ed", "\x1B[J", []int{CSI_ED}},
+	{"el", "\x1B[K", []int{CSI_EL}},
+	{"el1", "\x1B[1K", []int{CSI_EL}},
+	{"ich", "\x1B[%p1%d@", []int{CSI_ICH}},
+	{"il", "\x1B[%p1%dL", []int{CSI_IL}},
+	{"il1", "\x1B[L", []int{CSI_IL}},
+	{"rep", "%p1%c\x1B[%p2%{1}%-%db", []int{Graphemes, CSI_REP}},
+	{"E3", "\x1B[3J", []int{CSI_ED}},
+
+	// renditions
+	{"blink", "\x1B[5m", []int{CSI_SGR}},
+	{"bold", "\x1B[1m", []int{CSI_SGR}},
+	{"dim", "\x1B[2m", []int{CSI_SGR}},
+	{"invis", "\x1B[8m", []int{CSI_SGR}},
+	{"rev", "\x1B[7m", []int{CSI_SGR}},
+	{"ritm", "\x1B[23m", []int{CSI_SGR}},
+	{"rmso", "\x1B[27m", []int{CSI_SGR}},
+	{"rmul", "\x1B[24m", []int{CSI_SGR}},
+	{"rmxx", "\x1B[29m", []int{CSI_SGR}},
+	{"sitm", "\x1B[3m", []int{CSI_SGR}},
+	{"smso", "\x1B[7m", []int{CSI_SGR}},
+	{"smul", "\x1B[4m", []int{CSI_SGR}},
+	{"smxx", "\x1B[9m", []int{CSI_SGR}},
+	{"sgr0", "\x1B(B\x1B[m", []int{ESC_DCS, CSI_SGR}},
+	{"sgr", "%?%p9%t\x1B(0%e\x1B(B%;\x1B[0%?%p6%t;1%;%?%p5%t;2%;%?%p2%t;4%;%?%p1%p3%|%t;7%;%?%p4%t;5%;%?%p7%t;8%;m",
+		[]int{ESC_DCS, CSI_SGR}},
+	{"op", "\x1B[39;49m", []int{CSI_SGR}},
+	{"Smulx", "\x1B[4:%p1%dm", []int{CSI_SGR}},
+	{"Setulc", "\x1B[58:2::%p1%{65536}%/%d:%p1%{256}%/%{255}%&%d:%p1%{255}%&%dm", []int{CSI_SGR}},
+
+	// character set
+	{"acsc", "``aaffggiijjkkllmmnnooppqqrrssttuuvvwwxxyyzz{{||}}~~", nil},
+	{"rmacs", "\x1B(B", []int{ESC_DCS}},
+	{"smacs", "\x1B(0", []int{ESC_DCS}},
+
+	// modes
+	{"civis", "\x1B[?25l", []int{CSI_privRM}},
+	{"cnorm", "\x1B[?12l\x1B[?25h", []int{CSI_privRM, CSI_privSM}},
+	{"cvvis", "\x1B[?12;25h", []int{CSI_privSM}},
+	{"flash", "\x1B[?5h$<100/>\x1B[?5l", nil},
+	{"rmam", "\x1B[?7l", []int{CSI_privRM}},
+	{"smam", "\x1B[?7h", []int{CSI_privSM}},
+	{"rmcup", "\x1B[?1049l\x1B[23;0;0t", []int{CSI_privRM, CSI_XTWINOPS}},
+	{"smcup", "\x1B[?1049h\x1B[22;0;0t", []int{CSI_privSM, CSI_XTWINOPS}},
+	{"rmir", "\x1B[4l", []int{CSI_RM}},
+	{"smir", "\x1B[4h", []int{CSI_SM}},
+	{"rmkx", "\x1B[?1l\x1B>", []int{CSI_privRM, ESC_DECKPNM}},
+	{"smkx", "\x1B[?1h\x1B=", []int{CSI_privSM, ESC_DECKPAM}},
+	{"rs1", "\x1Bc", []int{ESC_RIS}},
+	{"rs2", "\x1B[!p\x1B[?3;4l\x1B[4l\x1B>", []int{CSI_DECSTR, CSI_privRM, CSI_RM, ESC_DECKPNM}},
+	{"Ss", "\x1B[%p1%d q", []int{CSI_DECSCUSR}},
+	{"Se", "\x1B[2 q", []int{CSI_DECSCUSR}},
+	{"BD", "\x1B[?2004l", []int{CSI_privRM}},
+	{"BE", "\x1B[?2004h", []int{CSI_privSM}},
+	{"fd", "\x1B[?1004l", []int{CSI_privRM}},
+	{"fe", "\x1B[?1004h", []int{CSI_privSM}},
+	{"XM", "\x1B[?1006;1000%?%p1%{1}%=%th%el%;", []int{CSI_privSM}},
+	{"Sync", "\x1B[?2026%?%p1%{1}%-%tl%eh%;", []int{CSI_privSM}},
+
+	// operating system commands
+	{"tsl", "\x1B]2;", nil},
+	{"fsl", "\x07", nil},
+	{"dsl", "\x1B]2;\x07", []int{OSC_0_1_2}},
+	{"Ms", "\x1B]52;%p1%s;%p2%s\x07", []int{OSC_52}},
+	{"Cs", "\x1B]12;%p1%s\x07", []int{OSC_10_11_12_17_19}},
+	{"Cr", "\x1B]112\x07", []int{OSC_112}},
+
+	// input
+	{"kbs", "\x7F", nil},
+	{"kcbt", "\x1B[Z", nil},
+	{"kcub1", "\x1BOD", nil},
+	{"kcud1", "\x1BOB", nil},
+	{"kcuf1", "\x1BOC", nil},
+	{"kcuu1", "\x1BOA", nil},
+	{"kdch1", "\x1B[3~", nil},
+	{"kend", "\x1BOF", nil},
+	{"kent", "\x1BOM", nil},
+	{"khome", "\x1BOH", nil},
+	{"kich1", "\x1B[2~", nil},
+	{"knp", "\x1B[6~", nil},
+	{"kpp", "\x1B[5~", nil},
+	{"kf1", "\x1BOP", nil},
+	{"kf2", "\x1BOQ", nil},
+	{"kf3", "\x1BOR", nil},
+	{"kf4", "\x1BOS", nil},
+	{"kf5", "\x1B[15~", nil},
+	{"kf6", "\x1B[17~", nil},
+	{"kf7", "\x1B[18~", nil},
+	{"kf8", "\x1B[19~", nil},
+	{"kf9", "\x1B[20~", nil},
+	{"kf10", "\x1B[21~", nil},
+	{"kf11", "\x1B[23~", nil},
+	{"kf12", "\x1B[24~", nil},
+	{"kDC", "\x1B[3;2~", nil},
+	{"kEND", "\x1B[1;2F", nil},
+	{"kHOM", "\x1B[1;2H", nil},
+	{"kLFT", "\x1B[1;2D", nil},
+	{"kRIT", "\x1B[1;2C", nil},
+	{"kind", "\x1B[1;2B", nil},
+	{"kri", "\x1B[1;2A", nil},
+	{"kDN5", "\x1B[1;5B", nil},
+	{"kLFT5", "\x1B[1;5D", nil},
+	{"kRIT5", "\x1B[1;5C", nil},
+	{"kUP5", "\x1B[1;5A", nil},
+	{"kmous", "\x1B[<", nil},
+	{"kxIN", "\x1B[I", nil},
+	{"kxOUT", "\x1B[O", nil},
+	{"PS", "\x1B[200~", nil},
+	{"PE", "\x1B[201~", nil},
+	{"xm", "\x1B[<%i%p3%d;%p1%d;%p2%d;%?%p4%tM%em%;", nil},
+}
+
+// the 256 colors capabilities of aprilsh entry.
+var aprilsh256Caps = []capability{
+	{"ccc", true, nil},
+	{"colors", 256, nil},
+	{"pairs", 32767, nil},
+	{"setaf", "\x1B[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m", []int{CSI_SGR}},
+	{"setab", "\x1B[%?%p1%{8}%<%t4%p1%d%e%p1%{16}%<%t10%p1%{8}%-%d%e48;5;%p1%d%;m", []int{CSI_SGR}},
+	{"initc", "\x1B]4;%p1%d;rgb:%p2%{255}%*%{1000}%/%2.2X/%p3%{255}%*%{1000}%/%2.2X/%p4%{255}%*%{1000}%/%2.2X\x1B\\",
+		[]int{OSC_4}},
+	{"oc", "\x1B]104\x07", []int{OSC_104_110_111}},
+}
+
+// the direct colors capabilities of aprilsh-direct entry, the color number is
+// the RGB value.
+var aprilshDirectCaps = []capability{
+	{"RGB", true, nil},
+	{"colors", 0x1000000, nil},
+	{"pairs", 0x10000, nil},
+	{"setaf", "\x1B[%?%p1%{8}%<%t3%p1%d%e38:2::%p1%{65536}%/%d:%p1%{256}%/%{255}%&%d:%p1%{255}%&%d%;m", []int{CSI_SGR}},
+	{"setab", "\x1B[%?%p1%{8}%<%t4%p1%d%e48:2::%p1%{65536}%/%d:%p1%{256}%/%{255}%&%d:%p1%{255}%&%d%;m", []int{CSI_SGR}},
+}
+
+// AprilshTermName returns the aprilsh terminfo entry name for the terminal
+// which supports the number of colors, see TerminalColors().
+func AprilshTermName(colors int) string {
+	if colors >= ColorsTrue {
+		return TermAprilshDirect
+	}
+	return TermAprilsh
+}
+
+// return the capabilities of the aprilsh terminfo entry.
+func aprilshCapabilities(name string) ([]capability, error) {
+	switch name {
+	case TermAprilsh:
+		return append(aprilshCaps[:len(aprilshCaps):len(aprilshCaps)], aprilsh256Caps...), nil
+	case TermAprilshDirect:
+		return append(aprilshCaps[:len(aprilshCaps):len(aprilshCaps)], aprilshDirectCaps...), nil
+	}
+	return nil, fmt.Errorf("unknown aprilsh terminfo entry: %q", name)
+}
+
+// AprilshTerminfo returns the compiled terminfo entry of aprilsh, which
+// describes the control sequences implemented by emulator. name is
+// TermAprilsh or TermAprilshDirect.
+func AprilshTerminfo(name string) ([]byte, error) {
+	caps, err := aprilshCapabilities(name)
+	if err != nil {
+		return nil, err
+	}
+
+	bools := make(map[string]bool)
+	nums := make(map[string]int)
+	strs := make(map[string]string)
+	for _, c := range caps {
+		switch v := c.value.(type) {
+		case bool:
+			bools[c.name] = v
+		case int:
+			nums[c.name] = v
+		case string:
+			strs[c.name] = v
+		}
+	}
+
+	desc := "aprilsh terminal emulator"
+	if name == TermAprilshDirect {
+		desc = "aprilsh terminal emulator with direct color indexing"
+	}
+	return terminfo.Compile(name+"|"+desc, bools, nums, strs), nil
+}
+
+// lookup the capability of aprilsh terminfo entry for XTGETTCAP. besides the
+// capability names, the TN, Co and RGB features of xterm are recognized. the
+// RGB feature is always provided, since the emulator supports direct colors.
+func lookupAprilshCap(termName string, capName string) (string, bool) {
+	caps, _ := aprilshCapabilities(termName)
+	switch capName {
+	case "TN", "name":
+		return termName, true
+	case "Co":
+		capName = "colors"
+	case "RGB":
+		return "8/8/8", true
+	}
+
+	for _, c := range caps {
+		if c.name != capName {
+			continue
+		}
+		switch v := c.value.(type) {
+		case int:
+			return strconv.Itoa(v), true
+		case string:
+			return v, true
+		default:
+			return "", true
+		}
+	}
+	return "", false
+}
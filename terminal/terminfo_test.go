@@ -0,0 +1,259 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ericwq/aprilsh/util"
+)
+
+// instantiate the parameterized string capability, only the operations used
+// by aprilsh entries are supported.
+func tparm(s string, params ...any) string {
+	p := append(append([]any{}, params...), make([]any, 9)...)
+	var out strings.Builder
+	var stack []any
+
+	push := func(v any) { stack = append(stack, v) }
+	pop := func() any {
+		if len(stack) == 0 {
+			return 0
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	popInt := func() int {
+		v, _ := pop().(int)
+		return v
+	}
+	// skip to the position after the matching %; or %e (if els is true)
+	skip := func(i int, els bool) int {
+		level := 0
+		for ; i+1 < len(s); i++ {
+			if s[i] != '%' {
+				continue
+			}
+			i++
+			switch s[i] {
+			case '?':
+				level++
+			case ';':
+				if level == 0 {
+					return i
+				}
+				level--
+			case 'e':
+				if level == 0 && els {
+					return i
+				}
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case '%':
+			out.WriteByte('%')
+		case 'i':
+			p[0], p[1] = p[0].(int)+1, p[1].(int)+1
+		case 'p':
+			i++
+			push(p[s[i]-'1'])
+		case 'd':
+			fmt.Fprintf(&out, "%d", popInt())
+		case 'c':
+			out.WriteByte(byte(popInt()))
+		case 's':
+			fmt.Fprint(&out, pop())
+		case '2': // %2.2X
+			i += 3
+			fmt.Fprintf(&out, "%02X", popInt())
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			n, _ := strconv.Atoi(s[i+1 : i+end])
+			push(n)
+			i += end
+		case '+', '-', '*', '/', '&', '|', '<', '=':
+			b, a := popInt(), popInt()
+			switch c {
+			case '+':
+				push(a + b)
+			case '-':
+				push(a - b)
+			case '*':
+				push(a * b)
+			case '/':
+				push(a / b)
+			case '&':
+				push(a & b)
+			case '|':
+				push(a | b)
+			case '<':
+				push(boolToInt(a < b))
+			case '=':
+				push(boolToInt(a == b))
+			}
+		case 't':
+			if popInt() == 0 {
+				i = skip(i+1, true)
+			}
+		case 'e':
+			i = skip(i+1, false)
+		}
+	}
+	return out.String()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestTparm(t *testing.T) {
+	tc := []struct {
+		label  string
+		str    string
+		params []any
+		expect string
+	}{
+		{"cup", "\x1B[%i%p1%d;%p2%dH", []any{1, 2}, "\x1B[2;3H"},
+		{"setaf low", aprilsh256Caps[3].value.(string), []any{1}, "\x1B[31m"},
+		{"setaf high", aprilsh256Caps[3].value.(string), []any{9}, "\x1B[91m"},
+		{"setaf 256", aprilsh256Caps[3].value.(string), []any{200}, "\x1B[38;5;200m"},
+		{"setaf direct", aprilshDirectCaps[3].value.(string), []any{0x123456}, "\x1B[38:2::18:52:86m"},
+		{"rep", "%p1%c\x1B[%p2%{1}%-%db", []any{int('x'), 3}, "x\x1B[2b"},
+	}
+
+	for _, v := range tc {
+		if got := tparm(v.str, v.params...); got != v.expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, v.expect, got)
+		}
+	}
+}
+
+// the output capabilities must be implemented by the declared handlers.
+func TestAprilshCaps(t *testing.T) {
+	params := map[string][]any{
+		"rep":    {int('x'), 3},
+		"sgr":    {1, 1, 0, 1, 1, 1, 1, 0, 1},
+		"Ms":     {"c", "YQ=="},
+		"Cs":     {"red"},
+		"initc":  {1, 500, 600, 700},
+		"Setulc": {0x123456},
+	}
+	util.Logger.CreateLogger(io.Discard, false, util.LevelTrace)
+
+	for _, name := range []string{TermAprilsh, TermAprilshDirect} {
+		caps, _ := aprilshCapabilities(name)
+		for _, c := range caps {
+			if c.hdIDs == nil {
+				continue
+			}
+
+			args, ok := params[c.name]
+			if !ok {
+				args = []any{1, 2, 3, 4, 0, 0, 0, 0, 0}
+			}
+			seq := tparm(c.value.(string), args...)
+
+			p := NewParser()
+			var ids []int
+			for _, hd := range p.processStream(seq, nil) {
+				if len(ids) == 0 || ids[len(ids)-1] != hd.id {
+					ids = append(ids, hd.id)
+				}
+			}
+			if !slices.Equal(ids, c.hdIDs) || p.inputState != InputState_Normal {
+				t.Errorf("%s %s=%q expect %v, got %v\n", name, c.name, seq, c.hdIDs, ids)
+			}
+		}
+	}
+}
+
+func TestAprilshTerminfo(t *testing.T) {
+	tc := []struct {
+		name  string
+		magic []byte
+		err   bool
+	}{
+		{TermAprilsh, []byte{0x1A, 0x01}, false},
+		{TermAprilshDirect, []byte{0x1E, 0x02}, false},
+		{"xterm", nil, true},
+	}
+
+	for _, v := range tc {
+		data, err := AprilshTerminfo(v.name)
+		if (err != nil) != v.err {
+			t.Errorf("%s expect error %t, got %v\n", v.name, v.err, err)
+		}
+		if !v.err && (len(data) < 2 || !slices.Equal(data[:2], v.magic)) {
+			t.Errorf("%s expect magic %x, got %x\n", v.name, v.magic, data[:2])
+		}
+	}
+
+	if AprilshTermName(Colors256) != TermAprilsh || AprilshTermName(ColorsTrue) != TermAprilshDirect {
+		t.Errorf("AprilshTermName expect %s and %s\n", TermAprilsh, TermAprilshDirect)
+	}
+}
+
+func TestXtgettcapReply_Aprilsh(t *testing.T) {
+	tc := []struct {
+		label string
+		term  string
+		name  string
+		value string
+		ok    bool
+	}{
+		{"terminal name", TermAprilsh, "TN", TermAprilsh, true},
+		{"direct terminal name", TermAprilshDirect, "TN", TermAprilshDirect, true},
+		{"termcap colors", TermAprilsh, "Co", "256", true},
+		{"direct colors", TermAprilshDirect, "colors", "16777216", true},
+		{"RGB", TermAprilsh, "RGB", "8/8/8", true},
+		{"string", TermAprilsh, "cup", "\x1B[%i%p1%d;%p2%dH", true},
+		{"extended string", TermAprilsh, "Ss", "\x1B[%p1%d q", true},
+		{"boolean", TermAprilshDirect, "bce", "", true},
+		{"not supported", TermAprilsh, "setrgbf", "", false},
+		{"palette in 256 colors", TermAprilsh, "initc", "\x1B]4;%p1%d;rgb:%p2%{255}%*%{1000}%/%2.2X/" +
+			"%p3%{255}%*%{1000}%/%2.2X/%p4%{255}%*%{1000}%/%2.2X\x1B\\", true},
+		{"not in direct colors", TermAprilshDirect, "initc", "", false},
+		{"client terminal name", "xterm-256color", "TN", "xterm-256color", true},
+		{"client termcap colors", "xterm-256color", "Co", "256", true},
+		{"client unknown terminal", "badTerm", "TN", "", false},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 0)
+		emu.SetTermName(v.term)
+
+		hexName := hex.EncodeToString([]byte(v.name))
+		xtgettcapReply(emu, hexName)
+
+		expect := fmt.Sprintf("\x1BP0+r%s\x1B\\", hexName)
+		if v.ok && v.value == "" {
+			expect = fmt.Sprintf("\x1BP1+r%s\x1B\\", hexName)
+		} else if v.ok {
+			expect = fmt.Sprintf("\x1BP1+r%s=%s\x1B\\", hexName, hex.EncodeToString([]byte(v.value)))
+		}
+		if got := emu.ReadOctetsToHost(); got != expect {
+			t.Errorf("%s expect %q, got %q\n", v.label, expect, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+// WidthPolicy decides the column width of graphemes. the emulator must use the
+// same policy as the client terminal, otherwise the cursor position of them
+// diverges and the screen is corrupted.
+type WidthPolicy struct {
+	Unicode   int  // unicode version of the terminal width table, emoji is wide since unicode 9
+	Ambiguous int  // width of East Asian ambiguous characters: 1 or 2
+	EmojiVS16 bool // the emoji variation selector (U+FE0F) makes the grapheme wide
+}
+
+// the caps key of width policy. unlike the other caps keys, it's not a
+// handler ID, the value is decided by client instead of a terminal reply.
+const CAPS_WIDTH_POLICY = -1
+
+// the width policy of uniseg, which follows the latest unicode standard.
+var DefaultWidthPolicy = WidthPolicy{Unicode: 14, Ambiguous: 1, EmojiVS16: true}
+
+const (
+	emojiVS16      = 0xFE0F // emoji variation selector
+	emojiWideSince = 9      // the unicode version which makes emoji wide
+)
+
+type runeRange struct {
+	lo, hi rune
+}
+
+func inTable(table []runeRange, r rune) bool {
+	_, found := slices.BinarySearchFunc(table, r, func(rr runeRange, r rune) int {
+		switch {
+		case r < rr.lo:
+			return 1
+		case r > rr.hi:
+			return -1
+		}
+		return 0
+	})
+	return found
+}
+
+// return the policy in the form of "unicode=14,ambiguous=1,vs16=1".
+func (wp WidthPolicy) String() string {
+	vs16 := 0
+	if wp.EmojiVS16 {
+		vs16 = 1
+	}
+	return fmt.Sprintf("unicode=%d,ambiguous=%d,vs16=%d", wp.Unicode, wp.Ambiguous, vs16)
+}
+
+// ParseWidthPolicy parses the policy in the form of "unicode=9,ambiguous=2,vs16=0",
+// the missing fields keep the value of base.
+func ParseWidthPolicy(s string, base WidthPolicy) (WidthPolicy, error) {
+	wp := base
+	if s == "" {
+		return wp, nil
+	}
+
+	for _, field := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(field, "=")
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return base, fmt.Errorf("invalid width policy %q", field)
+		}
+
+		switch {
+		case key == "unicode" && n > 0:
+			wp.Unicode = n
+		case key == "ambiguous" && (n == 1 || n == 2):
+			wp.Ambiguous = n
+		case key == "vs16" && (n == 0 || n == 1):
+			wp.EmojiVS16 = n == 1
+		default:
+			return base, fmt.Errorf("invalid width policy %q", field)
+		}
+	}
+	return wp, nil
+}
+
+// return the column width of grapheme according to the policy.
+func (wp WidthPolicy) GraphemeWidth(chs []rune) int {
+	w := uniseg.StringWidth(string(chs))
+	if len(chs) == 0 || wp == DefaultWidthPolicy {
+		return w
+	}
+
+	if !wp.EmojiVS16 && w == 2 && slices.Contains(chs[1:], emojiVS16) {
+		// the variation selector is ignored by terminal
+		w = uniseg.StringWidth(string(slices.DeleteFunc(slices.Clone(chs), func(r rune) bool {
+			return r == emojiVS16
+		})))
+	}
+
+	first := chs[0]
+	switch {
+	case w == 2 && wp.Unicode < emojiWideSince && isEmojiWideSince9(first):
+		w = 1
+	case w == 1 && wp.Ambiguous == 2 && inTable(ambiguousTable, first):
+		w = 2
+	}
+	return w
+}
+
+// the emoji presentation characters and regional indicators are narrow before
+// unicode 9, except the enclosed ideographic supplement.
+func isEmojiWideSince9(r rune) bool {
+	if 0x1F200 <= r && r <= 0x1F2FF {
+		return false
+	}
+	return (0x1F1E6 <= r && r <= 0x1F1FF) || inTable(emojiPresentationTable, r)
+}
@@ -0,0 +1,95 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"testing"
+)
+
+func TestParseWidthPolicy(t *testing.T) {
+	tc := []struct {
+		label  string
+		policy string
+		expect WidthPolicy
+		err    bool
+	}{
+		{"empty", "", DefaultWidthPolicy, false},
+		{"all fields", "unicode=8,ambiguous=2,vs16=0", WidthPolicy{Unicode: 8, Ambiguous: 2}, false},
+		{"one field", "ambiguous=2", WidthPolicy{Unicode: 14, Ambiguous: 2, EmojiVS16: true}, false},
+		{"round trip", DefaultWidthPolicy.String(), DefaultWidthPolicy, false},
+		{"invalid ambiguous", "ambiguous=3", DefaultWidthPolicy, true},
+		{"invalid number", "unicode=x", DefaultWidthPolicy, true},
+		{"unknown field", "emoji=1", DefaultWidthPolicy, true},
+	}
+
+	for _, v := range tc {
+		got, err := ParseWidthPolicy(v.policy, DefaultWidthPolicy)
+		if got != v.expect || (err != nil) != v.err {
+			t.Errorf("%s expect %v %t, got %v %v\n", v.label, v.expect, v.err, got, err)
+		}
+	}
+}
+
+func TestGraphemeWidth(t *testing.T) {
+	old := WidthPolicy{Unicode: 8, Ambiguous: 1, EmojiVS16: false}
+	cjk := WidthPolicy{Unicode: 14, Ambiguous: 2, EmojiVS16: true}
+
+	tc := []struct {
+		label  string
+		policy WidthPolicy
+		chs    string
+		expect int
+	}{
+		{"ascii", DefaultWidthPolicy, "a", 1},
+		{"chinese", DefaultWidthPolicy, "中", 2},
+		{"ambiguous", DefaultWidthPolicy, "①", 1},
+		{"ambiguous wide", cjk, "①", 2},
+		{"box drawing wide", cjk, "─", 2},
+		{"ascii with cjk policy", cjk, "a", 1},
+		{"combining with cjk policy", cjk, "\u0301", 0},
+		{"emoji", DefaultWidthPolicy, "\U0001F600", 2},
+		{"emoji before unicode 9", old, "\U0001F600", 1},
+		{"flag before unicode 9", old, "\U0001F1E8\U0001F1F3", 1},
+		{"enclosed ideograph before unicode 9", old, "\U0001F250", 2},
+		{"chinese before unicode 9", old, "中", 2},
+		{"vs16", DefaultWidthPolicy, "❤️", 2},
+		{"vs16 ignored", old, "❤️", 1},
+		{"text presentation", DefaultWidthPolicy, "❤", 1},
+	}
+
+	for _, v := range tc {
+		if got := v.policy.GraphemeWidth([]rune(v.chs)); got != v.expect {
+			t.Errorf("%s %q expect %d, got %d\n", v.label, v.chs, v.expect, got)
+		}
+	}
+}
+
+func TestEmulatorWidthPolicy(t *testing.T) {
+	tc := []struct {
+		label  string
+		policy string
+		seq    string
+		posX   int
+	}{
+		{"default", "", "①a", 2},
+		{"ambiguous wide", "ambiguous=2", "①a", 3},
+		{"old emoji", "unicode=8", "\U0001F600a", 2},
+		{"vs16 ignored", "vs16=0", "❤️a", 2},
+	}
+
+	for _, v := range tc {
+		emu := NewEmulator3(80, 40, 40)
+		emu.SetTerminalCaps(map[int]string{CAPS_WIDTH_POLICY: v.policy})
+		emu.HandleStream(v.seq)
+		if emu.posX != v.posX {
+			t.Errorf("%s expect posX %d, got %d\n", v.label, v.posX, emu.posX)
+		}
+
+		// the policy is kept by clone
+		if clone := emu.Clone(); clone.width != emu.width {
+			t.Errorf("%s clone expect %v, got %v\n", v.label, emu.width, clone.width)
+		}
+	}
+}
@@ -5,19 +5,11 @@
 package terminfo
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"strconv"
-	"strings"
 	"sync"
 )
 
-// https://github.com/xo/terminfo
-// TODO: check the above implementation, dynamic.go is just a simple implementation.
-
 type terminfo struct {
 	bools   map[string]bool
 	nums    map[string]int
@@ -39,75 +31,13 @@ func (tc *terminfo) getstr(s string) string {
 	return (tc.strs[s])
 }
 
-const (
-	none = iota
-	control
-	escaped
-)
-
+// load the compiled terminfo entry of termName.
 func (tc *terminfo) setupterm(termName string) error {
-	cmd := exec.Command("infocmp", "-1", termName)
-	output := &bytes.Buffer{}
-	cmd.Stdout = output
-	cmd.Stderr = output
-
-	tc.strs = make(map[string]string)
-	tc.bools = make(map[string]bool)
-	tc.nums = make(map[string]int)
-
-	if err := cmd.Run(); err != nil {
-		// this translaet the "exit status 1" into "infocmp: couldn't open terminfo file (null)."
-		return errors.New(strings.TrimSpace(output.String()))
-	}
-
-	// Now parse the output.
-	// We get comment lines (starting with "#"), followed by
-	// a header line that looks like "<name>|<alias>|...|<desc>"
-	// then capabilities, one per line, starting with a tab and ending
-	// with a comma and newline.
-	lines := strings.Split(output.String(), "\n")
-	for len(lines) > 0 && strings.HasPrefix(lines[0], "#") {
-		lines = lines[1:]
-	}
-
-	// Ditch trailing empty last line
-	if lines[len(lines)-1] == "" {
-		lines = lines[:len(lines)-1]
-	}
-	header := lines[0]
-	header = strings.TrimSuffix(header, ",")
-	// if strings.HasSuffix(header, ",") {
-	// 	header = header[:len(header)-1]
-	// }
-	names := strings.Split(header, "|")
-	tc.name = names[0]
-	names = names[1:]
-	if len(names) > 0 {
-		tc.desc = names[len(names)-1]
-		names = names[:len(names)-1]
-	}
-	tc.aliases = names
-	for _, val := range lines[1:] {
-		if (!strings.HasPrefix(val, "\t")) ||
-			(!strings.HasSuffix(val, ",")) {
-			return (errors.New("malformed infocmp: " + val))
-		}
-
-		val = val[1:]
-		val = val[:len(val)-1]
-
-		if k := strings.SplitN(val, "=", 2); len(k) == 2 {
-			tc.strs[k[0]] = unescape(k[1])
-		} else if k := strings.SplitN(val, "#", 2); len(k) == 2 {
-			u, err := strconv.ParseUint(k[1], 0, 0)
-			if err != nil {
-				return (err)
-			}
-			tc.nums[k[0]] = int(u)
-		} else {
-			tc.bools[val] = true
-		}
+	entry, err := loadTerminfo(termName)
+	if err != nil {
+		return err
 	}
+	*tc = *entry
 	return nil
 }
 
@@ -116,75 +46,52 @@ var cache struct {
 	sync.Once
 }
 
-func unescape(s string) string {
-	// Various escapes are in \x format.  Control codes are
-	// encoded as ^M (carat followed by ASCII equivalent).
-	// escapes are: \e, \E - escape
-	//  \0 NULL, \n \l \r \t \b \f \s for equivalent C escape.
-	buf := &bytes.Buffer{}
-	esc := none
+func Lookup(capName string) (string, bool) {
+	if cache.pTerminfo == nil {
+		cache.Do(dynamicInit)
+	}
 
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		switch esc {
-		case none:
-			switch c {
-			case '\\':
-				esc = escaped
-			case '^':
-				esc = control
-			default:
-				buf.WriteByte(c)
-			}
-		case control:
-			buf.WriteByte(c ^ 1<<6)
-			esc = none
-		case escaped:
-			switch c {
-			case 'E', 'e':
-				buf.WriteByte(0x1b)
-			case '0', '1', '2', '3', '4', '5', '6', '7':
-				if i+2 < len(s) && s[i+1] >= '0' && s[i+1] <= '7' && s[i+2] >= '0' && s[i+2] <= '7' {
-					buf.WriteByte(((c - '0') * 64) + ((s[i+1] - '0') * 8) + (s[i+2] - '0'))
-					i = i + 2
-				} else if c == '0' {
-					buf.WriteByte(0)
-				}
-			case 'n':
-				buf.WriteByte('\n')
-			case 'r':
-				buf.WriteByte('\r')
-			case 't':
-				buf.WriteByte('\t')
-			case 'b':
-				buf.WriteByte('\b')
-			case 'f':
-				buf.WriteByte('\f')
-			case 's':
-				buf.WriteByte(' ')
-			default:
-				buf.WriteByte(c)
-			}
-			esc = none
+	return cache.pTerminfo.lookup(capName)
+}
+
+// the terminfo entries loaded by LookupTerm, nil means not found.
+var entries struct {
+	m map[string]*terminfo
+	sync.Mutex
+}
+
+// LookupTerm is like Lookup, the capability is looked up in the terminfo
+// entry of termName, such as the TERM of client, instead of TERM.
+func LookupTerm(termName string, capName string) (string, bool) {
+	entries.Lock()
+	tc, ok := entries.m[termName]
+	if !ok {
+		if tc, _ = loadTerminfo(termName); tc != nil {
+			tc.addSpecial()
+		}
+		if entries.m == nil {
+			entries.m = make(map[string]*terminfo)
 		}
+		entries.m[termName] = tc
 	}
-	return (buf.String())
-}
+	entries.Unlock()
 
-func Lookup(capName string) (string, bool) {
-	if cache.pTerminfo == nil {
-		cache.Do(dynamicInit)
+	if tc == nil {
+		return "", false
 	}
+	return tc.lookup(capName)
+}
 
-	if v, ok := cache.pTerminfo.nums[capName]; ok {
+func (tc *terminfo) lookup(capName string) (string, bool) {
+	if v, ok := tc.nums[capName]; ok {
 		return fmt.Sprintf("%d", v), true
 	}
 
-	if v, ok := cache.pTerminfo.strs[capName]; ok {
+	if v, ok := tc.strs[capName]; ok {
 		return v, true
 	}
 
-	if _, ok := cache.pTerminfo.bools[capName]; ok {
+	if _, ok := tc.bools[capName]; ok {
 		return "", true
 	}
 
@@ -208,7 +115,11 @@ func dynamicInit() {
 		cache.pTerminfo = nil
 		panic(err)
 	}
+	cache.pTerminfo.addSpecial()
+}
 
+// add the special features of XTGETTCAP.
+func (tc *terminfo) addSpecial() {
 	/*
 		https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Device-Control-functions
 
@@ -252,15 +163,15 @@ func dynamicInit() {
 		and blue components as a slash-separated list of decimal
 		integers.
 	*/
-	cache.pTerminfo.nums["Co"] = cache.pTerminfo.getnum("colors")
-	cache.pTerminfo.strs["TN"] = cache.pTerminfo.name
+	tc.nums["Co"] = tc.getnum("colors")
+	tc.strs["TN"] = tc.name
 	// WezTerm response TN=WezTerm
 
 	capName := "RGB"
-	if _, ok := cache.pTerminfo.nums[capName]; !ok {
-		if _, ok := cache.pTerminfo.bools[capName]; !ok {
-			if _, ok := cache.pTerminfo.strs[capName]; !ok {
-				cache.pTerminfo.strs[capName] = "8/8/8"
+	if _, ok := tc.nums[capName]; !ok {
+		if _, ok := tc.bools[capName]; !ok {
+			if _, ok := tc.strs[capName]; !ok {
+				tc.strs[capName] = "8/8/8"
 			}
 		}
 	}
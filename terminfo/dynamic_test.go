@@ -12,7 +12,7 @@ import (
 	"github.com/ericwq/aprilsh/util"
 )
 
-func TestUnescape(t *testing.T) {
+func TestDynamicInit(t *testing.T) {
 	defer func() {
 		Reset()
 	}()
@@ -32,26 +32,6 @@ func TestUnescape(t *testing.T) {
 	if cache.pTerminfo.getnum("cols") != expectNum {
 		t.Errorf("cols expect %d, got %d\n", expectNum, cache.pTerminfo.getnum("cols"))
 	}
-
-	tc := []struct {
-		label string
-		data  string
-		value []rune
-	}{
-		{
-			"special escape",
-			"\\0\\n\\r\\t\\b\\f\\s",
-			[]rune{'\x00', '\n', '\r', '\t', '\b', '\f', ' '},
-		},
-	}
-	for _, v := range tc {
-		t.Run(v.label, func(t *testing.T) {
-			got := unescape(v.data)
-			if got != string(v.value) {
-				t.Errorf("%s require %q, got %q\b", v.label, string(v.value), got)
-			}
-		})
-	}
 }
 
 func TestSetupterm(t *testing.T) {
@@ -145,3 +125,28 @@ func TestLookup(t *testing.T) {
 		})
 	}
 }
+
+func TestLookupTerm(t *testing.T) {
+	tc := []struct {
+		label string
+		term  string
+		name  string
+		value string
+		ok    bool
+	}{
+		{"terminal name", "xterm-256color", "TN", "xterm-256color", true},
+		{"termcap colors", "xterm-256color", "Co", "256", true},
+		{"string capability", "xterm-256color", "cup", "\x1b[%i%p1%d;%p2%dH", true},
+		{"bool capability", "xterm-256color", "am", "", true},
+		{"not supported", "xterm-256color", "setrgbf", "", false},
+		{"unknown terminal", "badTerm", "TN", "", false},
+		{"unknown terminal again", "badTerm", "Co", "", false},
+	}
+
+	for _, v := range tc {
+		value, ok := LookupTerm(v.term, v.name)
+		if value != v.value || ok != v.ok {
+			t.Errorf("%s expect %q,%t got %q,%t\n", v.label, v.value, v.ok, value, ok)
+		}
+	}
+}
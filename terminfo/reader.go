@@ -0,0 +1,343 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminfo
+
+import (
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the compiled terminfo format is described in term(5).
+// https://man7.org/linux/man-pages/man5/term.5.html
+const (
+	magicLegacy = 0o432  // 16-bit numbers
+	magicNum32  = 0o1036 // 32-bit numbers, ncurses 6.1 and later
+)
+
+// the fallback entries for the common terminals, which are used if the
+// terminfo database is not installed. the entries are compiled by ncurses 6.5.
+//
+//go:embed fallback
+var fallback embed.FS
+
+// the predefined capability names in the order of compiled terminfo, see
+// boolnames, numnames and strnames in term_variables(3X).
+var boolNames = [...]string{
+	"bw", "am", "xsb", "xhp", "xenl", "eo", "gn", "hc", "km", "hs", "in", "da",
+	"db", "mir", "msgr", "os", "eslok", "xt", "hz", "ul", "xon", "nxon", "mc5i",
+	"chts", "nrrmc", "npc", "ndscr", "ccc", "bce", "hls", "xhpa", "crxm", "daisy",
+	"xvpa", "sam", "cpix", "lpix", "OTbs", "OTns", "OTnc", "OTMT", "OTNL", "OTpt",
+	"OTxr",
+}
+
+var numNames = [...]string{
+	"cols", "it", "lines", "lm", "xmc", "pb", "vt", "wsl", "nlab", "lh", "lw",
+	"ma", "wnum", "colors", "pairs", "ncv", "bufsz", "spinv", "spinh", "maddr",
+	"mjump", "mcs", "mls", "npins", "orc", "orl", "orhi", "orvi", "cps", "widcs",
+	"btns", "bitwin", "bitype", "OTug", "OTdC", "OTdN", "OTdB", "OTdT", "OTkn",
+}
+
+var strNames = [...]string{
+	"cbt", "bel", "cr", "csr", "tbc", "clear", "el", "ed", "hpa", "cmdch", "cup",
+	"cud1", "home", "civis", "cub1", "mrcup", "cnorm", "cuf1", "ll", "cuu1",
+	"cvvis", "dch1", "dl1", "dsl", "hd", "smacs", "blink", "bold", "smcup",
+	"smdc", "dim", "smir", "invis", "prot", "rev", "smso", "smul", "ech", "rmacs",
+	"sgr0", "rmcup", "rmdc", "rmir", "rmso", "rmul", "flash", "ff", "fsl", "is1",
+	"is2", "is3", "if", "ich1", "il1", "ip", "kbs", "ktbc", "kclr", "kctab",
+	"kdch1", "kdl1", "kcud1", "krmir", "kel", "ked", "kf0", "kf1", "kf10", "kf2",
+	"kf3", "kf4", "kf5", "kf6", "kf7", "kf8", "kf9", "khome", "kich1", "kil1",
+	"kcub1", "kll", "knp", "kpp", "kcuf1", "kind", "kri", "khts", "kcuu1", "rmkx",
+	"smkx", "lf0", "lf1", "lf10", "lf2", "lf3", "lf4", "lf5", "lf6", "lf7", "lf8",
+	"lf9", "rmm", "smm", "nel", "pad", "dch", "dl", "cud", "ich", "indn", "il",
+	"cub", "cuf", "rin", "cuu", "pfkey", "pfloc", "pfx", "mc0", "mc4", "mc5",
+	"rep", "rs1", "rs2", "rs3", "rf", "rc", "vpa", "sc", "ind", "ri", "sgr",
+	"hts", "wind", "ht", "tsl", "uc", "hu", "iprog", "ka1", "ka3", "kb2", "kc1",
+	"kc3", "mc5p", "rmp", "acsc", "pln", "kcbt", "smxon", "rmxon", "smam", "rmam",
+	"xonc", "xoffc", "enacs", "smln", "rmln", "kbeg", "kcan", "kclo", "kcmd",
+	"kcpy", "kcrt", "kend", "kent", "kext", "kfnd", "khlp", "kmrk", "kmsg",
+	"kmov", "knxt", "kopn", "kopt", "kprv", "kprt", "krdo", "kref", "krfr",
+	"krpl", "krst", "kres", "ksav", "kspd", "kund", "kBEG", "kCAN", "kCMD",
+	"kCPY", "kCRT", "kDC", "kDL", "kslt", "kEND", "kEOL", "kEXT", "kFND", "kHLP",
+	"kHOM", "kIC", "kLFT", "kMSG", "kMOV", "kNXT", "kOPT", "kPRV", "kPRT", "kRDO",
+	"kRPL", "kRIT", "kRES", "kSAV", "kSPD", "kUND", "rfi", "kf11", "kf12", "kf13",
+	"kf14", "kf15", "kf16", "kf17", "kf18", "kf19", "kf20", "kf21", "kf22",
+	"kf23", "kf24", "kf25", "kf26", "kf27", "kf28", "kf29", "kf30", "kf31",
+	"kf32", "kf33", "kf34", "kf35", "kf36", "kf37", "kf38", "kf39", "kf40",
+	"kf41", "kf42", "kf43", "kf44", "kf45", "kf46", "kf47", "kf48", "kf49",
+	"kf50", "kf51", "kf52", "kf53", "kf54", "kf55", "kf56", "kf57", "kf58",
+	"kf59", "kf60", "kf61", "kf62", "kf63", "el1", "mgc", "smgl", "smgr", "fln",
+	"sclk", "dclk", "rmclk", "cwin", "wingo", "hup", "dial", "qdial", "tone",
+	"pulse", "hook", "pause", "wait", "u0", "u1", "u2", "u3", "u4", "u5", "u6",
+	"u7", "u8", "u9", "op", "oc", "initc", "initp", "scp", "setf", "setb", "cpi",
+	"lpi", "chr", "cvr", "defc", "swidm", "sdrfq", "sitm", "slm", "smicm", "snlq",
+	"snrmq", "sshm", "ssubm", "ssupm", "sum", "rwidm", "ritm", "rlm", "rmicm",
+	"rshm", "rsubm", "rsupm", "rum", "mhpa", "mcud1", "mcub1", "mcuf1", "mvpa",
+	"mcuu1", "porder", "mcud", "mcub", "mcuf", "mcuu", "scs", "smgb", "smgbp",
+	"smglp", "smgrp", "smgt", "smgtp", "sbim", "scsd", "rbim", "rcsd", "subcs",
+	"supcs", "docr", "zerom", "csnm", "kmous", "minfo", "reqmp", "getm", "setaf",
+	"setab", "pfxl", "devt", "csin", "s0ds", "s1ds", "s2ds", "s3ds", "smglr",
+	"smgtb", "birep", "binel", "bicr", "colornm", "defbi", "endbi", "setcolor",
+	"slines", "dispc", "smpch", "rmpch", "smsc", "rmsc", "pctrm", "scesc",
+	"scesa", "ehhlm", "elhlm", "elohlm", "erhlm", "ethlm", "evhlm", "sgr1",
+	"slength", "OTi2", "OTrs", "OTnl", "OTbc", "OTko", "OTma", "OTG2", "OTG3",
+	"OTG1", "OTG4", "OTGR", "OTGL", "OTGU", "OTGD", "OTGH", "OTGV", "OTGC",
+	"meml", "memu", "box1",
+}
+
+// return the terminfo directories in the search order of ncurses.
+func terminfoDirs() (dirs []string) {
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	if v := os.Getenv("TERMINFO_DIRS"); v != "" {
+		for _, dir := range strings.Split(v, ":") {
+			if dir == "" { // empty means the system directory
+				dir = "/usr/share/terminfo"
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	return append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo",
+		"/usr/lib/terminfo", "/usr/share/lib/terminfo", "/usr/local/share/terminfo",
+		"/opt/homebrew/share/terminfo")
+}
+
+// read the compiled terminfo entry from fsys. ncurses uses the first character
+// as sub directory, macOS uses the hexadecimal value of the first character.
+func readEntry(fsys fs.FS, termName string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, termName[:1]+"/"+termName)
+	if err != nil {
+		data, err = fs.ReadFile(fsys, fmt.Sprintf("%02x/%s", termName[0], termName))
+	}
+	return data, err
+}
+
+// load the terminfo entry from the terminfo database, fallback to the embedded
+// entries if it's not found.
+func loadTerminfo(termName string) (*terminfo, error) {
+	if termName == "" || strings.ContainsAny(termName, "/\\") || termName[0] == '.' {
+		return nil, fmt.Errorf("invalid terminal name: %q", termName)
+	}
+
+	for _, dir := range terminfoDirs() {
+		if data, err := readEntry(os.DirFS(dir), termName); err == nil {
+			return parseTerminfo(data)
+		}
+	}
+
+	sub, _ := fs.Sub(fallback, "fallback")
+	if data, err := readEntry(sub, termName); err == nil {
+		return parseTerminfo(data)
+	}
+	return nil, fmt.Errorf("couldn't find terminfo entry for %q", termName)
+}
+
+var errMalformed = errors.New("malformed terminfo entry")
+
+// decoder for the little endian integers and strings in compiled terminfo.
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil || n < 0 || d.pos+n > len(d.data) {
+		d.err = errMalformed
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) int16() int {
+	if b := d.bytes(2); b != nil {
+		return int(int16(binary.LittleEndian.Uint16(b)))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int {
+	if b := d.bytes(4); b != nil {
+		return int(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return 0
+}
+
+// the sections start at even offset.
+func (d *decoder) align() {
+	if d.pos%2 == 1 && d.pos < len(d.data) {
+		d.pos++
+	}
+}
+
+// read the int16 value list with count items.
+func (d *decoder) int16s(count int) []int {
+	v := make([]int, 0, max(count, 0))
+	for i := 0; i < count && d.err == nil; i++ {
+		v = append(v, d.int16())
+	}
+	return v
+}
+
+// read the number value list with count items, the size of number depends on
+// the format.
+func (d *decoder) numbers(count int, num32 bool) []int {
+	if !num32 {
+		return d.int16s(count)
+	}
+	v := make([]int, 0, max(count, 0))
+	for i := 0; i < count && d.err == nil; i++ {
+		v = append(v, d.int32())
+	}
+	return v
+}
+
+// return the NUL terminated string at offset of table.
+func cstring(table []byte, offset int) (string, bool) {
+	if offset < 0 || offset >= len(table) {
+		return "", false
+	}
+	end := offset
+	for end < len(table) && table[end] != 0 {
+		end++
+	}
+	return string(table[offset:end]), true
+}
+
+// parse the compiled terminfo entry, both the legacy and 32-bit number format
+// are supported, including the extended capabilities of ncurses.
+func parseTerminfo(data []byte) (*terminfo, error) {
+	d := &decoder{data: data}
+
+	magic := d.int16()
+	if magic != magicLegacy && magic != magicNum32 {
+		return nil, fmt.Errorf("%w: bad magic number 0%o", errMalformed, magic)
+	}
+	num32 := magic == magicNum32
+	header := d.int16s(5)
+	if d.err != nil {
+		return nil, d.err
+	}
+	nameSize, boolCount, numCount, strCount, tableSize := header[0], header[1], header[2], header[3], header[4]
+
+	tc := &terminfo{
+		bools: make(map[string]bool),
+		nums:  make(map[string]int),
+		strs:  make(map[string]string),
+	}
+
+	names, _ := cstring(d.bytes(nameSize), 0)
+	tc.setNames(names)
+
+	bools := d.bytes(boolCount)
+	d.align()
+	nums := d.numbers(numCount, num32)
+	offsets := d.int16s(strCount)
+	table := d.bytes(tableSize)
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	for i, v := range bools {
+		if i < len(boolNames) && v == 1 {
+			tc.bools[boolNames[i]] = true
+		}
+	}
+	for i, v := range nums {
+		if i < len(numNames) && v >= 0 {
+			tc.nums[numNames[i]] = v
+		}
+	}
+	for i, offset := range offsets {
+		if s, ok := cstring(table, offset); i < len(strNames) && ok {
+			tc.strs[strNames[i]] = s
+		}
+	}
+
+	// the extended capabilities are optional
+	d.align()
+	if d.pos < len(data) {
+		if err := tc.parseExtended(d, num32); err != nil {
+			return nil, err
+		}
+	}
+	return tc, nil
+}
+
+// parse the extended capabilities section, which is appended by ncurses for
+// the user defined capabilities, see user_caps(5).
+func (tc *terminfo) parseExtended(d *decoder, num32 bool) error {
+	header := d.int16s(5)
+	if d.err != nil {
+		return d.err
+	}
+	boolCount, numCount, strCount, tableSize := header[0], header[1], header[2], header[4]
+
+	bools := d.bytes(boolCount)
+	d.align()
+	nums := d.numbers(numCount, num32)
+	offsets := d.int16s(strCount)
+	nameOffsets := d.int16s(boolCount + numCount + strCount)
+	table := d.bytes(tableSize)
+	if d.err != nil {
+		return d.err
+	}
+
+	// the names follow the last string value in table
+	nameBase := 0
+	for _, offset := range offsets {
+		if s, ok := cstring(table, offset); ok {
+			nameBase = max(nameBase, offset+len(s)+1)
+		}
+	}
+	names := make([]string, len(nameOffsets))
+	for i, offset := range nameOffsets {
+		var ok bool
+		if names[i], ok = cstring(table, nameBase+offset); !ok {
+			return errMalformed
+		}
+	}
+
+	for i, v := range bools {
+		if v == 1 {
+			tc.bools[names[i]] = true
+		}
+	}
+	for i, v := range nums {
+		if v >= 0 {
+			tc.nums[names[boolCount+i]] = v
+		}
+	}
+	for i, offset := range offsets {
+		if s, ok := cstring(table, offset); ok {
+			tc.strs[names[boolCount+numCount+i]] = s
+		}
+	}
+	return nil
+}
+
+// set the name, aliases and description from the names section:
+// "<name>|<alias>|...|<desc>".
+func (tc *terminfo) setNames(header string) {
+	names := strings.Split(header, "|")
+	tc.name = names[0]
+	names = names[1:]
+	if len(names) > 0 {
+		tc.desc = names[len(names)-1]
+		names = names[:len(names)-1]
+	}
+	tc.aliases = names
+}
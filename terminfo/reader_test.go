@@ -0,0 +1,200 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// build the compiled terminfo entry, the extended capabilities section is
+// appended if ext is true.
+func buildEntry(num32 bool, ext bool) []byte {
+	var b []byte
+	put16 := func(v int) { b = binary.LittleEndian.AppendUint16(b, uint16(int16(v))) }
+	putNum := func(v int) {
+		if num32 {
+			b = binary.LittleEndian.AppendUint32(b, uint32(int32(v)))
+		} else {
+			put16(v)
+		}
+	}
+	align := func() {
+		if len(b)%2 == 1 {
+			b = append(b, 0)
+		}
+	}
+
+	magic := magicLegacy
+	if num32 {
+		magic = magicNum32
+	}
+	names := "test|alias|test terminal\x00"
+	table := "\x07\x00\r\x00"
+
+	// bools: bw=absent, am=true; nums: cols=80, it=absent, lines=24
+	// strs: cbt=absent, bel, cr
+	put16(magic)
+	put16(len(names))
+	put16(2)
+	put16(3)
+	put16(3)
+	put16(len(table))
+	b = append(b, names...)
+	b = append(b, 0, 1)
+	align()
+	putNum(80)
+	putNum(-1)
+	putNum(24)
+	put16(-1)
+	put16(0)
+	put16(2)
+	b = append(b, table...)
+
+	if ext {
+		// bools: XT; nums: U8=1; strs: Ss, Se
+		values := "\x1b[%p1%d q\x00\x1b[2 q\x00"
+		extNames := "XT\x00U8\x00Ss\x00Se\x00"
+		align()
+		put16(1)
+		put16(1)
+		put16(2)
+		put16(6)
+		put16(len(values) + len(extNames))
+		b = append(b, 1)
+		align()
+		putNum(1)
+		put16(0)
+		put16(10)
+		put16(0)
+		put16(3)
+		put16(6)
+		put16(9)
+		b = append(b, values...)
+		b = append(b, extNames...)
+	}
+	return b
+}
+
+func TestParseTerminfo(t *testing.T) {
+	tc := []struct {
+		label string
+		num32 bool
+		ext   bool
+	}{
+		{"legacy format", false, false},
+		{"32-bit number format", true, false},
+		{"legacy extended format", false, true},
+		{"32-bit number extended format", true, true},
+	}
+
+	for _, v := range tc {
+		tc, err := parseTerminfo(buildEntry(v.num32, v.ext))
+		if err != nil {
+			t.Errorf("%s expect no error, got %s\n", v.label, err)
+			continue
+		}
+
+		if tc.name != "test" || tc.desc != "test terminal" || len(tc.aliases) != 1 || tc.aliases[0] != "alias" {
+			t.Errorf("%s names got %q %q %q\n", v.label, tc.name, tc.aliases, tc.desc)
+		}
+		if !tc.getflag("am") || tc.getflag("bw") {
+			t.Errorf("%s bool expect am, got %v\n", v.label, tc.bools)
+		}
+		if _, ok := tc.nums["it"]; ok || tc.getnum("cols") != 80 || tc.getnum("lines") != 24 {
+			t.Errorf("%s number expect cols#80 lines#24, got %v\n", v.label, tc.nums)
+		}
+		if _, ok := tc.strs["cbt"]; ok || tc.getstr("bel") != "\x07" || tc.getstr("cr") != "\r" {
+			t.Errorf("%s string expect bel and cr, got %q\n", v.label, tc.strs)
+		}
+
+		if v.ext {
+			if !tc.getflag("XT") || tc.getnum("U8") != 1 ||
+				tc.getstr("Ss") != "\x1b[%p1%d q" || tc.getstr("Se") != "\x1b[2 q" {
+				t.Errorf("%s extended capability got %v %v %q\n", v.label, tc.bools, tc.nums, tc.strs)
+			}
+		}
+	}
+}
+
+func TestParseTerminfo_Malformed(t *testing.T) {
+	entry := buildEntry(true, true)
+	bad := append([]byte{}, entry...)
+	bad[0] = 0
+
+	tc := []struct {
+		label string
+		data  []byte
+	}{
+		{"empty entry", nil},
+		{"bad magic", bad},
+		{"truncated header", entry[:6]},
+		{"truncated string table", entry[:50]},
+		{"truncated extended section", entry[:len(entry)-4]},
+	}
+
+	for _, v := range tc {
+		if _, err := parseTerminfo(v.data); !errors.Is(err, errMalformed) {
+			t.Errorf("%s expect malformed error, got %v\n", v.label, err)
+		}
+	}
+}
+
+func TestLoadTerminfo(t *testing.T) {
+	// the entry in $TERMINFO with hexadecimal sub directory
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "74"), 0o755)
+	os.WriteFile(filepath.Join(dir, "74", "test"), buildEntry(true, false), 0o644)
+	t.Setenv("TERMINFO", dir)
+
+	tc, err := loadTerminfo("test")
+	if err != nil || tc.getnum("cols") != 80 {
+		t.Errorf("TERMINFO expect cols#80, got %v %v\n", tc, err)
+	}
+
+	// the entry in $TERMINFO_DIRS with first character sub directory
+	t.Setenv("TERMINFO", "")
+	dir2 := t.TempDir()
+	os.Mkdir(filepath.Join(dir2, "t"), 0o755)
+	os.WriteFile(filepath.Join(dir2, "t", "test2"), buildEntry(false, true), 0o644)
+	t.Setenv("TERMINFO_DIRS", dir+"::"+dir2)
+
+	tc, err = loadTerminfo("test2")
+	if err != nil || !tc.getflag("XT") {
+		t.Errorf("TERMINFO_DIRS expect XT, got %v %v\n", tc, err)
+	}
+
+	// the common terminals are always available
+	for _, name := range []string{"xterm-256color", "tmux-256color", "alacritty"} {
+		tc, err = loadTerminfo(name)
+		if err != nil || tc.name != name || tc.getnum("colors") != 256 || tc.getstr("cup") == "" {
+			t.Errorf("%s expect colors#256 and cup, got %v\n", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "../test", "t/test", ".test", "not-exist-term"} {
+		if _, err = loadTerminfo(name); err == nil {
+			t.Errorf("%q expect error, got nil\n", name)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	sub, _ := fs.Sub(fallback, "fallback")
+	for _, name := range []string{"xterm", "xterm-256color", "tmux-256color", "screen-256color", "alacritty"} {
+		data, err := readEntry(sub, name)
+		if err != nil {
+			t.Errorf("%s expect embedded entry, got %s\n", name, err)
+			continue
+		}
+		if tc, err := parseTerminfo(data); err != nil || tc.name != name {
+			t.Errorf("%s expect parsed entry, got %v\n", name, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// the position of predefined capability names in compiled terminfo.
+var boolIndex, numIndex, strIndex = nameIndex(boolNames[:]), nameIndex(numNames[:]), nameIndex(strNames[:])
+
+func nameIndex(names []string) map[string]int {
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+	return index
+}
+
+// encoder for the little endian integers and strings in compiled terminfo.
+type encoder struct {
+	bytes.Buffer
+	num32 bool
+}
+
+func (e *encoder) int16(v int) {
+	binary.Write(e, binary.LittleEndian, int16(v))
+}
+
+func (e *encoder) number(v int) {
+	if e.num32 {
+		binary.Write(e, binary.LittleEndian, int32(v))
+	} else {
+		e.int16(v)
+	}
+}
+
+// the sections start at even offset.
+func (e *encoder) align() {
+	if e.Len()%2 == 1 {
+		e.WriteByte(0)
+	}
+}
+
+// append s to table, return the offset of s.
+func appendString(table *[]byte, s string) int {
+	offset := len(*table)
+	*table = append(append(*table, s...), 0)
+	return offset
+}
+
+// Compile returns the compiled terminfo entry described by the names section
+// "<name>|<alias>|...|<desc>" and the capabilities. the capabilities which are
+// not predefined are written as extended capabilities. the legacy format is
+// used unless a number exceeds 16-bit, see term(5).
+func Compile(names string, bools map[string]bool, nums map[string]int, strs map[string]string) []byte {
+	e := &encoder{}
+	for _, v := range nums {
+		if v > math.MaxInt16 {
+			e.num32 = true
+		}
+	}
+
+	// split the predefined and extended capabilities
+	boolValues, numValues, strValues := []byte{}, []int{}, []int{}
+	var extBools, extNums, extStrs []string
+	var table []byte
+	for name, v := range bools {
+		if i, ok := boolIndex[name]; !ok {
+			extBools = append(extBools, name)
+		} else if v {
+			boolValues = append(boolValues, make([]byte, max(i+1-len(boolValues), 0))...)
+			boolValues[i] = 1
+		}
+	}
+	for name, v := range nums {
+		if i, ok := numIndex[name]; !ok {
+			extNums = append(extNums, name)
+		} else {
+			for len(numValues) <= i {
+				numValues = append(numValues, -1)
+			}
+			numValues[i] = v
+		}
+	}
+	// sort the string capabilities to get a stable string table
+	for _, name := range sortedKeys(strs) {
+		if i, ok := strIndex[name]; !ok {
+			extStrs = append(extStrs, name)
+		} else {
+			for len(strValues) <= i {
+				strValues = append(strValues, -1)
+			}
+			strValues[i] = appendString(&table, strs[name])
+		}
+	}
+
+	magic := magicLegacy
+	if e.num32 {
+		magic = magicNum32
+	}
+	e.int16(magic)
+	e.int16(len(names) + 1)
+	e.int16(len(boolValues))
+	e.int16(len(numValues))
+	e.int16(len(strValues))
+	e.int16(len(table))
+	e.WriteString(names)
+	e.WriteByte(0)
+	e.Write(boolValues)
+	e.align()
+	for _, v := range numValues {
+		e.number(v)
+	}
+	for _, v := range strValues {
+		e.int16(v)
+	}
+	e.Write(table)
+
+	if len(extBools)+len(extNums)+len(extStrs) == 0 {
+		return e.Bytes()
+	}
+
+	// the extended names are sorted as ncurses does
+	slices.Sort(extBools)
+	slices.Sort(extNums)
+	slices.Sort(extStrs)
+	table = table[:0]
+	var offsets, nameOffsets []int
+	for _, name := range extStrs {
+		offsets = append(offsets, appendString(&table, strs[name]))
+	}
+	var nameTable []byte
+	extNames := make([]string, 0, len(extBools)+len(extNums)+len(extStrs))
+	extNames = append(append(append(extNames, extBools...), extNums...), extStrs...)
+	for _, name := range extNames {
+		nameOffsets = append(nameOffsets, appendString(&nameTable, name))
+	}
+	table = append(table, nameTable...)
+
+	e.align()
+	e.int16(len(extBools))
+	e.int16(len(extNums))
+	e.int16(len(extStrs))
+	e.int16(len(offsets) + len(nameOffsets))
+	e.int16(len(table))
+	for _, name := range extBools {
+		if bools[name] {
+			e.WriteByte(1)
+		} else {
+			e.WriteByte(0)
+		}
+	}
+	e.align()
+	for _, name := range extNums {
+		e.number(nums[name])
+	}
+	for _, v := range append(offsets, nameOffsets...) {
+		e.int16(v)
+	}
+	e.Write(table)
+
+	return e.Bytes()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Install writes the compiled terminfo entry data of termName into the
+// terminfo directory dir, such as ~/.terminfo. the entry is kept if it's the
+// same, otherwise it's replaced atomically. return the path of entry.
+func Install(dir string, termName string, data []byte) (string, error) {
+	if termName == "" || filepath.Base(termName) != termName || termName[0] == '.' {
+		return "", fmt.Errorf("invalid terminal name: %q", termName)
+	}
+
+	// macOS uses the hexadecimal sub directory for the case-insensitive file system
+	sub := termName[:1]
+	if runtime.GOOS == "darwin" {
+		sub = fmt.Sprintf("%02x", termName[0])
+	}
+	path := filepath.Join(dir, sub, termName)
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+termName+"-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package terminfo
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tc := []struct {
+		label string
+		bools map[string]bool
+		nums  map[string]int
+		strs  map[string]string
+		magic int
+	}{
+		{
+			"predefined capability", map[string]bool{"am": true, "xenl": true},
+			map[string]int{"cols": 80, "colors": 256}, map[string]string{"bel": "\x07", "cup": "\x1B[%i%p1%d;%p2%dH"},
+			magicLegacy,
+		},
+		{
+			"extended capability", map[string]bool{"am": true, "XT": true, "AX": true},
+			map[string]int{"cols": 80, "U8": 1}, map[string]string{"cr": "\r", "Ss": "\x1B[%p1%d q", "Se": "\x1B[2 q"},
+			magicLegacy,
+		},
+		{
+			"32-bit number", map[string]bool{"RGB": true},
+			map[string]int{"colors": 0x1000000, "pairs": 0x10000}, map[string]string{"BE": "\x1B[?2004h"},
+			magicNum32,
+		},
+	}
+
+	for _, v := range tc {
+		data := Compile("test|test terminal", v.bools, v.nums, v.strs)
+		if magic := int(data[0]) | int(data[1])<<8; magic != v.magic {
+			t.Errorf("%s expect magic 0%o, got 0%o\n", v.label, v.magic, magic)
+		}
+
+		tc, err := parseTerminfo(data)
+		if err != nil {
+			t.Errorf("%s expect no error, got %s\n", v.label, err)
+			continue
+		}
+		if tc.name != "test" || tc.desc != "test terminal" {
+			t.Errorf("%s expect name test, got %q %q\n", v.label, tc.name, tc.desc)
+		}
+		if !maps.Equal(tc.bools, v.bools) || !maps.Equal(tc.nums, v.nums) || !maps.Equal(tc.strs, v.strs) {
+			t.Errorf("%s got %v %v %q\n", v.label, tc.bools, tc.nums, tc.strs)
+		}
+
+		// the output is stable
+		if again := Compile("test|test terminal", v.bools, v.nums, v.strs); !bytes.Equal(again, data) {
+			t.Errorf("%s expect the same output\n", v.label)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".terminfo")
+	data := Compile("test|test terminal", map[string]bool{"am": true}, nil, nil)
+
+	path, err := Install(dir, "test", data)
+	if err != nil {
+		t.Fatalf("install expect no error, got %s\n", err)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, data) {
+		t.Errorf("install expect the entry in %s\n", path)
+	}
+
+	// the installed entry can be loaded
+	t.Setenv("TERMINFO", dir)
+	if tc, err := loadTerminfo("test"); err != nil || !tc.getflag("am") {
+		t.Errorf("load expect am, got %v\n", err)
+	}
+
+	// the entry is replaced if it's changed
+	data = Compile("test|test terminal", map[string]bool{"bce": true}, nil, nil)
+	if _, err = Install(dir, "test", data); err != nil {
+		t.Errorf("replace expect no error, got %s\n", err)
+	}
+	got, _ = os.ReadFile(path)
+	if !bytes.Equal(got, data) {
+		t.Errorf("replace expect the new entry in %s\n", path)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("replace expect no temporary file, got %d files\n", len(entries))
+	}
+
+	for _, name := range []string{"", "../test", "t/test", ".test"} {
+		if _, err = Install(dir, name, data); err == nil {
+			t.Errorf("%q expect error, got nil\n", name)
+		}
+	}
+}
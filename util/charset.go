@@ -0,0 +1,99 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// the codeset names reported by nl_langinfo which are unknown to IANA and WHATWG.
+var charsetAlias = map[string]string{
+	"646":   "US-ASCII", // Solaris
+	"eucJP": "EUC-JP",
+	"eucKR": "EUC-KR",
+	"eucCN": "GB2312",
+	"eucTW": "x-euc-tw",
+}
+
+// LookupCharset returns the encoding of charset name, such as GB18030, Big5 or
+// ISO-8859-1. the name is looked up in IANA registry first, then in WHATWG
+// encoding standard.
+func LookupCharset(name string) (encoding.Encoding, error) {
+	if alias, ok := charsetAlias[name]; ok {
+		name = alias
+	}
+
+	if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	if enc, err := htmlindex.Get(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", name)
+}
+
+// IsUtf8Charset returns true if enc is UTF-8 or nil.
+func IsUtf8Charset(enc encoding.Encoding) bool {
+	return enc == nil || enc == unicode.UTF8
+}
+
+// IsAsciiCharset returns true if enc is US-ASCII, which is the charset of C/POSIX
+// locale.
+func IsAsciiCharset(enc encoding.Encoding) bool {
+	name, err := ianaindex.IANA.Name(enc)
+	return err == nil && name == "US-ASCII"
+}
+
+// NewCharsetReader returns a reader which converts the text of r from enc to UTF-8.
+// the invalid bytes are replaced with U+FFFD.
+func NewCharsetReader(r io.Reader, enc encoding.Encoding) io.Reader {
+	if IsUtf8Charset(enc) {
+		return r
+	}
+	return transform.NewReader(r, enc.NewDecoder())
+}
+
+// NewCharsetWriter returns a writer which converts the UTF-8 text to enc before
+// writing to w. the characters unsupported by enc are replaced with the
+// encoding-specific replacement, usually '?' or SUB.
+func NewCharsetWriter(w io.Writer, enc encoding.Encoding) *CharsetWriter {
+	return &CharsetWriter{w: w, t: encoding.ReplaceUnsupported(enc.NewEncoder())}
+}
+
+// CharsetWriter encodes each write as a whole, an incomplete UTF-8 sequence at
+// the tail is kept until the next write.
+type CharsetWriter struct {
+	w       io.Writer
+	t       transform.Transformer
+	pending []byte
+}
+
+func (cw *CharsetWriter) Write(p []byte) (int, error) {
+	src := append(cw.pending, p...)
+	dst := make([]byte, len(src)*4+16)
+
+	nDst, nSrc, err := cw.t.Transform(dst, src, false)
+	if err != nil && !errors.Is(err, transform.ErrShortSrc) {
+		return 0, err
+	}
+	cw.pending = append([]byte(nil), src[nSrc:]...)
+
+	if _, err = cw.w.Write(dst[:nDst]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (cw *CharsetWriter) WriteString(s string) (int, error) {
+	return cw.Write([]byte(s))
+}
@@ -0,0 +1,87 @@
+// Copyright 2022~2024 wangqi. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLookupCharset(t *testing.T) {
+	tc := []struct {
+		label   string
+		charset string
+		utf8    bool
+		ascii   bool
+		err     bool
+	}{
+		{"utf-8", "UTF-8", true, false, false},
+		{"gb18030", "GB18030", false, false, false},
+		{"big5", "BIG5", false, false, false},
+		{"iso-8859-1", "ISO-8859-1", false, false, false},
+		{"glibc ascii", "ANSI_X3.4-1968", false, true, false},
+		{"whatwg name", "ISO8859-15", false, false, false},
+		{"solaris ascii", "646", false, true, false},
+		{"solaris euc", "eucJP", false, false, false},
+		{"unknown", "UNKNOWN-1", false, false, true},
+	}
+
+	for _, v := range tc {
+		enc, err := LookupCharset(v.charset)
+		if (err != nil) != v.err {
+			t.Errorf("%s expect error %t, got %v\n", v.label, v.err, err)
+			continue
+		}
+		if err == nil && IsUtf8Charset(enc) != v.utf8 {
+			t.Errorf("%s expect utf-8 %t, got %t\n", v.label, v.utf8, IsUtf8Charset(enc))
+		}
+		if err == nil && IsAsciiCharset(enc) != v.ascii {
+			t.Errorf("%s expect ascii %t, got %t\n", v.label, v.ascii, IsAsciiCharset(enc))
+		}
+	}
+}
+
+func TestCharsetReadWrite(t *testing.T) {
+	tc := []struct {
+		label   string
+		charset string
+		text    string
+		legacy  string
+	}{
+		{"gb18030", "GB18030", "中文 abc", "\xd6\xd0\xce\xc4 abc"},
+		{"big5", "Big5", "中文", "\xa4\xa4\xa4\xe5"},
+		{"latin1", "ISO-8859-1", "café", "caf\xe9"},
+		{"unsupported character", "ISO-8859-1", "中", "\x1a"},
+	}
+
+	for _, v := range tc {
+		enc, _ := LookupCharset(v.charset)
+
+		// write the UTF-8 text byte by byte, the incomplete sequence is kept
+		var b strings.Builder
+		w := NewCharsetWriter(&b, enc)
+		for i := 0; i < len(v.text); i++ {
+			w.Write([]byte{v.text[i]})
+		}
+		if b.String() != v.legacy {
+			t.Errorf("%s write expect %q, got %q\n", v.label, v.legacy, b.String())
+		}
+
+		if v.label == "unsupported character" {
+			continue
+		}
+		got, _ := io.ReadAll(NewCharsetReader(strings.NewReader(v.legacy), enc))
+		if string(got) != v.text {
+			t.Errorf("%s read expect %q, got %q\n", v.label, v.text, got)
+		}
+	}
+
+	// utf-8 reader is not wrapped
+	r := strings.NewReader("abc")
+	if NewCharsetReader(r, nil) != io.Reader(r) {
+		t.Errorf("utf-8 reader expect the same reader\n")
+	}
+}